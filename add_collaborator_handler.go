@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -24,34 +25,57 @@ import (
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
 	bw "github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
 )
 
 type AddCollaboratorHandler struct {
 	hostname      string
 	db            *sql.DB
 	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
 }
 
 func (h *AddCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	host := req.URL.Query().Get(":host")
 	username := req.URL.Query().Get(":username")
 	repo := req.URL.Query().Get(":repo")
 
-	if username == "" || repo == "" {
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
+
+	if validateRepositoryName(h.Providers, fullName) != nil {
 		http.Error(w, "Incorrect full name", http.StatusBadRequest)
 		return
 	}
 
-	fullName := fmt.Sprintf("%s/%s", username, repo)
-
 	var account bw.Account
 
 	if err := json.NewDecoder(req.Body).Decode(&account); err != nil {
+		if _, ok := err.(*bw.UnknownPermissionError); ok {
+			http.Error(w, "Unknown permission", http.StatusUnprocessableEntity)
+			return
+		}
+
 		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.AddCollaborator(fullName, &account); err != nil {
+	if validateLogin(h.Providers, fullName, account.Login) != nil {
+		http.Error(w, "Incorrect collaborator name", http.StatusBadRequest)
+		return
+	}
+
+	if account.Affiliation != "" && !bw.IsValidAffiliation(account.Affiliation) {
+		http.Error(w, "Incorrect affiliation", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	if err := h.AddCollaborator(ctx, fullName, &account); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		log.Printf("%s\t%s\t%v\t%s", "POST", req.RequestURI, http.StatusInternalServerError, err)
 		return
@@ -60,24 +84,27 @@ func (h *AddCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Requ
 	w.WriteHeader(http.StatusCreated)
 }
 
-func (h *AddCollaboratorHandler) AddCollaborator(fullName string, account *bw.Account) (err error) {
-	tx, err := h.db.Begin()
+func (h *AddCollaboratorHandler) AddCollaborator(ctx context.Context, fullName string, account *bw.Account) (err error) {
+	tx, err := h.db.BeginTx(ctx, nil)
 
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback()
+	defer bw.Rollback(h.collaboration, tx)
 
-	_, err = h.collaboration.AddAccount(tx, fullName, account)
+	// unknown repositories are created the same way gRPC API and import do
+	if err := h.collaboration.CreateRepository(ctx, tx, fullName); err != nil {
+		return err
+	}
+
+	_, err = h.collaboration.AddAccount(ctx, tx, fullName, account)
 
 	if err != nil {
 		return err
 	}
 
-	tx.Commit()
-
-	return nil
+	return bw.Commit(h.collaboration, tx)
 }
 
 func NewAddCollaboratorHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration) *AddCollaboratorHandler {
@@ -2,6 +2,7 @@ package main_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -39,7 +40,7 @@ func TestAddCollaboratorHandler(t *testing.T) {
 	for _, result := range results {
 		db, teardown := setupTestDBConn()
 
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name))
@@ -61,6 +62,124 @@ func TestAddCollaboratorHandler(t *testing.T) {
 	}
 }
 
+func TestAddCollaboratorHandler_UnknownPermission(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	results := []struct {
+		RequestBody  string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": true, "pusj": true}}`,
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: "Unknown permission\n",
+		},
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": "yes"}}`,
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Unable to decode request body\n",
+		},
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": true, "push": true}}`,
+			ResponseCode: http.StatusCreated,
+			ResponseBody: "",
+		},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("POST", "/collaborators?:username=blamewarrior&:repo=test_permissions", bytes.NewBufferString(result.RequestBody))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewAddCollaboratorHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.RequestBody)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body), result.RequestBody)
+	}
+}
+
+func TestAddCollaboratorHandler_IncorrectNames(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	err := collaboration.CreateRepository(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+
+	results := []struct {
+		Owner, Name  string
+		RequestBody  string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			Owner:        "-blamewarrior",
+			Name:         "hooks",
+			RequestBody:  `{"uid": 1345, "login": "octocat"}`,
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "hooks%20and%20more",
+			RequestBody:  `{"uid": 1345, "login": "octocat"}`,
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "hooks",
+			RequestBody:  `{"uid": 1345, "login": "octo_cat"}`,
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect collaborator name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "hooks",
+			RequestBody:  `{"uid": 1345, "login": "octocat"}`,
+			ResponseCode: http.StatusCreated,
+			ResponseBody: "",
+		},
+		{
+			Owner:        "BlameWarrior",
+			Name:         "Hooks",
+			RequestBody:  `{"uid": 1346, "login": "Hubot"}`,
+			ResponseCode: http.StatusCreated,
+			ResponseBody: "",
+		},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("POST", "/collaborators?:username="+result.Owner+"&:repo="+result.Name, bytes.NewBufferString(result.RequestBody))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewAddCollaboratorHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.RequestBody)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body), result.RequestBody)
+	}
+
+	repositories, err := collaboration.ListRepositories(context.Background(), db, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blamewarrior/hooks"}, repositories)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "Hubot", accounts[0].Login)
+	assert.Equal(t, "octocat", accounts[1].Login)
+}
+
 const (
 	addCollaboratorRequestBody = `
 		{
@@ -73,3 +192,27 @@ const (
 
 	`
 )
+
+func TestAddCollaboratorHandler_UnknownRepository(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	req, err := http.NewRequest("POST", "/collaborators?:username=blamewarrior&:repo=repos", bytes.NewBufferString(`{"uid": 1345, "login": "octocat"}`))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	main.NewAddCollaboratorHandler("blamewarrior.com", db, collaboration).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	repositories, err := collaboration.ListRepositories(context.Background(), db, "blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blamewarrior/repos"}, repositories)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "octocat", accounts[0].Login)
+}
@@ -0,0 +1,37 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package backoff computes delays between attempts to repeat failed requests.
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Delay returns a random delay between a half and a whole of exponentially
+// growing backoff for given attempt, so that retries of concurrent requests
+// are spread in time.
+func Delay(base time.Duration, attempt int) time.Duration {
+	backoff := base << uint(attempt)
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,44 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package backoff_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/backoff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	for attempt := 0; attempt < 5; attempt++ {
+		t.Run(fmt.Sprintf("attempt: %d", attempt), func(t *testing.T) {
+			max := 100 * time.Millisecond << uint(attempt)
+
+			for i := 0; i < 100; i++ {
+				delay := backoff.Delay(100*time.Millisecond, attempt)
+				assert.True(t, delay >= max/2 && delay <= max, "%s", delay)
+			}
+		})
+	}
+
+	assert.Equal(t, time.Duration(0), backoff.Delay(0, 1))
+	assert.Equal(t, time.Duration(0), backoff.Delay(time.Second, 64))
+}
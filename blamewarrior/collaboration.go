@@ -19,64 +19,214 @@
 package blamewarrior
 
 import (
+	"context"
 	"database/sql"
-	"database/sql/driver"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"time"
 )
 
-type AccountPermissions map[string]bool
-
-func (perms AccountPermissions) Value() (driver.Value, error) {
-	b, err := json.Marshal(perms)
-	return b, err
-}
-
-func (perms *AccountPermissions) Scan(src interface{}) error {
-	source, ok := src.([]byte)
-	if !ok {
-		return errors.New("Type assertion .([]byte) failed.")
-	}
+// Collaborator affiliations describe how an account has been granted access to a repository.
+const (
+	// AffiliationDirect is a collaborator that has been added to the repository directly.
+	AffiliationDirect = "direct"
+	// AffiliationOutside is a collaborator that is not a member of the organization owning the repository.
+	AffiliationOutside = "outside"
+	// AffiliationMember is an organization member that inherits access through the organization or its teams.
+	AffiliationMember = "member"
+)
 
-	err := json.Unmarshal(source, &perms)
-	if err != nil {
-		return err
+// IsValidAffiliation checks whether s is one of known collaborator affiliations.
+func IsValidAffiliation(s string) bool {
+	switch s {
+	case AffiliationDirect, AffiliationOutside, AffiliationMember:
+		return true
 	}
 
-	return nil
+	return false
 }
 
 // Account represents GitHub user account stored in BlameWarrior database.
 type Account struct {
-	Id          int                `json:"-"`
-	Uid         int                `json:"uid"`
-	Login       string             `json:"login"`
+	Id    int    `json:"-"`
+	Uid   int    `json:"uid"`
+	Login string `json:"login"`
+	// Permissions are granted to the account in a particular repository, so the same
+	// account may have different permissions in each of them.
 	Permissions AccountPermissions `json:"permissions"`
+	// Affiliation is one of Affiliation* constants. Accounts added without
+	// affiliation are considered direct collaborators.
+	Affiliation string `json:"affiliation,omitempty"`
+	// Teams lists slugs of repository teams the account is a member of. Accounts
+	// without teams have been granted access to the repository directly.
+	Teams []string `json:"teams,omitempty"`
+
+	AccountProfile
+}
+
+// AccountProfile is the public GitHub profile of an account. Profiles are refreshed
+// by repository syncs, so it's empty for accounts that have not been synced yet.
+type AccountProfile struct {
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	// Type is either "User" or "Bot".
+	Type      string `json:"type,omitempty"`
+	SiteAdmin bool   `json:"site_admin,omitempty"`
 }
 
 type Collaboration interface {
-	CreateRepository(sqlRunner SQLRunner, repositoryFullName string) error
-	ListAccounts(sqlRunner SQLRunner, repositoryFullName string) ([]Account, error)
-	AddAccount(tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error)
-	EditAccount(sqlRunner SQLRunner, repositoryFullName string, account *Account) error
-	DisconnectAccount(sqlRunner SQLRunner, repositoryFullName, login string) error
+	CreateRepository(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) error
+	SetRepositoryProvider(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, provider string) error
+	LockRepository(ctx context.Context, db *sql.DB, repositoryFullName string, wait bool) (unlock func(), err error)
+	ResetRepository(ctx context.Context, tx *sql.Tx, repositoryFullName string) error
+	ListRepositories(ctx context.Context, sqlRunner SQLRunner, owner string) ([]string, error)
+	ListAccounts(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Account, error)
+	AddAccount(ctx context.Context, tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error)
+	EditAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, account *Account) error
+	DisconnectAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, login string) error
+	UpdateProfile(ctx context.Context, sqlRunner SQLRunner, login string, profile *AccountProfile, updatedAt time.Time) error
+	ListOutdatedProfiles(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, updatedBefore time.Time, limit int) ([]string, error)
+	AddTeam(ctx context.Context, tx *sql.Tx, repositoryFullName string, team *Team) (*Team, error)
+	AddTeamMember(ctx context.Context, tx *sql.Tx, repositoryFullName, teamSlug string, account *Account) error
+	ListTeams(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Team, error)
+	ListTeamMembers(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, teamSlug string) ([]Account, error)
+	AddInvitation(ctx context.Context, tx *sql.Tx, repositoryFullName string, invitation *Invitation) (*Invitation, error)
+	ListInvitations(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Invitation, error)
 }
 
-type CollaborationService struct{}
+// CollaborationService is an SQL implementation of Collaboration. The zero value
+// works with PostgreSQL.
+type CollaborationService struct {
+	dialect *Dialect
+	locks   repositoryLocks
+}
 
 func NewCollaborationService() *CollaborationService {
-	return new(CollaborationService)
+	return NewDialectCollaborationService(PostgresDialect)
 }
 
-func (service *CollaborationService) CreateRepository(sqlRunner SQLRunner, repositoryFullName string) error {
-	_, err := sqlRunner.Exec(CreateRepositoryQuery, repositoryFullName)
+// NewDialectCollaborationService returns a CollaborationService that uses queries
+// of given SQL dialect.
+func NewDialectCollaborationService(dialect *Dialect) *CollaborationService {
+	return &CollaborationService{dialect: dialect}
+}
+
+func (service *CollaborationService) queries() *Dialect {
+	if service.dialect == nil {
+		return PostgresDialect
+	}
+
+	return service.dialect
+}
+
+func (service *CollaborationService) CreateRepository(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) error {
+	_, err := sqlRunner.ExecContext(ctx, service.queries().CreateRepositoryQuery, repositoryFullName)
 	return err
 }
 
-func (service *CollaborationService) ListAccounts(sqlRunner SQLRunner, repositoryFullName string) ([]Account, error) {
+// SetRepositoryProvider records the provider a repository is synchronized with. It returns
+// ErrProviderMismatch if the repository has been synchronized with another provider before.
+func (service *CollaborationService) SetRepositoryProvider(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, provider string) error {
+	res, err := sqlRunner.ExecContext(ctx, service.queries().SetRepositoryProviderQuery, repositoryFullName, provider)
+	if err != nil {
+		return fmt.Errorf("failed to set repository provider: %s", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrProviderMismatch
+	}
+
+	return nil
+}
+
+// LockRepository takes an exclusive lock of a repository that is held until unlock is called, so
+// that concurrent syncs do not interfere. Unless wait is true it fails with ErrRepositoryLocked if
+// the lock is already held. PostgreSQL databases are locked with transaction-level advisory locks
+// shared by all instances and released by the server if the connection is lost, databases that
+// have no such locks are locked within the process.
+func (service *CollaborationService) LockRepository(ctx context.Context, db *sql.DB, repositoryFullName string, wait bool) (func(), error) {
+	queries := service.queries()
+	if queries.TryLockRepositoryQuery == "" {
+		return service.locks.lock(ctx, repositoryFullName, wait)
+	}
+
+	// the lock is held by a transaction of its own, so that it does not depend
+	// on the outcome of the one the sync writes in
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key := repositoryLockKey(repositoryFullName)
+
+	if wait {
+		_, err = tx.ExecContext(ctx, queries.LockRepositoryQuery, key)
+	} else {
+		var locked bool
+		if err = tx.QueryRowContext(ctx, queries.TryLockRepositoryQuery, key).Scan(&locked); err == nil && !locked {
+			err = ErrRepositoryLocked
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == ErrRepositoryLocked {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to lock repository: %s", err)
+	}
+
+	return func() { tx.Rollback() }, nil
+}
+
+// ResetRepository removes all collaborators, teams and invitations of a repository
+// so that they can be rebuilt by a sync. Accounts themselves are kept.
+func (service *CollaborationService) ResetRepository(ctx context.Context, tx *sql.Tx, repositoryFullName string) error {
+	queries := service.queries()
+
+	for _, query := range []string{queries.ResetTeamMembersQuery, queries.ResetTeamsQuery, queries.ResetCollaborationQuery, queries.ResetInvitationsQuery} {
+		if _, err := tx.ExecContext(ctx, query, repositoryFullName); err != nil {
+			return fmt.Errorf("failed to reset repository: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// ListRepositories returns sorted full names of known repositories that belong to the owner.
+// All repositories are returned if owner is empty.
+func (service *CollaborationService) ListRepositories(ctx context.Context, sqlRunner SQLRunner, owner string) ([]string, error) {
+	repositories := make([]string, 0)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListRepositoriesQuery, owner)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fullName string
+
+		if err := rows.Scan(&fullName); err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, fullName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repositories, nil
+}
+
+func (service *CollaborationService) ListAccounts(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Account, error) {
 	accounts := make([]Account, 0)
-	rows, err := sqlRunner.Query(GetListAccountsQuery, repositoryFullName)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListAccountsQuery, repositoryFullName)
 
 	if err != nil {
 		return nil, err
@@ -91,6 +241,12 @@ func (service *CollaborationService) ListAccounts(sqlRunner SQLRunner, repositor
 			&account.Uid,
 			&account.Login,
 			&account.Permissions,
+			&account.Affiliation,
+			service.queries().StringList(&account.Teams),
+			&account.Name,
+			&account.AvatarURL,
+			&account.Type,
+			&account.SiteAdmin,
 		); err != nil {
 			return nil, err
 		}
@@ -104,44 +260,82 @@ func (service *CollaborationService) ListAccounts(sqlRunner SQLRunner, repositor
 
 	return accounts, nil
 }
-func (service *CollaborationService) AddAccount(tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error) {
-	err := tx.QueryRow("SELECT id FROM accounts WHERE login = $1 LIMIT 1", account.Login).Scan(&account.Id)
 
+// AddAccount connects an account to a repository with given permissions. An unknown account
+// is created, while uid of an existing one gets updated. A pending invitation of the account
+// to this repository is considered accepted and gets removed.
+func (service *CollaborationService) AddAccount(ctx context.Context, tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error) {
+	created, err := service.findOrCreateAccount(ctx, tx, account)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			return nil, err
-		}
+		return nil, err
+	}
 
-		if err = tx.QueryRow(AddAccountQuery,
-			account.Uid,
-			account.Login,
-			account.Permissions,
-		).Scan(&account.Id); err != nil {
-			return nil, fmt.Errorf("failed to create account: %s", err)
+	if !created {
+		if _, err := tx.ExecContext(ctx, service.queries().RefreshAccountQuery, account.Id, account.Uid); err != nil {
+			return nil, fmt.Errorf("failed to update account: %s", err)
 		}
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create account: %s", err)
+	if account.Affiliation == "" {
+		account.Affiliation = AffiliationDirect
 	}
 
-	_, err = tx.Exec(BuildCollaborationQuery,
+	_, err = tx.ExecContext(ctx, service.queries().BuildCollaborationQuery,
 		repositoryFullName,
 		account.Id,
+		account.Affiliation,
+		account.Permissions,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create collaboration: %s", err)
 	}
 
+	if err := service.promoteInvitation(ctx, tx, repositoryFullName, account.Login); err != nil {
+		return nil, err
+	}
+
 	return account, nil
 }
 
-func (service *CollaborationService) EditAccount(sqlRunner SQLRunner, repositoryFullName string, account *Account) error {
-	_, err := sqlRunner.Exec(EditAccountQuery,
+// findOrCreateAccount looks up an account by login and creates a new one if there
+// is none, setting account.Id in both cases.
+func (service *CollaborationService) findOrCreateAccount(ctx context.Context, tx *sql.Tx, account *Account) (created bool, err error) {
+	err = tx.QueryRowContext(ctx, service.queries().FindAccountQuery, account.Login).Scan(&account.Id)
+
+	if err == nil {
+		return false, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to create account: %s", err)
+	}
+
+	if err = tx.QueryRowContext(ctx, service.queries().AddAccountQuery,
+		account.Uid,
+		account.Login,
+	).Scan(&account.Id); err != nil {
+		return false, fmt.Errorf("failed to create account: %s", err)
+	}
+
+	return true, nil
+}
+
+// EditAccount updates uid of a repository collaborator and permissions granted to it in this repository.
+func (service *CollaborationService) EditAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, account *Account) error {
+	_, err := sqlRunner.ExecContext(ctx, service.queries().EditAccountQuery,
 		repositoryFullName,
 		account.Uid,
 		account.Login,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update account: %s", err)
+	}
+
+	_, err = sqlRunner.ExecContext(ctx, service.queries().EditCollaborationQuery,
+		repositoryFullName,
+		account.Login,
 		account.Permissions,
 	)
 
@@ -149,44 +343,142 @@ func (service *CollaborationService) EditAccount(sqlRunner SQLRunner, repository
 		return fmt.Errorf("failed to update account: %s", err)
 	}
 
-	return err
+	return nil
 }
-func (service *CollaborationService) DisconnectAccount(sqlRunner SQLRunner, repositoryFullName, login string) error {
-	if _, err := sqlRunner.Exec(DisconnectAccountQuery, repositoryFullName, login); err != nil {
+
+func (service *CollaborationService) DisconnectAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, login string) error {
+	if _, err := sqlRunner.ExecContext(ctx, service.queries().DisconnectAccountQuery, repositoryFullName, login); err != nil {
 		return fmt.Errorf("failed to delete account: %s", err)
 	}
 
 	return nil
 }
 
+// UpdateProfile replaces the profile of an account and remembers when it has been received.
+func (service *CollaborationService) UpdateProfile(ctx context.Context, sqlRunner SQLRunner, login string, profile *AccountProfile, updatedAt time.Time) error {
+	_, err := sqlRunner.ExecContext(ctx, service.queries().UpdateProfileQuery,
+		login,
+		profile.Name,
+		profile.AvatarURL,
+		profile.Type,
+		profile.SiteAdmin,
+		updatedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %s", err)
+	}
+
+	return nil
+}
+
+// ListOutdatedProfiles returns up to limit logins of repository collaborators whose profiles have
+// never been received or have been received before updatedBefore, the oldest ones first.
+func (service *CollaborationService) ListOutdatedProfiles(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, updatedBefore time.Time, limit int) ([]string, error) {
+	logins := make([]string, 0)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListOutdatedProfilesQuery, repositoryFullName, updatedBefore.UTC(), limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var login string
+
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+
+		logins = append(logins, login)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logins, nil
+}
+
 const (
 	CreateRepositoryQuery = `
-    INSERT INTO repositories(full_name) VALUES($1) RETURNING id
+    INSERT INTO repositories(full_name) VALUES($1) ON CONFLICT (full_name) DO NOTHING RETURNING id
+  `
+
+	SetRepositoryProviderQuery = `
+    UPDATE repositories SET provider = $2 WHERE full_name = $1 AND (provider IS NULL OR provider = $2)
+  `
+
+	ResetTeamMembersQuery = `
+    DELETE FROM team_members WHERE team_id IN (
+      SELECT teams.id FROM teams
+      INNER JOIN repositories ON teams.repository_id = repositories.id
+      WHERE repositories.full_name = $1
+    )
+  `
+
+	ResetTeamsQuery = `
+    DELETE FROM teams WHERE repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1)
+  `
+
+	ResetCollaborationQuery = `
+    DELETE FROM collaboration WHERE repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1)
+  `
+
+	GetListRepositoriesQuery = `
+    SELECT full_name FROM repositories
+      WHERE $1::text = '' OR regexp_replace(full_name::text, '/[^/]*$', '')::citext = $1
+      ORDER BY full_name
   `
 
 	GetListAccountsQuery = `
-     SELECT accounts.id, accounts.uid, accounts.login, accounts.permissions
+     SELECT accounts.id, accounts.uid, accounts.login, collaboration.permissions, collaboration.affiliation,
+         ARRAY(
+           SELECT teams.slug FROM teams
+           INNER JOIN team_members ON teams.id = team_members.team_id
+           WHERE teams.repository_id = repositories.id AND team_members.account_id = accounts.id
+           ORDER BY teams.slug
+         ),
+         COALESCE(accounts.name, ''), COALESCE(accounts.avatar_url, ''), COALESCE(accounts.type, ''), accounts.site_admin
          FROM accounts
          INNER JOIN collaboration ON accounts.id = collaboration.account_id
          INNER JOIN repositories ON collaboration.repository_id = repositories.id
          WHERE repositories.full_name = $1
+         ORDER BY accounts.login
    `
+	FindAccountQuery = `
+      SELECT id FROM accounts WHERE login = $1 LIMIT 1
+  `
+
 	AddAccountQuery = `
-      INSERT INTO accounts(uid, login, permissions) VALUES ($1, $2, $3) RETURNING id
+      INSERT INTO accounts(uid, login) VALUES ($1, $2)
+        ON CONFLICT (login) DO UPDATE SET uid = EXCLUDED.uid
+        RETURNING id
+  `
+
+	RefreshAccountQuery = `
+      UPDATE accounts SET uid=$2 WHERE id = $1
   `
 
 	BuildCollaborationQuery = `
-    INSERT INTO collaboration (SELECT DISTINCT id, $2::int FROM repositories WHERE full_name=$1)
+    INSERT INTO collaboration (repository_id, account_id, affiliation, permissions)
+      SELECT DISTINCT id, $2::int, $3, $4::jsonb FROM repositories WHERE full_name=$1
   `
 
 	EditAccountQuery = `
-    UPDATE accounts SET uid=$2, login=$3, permissions=$4 WHERE id = (
+    UPDATE accounts SET uid=$2 WHERE login = $3 AND id IN (
       SELECT account_id FROM collaboration
       INNER JOIN repositories ON collaboration.repository_id = repositories.id
       WHERE full_name = $1
     );
    `
 
+	EditCollaborationQuery = `
+    UPDATE collaboration SET permissions = $3
+      WHERE account_id IN (SELECT id FROM accounts WHERE login = $2)
+        AND repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1)
+   `
+
 	DisconnectAccountQuery = `
       WITH account AS (
         SELECT id FROM accounts WHERE login=$2
@@ -195,4 +487,18 @@ const (
         SELECT id from account
       ) AND repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1)
    `
+
+	UpdateProfileQuery = `
+      UPDATE accounts SET name = $2, avatar_url = $3, type = $4, site_admin = $5, profile_updated_at = $6
+        WHERE login = $1
+  `
+
+	GetListOutdatedProfilesQuery = `
+      SELECT accounts.login FROM accounts
+        INNER JOIN collaboration ON accounts.id = collaboration.account_id
+        INNER JOIN repositories ON collaboration.repository_id = repositories.id
+        WHERE repositories.full_name = $1 AND (accounts.profile_updated_at IS NULL OR accounts.profile_updated_at < $2)
+        ORDER BY accounts.profile_updated_at NULLS FIRST, accounts.login
+        LIMIT $3
+  `
 )
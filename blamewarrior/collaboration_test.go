@@ -19,12 +19,14 @@
 package blamewarrior_test
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
 	"testing"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/collaborationtest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -40,7 +42,7 @@ func TestRepositoryAddAccount(t *testing.T) {
 			Account: &blamewarrior.Account{
 				Uid:         123,
 				Login:       "octocat",
-				Permissions: map[string]bool{"admin": true},
+				Permissions: blamewarrior.AccountPermissions{Admin: true},
 			},
 			Err: nil,
 		},
@@ -49,7 +51,7 @@ func TestRepositoryAddAccount(t *testing.T) {
 	for _, result := range results {
 		db, teardown := setup()
 
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		var repositoryId int
@@ -60,9 +62,10 @@ func TestRepositoryAddAccount(t *testing.T) {
 		account := result.Account
 		repositoriesService := blamewarrior.NewCollaborationService()
 
-		account, err = repositoriesService.AddAccount(db, "blamewarrior/repos", account)
+		account, err = repositoriesService.AddAccount(context.Background(), db, "blamewarrior/repos", account)
 		assert.Equal(t, result.Err, err)
 		assert.NotEmpty(t, account.Id)
+		assert.Equal(t, blamewarrior.AffiliationDirect, account.Affiliation)
 
 		var obtainedRepositoryId, obtainedAccountId int
 		err = db.QueryRow("SELECT repository_id FROM collaboration").Scan(&obtainedRepositoryId)
@@ -81,7 +84,7 @@ func TestRepositoryListAccount(t *testing.T) {
 
 	db, teardown := setup()
 
-	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 	require.NoError(t, err)
 
 	defer teardown()
@@ -89,13 +92,13 @@ func TestRepositoryListAccount(t *testing.T) {
 	var accountId int
 	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
 	require.NoError(t, err)
-	err = db.QueryRow(blamewarrior.AddAccountQuery, 123, "octocat", `{"admin": true}`).Scan(&accountId)
+	err = db.QueryRow(blamewarrior.AddAccountQuery, 123, "octocat").Scan(&accountId)
 	require.NoError(t, err)
-	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", accountId)
+	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", accountId, blamewarrior.AffiliationDirect, `{"admin": true}`)
 	require.NoError(t, err)
 
 	repositoriesService := blamewarrior.NewCollaborationService()
-	accounts, err := repositoriesService.ListAccounts(db, "blamewarrior/repos")
+	accounts, err := repositoriesService.ListAccounts(context.Background(), db, "blamewarrior/repos")
 
 	require.NoError(t, err)
 	assert.NotEmpty(t, accounts)
@@ -105,7 +108,7 @@ func TestRepositoryDisconnectAccount(t *testing.T) {
 
 	db, teardown := setup()
 
-	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 	require.NoError(t, err)
 
 	defer teardown()
@@ -117,20 +120,20 @@ func TestRepositoryDisconnectAccount(t *testing.T) {
 	err = db.QueryRow(blamewarrior.CreateRepositoryQuery, "blamewarrior/hooks").Scan(&blamewarriorHooksId)
 	require.NoError(t, err)
 
-	err = db.QueryRow(blamewarrior.AddAccountQuery, 123, "octocat", "{}").Scan(&octocatId)
+	err = db.QueryRow(blamewarrior.AddAccountQuery, 123, "octocat").Scan(&octocatId)
 	require.NoError(t, err)
 
-	err = db.QueryRow(blamewarrior.AddAccountQuery, 1234, "octocat_tst", "{}").Scan(&octocatTstId)
+	err = db.QueryRow(blamewarrior.AddAccountQuery, 1234, "octocat_tst").Scan(&octocatTstId)
 	require.NoError(t, err)
 
-	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", octocatId)
+	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", octocatId, blamewarrior.AffiliationDirect, "{}")
 	require.NoError(t, err)
 
-	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/hooks", octocatTstId)
+	_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/hooks", octocatTstId, blamewarrior.AffiliationDirect, "{}")
 	require.NoError(t, err)
 
 	repositoriesService := blamewarrior.NewCollaborationService()
-	err = repositoriesService.DisconnectAccount(db, "blamewarrior/repos", "octocat")
+	err = repositoriesService.DisconnectAccount(context.Background(), db, "blamewarrior/repos", "octocat")
 	require.NoError(t, err)
 
 	var collaborationCount int
@@ -146,6 +149,87 @@ func TestRepositoryDisconnectAccount(t *testing.T) {
 	assert.Equal(t, octocatTstId, obtainedAccountId)
 }
 
+func TestRepositoryAddAccount_ExistingAccount(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
+	require.NoError(t, err)
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/hooks")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	first, err := repositoriesService.AddAccount(context.Background(), db, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         123,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Pull: true},
+	})
+	require.NoError(t, err)
+
+	second, err := repositoriesService.AddAccount(context.Background(), db, "blamewarrior/hooks", &blamewarrior.Account{
+		Uid:         123,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Admin: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, first.Id, second.Id)
+
+	var accountsCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM accounts").Scan(&accountsCount)
+	require.NoError(t, err)
+	assert.Equal(t, 1, accountsCount)
+
+	accounts, err := repositoriesService.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Admin: true}, accounts[0].Permissions)
+}
+
+func TestRepositoryResetRepository(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	for _, fullName := range []string{"blamewarrior/repos", "blamewarrior/hooks"} {
+		require.NoError(t, repositoriesService.CreateRepository(context.Background(), db, fullName))
+
+		_, err = repositoriesService.AddAccount(context.Background(), db, fullName, &blamewarrior.Account{Uid: 123, Login: "octocat"})
+		require.NoError(t, err)
+
+		_, err = repositoriesService.AddTeam(context.Background(), db, fullName, &blamewarrior.Team{Uid: 1, Slug: "developers"})
+		require.NoError(t, err)
+
+		err = repositoriesService.AddTeamMember(context.Background(), db, fullName, "developers", &blamewarrior.Account{Uid: 123, Login: "octocat"})
+		require.NoError(t, err)
+	}
+
+	// registering the same repository twice is a no-op
+	require.NoError(t, repositoriesService.CreateRepository(context.Background(), db, "blamewarrior/repos"))
+
+	require.NoError(t, repositoriesService.ResetRepository(context.Background(), db, "blamewarrior/repos"))
+
+	accounts, err := repositoriesService.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	teams, err := repositoriesService.ListTeams(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+
+	accounts, err = repositoriesService.ListAccounts(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, []string{"developers"}, accounts[0].Teams)
+}
+
 func TestRepositoryEditAccount(t *testing.T) {
 
 	results := []struct {
@@ -156,7 +240,7 @@ func TestRepositoryEditAccount(t *testing.T) {
 			Account: &blamewarrior.Account{
 				Uid:         126,
 				Login:       "octocat_client",
-				Permissions: map[string]bool{"admin": true},
+				Permissions: blamewarrior.AccountPermissions{Admin: true},
 			},
 			Err: nil,
 		},
@@ -165,44 +249,44 @@ func TestRepositoryEditAccount(t *testing.T) {
 	for _, result := range results {
 		db, teardown := setup()
 
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		var accountId int
 		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
 		require.NoError(t, err)
-		err = db.QueryRow(blamewarrior.AddAccountQuery, result.Account.Uid, result.Account.Login, "{}").Scan(&accountId)
+		err = db.QueryRow(blamewarrior.AddAccountQuery, result.Account.Uid, result.Account.Login).Scan(&accountId)
 		require.NoError(t, err)
-		_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", accountId)
+		_, err = db.Exec(blamewarrior.BuildCollaborationQuery, "blamewarrior/repos", accountId, blamewarrior.AffiliationDirect, "{}")
 		require.NoError(t, err)
 
 		account := result.Account
 		repositoriesService := blamewarrior.NewCollaborationService()
-		err = repositoriesService.EditAccount(db, "blamewarrior/repos", account)
+		err = repositoriesService.EditAccount(context.Background(), db, "blamewarrior/repos", account)
 		assert.Equal(t, result.Err, err)
 		teardown()
 	}
 }
 
-func setup() (tx *sql.Tx, teardownFn func()) {
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		log.Fatal("missing test database name (expected to be passed via ENV['DB_NAME'])")
-	}
+func TestCollaborationService_Conformance(t *testing.T) {
+	collaborationtest.RunConformanceSuite(t, func(t *testing.T) (*sql.DB, blamewarrior.Collaboration, func()) {
+		db := setupDB()
 
-	opts := &blamewarrior.DatabaseOptions{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-	}
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+		require.NoError(t, err)
 
-	db, err := blamewarrior.ConnectDatabase(dbName, opts)
-	if err != nil {
-		log.Fatalf("failed to establish connection with test db %s using connection string %s: %s", dbName, opts.ConnectionString(), err)
-	}
+		return db, blamewarrior.NewCollaborationService(), func() {
+			if err := db.Close(); err != nil {
+				log.Printf("failed to close database connection: %s", err)
+			}
+		}
+	})
+}
+
+func setup() (tx *sql.Tx, teardownFn func()) {
+	db := setupDB()
 
-	tx, err = db.Begin()
+	tx, err := db.Begin()
 
 	if err != nil {
 		log.Fatalf("failed to create transaction, %s", err)
@@ -215,3 +299,28 @@ func setup() (tx *sql.Tx, teardownFn func()) {
 		}
 	}
 }
+
+func setupDB() *sql.DB {
+	dbName, opts := testDatabaseOptions()
+
+	db, err := blamewarrior.ConnectDatabase(dbName, opts)
+	if err != nil {
+		log.Fatalf("failed to establish connection with test db %s using connection string %s: %s", dbName, opts.ConnectionString(), err)
+	}
+
+	return db
+}
+
+func testDatabaseOptions() (dbName string, opts *blamewarrior.DatabaseOptions) {
+	dbName = os.Getenv("DB_NAME")
+	if dbName == "" {
+		log.Fatal("missing test database name (expected to be passed via ENV['DB_NAME'])")
+	}
+
+	return dbName, &blamewarrior.DatabaseOptions{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     os.Getenv("DB_PORT"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+	}
+}
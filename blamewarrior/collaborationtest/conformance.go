@@ -0,0 +1,871 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package collaborationtest provides a test suite that checks whether an implementation
+// of blamewarrior.Collaboration behaves the same way as the PostgreSQL one does.
+package collaborationtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SetupFunc returns a connection to an empty database along with the Collaboration
+// implementation to test and a function to release resources once the test is finished.
+type SetupFunc func(t *testing.T) (db *sql.DB, collaboration blamewarrior.Collaboration, teardownFn func())
+
+var conformanceTests = []struct {
+	Name string
+	Test func(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration)
+}{
+	{"ListRepositories", testListRepositories},
+	{"AddAccount", testAddAccount},
+	{"AddAccount_ExistingAccount", testAddAccountExistingAccount},
+	{"AddAccount_Duplicate", testAddAccountDuplicate},
+	{"EditAccount", testEditAccount},
+	{"DisconnectAccount", testDisconnectAccount},
+	{"Teams", testTeams},
+	{"AddTeam_UnknownRepository", testAddTeamUnknownRepository},
+	{"Invitations", testInvitations},
+	{"ResetRepository", testResetRepository},
+	{"Rollback", testRollback},
+	{"Isolation", testIsolation},
+	{"AbortedTransaction", testAbortedTransaction},
+	{"ConcurrentTransactions", testConcurrentTransactions},
+	{"ConcurrentReads", testConcurrentReads},
+	{"CancelledContext", testCancelledContext},
+	{"CaseInsensitiveNames", testCaseInsensitiveNames},
+	{"Profiles", testProfiles},
+	{"RepositoryProvider", testRepositoryProvider},
+	{"LockRepository", testLockRepository},
+}
+
+// conformanceTestTimeout limits the time each test of the suite is allowed to run.
+const conformanceTestTimeout = 30 * time.Second
+
+// RunConformanceSuite runs the test suite against each database returned by setup.
+func RunConformanceSuite(t *testing.T, setup SetupFunc) {
+	for _, test := range conformanceTests {
+		test := test
+
+		t.Run(test.Name, func(t *testing.T) {
+			db, collaboration, teardown := setup(t)
+			defer teardown()
+
+			ctx, cancel := context.WithTimeout(context.Background(), conformanceTestTimeout)
+			defer cancel()
+
+			test.Test(t, ctx, db, collaboration)
+		})
+	}
+}
+
+func testListRepositories(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	for _, fullName := range []string{"blamewarrior/repos", "octocat/hello-world", "blamewarrior/hooks", "blamewarrior-test/repos", "ghe.example.com/blamewarrior/repos"} {
+		require.NoError(t, collaboration.CreateRepository(ctx, db, fullName))
+	}
+
+	repositories, err := collaboration.ListRepositories(ctx, db, "blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blamewarrior/hooks", "blamewarrior/repos"}, repositories)
+
+	repositories, err = collaboration.ListRepositories(ctx, db, "ghe.example.com/blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ghe.example.com/blamewarrior/repos"}, repositories)
+
+	repositories, err = collaboration.ListRepositories(ctx, db, "")
+	require.NoError(t, err)
+	// the order of names that differ in punctuation depends on database collation
+	assert.ElementsMatch(t, []string{"blamewarrior-test/repos", "blamewarrior/hooks", "blamewarrior/repos", "octocat/hello-world", "ghe.example.com/blamewarrior/repos"}, repositories)
+
+	repositories, err = collaboration.ListRepositories(ctx, db, "hubot")
+	require.NoError(t, err)
+	assert.Empty(t, repositories)
+}
+
+func testAddAccount(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		account, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+			Uid:         2,
+			Login:       "octocat",
+			Permissions: blamewarrior.AccountPermissions{Admin: true},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, account.Id)
+		assert.Equal(t, blamewarrior.AffiliationDirect, account.Affiliation)
+
+		_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+			Uid:         1,
+			Login:       "hubot",
+			Permissions: blamewarrior.AccountPermissions{Pull: true},
+			Affiliation: blamewarrior.AffiliationOutside,
+		})
+		require.NoError(t, err)
+	})
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	assert.Equal(t, "hubot", accounts[0].Login)
+	assert.Equal(t, 1, accounts[0].Uid)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[0].Permissions)
+	assert.Equal(t, blamewarrior.AffiliationOutside, accounts[0].Affiliation)
+	assert.Empty(t, accounts[0].Teams)
+
+	assert.Equal(t, "octocat", accounts[1].Login)
+	assert.Equal(t, blamewarrior.AffiliationDirect, accounts[1].Affiliation)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/unknown")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+func testAddAccountExistingAccount(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/hooks"))
+
+	var first, second *blamewarrior.Account
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		var err error
+
+		first, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+			Uid:         1,
+			Login:       "octocat",
+			Permissions: blamewarrior.AccountPermissions{Pull: true},
+		})
+		require.NoError(t, err)
+
+		second, err = collaboration.AddAccount(ctx, tx, "blamewarrior/hooks", &blamewarrior.Account{
+			Uid:         1,
+			Login:       "octocat",
+			Permissions: blamewarrior.AccountPermissions{Admin: true},
+		})
+		require.NoError(t, err)
+	})
+
+	assert.Equal(t, first.Id, second.Id)
+
+	// permissions are granted per repository
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[0].Permissions)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Admin: true}, accounts[0].Permissions)
+}
+
+func testAddAccountDuplicate(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+		require.NoError(t, err)
+	})
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	assert.Error(t, err)
+}
+
+func testEditAccount(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for i, login := range []string{"hubot", "octocat"} {
+			_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+				Uid:         i + 1,
+				Login:       login,
+				Permissions: blamewarrior.AccountPermissions{Pull: true},
+			})
+			require.NoError(t, err)
+		}
+	})
+
+	require.NoError(t, collaboration.EditAccount(ctx, db, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         10,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Admin: true},
+	}))
+
+	// editing an account that is not a collaborator is a no-op
+	require.NoError(t, collaboration.EditAccount(ctx, db, "blamewarrior/hooks", &blamewarrior.Account{
+		Uid:   20,
+		Login: "hubot",
+	}))
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	assert.Equal(t, 1, accounts[0].Uid)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[0].Permissions)
+
+	assert.Equal(t, 10, accounts[1].Uid)
+	assert.Equal(t, blamewarrior.AccountPermissions{Admin: true}, accounts[1].Permissions)
+}
+
+func testDisconnectAccount(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for _, fullName := range []string{"blamewarrior/repos", "blamewarrior/hooks"} {
+			require.NoError(t, collaboration.CreateRepository(ctx, tx, fullName))
+
+			_, err := collaboration.AddAccount(ctx, tx, fullName, &blamewarrior.Account{Uid: 1, Login: "octocat"})
+			require.NoError(t, err)
+		}
+	})
+
+	require.NoError(t, collaboration.DisconnectAccount(ctx, db, "blamewarrior/repos", "octocat"))
+	require.NoError(t, collaboration.DisconnectAccount(ctx, db, "blamewarrior/repos", "hubot"))
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "octocat", accounts[0].Login)
+}
+
+func testTeams(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for _, team := range []*blamewarrior.Team{
+			{Uid: 2, Name: "Owners", Slug: "owners", Permission: "admin"},
+			{Uid: 1, Name: "Developers", Slug: "developers", Permission: "push"},
+		} {
+			team, err := collaboration.AddTeam(ctx, tx, "blamewarrior/repos", team)
+			require.NoError(t, err)
+			assert.NotEmpty(t, team.Id)
+		}
+
+		for _, login := range []string{"octocat", "hubot"} {
+			_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+				Login:       login,
+				Permissions: blamewarrior.AccountPermissions{Push: true},
+			})
+			require.NoError(t, err)
+
+			err = collaboration.AddTeamMember(ctx, tx, "blamewarrior/repos", "developers", &blamewarrior.Account{Login: login})
+			require.NoError(t, err)
+		}
+
+		err := collaboration.AddTeamMember(ctx, tx, "blamewarrior/repos", "owners", &blamewarrior.Account{Login: "octocat"})
+		require.NoError(t, err)
+	})
+
+	teams, err := collaboration.ListTeams(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, teams, 2)
+	assert.Equal(t, "developers", teams[0].Slug)
+	assert.Equal(t, "Developers", teams[0].Name)
+	assert.Equal(t, 1, teams[0].Uid)
+	assert.Equal(t, "push", teams[0].Permission)
+	assert.Equal(t, "owners", teams[1].Slug)
+
+	members, err := collaboration.ListTeamMembers(ctx, db, "blamewarrior/repos", "developers")
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "hubot", members[0].Login)
+	assert.Equal(t, "octocat", members[1].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Push: true}, members[1].Permissions)
+
+	members, err = collaboration.ListTeamMembers(ctx, db, "blamewarrior/repos", "unknown")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, []string{"developers"}, accounts[0].Teams)
+	assert.Equal(t, []string{"developers", "owners"}, accounts[1].Teams)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 3, Slug: "owners"})
+	assert.Error(t, err, "team slugs are unique within a repository")
+}
+
+func testAddTeamUnknownRepository(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/unknown", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	assert.Error(t, err)
+}
+
+func testInvitations(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	createdAt := time.Date(2018, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for i, login := range []string{"octocat", "hubot"} {
+			invitation, err := collaboration.AddInvitation(ctx, tx, "blamewarrior/repos", &blamewarrior.Invitation{
+				Uid:          i + 1,
+				InviteeUid:   i + 10,
+				InviteeLogin: login,
+				InviterLogin: "blamewarrior",
+				Permission:   "write",
+				CreatedAt:    createdAt.Add(time.Duration(i) * time.Hour),
+			})
+			require.NoError(t, err)
+			assert.NotEmpty(t, invitation.Id)
+		}
+	})
+
+	invitations, err := collaboration.ListInvitations(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 2)
+
+	assert.Equal(t, "octocat", invitations[0].InviteeLogin)
+	assert.Equal(t, 10, invitations[0].InviteeUid)
+	assert.Equal(t, "blamewarrior", invitations[0].InviterLogin)
+	assert.Equal(t, "write", invitations[0].Permission)
+	assert.Equal(t, createdAt, invitations[0].CreatedAt)
+	assert.Equal(t, createdAt.Add(blamewarrior.InvitationTTL), invitations[0].ExpiresAt)
+	assert.Equal(t, "hubot", invitations[1].InviteeLogin)
+
+	// an invitation is accepted once the invitee becomes a collaborator
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 10, Login: "octocat"})
+		require.NoError(t, err)
+	})
+
+	invitations, err = collaboration.ListInvitations(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, "hubot", invitations[0].InviteeLogin)
+}
+
+func testResetRepository(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for _, fullName := range []string{"blamewarrior/repos", "blamewarrior/hooks"} {
+			require.NoError(t, collaboration.CreateRepository(ctx, tx, fullName))
+
+			_, err := collaboration.AddAccount(ctx, tx, fullName, &blamewarrior.Account{Uid: 1, Login: "octocat"})
+			require.NoError(t, err)
+
+			_, err = collaboration.AddTeam(ctx, tx, fullName, &blamewarrior.Team{Uid: 1, Slug: "developers"})
+			require.NoError(t, err)
+
+			err = collaboration.AddTeamMember(ctx, tx, fullName, "developers", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+			require.NoError(t, err)
+
+			_, err = collaboration.AddInvitation(ctx, tx, fullName, &blamewarrior.Invitation{
+				Uid:          1,
+				InviteeLogin: "hubot",
+				CreatedAt:    time.Now(),
+			})
+			require.NoError(t, err)
+		}
+
+		// registering the same repository twice is a no-op
+		require.NoError(t, collaboration.CreateRepository(ctx, tx, "blamewarrior/repos"))
+	})
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		require.NoError(t, collaboration.ResetRepository(ctx, tx, "blamewarrior/repos"))
+	})
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	teams, err := collaboration.ListTeams(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+
+	invitations, err := collaboration.ListInvitations(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, []string{"developers"}, accounts[0].Teams)
+
+	invitations, err = collaboration.ListInvitations(ctx, db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	assert.Len(t, invitations, 1)
+}
+
+func testRollback(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, collaboration.CreateRepository(ctx, tx, "blamewarrior/hooks"))
+
+	_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	require.NoError(t, err)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	require.NoError(t, err)
+
+	require.NoError(t, blamewarrior.Rollback(collaboration, tx))
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	teams, err := collaboration.ListTeams(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+
+	// the repository has not been created, so there is nothing to add a team to
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/hooks", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	assert.Error(t, err)
+}
+
+func testIsolation(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 2, Login: "hubot"})
+		require.NoError(t, err)
+	})
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	require.NoError(t, err)
+
+	// uncommitted changes are only visible inside of the transaction along with committed ones
+	accounts, err := collaboration.ListAccounts(ctx, tx, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "hubot", accounts[0].Login)
+	assert.Equal(t, "octocat", accounts[1].Login)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "hubot", accounts[0].Login)
+
+	require.NoError(t, blamewarrior.Commit(collaboration, tx))
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+}
+
+func testAbortedTransaction(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	require.NoError(t, err)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	require.NoError(t, err)
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	require.Error(t, err)
+
+	// once a statement fails, the rest of the transaction is rejected
+	_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 2, Login: "hubot"})
+	assert.Error(t, err)
+
+	assert.Error(t, blamewarrior.Commit(collaboration, tx))
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+func testConcurrentTransactions(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	const n = 16
+
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if _, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+				Uid:   i,
+				Login: fmt.Sprintf("user%02d", i),
+			}); err != nil {
+				blamewarrior.Rollback(collaboration, tx)
+				errs <- err
+				return
+			}
+
+			if _, err := collaboration.ListAccounts(ctx, tx, "blamewarrior/repos"); err != nil {
+				blamewarrior.Rollback(collaboration, tx)
+				errs <- err
+				return
+			}
+
+			errs <- blamewarrior.Commit(collaboration, tx)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, n)
+
+	for i, account := range accounts {
+		assert.Equal(t, fmt.Sprintf("user%02d", i), account.Login)
+		assert.Equal(t, i, account.Uid)
+	}
+}
+
+func testConcurrentReads(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	const n = 16
+
+	// the repository is the only record, so that readers are the first to access other tables
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	var wg sync.WaitGroup
+	start, errs := make(chan struct{}), make(chan error, 4*n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			<-start
+
+			_, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+			errs <- err
+
+			_, err = collaboration.ListTeams(ctx, db, "blamewarrior/repos")
+			errs <- err
+
+			_, err = collaboration.ListInvitations(ctx, db, "blamewarrior/repos")
+			errs <- err
+
+			_, err = collaboration.ListRepositories(ctx, db, "blamewarrior")
+			errs <- err
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func testCancelledContext(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.Error(t, collaboration.CreateRepository(cancelledCtx, db, "blamewarrior/repos"))
+
+	_, err := collaboration.ListAccounts(cancelledCtx, db, "blamewarrior/repos")
+	assert.Error(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	_, err = collaboration.AddTeam(cancelledCtx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	assert.Error(t, err)
+
+	// the repository has not been created by a cancelled call
+	require.NoError(t, collaboration.CreateRepository(ctx, tx, "blamewarrior/repos"))
+
+	_, err = collaboration.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	require.NoError(t, err)
+	require.NoError(t, blamewarrior.Commit(collaboration, tx))
+
+	teams, err := collaboration.ListTeams(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, teams, 1)
+}
+
+// inTx runs fn within a transaction and commits it.
+func testCaseInsensitiveNames(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "BlameWarrior/Repos"))
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/hooks"))
+
+	repositories, err := collaboration.ListRepositories(ctx, db, "BLAMEWARRIOR")
+	require.NoError(t, err)
+	// the name a repository was registered with first is kept
+	assert.Equal(t, []string{"blamewarrior/hooks", "BlameWarrior/Repos"}, repositories)
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		_, err := collaboration.AddInvitation(ctx, tx, "blamewarrior/REPOS", &blamewarrior.Invitation{
+			Uid:          1,
+			InviteeLogin: "HuBot",
+			InviterLogin: "blamewarrior",
+			Permission:   "write",
+			CreatedAt:    time.Now(),
+		})
+		require.NoError(t, err)
+
+		_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+			Uid:         1,
+			Login:       "OctoCat",
+			Permissions: blamewarrior.AccountPermissions{Pull: true},
+		})
+		require.NoError(t, err)
+
+		_, err = collaboration.AddAccount(ctx, tx, "blamewarrior/hooks", &blamewarrior.Account{
+			Uid:         1,
+			Login:       "octocat",
+			Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true},
+		})
+		require.NoError(t, err)
+
+		_, err = collaboration.AddAccount(ctx, tx, "BLAMEWARRIOR/repos", &blamewarrior.Account{
+			Uid:         2,
+			Login:       "hubot",
+			Permissions: blamewarrior.AccountPermissions{Pull: true},
+		})
+		require.NoError(t, err)
+	})
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/Repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "hubot", accounts[0].Login)
+	assert.Equal(t, "OctoCat", accounts[1].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[1].Permissions)
+
+	// the invitation of a collaborator is removed once it's accepted
+	invitations, err := collaboration.ListInvitations(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	require.NoError(t, collaboration.EditAccount(ctx, db, "BlameWarrior/Repos", &blamewarrior.Account{
+		Uid:         1,
+		Login:       "OCTOCAT",
+		Permissions: blamewarrior.AccountPermissions{Admin: true},
+	}))
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, blamewarrior.AccountPermissions{Admin: true}, accounts[1].Permissions)
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true, Push: true}, accounts[0].Permissions)
+
+	require.NoError(t, collaboration.DisconnectAccount(ctx, db, "blamewarrior/REPOS", "octoCAT"))
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "hubot", accounts[0].Login)
+}
+
+func inTx(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration, fn func(tx *sql.Tx)) {
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	fn(tx)
+
+	require.NoError(t, blamewarrior.Commit(collaboration, tx))
+}
+
+func testProfiles(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		for i, login := range []string{"octocat", "hubot", "monalisa"} {
+			_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+				Uid:         i + 1,
+				Login:       login,
+				Permissions: blamewarrior.AccountPermissions{Pull: true},
+			})
+			require.NoError(t, err)
+		}
+	})
+
+	now := time.Now().Truncate(time.Second)
+
+	logins, err := collaboration.ListOutdatedProfiles(ctx, db, "blamewarrior/repos", now, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hubot", "monalisa"}, logins)
+
+	require.NoError(t, collaboration.UpdateProfile(ctx, db, "HuBot", &blamewarrior.AccountProfile{
+		Name:      "Hubot",
+		AvatarURL: "https://avatars.githubusercontent.com/u/2",
+		Type:      "Bot",
+	}, now.Add(-time.Hour)))
+
+	require.NoError(t, collaboration.UpdateProfile(ctx, db, "monalisa", &blamewarrior.AccountProfile{
+		Name:      "Mona Lisa",
+		AvatarURL: "https://avatars.githubusercontent.com/u/3",
+		Type:      "User",
+		SiteAdmin: true,
+	}, now))
+
+	accounts, err := collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 3)
+
+	assert.Equal(t, blamewarrior.AccountProfile{Name: "Hubot", AvatarURL: "https://avatars.githubusercontent.com/u/2", Type: "Bot"}, accounts[0].AccountProfile)
+	assert.Equal(t, blamewarrior.AccountProfile{Name: "Mona Lisa", AvatarURL: "https://avatars.githubusercontent.com/u/3", Type: "User", SiteAdmin: true}, accounts[1].AccountProfile)
+	assert.Equal(t, blamewarrior.AccountProfile{}, accounts[2].AccountProfile)
+
+	// accounts without a profile go first, then the ones updated earlier
+	logins, err = collaboration.ListOutdatedProfiles(ctx, db, "blamewarrior/repos", now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"octocat", "hubot", "monalisa"}, logins)
+
+	logins, err = collaboration.ListOutdatedProfiles(ctx, db, "blamewarrior/repos", now.Add(-time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"octocat", "hubot"}, logins)
+
+	// profiles are kept when a sync updates permissions of an account
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		require.NoError(t, collaboration.ResetRepository(ctx, tx, "blamewarrior/repos"))
+
+		_, err := collaboration.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{
+			Uid:         3,
+			Login:       "monalisa",
+			Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true},
+		})
+		require.NoError(t, err)
+	})
+
+	accounts, err = collaboration.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "Mona Lisa", accounts[0].Name)
+}
+
+func testRepositoryProvider(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	require.NoError(t, collaboration.CreateRepository(ctx, db, "gitlab.com/blamewarrior/repos"))
+
+	require.NoError(t, collaboration.SetRepositoryProvider(ctx, db, "gitlab.com/blamewarrior/repos", blamewarrior.ProviderGitLab))
+	require.NoError(t, collaboration.SetRepositoryProvider(ctx, db, "GitLab.com/BlameWarrior/Repos", blamewarrior.ProviderGitLab))
+
+	err := collaboration.SetRepositoryProvider(ctx, db, "gitlab.com/blamewarrior/repos", blamewarrior.ProviderGitHub)
+	assert.Equal(t, blamewarrior.ErrProviderMismatch, err)
+
+	// the provider can only be set for known repositories
+	err = collaboration.SetRepositoryProvider(ctx, db, "blamewarrior/unknown", blamewarrior.ProviderGitHub)
+	assert.Equal(t, blamewarrior.ErrProviderMismatch, err)
+
+	// the provider is kept when a sync resets the repository
+	inTx(t, ctx, db, collaboration, func(tx *sql.Tx) {
+		require.NoError(t, collaboration.ResetRepository(ctx, tx, "gitlab.com/blamewarrior/repos"))
+		require.NoError(t, collaboration.SetRepositoryProvider(ctx, tx, "gitlab.com/blamewarrior/repos", blamewarrior.ProviderGitLab))
+	})
+}
+
+func testLockRepository(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration) {
+	unlock, err := collaboration.LockRepository(ctx, db, "blamewarrior/repos", false)
+	require.NoError(t, err)
+
+	_, err = collaboration.LockRepository(ctx, db, "BlameWarrior/Repos", false)
+	assert.Equal(t, blamewarrior.ErrRepositoryLocked, err)
+
+	// other repositories are locked independently
+	unlockHooks, err := collaboration.LockRepository(ctx, db, "blamewarrior/hooks", false)
+	require.NoError(t, err)
+	unlockHooks()
+
+	// a waiting lock is taken once the repository is unlocked
+	locked := make(chan error, 1)
+	go func() {
+		unlock, err := collaboration.LockRepository(ctx, db, "blamewarrior/repos", true)
+		if err == nil {
+			unlock()
+		}
+		locked <- err
+	}()
+
+	select {
+	case err := <-locked:
+		t.Fatalf("the lock has been taken while being held by someone else, err = %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	require.NoError(t, <-locked)
+
+	// waiting is interrupted once the context is done
+	unlock, err = collaboration.LockRepository(ctx, db, "blamewarrior/repos", false)
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = collaboration.LockRepository(timeoutCtx, db, "blamewarrior/repos", true)
+	cancel()
+	assert.Error(t, err)
+
+	// unlocking twice is harmless
+	unlock()
+	unlock()
+
+	unlock, err = collaboration.LockRepository(ctx, db, "blamewarrior/repos", false)
+	require.NoError(t, err)
+	unlock()
+}
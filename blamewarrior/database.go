@@ -21,9 +21,11 @@ package blamewarrior
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"strconv"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DatabaseOptions is a configuration object type to pass PostgreSQL connection options.
@@ -65,16 +67,30 @@ func (opts *DatabaseOptions) ConnectionString() string {
 }
 
 func ConnectDatabase(dbName string, opts ...*DatabaseOptions) (*sql.DB, error) {
+	db, err := sql.Open("postgres", databaseConnectionString(dbName, opts...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db: %s", err)
+	}
+
+	return db, db.Ping()
+}
+
+// NewDatabaseListener returns a listener of PostgreSQL notifications that reconnects
+// to the database once the connection is lost.
+func NewDatabaseListener(dbName string, opts ...*DatabaseOptions) *pq.Listener {
+	return pq.NewListener(databaseConnectionString(dbName, opts...), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("database listener: %s", err)
+		}
+	})
+}
+
+func databaseConnectionString(dbName string, opts ...*DatabaseOptions) string {
 	connStr := "sslmode=disable dbname=" + dbName
 
 	if len(opts) > 0 && opts[0] != nil {
 		connStr += " " + opts[0].ConnectionString()
 	}
 
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to db: %s", err)
-	}
-
-	return db, db.Ping()
+	return connStr
 }
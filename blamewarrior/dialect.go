@@ -0,0 +1,149 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTransactionAborted is returned for statements issued in a transaction after one of
+	// the previous statements has failed. Backends other than PostgreSQL emulate this behavior.
+	ErrTransactionAborted = errors.New("current transaction is aborted, commands ignored until end of transaction block")
+	// ErrTransactionFailed is returned when committing a transaction that has been aborted.
+	ErrTransactionFailed = errors.New("could not complete operation in a failed transaction")
+)
+
+// Dialect is a set of SQL queries used by CollaborationService to work with a particular
+// database. Queries use $1, $2, ... as placeholders for arguments unless the database
+// requires otherwise.
+type Dialect struct {
+	Name string
+
+	CreateRepositoryQuery      string
+	SetRepositoryProviderQuery string
+	// TryLockRepositoryQuery and LockRepositoryQuery take an advisory lock of a repository
+	// held until the end of transaction. Repositories are locked within the process if
+	// they are empty.
+	TryLockRepositoryQuery   string
+	LockRepositoryQuery      string
+	ResetTeamMembersQuery    string
+	ResetTeamsQuery          string
+	ResetCollaborationQuery  string
+	ResetInvitationsQuery    string
+	GetListRepositoriesQuery string
+
+	GetListAccountsQuery    string
+	FindAccountQuery        string
+	AddAccountQuery         string
+	RefreshAccountQuery     string
+	BuildCollaborationQuery string
+	EditAccountQuery        string
+	EditCollaborationQuery  string
+	DisconnectAccountQuery  string
+
+	UpdateProfileQuery           string
+	GetListOutdatedProfilesQuery string
+
+	AddTeamQuery            string
+	AddTeamMemberQuery      string
+	GetListTeamsQuery       string
+	GetListTeamMembersQuery string
+
+	AddInvitationQuery      string
+	GetListInvitationsQuery string
+	PromoteInvitationQuery  string
+
+	// StringList returns a scan destination for the list of team slugs selected
+	// by GetListAccountsQuery.
+	StringList func(dest *[]string) sql.Scanner
+}
+
+// PostgresDialect is the dialect of PostgreSQL database created with db/schema.sql.
+var PostgresDialect = &Dialect{
+	Name: "postgres",
+
+	CreateRepositoryQuery:      CreateRepositoryQuery,
+	SetRepositoryProviderQuery: SetRepositoryProviderQuery,
+	TryLockRepositoryQuery:     TryLockRepositoryQuery,
+	LockRepositoryQuery:        LockRepositoryQuery,
+	ResetTeamMembersQuery:      ResetTeamMembersQuery,
+	ResetTeamsQuery:            ResetTeamsQuery,
+	ResetCollaborationQuery:    ResetCollaborationQuery,
+	ResetInvitationsQuery:      ResetInvitationsQuery,
+	GetListRepositoriesQuery:   GetListRepositoriesQuery,
+
+	GetListAccountsQuery:    GetListAccountsQuery,
+	FindAccountQuery:        FindAccountQuery,
+	AddAccountQuery:         AddAccountQuery,
+	RefreshAccountQuery:     RefreshAccountQuery,
+	BuildCollaborationQuery: BuildCollaborationQuery,
+	EditAccountQuery:        EditAccountQuery,
+	EditCollaborationQuery:  EditCollaborationQuery,
+	DisconnectAccountQuery:  DisconnectAccountQuery,
+
+	UpdateProfileQuery:           UpdateProfileQuery,
+	GetListOutdatedProfilesQuery: GetListOutdatedProfilesQuery,
+
+	AddTeamQuery:            AddTeamQuery,
+	AddTeamMemberQuery:      AddTeamMemberQuery,
+	GetListTeamsQuery:       GetListTeamsQuery,
+	GetListTeamMembersQuery: GetListTeamMembersQuery,
+
+	AddInvitationQuery:      AddInvitationQuery,
+	GetListInvitationsQuery: GetListInvitationsQuery,
+	PromoteInvitationQuery:  PromoteInvitationQuery,
+
+	StringList: func(dest *[]string) sql.Scanner {
+		return (*pq.StringArray)(dest)
+	},
+}
+
+// jsonStringList scans a list of strings encoded as JSON array.
+type jsonStringList []string
+
+func (list *jsonStringList) Scan(src interface{}) error {
+	var source []byte
+
+	switch src := src.(type) {
+	case []byte:
+		source = src
+	case string:
+		source = []byte(src)
+	default:
+		return errors.New("Type assertion .([]byte) failed.")
+	}
+
+	var items []string
+	if err := json.Unmarshal(source, &items); err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		items = nil
+	}
+
+	*list = items
+
+	return nil
+}
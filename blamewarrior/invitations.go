@@ -0,0 +1,140 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// InvitationTTL is the period of time after which GitHub expires a pending
+// repository invitation.
+const InvitationTTL = 7 * 24 * time.Hour
+
+// Invitation represents a pending GitHub invitation to collaborate on a repository.
+type Invitation struct {
+	Id           int       `json:"-"`
+	Uid          int       `json:"uid"`
+	InviteeUid   int       `json:"invitee_uid"`
+	InviteeLogin string    `json:"invitee"`
+	InviterLogin string    `json:"inviter"`
+	Permission   string    `json:"permission"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// AddInvitation stores a pending invitation. If the expiry time is not set, it's
+// calculated from the creation time using InvitationTTL.
+func (service *CollaborationService) AddInvitation(ctx context.Context, tx *sql.Tx, repositoryFullName string, invitation *Invitation) (*Invitation, error) {
+	if invitation.ExpiresAt.IsZero() {
+		invitation.ExpiresAt = invitation.CreatedAt.Add(InvitationTTL)
+	}
+
+	err := tx.QueryRowContext(ctx, service.queries().AddInvitationQuery,
+		repositoryFullName,
+		invitation.Uid,
+		invitation.InviteeUid,
+		invitation.InviteeLogin,
+		invitation.InviterLogin,
+		invitation.Permission,
+		invitation.CreatedAt.UTC(),
+		invitation.ExpiresAt.UTC(),
+	).Scan(&invitation.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %s", err)
+	}
+
+	return invitation, nil
+}
+
+func (service *CollaborationService) ListInvitations(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Invitation, error) {
+	invitations := make([]Invitation, 0)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListInvitationsQuery, repositoryFullName)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invitation := Invitation{}
+
+		if err := rows.Scan(
+			&invitation.Id,
+			&invitation.Uid,
+			&invitation.InviteeUid,
+			&invitation.InviteeLogin,
+			&invitation.InviterLogin,
+			&invitation.Permission,
+			&invitation.CreatedAt,
+			&invitation.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+
+		invitation.CreatedAt, invitation.ExpiresAt = invitation.CreatedAt.UTC(), invitation.ExpiresAt.UTC()
+
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// promoteInvitation removes a pending invitation of an account that has become
+// a collaborator of the repository.
+func (service *CollaborationService) promoteInvitation(ctx context.Context, tx *sql.Tx, repositoryFullName, login string) error {
+	if _, err := tx.ExecContext(ctx, service.queries().PromoteInvitationQuery, repositoryFullName, login); err != nil {
+		return fmt.Errorf("failed to promote invitation: %s", err)
+	}
+
+	return nil
+}
+
+const (
+	AddInvitationQuery = `
+    INSERT INTO invitations(repository_id, uid, invitee_uid, invitee_login, inviter_login, permission, created_at, expires_at)
+      SELECT id, $2, $3, $4, $5, $6, $7, $8 FROM repositories WHERE full_name = $1
+      RETURNING id
+  `
+
+	GetListInvitationsQuery = `
+     SELECT invitations.id, invitations.uid, invitations.invitee_uid, invitations.invitee_login,
+            invitations.inviter_login, invitations.permission, invitations.created_at, invitations.expires_at
+         FROM invitations
+         INNER JOIN repositories ON invitations.repository_id = repositories.id
+         WHERE repositories.full_name = $1
+         ORDER BY invitations.created_at
+   `
+
+	PromoteInvitationQuery = `
+    DELETE FROM invitations
+      WHERE repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1) AND invitee_login = $2
+  `
+
+	ResetInvitationsQuery = `
+    DELETE FROM invitations WHERE repository_id = (SELECT id FROM repositories WHERE full_name = $1 LIMIT 1)
+  `
+)
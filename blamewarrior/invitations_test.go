@@ -0,0 +1,99 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryAddInvitation(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	createdAt := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	invitation, err := repositoriesService.AddInvitation(context.Background(), db, "blamewarrior/repos", &blamewarrior.Invitation{
+		Uid:          1,
+		InviteeUid:   123,
+		InviteeLogin: "octocat",
+		InviterLogin: "hubot",
+		Permission:   "write",
+		CreatedAt:    createdAt,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, invitation.Id)
+	assert.Equal(t, createdAt.Add(blamewarrior.InvitationTTL), invitation.ExpiresAt)
+
+	invitations, err := repositoriesService.ListInvitations(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, "octocat", invitations[0].InviteeLogin)
+	assert.Equal(t, "hubot", invitations[0].InviterLogin)
+	assert.Equal(t, "write", invitations[0].Permission)
+	assert.True(t, createdAt.Equal(invitations[0].CreatedAt))
+	assert.True(t, createdAt.Add(blamewarrior.InvitationTTL).Equal(invitations[0].ExpiresAt))
+}
+
+func TestRepositoryAddAccount_PromotesInvitation(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	for i, login := range []string{"octocat", "hubot"} {
+		_, err = repositoriesService.AddInvitation(context.Background(), db, "blamewarrior/repos", &blamewarrior.Invitation{
+			Uid:          i + 1,
+			InviteeLogin: login,
+			CreatedAt:    time.Now(),
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = repositoriesService.AddAccount(context.Background(), db, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         123,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Push: true},
+	})
+	require.NoError(t, err)
+
+	invitations, err := repositoriesService.ListInvitations(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, "hubot", invitations[0].InviteeLogin)
+}
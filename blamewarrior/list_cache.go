@@ -0,0 +1,466 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// ListCacheChannel is the PostgreSQL notification channel CachingCollaboration instances
+	// exchange invalidations through.
+	ListCacheChannel = "collaborators_list_cache"
+
+	// listenerPingInterval is the time after which an idle listener checks its connection.
+	listenerPingInterval = 90 * time.Second
+)
+
+// ListCacheStats contains CachingCollaboration counters.
+type ListCacheStats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Invalidations int64   `json:"invalidations"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+// listCacheInvalidation is the payload of an invalidation notification. Login is set for changes
+// that affect lists of all repositories an account collaborates on.
+type listCacheInvalidation struct {
+	Repository string `json:"repository,omitempty"`
+	Login      string `json:"login,omitempty"`
+}
+
+type listCacheEntry struct {
+	accounts  []Account
+	expiresAt time.Time
+}
+
+// CachingCollaboration is a Collaboration decorator that keeps repository collaborator lists
+// returned by ListAccounts for TTL. Lists read within a transaction are never cached. Entries
+// are invalidated by each write made through the decorator. Lists affected by a write made
+// within a transaction are not cached until the transaction is finished with Commit or
+// Rollback, which are expected to be used for all transactions the decorator writes in.
+//
+// If Channel is set, invalidations are also sent as PostgreSQL notifications along with the
+// transaction of the write, and caches of other instances receive them with Listen.
+type CachingCollaboration struct {
+	Collaboration
+
+	TTL     time.Duration
+	Channel string
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]listCacheEntry
+	// repositories maps logins to the keys of cached lists they appear in
+	repositories map[string]map[string]struct{}
+	// transactions keeps invalidations of writes made within transactions that
+	// have not been finished yet, pendingRepositories and pendingLogins count them
+	transactions        map[*sql.Tx][]listCacheInvalidation
+	pendingRepositories map[string]int
+	pendingLogins       map[string]int
+	// generation is incremented on each invalidation to prevent lists read
+	// before it from being cached
+	generation uint64
+
+	hits, misses, invalidations int64
+}
+
+func NewCachingCollaboration(collaboration Collaboration, ttl time.Duration) *CachingCollaboration {
+	return &CachingCollaboration{
+		Collaboration: collaboration,
+		TTL:           ttl,
+		now:           time.Now,
+		entries:       make(map[string]listCacheEntry),
+		repositories:  make(map[string]map[string]struct{}),
+
+		transactions:        make(map[*sql.Tx][]listCacheInvalidation),
+		pendingRepositories: make(map[string]int),
+		pendingLogins:       make(map[string]int),
+	}
+}
+
+func (c *CachingCollaboration) ListAccounts(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Account, error) {
+	if _, ok := sqlRunner.(*sql.Tx); ok {
+		return c.Collaboration.ListAccounts(ctx, sqlRunner, repositoryFullName)
+	}
+
+	key := strings.ToLower(repositoryFullName)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && !c.now().Before(entry.expiresAt) {
+		c.remove(key)
+		ok = false
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+		return copyAccounts(entry.accounts), nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	accounts, err := c.Collaboration.ListAccounts(ctx, sqlRunner, repositoryFullName)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TTL > 0 {
+		c.mu.Lock()
+		if c.generation == generation && !c.pending(key, accounts) {
+			c.store(key, accounts)
+		}
+		c.mu.Unlock()
+	}
+
+	return accounts, nil
+}
+
+func (c *CachingCollaboration) ResetRepository(ctx context.Context, tx *sql.Tx, repositoryFullName string) error {
+	if err := c.Collaboration.ResetRepository(ctx, tx, repositoryFullName); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, tx, listCacheInvalidation{Repository: repositoryFullName})
+}
+
+func (c *CachingCollaboration) AddAccount(ctx context.Context, tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error) {
+	added, err := c.Collaboration.AddAccount(ctx, tx, repositoryFullName, account)
+	if err != nil {
+		return nil, err
+	}
+
+	// accounts are shared by repositories, so are their uids
+	if err := c.invalidate(ctx, tx, listCacheInvalidation{Repository: repositoryFullName, Login: account.Login}); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+func (c *CachingCollaboration) EditAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, account *Account) error {
+	if err := c.Collaboration.EditAccount(ctx, sqlRunner, repositoryFullName, account); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, sqlRunner, listCacheInvalidation{Repository: repositoryFullName, Login: account.Login})
+}
+
+func (c *CachingCollaboration) DisconnectAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, login string) error {
+	if err := c.Collaboration.DisconnectAccount(ctx, sqlRunner, repositoryFullName, login); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, sqlRunner, listCacheInvalidation{Repository: repositoryFullName})
+}
+
+func (c *CachingCollaboration) UpdateProfile(ctx context.Context, sqlRunner SQLRunner, login string, profile *AccountProfile, updatedAt time.Time) error {
+	if err := c.Collaboration.UpdateProfile(ctx, sqlRunner, login, profile, updatedAt); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, sqlRunner, listCacheInvalidation{Login: login})
+}
+
+func (c *CachingCollaboration) AddTeam(ctx context.Context, tx *sql.Tx, repositoryFullName string, team *Team) (*Team, error) {
+	added, err := c.Collaboration.AddTeam(ctx, tx, repositoryFullName, team)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.invalidate(ctx, tx, listCacheInvalidation{Repository: repositoryFullName}); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+func (c *CachingCollaboration) AddTeamMember(ctx context.Context, tx *sql.Tx, repositoryFullName, teamSlug string, account *Account) error {
+	if err := c.Collaboration.AddTeamMember(ctx, tx, repositoryFullName, teamSlug, account); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, tx, listCacheInvalidation{Repository: repositoryFullName})
+}
+
+// Invalidate drops all cached lists.
+func (c *CachingCollaboration) Invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]listCacheEntry)
+	c.repositories = make(map[string]map[string]struct{})
+	c.generation++
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.invalidations, 1)
+}
+
+// Commit commits tx and makes lists changed within it available for caching.
+func (c *CachingCollaboration) Commit(tx *sql.Tx) error {
+	defer c.finish(tx)
+
+	return tx.Commit()
+}
+
+// Rollback rolls tx back and makes lists changed within it available for caching.
+func (c *CachingCollaboration) Rollback(tx *sql.Tx) error {
+	defer c.finish(tx)
+
+	return tx.Rollback()
+}
+
+// transactionFinisher is implemented by Collaboration decorators that need to know when
+// a transaction they have written in is over.
+type transactionFinisher interface {
+	Commit(tx *sql.Tx) error
+	Rollback(tx *sql.Tx) error
+}
+
+// Commit commits a transaction collaboration has written in.
+func Commit(collaboration Collaboration, tx *sql.Tx) error {
+	if finisher, ok := collaboration.(transactionFinisher); ok {
+		return finisher.Commit(tx)
+	}
+
+	return tx.Commit()
+}
+
+// Rollback rolls back a transaction collaboration has written in. It is safe to call
+// once the transaction has been committed.
+func Rollback(collaboration Collaboration, tx *sql.Tx) error {
+	if finisher, ok := collaboration.(transactionFinisher); ok {
+		return finisher.Rollback(tx)
+	}
+
+	return tx.Rollback()
+}
+
+// Stats returns cache counters.
+func (c *CachingCollaboration) Stats() ListCacheStats {
+	stats := ListCacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+	}
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+
+	return stats
+}
+
+// Listen receives invalidations sent by other instances to Channel until ctx is done. All
+// cached lists are dropped whenever the listener reconnects, since notifications sent
+// in the meantime are lost.
+func (c *CachingCollaboration) Listen(ctx context.Context, listener *pq.Listener) error {
+	if err := listener.Listen(c.Channel); err != nil {
+		return fmt.Errorf("failed to listen to %s: %s", c.Channel, err)
+	}
+	defer listener.Unlisten(c.Channel)
+
+	for {
+		select {
+		case n := <-listener.NotificationChannel():
+			if n == nil {
+				c.Invalidate()
+				continue
+			}
+
+			var inv listCacheInvalidation
+			if err := json.Unmarshal([]byte(n.Extra), &inv); err != nil {
+				log.Printf("malformed list cache invalidation %q: %s", n.Extra, err)
+				c.Invalidate()
+				continue
+			}
+
+			c.drop(inv)
+		case <-time.After(listenerPingInterval):
+			go listener.Ping()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// invalidate drops affected lists and notifies other instances if Channel is set. Notifications
+// are sent with sqlRunner, so they are only delivered once the transaction is committed.
+func (c *CachingCollaboration) invalidate(ctx context.Context, sqlRunner SQLRunner, inv listCacheInvalidation) error {
+	c.drop(inv)
+
+	if tx, ok := sqlRunner.(*sql.Tx); ok {
+		c.hold(tx, inv)
+	}
+
+	if c.Channel == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sqlRunner.ExecContext(ctx, notifyListCacheQuery, c.Channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify of list cache invalidation: %s", err)
+	}
+
+	return nil
+}
+
+// drop removes the list of a repository along with lists that contain the account.
+func (c *CachingCollaboration) drop(inv listCacheInvalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if inv.Repository != "" {
+		c.remove(strings.ToLower(inv.Repository))
+	}
+
+	if inv.Login != "" {
+		for key := range c.repositories[strings.ToLower(inv.Login)] {
+			c.remove(key)
+		}
+	}
+
+	c.generation++
+	atomic.AddInt64(&c.invalidations, 1)
+}
+
+// hold keeps affected lists from being cached until tx is finished.
+func (c *CachingCollaboration) hold(tx *sql.Tx, inv listCacheInvalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.transactions[tx] = append(c.transactions[tx], inv)
+
+	if inv.Repository != "" {
+		c.pendingRepositories[strings.ToLower(inv.Repository)]++
+	}
+
+	if inv.Login != "" {
+		c.pendingLogins[strings.ToLower(inv.Login)]++
+	}
+}
+
+// finish releases lists held by the writes made within tx and drops them once again, so that
+// lists read before the transaction has been committed are not cached.
+func (c *CachingCollaboration) finish(tx *sql.Tx) {
+	c.mu.Lock()
+	invalidations := c.transactions[tx]
+	delete(c.transactions, tx)
+
+	for _, inv := range invalidations {
+		if inv.Repository != "" {
+			release(c.pendingRepositories, strings.ToLower(inv.Repository))
+		}
+
+		if inv.Login != "" {
+			release(c.pendingLogins, strings.ToLower(inv.Login))
+		}
+	}
+	c.mu.Unlock()
+
+	for _, inv := range invalidations {
+		c.drop(inv)
+	}
+}
+
+func release(pending map[string]int, key string) {
+	if pending[key]--; pending[key] <= 0 {
+		delete(pending, key)
+	}
+}
+
+// pending tells whether the list of a repository might be changed by a transaction
+// that is still in progress, c.mu is expected to be held.
+func (c *CachingCollaboration) pending(key string, accounts []Account) bool {
+	if c.pendingRepositories[key] > 0 {
+		return true
+	}
+
+	for _, account := range accounts {
+		if c.pendingLogins[strings.ToLower(account.Login)] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// store caches the list of a repository, c.mu is expected to be held.
+func (c *CachingCollaboration) store(key string, accounts []Account) {
+	c.remove(key)
+
+	c.entries[key] = listCacheEntry{
+		accounts:  copyAccounts(accounts),
+		expiresAt: c.now().Add(c.TTL),
+	}
+
+	for _, account := range accounts {
+		login := strings.ToLower(account.Login)
+		if c.repositories[login] == nil {
+			c.repositories[login] = make(map[string]struct{})
+		}
+		c.repositories[login][key] = struct{}{}
+	}
+}
+
+// remove drops the list of a repository, c.mu is expected to be held.
+func (c *CachingCollaboration) remove(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(c.entries, key)
+
+	for _, account := range entry.accounts {
+		login := strings.ToLower(account.Login)
+		delete(c.repositories[login], key)
+
+		if len(c.repositories[login]) == 0 {
+			delete(c.repositories, login)
+		}
+	}
+}
+
+// copyAccounts returns a deep copy of accounts, so that callers cannot change cached lists.
+func copyAccounts(accounts []Account) []Account {
+	copied := append([]Account(nil), accounts...)
+	for i := range copied {
+		copied[i].Teams = append([]string(nil), accounts[i].Teams...)
+	}
+
+	return copied
+}
+
+const notifyListCacheQuery = `SELECT pg_notify($1, $2)`
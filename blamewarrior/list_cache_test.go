@@ -0,0 +1,271 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/collaborationtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingCollaboration_MemoryConformance(t *testing.T) {
+	collaborationtest.RunConformanceSuite(t, func(t *testing.T) (*sql.DB, blamewarrior.Collaboration, func()) {
+		db := blamewarrior.OpenMemoryDatabase()
+
+		return db, blamewarrior.NewCachingCollaboration(blamewarrior.NewMemoryCollaborationService(), time.Minute), func() { db.Close() }
+	})
+}
+
+func TestCachingCollaboration_Memory(t *testing.T) {
+	ctx := context.Background()
+
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	backend := blamewarrior.NewMemoryCollaborationService()
+	cache := blamewarrior.NewCachingCollaboration(backend, time.Minute)
+
+	for _, repo := range []string{"blamewarrior/repos", "blamewarrior/hooks"} {
+		require.NoError(t, cache.CreateRepository(ctx, db, repo))
+		addAccount(t, ctx, db, cache, repo, &blamewarrior.Account{Uid: 1, Login: "octocat", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+	}
+
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "octocat")
+	assertLogins(t, ctx, db, cache, "BlameWarrior/Repos", "octocat")
+	assertLogins(t, ctx, db, cache, "blamewarrior/hooks", "octocat")
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+
+	// writes bypassing the cache are not seen until the list is invalidated
+	addAccount(t, ctx, db, backend, "blamewarrior/repos", &blamewarrior.Account{Uid: 2, Login: "hubot"})
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "octocat")
+
+	// lists read within a transaction are never cached
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	accounts, err := cache.ListAccounts(ctx, tx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	require.NoError(t, tx.Rollback())
+
+	// writes made through the cache invalidate the list of the repository
+	require.NoError(t, cache.DisconnectAccount(ctx, db, "blamewarrior/repos", "hubot"))
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "octocat")
+
+	addAccount(t, ctx, db, cache, "blamewarrior/repos", &blamewarrior.Account{Uid: 2, Login: "hubot"})
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "hubot", "octocat")
+
+	// as well as lists of other repositories that share the account
+	require.NoError(t, cache.UpdateProfile(ctx, db, "OctoCat", &blamewarrior.AccountProfile{Name: "The Octocat"}, time.Now()))
+
+	for _, repo := range []string{"blamewarrior/repos", "blamewarrior/hooks"} {
+		accounts, err := cache.ListAccounts(ctx, db, repo)
+		require.NoError(t, err)
+
+		for _, account := range accounts {
+			if account.Login == "octocat" {
+				assert.Equal(t, "The Octocat", account.Name, repo)
+			}
+		}
+	}
+
+	require.NoError(t, cache.EditAccount(ctx, db, "blamewarrior/hooks", &blamewarrior.Account{
+		Uid:         10,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true},
+	}))
+
+	accounts, err = cache.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, 10, accounts[1].Uid)
+	assert.False(t, accounts[1].Permissions.Push)
+
+	// lists changed within a transaction are not cached until it is committed
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, cache.ResetRepository(ctx, tx, "blamewarrior/repos"))
+
+	hits := cache.Stats().Hits
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "hubot", "octocat")
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "hubot", "octocat")
+	assert.Equal(t, hits, cache.Stats().Hits)
+
+	require.NoError(t, blamewarrior.Commit(cache, tx))
+
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos")
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos")
+	assert.Equal(t, hits+1, cache.Stats().Hits)
+
+	// Invalidate drops all lists
+	addAccount(t, ctx, db, backend, "blamewarrior/repos", &blamewarrior.Account{Uid: 2, Login: "hubot"})
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos")
+
+	invalidations := cache.Stats().Invalidations
+	cache.Invalidate()
+	assert.Equal(t, invalidations+1, cache.Stats().Invalidations)
+
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "hubot")
+}
+
+func TestCachingCollaboration_MemoryTTL(t *testing.T) {
+	ctx := context.Background()
+
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	backend := blamewarrior.NewMemoryCollaborationService()
+	cache := blamewarrior.NewCachingCollaboration(backend, 50*time.Millisecond)
+
+	require.NoError(t, cache.CreateRepository(ctx, db, "blamewarrior/repos"))
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos")
+
+	addAccount(t, ctx, db, backend, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos")
+
+	time.Sleep(100 * time.Millisecond)
+	assertLogins(t, ctx, db, cache, "blamewarrior/repos", "octocat")
+}
+
+func TestCachingCollaboration_MemoryCopies(t *testing.T) {
+	ctx := context.Background()
+
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	cache := blamewarrior.NewCachingCollaboration(blamewarrior.NewMemoryCollaborationService(), time.Minute)
+	require.NoError(t, cache.CreateRepository(ctx, db, "blamewarrior/repos"))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	_, err = cache.AddTeam(ctx, tx, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers"})
+	require.NoError(t, err)
+
+	_, err = cache.AddAccount(ctx, tx, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+	require.NoError(t, err)
+	require.NoError(t, cache.AddTeamMember(ctx, tx, "blamewarrior/repos", "developers", &blamewarrior.Account{Login: "octocat"}))
+	require.NoError(t, blamewarrior.Commit(cache, tx))
+
+	// neither the list that has been stored nor the one returned from the cache
+	// share teams with the cached one
+	for i := 0; i < 2; i++ {
+		accounts, err := cache.ListAccounts(ctx, db, "blamewarrior/repos")
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		require.Equal(t, []string{"developers"}, accounts[0].Teams)
+
+		accounts[0].Login = "hubot"
+		accounts[0].Teams[0] = "owners"
+	}
+
+	accounts, err := cache.ListAccounts(ctx, db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "octocat", accounts[0].Login)
+	assert.Equal(t, []string{"developers"}, accounts[0].Teams)
+	assert.Equal(t, int64(2), cache.Stats().Hits)
+}
+
+func TestCachingCollaboration_Notifications(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := setupDB()
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	// caches of two instances sharing the database
+	cache1 := blamewarrior.NewCachingCollaboration(blamewarrior.NewCollaborationService(), time.Minute)
+	cache1.Channel = "test_" + blamewarrior.ListCacheChannel
+
+	cache2 := blamewarrior.NewCachingCollaboration(blamewarrior.NewCollaborationService(), time.Minute)
+	cache2.Channel = cache1.Channel
+
+	listener := blamewarrior.NewDatabaseListener(testDatabaseOptions())
+	defer listener.Close()
+
+	go cache2.Listen(ctx, listener)
+
+	require.NoError(t, cache1.CreateRepository(ctx, db, "blamewarrior/repos"))
+	assertLogins(t, ctx, db, cache2, "blamewarrior/repos")
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	addAccount(t, ctx, tx, cache1, "blamewarrior/repos", &blamewarrior.Account{Uid: 1, Login: "octocat"})
+
+	// notifications are delivered once the transaction is committed
+	time.Sleep(100 * time.Millisecond)
+	assertLogins(t, ctx, db, cache2, "blamewarrior/repos")
+
+	require.NoError(t, blamewarrior.Commit(cache1, tx))
+
+	for {
+		accounts, err := cache2.ListAccounts(ctx, db, "blamewarrior/repos")
+		require.NoError(t, err)
+
+		if len(accounts) == 1 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func addAccount(t *testing.T, ctx context.Context, db blamewarrior.SQLRunner, collaboration blamewarrior.Collaboration, repo string, account *blamewarrior.Account) {
+	tx, ok := db.(*sql.Tx)
+	if !ok {
+		var err error
+
+		tx, err = db.(*sql.DB).BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, blamewarrior.Commit(collaboration, tx)) }()
+	}
+
+	_, err := collaboration.AddAccount(ctx, tx, repo, account)
+	require.NoError(t, err)
+}
+
+func assertLogins(t *testing.T, ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration, repo string, logins ...string) {
+	accounts, err := collaboration.ListAccounts(ctx, db, repo)
+	require.NoError(t, err)
+
+	actual := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		actual = append(actual, account.Login)
+	}
+
+	if logins == nil {
+		logins = []string{}
+	}
+
+	assert.Equal(t, logins, actual, repo)
+}
@@ -0,0 +1,95 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// ErrRepositoryLocked is returned by LockRepository when another sync holds the lock
+// of the repository.
+var ErrRepositoryLocked = errors.New("repository sync is already in progress")
+
+const (
+	TryLockRepositoryQuery = `SELECT pg_try_advisory_xact_lock($1)`
+	LockRepositoryQuery    = `SELECT pg_advisory_xact_lock($1)`
+)
+
+// repositoryLockKey returns the key of PostgreSQL advisory lock of a repository. Names
+// are case-insensitive, so are the keys.
+func repositoryLockKey(fullName string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("repository:"))
+	h.Write([]byte(strings.ToLower(fullName)))
+
+	return int64(h.Sum64())
+}
+
+// repositoryLocks are repository locks held within the process, they are used by databases
+// that are not shared by several instances. The zero value is ready to use.
+type repositoryLocks struct {
+	mu sync.Mutex
+	// released channels are closed once the lock of a repository is released
+	released map[string]chan struct{}
+}
+
+func (l *repositoryLocks) lock(ctx context.Context, fullName string, wait bool) (unlock func(), err error) {
+	key := strings.ToLower(fullName)
+
+	for {
+		l.mu.Lock()
+		if l.released == nil {
+			l.released = make(map[string]chan struct{})
+		}
+
+		held, ok := l.released[key]
+		if !ok {
+			released := make(chan struct{})
+			l.released[key] = released
+			l.mu.Unlock()
+
+			var once sync.Once
+
+			return func() {
+				once.Do(func() {
+					l.mu.Lock()
+					delete(l.released, key)
+					l.mu.Unlock()
+
+					close(released)
+				})
+			}, nil
+		}
+		l.mu.Unlock()
+
+		if !wait {
+			return nil, ErrRepositoryLocked
+		}
+
+		select {
+		case <-held:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
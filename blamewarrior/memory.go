@@ -0,0 +1,1050 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MemoryCollaborationService is an implementation of Collaboration that keeps its data in
+// an in-memory database returned by OpenMemoryDatabase(). It is not compatible with
+// PostgreSQL connections and vice versa.
+type MemoryCollaborationService struct {
+	locks repositoryLocks
+}
+
+func NewMemoryCollaborationService() *MemoryCollaborationService {
+	return new(MemoryCollaborationService)
+}
+
+func (service *MemoryCollaborationService) CreateRepository(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) error {
+	_, err := sqlRunner.ExecContext(ctx, memoryCreateRepository, repositoryFullName)
+	return err
+}
+
+// LockRepository takes an exclusive lock of a repository within the process, see
+// CollaborationService.LockRepository.
+func (service *MemoryCollaborationService) LockRepository(ctx context.Context, db *sql.DB, repositoryFullName string, wait bool) (func(), error) {
+	return service.locks.lock(ctx, repositoryFullName, wait)
+}
+
+func (service *MemoryCollaborationService) SetRepositoryProvider(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, provider string) error {
+	res, err := sqlRunner.ExecContext(ctx, memorySetRepositoryProvider, repositoryFullName, provider)
+	if err != nil {
+		return fmt.Errorf("failed to set repository provider: %s", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrProviderMismatch
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) ResetRepository(ctx context.Context, tx *sql.Tx, repositoryFullName string) error {
+	if _, err := tx.ExecContext(ctx, memoryResetRepository, repositoryFullName); err != nil {
+		return fmt.Errorf("failed to reset repository: %s", err)
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) ListRepositories(ctx context.Context, sqlRunner SQLRunner, owner string) ([]string, error) {
+	repositories := make([]string, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListRepositories, owner)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fullName string
+
+		if err := rows.Scan(&fullName); err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, fullName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repositories, nil
+}
+
+func (service *MemoryCollaborationService) ListAccounts(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Account, error) {
+	accounts := make([]Account, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListAccounts, repositoryFullName)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account := Account{}
+
+		if err := rows.Scan(
+			&account.Id,
+			&account.Uid,
+			&account.Login,
+			&account.Permissions,
+			&account.Affiliation,
+			(*jsonStringList)(&account.Teams),
+			&account.Name,
+			&account.AvatarURL,
+			&account.Type,
+			&account.SiteAdmin,
+		); err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (service *MemoryCollaborationService) AddAccount(ctx context.Context, tx *sql.Tx, repositoryFullName string, account *Account) (*Account, error) {
+	if account.Affiliation == "" {
+		account.Affiliation = AffiliationDirect
+	}
+
+	err := tx.QueryRowContext(ctx, memoryAddAccount,
+		repositoryFullName,
+		account.Uid,
+		account.Login,
+		account.Permissions,
+		account.Affiliation,
+	).Scan(&account.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collaboration: %s", err)
+	}
+
+	return account, nil
+}
+
+func (service *MemoryCollaborationService) EditAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, account *Account) error {
+	_, err := sqlRunner.ExecContext(ctx, memoryEditAccount,
+		repositoryFullName,
+		account.Uid,
+		account.Login,
+		account.Permissions,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update account: %s", err)
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) DisconnectAccount(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, login string) error {
+	if _, err := sqlRunner.ExecContext(ctx, memoryDisconnectAccount, repositoryFullName, login); err != nil {
+		return fmt.Errorf("failed to delete account: %s", err)
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) UpdateProfile(ctx context.Context, sqlRunner SQLRunner, login string, profile *AccountProfile, updatedAt time.Time) error {
+	_, err := sqlRunner.ExecContext(ctx, memoryUpdateProfile,
+		login,
+		profile.Name,
+		profile.AvatarURL,
+		profile.Type,
+		profile.SiteAdmin,
+		updatedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %s", err)
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) ListOutdatedProfiles(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string, updatedBefore time.Time, limit int) ([]string, error) {
+	logins := make([]string, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListOutdatedProfiles, repositoryFullName, updatedBefore.UTC(), int64(limit))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var login string
+
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+
+		logins = append(logins, login)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return logins, nil
+}
+
+func (service *MemoryCollaborationService) AddTeam(ctx context.Context, tx *sql.Tx, repositoryFullName string, team *Team) (*Team, error) {
+	err := tx.QueryRowContext(ctx, memoryAddTeam,
+		repositoryFullName,
+		team.Uid,
+		team.Name,
+		team.Slug,
+		team.Permission,
+	).Scan(&team.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create team: %s", err)
+	}
+
+	return team, nil
+}
+
+func (service *MemoryCollaborationService) AddTeamMember(ctx context.Context, tx *sql.Tx, repositoryFullName, teamSlug string, account *Account) error {
+	err := tx.QueryRowContext(ctx, memoryAddTeamMember,
+		repositoryFullName,
+		teamSlug,
+		account.Uid,
+		account.Login,
+	).Scan(&account.Id)
+
+	if err != nil {
+		return fmt.Errorf("failed to create team membership: %s", err)
+	}
+
+	return nil
+}
+
+func (service *MemoryCollaborationService) ListTeams(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Team, error) {
+	teams := make([]Team, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListTeams, repositoryFullName)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		team := Team{}
+
+		if err := rows.Scan(
+			&team.Id,
+			&team.Uid,
+			&team.Name,
+			&team.Slug,
+			&team.Permission,
+		); err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (service *MemoryCollaborationService) ListTeamMembers(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, teamSlug string) ([]Account, error) {
+	accounts := make([]Account, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListTeamMembers, repositoryFullName, teamSlug)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account := Account{}
+
+		if err := rows.Scan(
+			&account.Id,
+			&account.Uid,
+			&account.Login,
+			&account.Permissions,
+		); err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (service *MemoryCollaborationService) AddInvitation(ctx context.Context, tx *sql.Tx, repositoryFullName string, invitation *Invitation) (*Invitation, error) {
+	if invitation.ExpiresAt.IsZero() {
+		invitation.ExpiresAt = invitation.CreatedAt.Add(InvitationTTL)
+	}
+
+	err := tx.QueryRowContext(ctx, memoryAddInvitation,
+		repositoryFullName,
+		invitation.Uid,
+		invitation.InviteeUid,
+		invitation.InviteeLogin,
+		invitation.InviterLogin,
+		invitation.Permission,
+		invitation.CreatedAt,
+		invitation.ExpiresAt,
+	).Scan(&invitation.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %s", err)
+	}
+
+	return invitation, nil
+}
+
+func (service *MemoryCollaborationService) ListInvitations(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Invitation, error) {
+	invitations := make([]Invitation, 0)
+	rows, err := sqlRunner.QueryContext(ctx, memoryListInvitations, repositoryFullName)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invitation := Invitation{}
+
+		if err := rows.Scan(
+			&invitation.Id,
+			&invitation.Uid,
+			&invitation.InviteeUid,
+			&invitation.InviteeLogin,
+			&invitation.InviterLogin,
+			&invitation.Permission,
+			&invitation.CreatedAt,
+			&invitation.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// In-memory tables, the names match ones from db/schema.sql
+const (
+	memoryRepositoriesTable  = "repositories"
+	memoryAccountsTable      = "accounts"
+	memoryCollaborationTable = "collaboration"
+	memoryTeamsTable         = "teams"
+	memoryTeamMembersTable   = "team_members"
+	memoryInvitationsTable   = "invitations"
+)
+
+type memoryRepository struct {
+	id       int64
+	fullName string
+	// provider is empty for repositories that have not been synchronized yet
+	provider string
+}
+
+func (rec memoryRepository) primaryKey() string {
+	return strconv.FormatInt(rec.id, 10)
+}
+
+func (rec memoryRepository) uniqueKeys() []string {
+	return []string{"full_name=" + strings.ToLower(rec.fullName)}
+}
+
+type memoryAccount struct {
+	id    int64
+	uid   int64
+	login string
+
+	name, avatarURL, accountType string
+	siteAdmin                    bool
+	// profileUpdatedAt is zero for accounts without a profile
+	profileUpdatedAt time.Time
+}
+
+func (rec memoryAccount) primaryKey() string {
+	return strconv.FormatInt(rec.id, 10)
+}
+
+func (rec memoryAccount) uniqueKeys() []string {
+	return []string{"login=" + strings.ToLower(rec.login)}
+}
+
+type memoryCollaboration struct {
+	repositoryId, accountId int64
+	affiliation             string
+	permissions             []byte
+}
+
+func (rec memoryCollaboration) primaryKey() string {
+	return fmt.Sprintf("%d/%d", rec.repositoryId, rec.accountId)
+}
+
+func (rec memoryCollaboration) uniqueKeys() []string {
+	return nil
+}
+
+type memoryTeam struct {
+	id, repositoryId, uid  int64
+	name, slug, permission string
+}
+
+func (rec memoryTeam) primaryKey() string {
+	return strconv.FormatInt(rec.id, 10)
+}
+
+func (rec memoryTeam) uniqueKeys() []string {
+	return []string{
+		fmt.Sprintf("uid=%d/%d", rec.repositoryId, rec.uid),
+		fmt.Sprintf("slug=%d/%s", rec.repositoryId, rec.slug),
+	}
+}
+
+type memoryTeamMember struct {
+	teamId, accountId int64
+}
+
+func (rec memoryTeamMember) primaryKey() string {
+	return fmt.Sprintf("%d/%d", rec.teamId, rec.accountId)
+}
+
+func (rec memoryTeamMember) uniqueKeys() []string {
+	return nil
+}
+
+type memoryInvitation struct {
+	id, repositoryId, uid, inviteeUid      int64
+	inviteeLogin, inviterLogin, permission string
+	createdAt, expiresAt                   time.Time
+}
+
+func (rec memoryInvitation) primaryKey() string {
+	return strconv.FormatInt(rec.id, 10)
+}
+
+func (rec memoryInvitation) uniqueKeys() []string {
+	return []string{fmt.Sprintf("uid=%d/%d", rec.repositoryId, rec.uid)}
+}
+
+// In-memory commands used by MemoryCollaborationService instead of SQL queries
+const (
+	memoryCreateRepository      = "create_repository"
+	memorySetRepositoryProvider = "set_repository_provider"
+	memoryResetRepository       = "reset_repository"
+	memoryListRepositories      = "list_repositories"
+	memoryListAccounts          = "list_accounts"
+	memoryAddAccount            = "add_account"
+	memoryEditAccount           = "edit_account"
+	memoryDisconnectAccount     = "disconnect_account"
+	memoryUpdateProfile         = "update_profile"
+	memoryListOutdatedProfiles  = "list_outdated_profiles"
+	memoryAddTeam               = "add_team"
+	memoryAddTeamMember         = "add_team_member"
+	memoryListTeams             = "list_teams"
+	memoryListTeamMembers       = "list_team_members"
+	memoryAddInvitation         = "add_invitation"
+	memoryListInvitations       = "list_invitations"
+)
+
+var memoryCommands = map[string]memoryCommand{
+	memoryCreateRepository: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		fullName := argString(args, 0)
+
+		if _, ok := st.findRepository(fullName); ok {
+			return &memoryResult{}, nil
+		}
+
+		if err := st.insert(memoryRepositoriesTable, memoryRepository{
+			id:       store.nextId(memoryRepositoriesTable),
+			fullName: fullName,
+		}); err != nil {
+			return nil, err
+		}
+
+		return &memoryResult{affected: 1}, nil
+	}},
+	memorySetRepositoryProvider: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		provider := argString(args, 1)
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok || (repo.provider != "" && repo.provider != provider) {
+			return &memoryResult{}, nil
+		}
+
+		repo.provider = provider
+		if err := st.update(memoryRepositoriesTable, repo); err != nil {
+			return nil, err
+		}
+
+		return &memoryResult{affected: 1}, nil
+	}},
+	memoryResetRepository: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return &memoryResult{}, nil
+		}
+
+		teams := make(map[int64]bool)
+		for _, rec := range st.rows(memoryTeamsTable) {
+			if team := rec.(memoryTeam); team.repositoryId == repo.id {
+				teams[team.id] = true
+				st.remove(memoryTeamsTable, team.primaryKey())
+			}
+		}
+
+		for _, rec := range st.rows(memoryTeamMembersTable) {
+			if teams[rec.(memoryTeamMember).teamId] {
+				st.remove(memoryTeamMembersTable, rec.primaryKey())
+			}
+		}
+
+		for _, rec := range st.rows(memoryCollaborationTable) {
+			if rec.(memoryCollaboration).repositoryId == repo.id {
+				st.remove(memoryCollaborationTable, rec.primaryKey())
+			}
+		}
+
+		for _, rec := range st.rows(memoryInvitationsTable) {
+			if rec.(memoryInvitation).repositoryId == repo.id {
+				st.remove(memoryInvitationsTable, rec.primaryKey())
+			}
+		}
+
+		return &memoryResult{}, nil
+	}},
+	memoryListRepositories: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"full_name"}}
+
+		owner := argString(args, 0)
+		for _, rec := range st.rows(memoryRepositoriesTable) {
+			fullName := rec.(memoryRepository).fullName
+			if owner == "" || strings.HasPrefix(strings.ToLower(fullName), strings.ToLower(owner)+"/") {
+				res.values = append(res.values, []driver.Value{fullName})
+			}
+		}
+
+		sortByString(res.values, 0)
+
+		return res, nil
+	}},
+	memoryListAccounts: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"id", "uid", "login", "permissions", "affiliation", "teams", "name", "avatar_url", "type", "site_admin"}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		for _, rec := range st.rows(memoryCollaborationTable) {
+			collaboration := rec.(memoryCollaboration)
+			if collaboration.repositoryId != repo.id {
+				continue
+			}
+
+			account, ok := st.getAccount(collaboration.accountId)
+			if !ok {
+				continue
+			}
+
+			teams, err := json.Marshal(st.accountTeams(repo.id, account.id))
+			if err != nil {
+				return nil, err
+			}
+
+			res.values = append(res.values, []driver.Value{
+				account.id, account.uid, account.login, collaboration.permissions, collaboration.affiliation, teams,
+				account.name, account.avatarURL, account.accountType, account.siteAdmin,
+			})
+		}
+
+		sortByString(res.values, 2)
+
+		return res, nil
+	}},
+	memoryAddAccount: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		fullName, login := argString(args, 0), argString(args, 2)
+
+		account, err := st.findOrCreateAccount(store, argInt(args, 1), login)
+		if err != nil {
+			return nil, err
+		}
+
+		if repo, ok := st.findRepository(fullName); ok {
+			if err := st.insert(memoryCollaborationTable, memoryCollaboration{
+				repositoryId: repo.id,
+				accountId:    account.id,
+				affiliation:  argString(args, 4),
+				permissions:  argBytes(args, 3),
+			}); err != nil {
+				return nil, err
+			}
+
+			// a pending invitation is considered accepted once the account becomes a collaborator
+			for _, rec := range st.rows(memoryInvitationsTable) {
+				if invitation := rec.(memoryInvitation); invitation.repositoryId == repo.id && strings.EqualFold(invitation.inviteeLogin, login) {
+					st.remove(memoryInvitationsTable, invitation.primaryKey())
+				}
+			}
+		}
+
+		return &memoryResult{columns: []string{"id"}, values: [][]driver.Value{{account.id}}}, nil
+	}},
+	memoryEditAccount: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		login := argString(args, 2)
+		for _, rec := range st.rows(memoryCollaborationTable) {
+			collaboration := rec.(memoryCollaboration)
+			if collaboration.repositoryId != repo.id {
+				continue
+			}
+
+			account, ok := st.getAccount(collaboration.accountId)
+			if !ok || !strings.EqualFold(account.login, login) {
+				continue
+			}
+
+			account.uid, collaboration.permissions = argInt(args, 1), argBytes(args, 3)
+			if err := st.update(memoryAccountsTable, account); err != nil {
+				return nil, err
+			}
+
+			if err := st.update(memoryCollaborationTable, collaboration); err != nil {
+				return nil, err
+			}
+			res.affected++
+		}
+
+		return res, nil
+	}},
+	memoryDisconnectAccount: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		login := argString(args, 1)
+		for _, rec := range st.rows(memoryCollaborationTable) {
+			collaboration := rec.(memoryCollaboration)
+			if collaboration.repositoryId != repo.id {
+				continue
+			}
+
+			if account, ok := st.getAccount(collaboration.accountId); ok && strings.EqualFold(account.login, login) {
+				st.remove(memoryCollaborationTable, collaboration.primaryKey())
+				res.affected++
+			}
+		}
+
+		return res, nil
+	}},
+	memoryUpdateProfile: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{}
+
+		login := argString(args, 0)
+		for _, rec := range st.rows(memoryAccountsTable) {
+			account := rec.(memoryAccount)
+			if !strings.EqualFold(account.login, login) {
+				continue
+			}
+
+			account.name, account.avatarURL, account.accountType = argString(args, 1), argString(args, 2), argString(args, 3)
+			account.siteAdmin, account.profileUpdatedAt = argBool(args, 4), argTime(args, 5)
+			if err := st.update(memoryAccountsTable, account); err != nil {
+				return nil, err
+			}
+			res.affected++
+		}
+
+		return res, nil
+	}},
+	memoryListOutdatedProfiles: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"login"}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		updatedBefore := argTime(args, 1)
+
+		var accounts []memoryAccount
+		for _, rec := range st.rows(memoryCollaborationTable) {
+			collaboration := rec.(memoryCollaboration)
+			if collaboration.repositoryId != repo.id {
+				continue
+			}
+
+			if account, ok := st.getAccount(collaboration.accountId); ok && account.profileUpdatedAt.Before(updatedBefore) {
+				accounts = append(accounts, account)
+			}
+		}
+
+		// accounts without a profile have zero update time and go first
+		sort.SliceStable(accounts, func(i, j int) bool {
+			if !accounts[i].profileUpdatedAt.Equal(accounts[j].profileUpdatedAt) {
+				return accounts[i].profileUpdatedAt.Before(accounts[j].profileUpdatedAt)
+			}
+
+			return strings.ToLower(accounts[i].login) < strings.ToLower(accounts[j].login)
+		})
+
+		for i, account := range accounts {
+			if int64(i) >= argInt(args, 2) {
+				break
+			}
+
+			res.values = append(res.values, []driver.Value{account.login})
+		}
+
+		return res, nil
+	}},
+	memoryAddTeam: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"id"}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		team := memoryTeam{
+			id:           store.nextId(memoryTeamsTable),
+			repositoryId: repo.id,
+			uid:          argInt(args, 1),
+			name:         argString(args, 2),
+			slug:         argString(args, 3),
+			permission:   argString(args, 4),
+		}
+
+		if err := st.insert(memoryTeamsTable, team); err != nil {
+			return nil, err
+		}
+
+		res.values = append(res.values, []driver.Value{team.id})
+
+		return res, nil
+	}},
+	memoryAddTeamMember: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		account, err := st.findOrCreateAccount(store, argInt(args, 2), argString(args, 3))
+		if err != nil {
+			return nil, err
+		}
+
+		if team, ok := st.findTeam(argString(args, 0), argString(args, 1)); ok {
+			if err := st.insert(memoryTeamMembersTable, memoryTeamMember{teamId: team.id, accountId: account.id}); err != nil {
+				return nil, err
+			}
+		}
+
+		return &memoryResult{columns: []string{"id"}, values: [][]driver.Value{{account.id}}}, nil
+	}},
+	memoryListTeams: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"id", "uid", "name", "slug", "permission"}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		for _, rec := range st.rows(memoryTeamsTable) {
+			if team := rec.(memoryTeam); team.repositoryId == repo.id {
+				res.values = append(res.values, []driver.Value{team.id, team.uid, team.name, team.slug, team.permission})
+			}
+		}
+
+		sort.Slice(res.values, func(i, j int) bool {
+			return res.values[i][3].(string) < res.values[j][3].(string)
+		})
+
+		return res, nil
+	}},
+	memoryListTeamMembers: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"id", "uid", "login", "permissions"}}
+
+		team, ok := st.findTeam(argString(args, 0), argString(args, 1))
+		if !ok {
+			return res, nil
+		}
+
+		for _, rec := range st.rows(memoryTeamMembersTable) {
+			member := rec.(memoryTeamMember)
+			if member.teamId != team.id {
+				continue
+			}
+
+			account, ok := st.getAccount(member.accountId)
+			if !ok {
+				continue
+			}
+
+			// members that are not collaborators of the repository have no permissions in it
+			permissions := []byte("{}")
+			if rec, ok := st.get(memoryCollaborationTable, memoryCollaboration{repositoryId: team.repositoryId, accountId: account.id}.primaryKey()); ok && rec.(memoryCollaboration).permissions != nil {
+				permissions = rec.(memoryCollaboration).permissions
+			}
+
+			res.values = append(res.values, []driver.Value{account.id, account.uid, account.login, permissions})
+		}
+
+		sortByString(res.values, 2)
+
+		return res, nil
+	}},
+	memoryAddInvitation: {run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{"id"}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		invitation := memoryInvitation{
+			id:           store.nextId(memoryInvitationsTable),
+			repositoryId: repo.id,
+			uid:          argInt(args, 1),
+			inviteeUid:   argInt(args, 2),
+			inviteeLogin: argString(args, 3),
+			inviterLogin: argString(args, 4),
+			permission:   argString(args, 5),
+			// PostgreSQL timestamps have microsecond precision
+			createdAt: argTime(args, 6).Truncate(time.Microsecond),
+			expiresAt: argTime(args, 7).Truncate(time.Microsecond),
+		}
+
+		if err := st.insert(memoryInvitationsTable, invitation); err != nil {
+			return nil, err
+		}
+
+		res.values = append(res.values, []driver.Value{invitation.id})
+
+		return res, nil
+	}},
+	memoryListInvitations: {readOnly: true, run: func(st *memoryState, store *memoryStore, args []driver.Value) (*memoryResult, error) {
+		res := &memoryResult{columns: []string{
+			"id", "uid", "invitee_uid", "invitee_login", "inviter_login", "permission", "created_at", "expires_at",
+		}}
+
+		repo, ok := st.findRepository(argString(args, 0))
+		if !ok {
+			return res, nil
+		}
+
+		var invitations []memoryInvitation
+		for _, rec := range st.rows(memoryInvitationsTable) {
+			if invitation := rec.(memoryInvitation); invitation.repositoryId == repo.id {
+				invitations = append(invitations, invitation)
+			}
+		}
+
+		sort.Slice(invitations, func(i, j int) bool {
+			if !invitations[i].createdAt.Equal(invitations[j].createdAt) {
+				return invitations[i].createdAt.Before(invitations[j].createdAt)
+			}
+
+			return invitations[i].id < invitations[j].id
+		})
+
+		for _, invitation := range invitations {
+			res.values = append(res.values, []driver.Value{
+				invitation.id,
+				invitation.uid,
+				invitation.inviteeUid,
+				invitation.inviteeLogin,
+				invitation.inviterLogin,
+				invitation.permission,
+				invitation.createdAt.UTC(),
+				invitation.expiresAt.UTC(),
+			})
+		}
+
+		return res, nil
+	}},
+}
+
+func (st *memoryState) findRepository(fullName string) (memoryRepository, bool) {
+	pk, ok := st.table(memoryRepositoriesTable).unique[memoryRepository{fullName: fullName}.uniqueKeys()[0]]
+	if !ok {
+		return memoryRepository{}, false
+	}
+
+	rec, ok := st.get(memoryRepositoriesTable, pk)
+	if !ok {
+		return memoryRepository{}, false
+	}
+
+	return rec.(memoryRepository), true
+}
+
+func (st *memoryState) findTeam(repositoryFullName, slug string) (memoryTeam, bool) {
+	repo, ok := st.findRepository(repositoryFullName)
+	if !ok {
+		return memoryTeam{}, false
+	}
+
+	pk, ok := st.table(memoryTeamsTable).unique[memoryTeam{repositoryId: repo.id, slug: slug}.uniqueKeys()[1]]
+	if !ok {
+		return memoryTeam{}, false
+	}
+
+	rec, ok := st.get(memoryTeamsTable, pk)
+	if !ok {
+		return memoryTeam{}, false
+	}
+
+	return rec.(memoryTeam), true
+}
+
+func (st *memoryState) getAccount(id int64) (memoryAccount, bool) {
+	rec, ok := st.get(memoryAccountsTable, strconv.FormatInt(id, 10))
+	if !ok {
+		return memoryAccount{}, false
+	}
+
+	return rec.(memoryAccount), true
+}
+
+// findOrCreateAccount looks up an account by login updating its uid, or creates a new
+// one if there is none.
+func (st *memoryState) findOrCreateAccount(store *memoryStore, uid int64, login string) (memoryAccount, error) {
+	var (
+		account memoryAccount
+		found   bool
+	)
+
+	for _, rec := range st.rows(memoryAccountsTable) {
+		if acc := rec.(memoryAccount); strings.EqualFold(acc.login, login) && (!found || acc.id < account.id) {
+			account, found = acc, true
+		}
+	}
+
+	if !found {
+		account = memoryAccount{id: store.nextId(memoryAccountsTable), uid: uid, login: login}
+		return account, st.insert(memoryAccountsTable, account)
+	}
+
+	account.uid = uid
+
+	return account, st.update(memoryAccountsTable, account)
+}
+
+// accountTeams returns sorted slugs of repository teams the account is a member of.
+func (st *memoryState) accountTeams(repositoryId, accountId int64) []string {
+	slugs := make([]string, 0)
+
+	for _, rec := range st.rows(memoryTeamMembersTable) {
+		member := rec.(memoryTeamMember)
+		if member.accountId != accountId {
+			continue
+		}
+
+		if rec, ok := st.get(memoryTeamsTable, strconv.FormatInt(member.teamId, 10)); ok && rec.(memoryTeam).repositoryId == repositoryId {
+			slugs = append(slugs, rec.(memoryTeam).slug)
+		}
+	}
+
+	sort.Strings(slugs)
+
+	return slugs
+}
+
+// sortByString sorts rows by a string column ignoring case, same as names are ordered
+// by the SQL backends.
+func sortByString(values [][]driver.Value, column int) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return strings.ToLower(values[i][column].(string)) < strings.ToLower(values[j][column].(string))
+	})
+}
+
+func argString(args []driver.Value, i int) string {
+	switch v := args[i].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	return ""
+}
+
+func argInt(args []driver.Value, i int) int64 {
+	v, _ := args[i].(int64)
+	return v
+}
+
+func argBytes(args []driver.Value, i int) []byte {
+	switch v := args[i].(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+
+	return nil
+}
+
+func argBool(args []driver.Value, i int) bool {
+	v, _ := args[i].(bool)
+	return v
+}
+
+func argTime(args []driver.Value, i int) time.Time {
+	v, _ := args[i].(time.Time)
+	return v
+}
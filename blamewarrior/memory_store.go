@@ -0,0 +1,501 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OpenMemoryDatabase returns a handle to a new empty in-memory database. It's meant to
+// be used along with MemoryCollaborationService in tests and for local development.
+//
+// Transactions see data committed by others at the moment each statement is executed
+// along with their own changes. Changes are recorded row by row and applied on commit,
+// so that a conflicting unique key fails the transaction just like PostgreSQL would.
+func OpenMemoryDatabase() *sql.DB {
+	return sql.OpenDB(&memoryConnector{
+		store: &memoryStore{
+			state:     newMemoryState(),
+			sequences: make(map[string]int64),
+		},
+	})
+}
+
+// memoryRecord is a row stored in an in-memory table.
+type memoryRecord interface {
+	primaryKey() string
+	uniqueKeys() []string
+}
+
+// memoryEffect is a change made to an in-memory table by a transaction. A nil record
+// means that the row has been deleted.
+type memoryEffect struct {
+	table, key string
+	record     memoryRecord
+	insert     bool
+}
+
+type memoryTable struct {
+	rows   map[string]memoryRecord
+	unique map[string]string
+}
+
+// memoryState is a snapshot of all in-memory tables. Records are never modified in
+// place, so that snapshots can share them.
+type memoryState struct {
+	tables map[string]*memoryTable
+
+	// effects, when set, collects changes made to the state by a transaction
+	effects *[]memoryEffect
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{tables: make(map[string]*memoryTable)}
+}
+
+func newMemoryTable(size int) *memoryTable {
+	return &memoryTable{
+		rows:   make(map[string]memoryRecord, size),
+		unique: make(map[string]string, size),
+	}
+}
+
+func (st *memoryState) clone() *memoryState {
+	cp := newMemoryState()
+
+	for name, table := range st.tables {
+		t := newMemoryTable(len(table.rows))
+
+		for k, v := range table.rows {
+			t.rows[k] = v
+		}
+
+		for k, v := range table.unique {
+			t.unique[k] = v
+		}
+
+		cp.tables[name] = t
+	}
+
+	return cp
+}
+
+// table returns a table by its name, a missing table is empty. It never modifies the
+// state, since read-only commands share the committed one.
+func (st *memoryState) table(name string) *memoryTable {
+	if t, ok := st.tables[name]; ok {
+		return t
+	}
+
+	return newMemoryTable(0)
+}
+
+// rows returns all records of a table in no particular order.
+func (st *memoryState) rows(table string) []memoryRecord {
+	t := st.table(table)
+
+	records := make([]memoryRecord, 0, len(t.rows))
+	for _, rec := range t.rows {
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func (st *memoryState) get(table, key string) (memoryRecord, bool) {
+	rec, ok := st.table(table).rows[key]
+	return rec, ok
+}
+
+func (st *memoryState) insert(table string, rec memoryRecord) error {
+	return st.apply(memoryEffect{table: table, key: rec.primaryKey(), record: rec, insert: true})
+}
+
+func (st *memoryState) update(table string, rec memoryRecord) error {
+	return st.apply(memoryEffect{table: table, key: rec.primaryKey(), record: rec})
+}
+
+func (st *memoryState) remove(table, key string) {
+	st.apply(memoryEffect{table: table, key: key})
+}
+
+// apply makes a change to the state checking table constraints. Updates and
+// deletes of missing rows are no-op, same as UPDATE and DELETE statements
+// affecting a row that has been deleted by a concurrent transaction.
+func (st *memoryState) apply(effect memoryEffect) error {
+	t, ok := st.tables[effect.table]
+	if !ok {
+		t = newMemoryTable(0)
+		st.tables[effect.table] = t
+	}
+
+	prev, exists := t.rows[effect.key]
+	if effect.insert && exists {
+		return fmt.Errorf("duplicate key value violates unique constraint \"%s_pkey\"", effect.table)
+	}
+
+	if !effect.insert && !exists {
+		return nil
+	}
+
+	if exists {
+		for _, key := range prev.uniqueKeys() {
+			delete(t.unique, key)
+		}
+		delete(t.rows, effect.key)
+	}
+
+	if effect.record != nil {
+		for _, key := range effect.record.uniqueKeys() {
+			if _, ok := t.unique[key]; ok {
+				// restore the previous version of the row before failing
+				if exists {
+					t.rows[effect.key] = prev
+					for _, key := range prev.uniqueKeys() {
+						t.unique[key] = effect.key
+					}
+				}
+
+				return fmt.Errorf("duplicate key value violates unique constraint on %s (%s)", effect.table, key)
+			}
+		}
+
+		for _, key := range effect.record.uniqueKeys() {
+			t.unique[key] = effect.key
+		}
+		t.rows[effect.key] = effect.record
+	}
+
+	if st.effects != nil {
+		*st.effects = append(*st.effects, effect)
+	}
+
+	return nil
+}
+
+// memoryResult is an outcome of an in-memory command.
+type memoryResult struct {
+	columns  []string
+	values   [][]driver.Value
+	affected int64
+}
+
+// memoryCommand is an operation on in-memory database state identified by its name
+// that is used instead of SQL query.
+type memoryCommand struct {
+	readOnly bool
+	run      func(st *memoryState, seq *memoryStore, args []driver.Value) (*memoryResult, error)
+}
+
+type memoryStore struct {
+	mu      sync.RWMutex
+	state   *memoryState
+	version int64
+
+	seqMu     sync.Mutex
+	sequences map[string]int64
+}
+
+// nextId returns next value of a sequence. Like PostgreSQL sequences, they are
+// not affected by transaction rollback.
+func (store *memoryStore) nextId(table string) int64 {
+	store.seqMu.Lock()
+	defer store.seqMu.Unlock()
+
+	store.sequences[table]++
+
+	return store.sequences[table]
+}
+
+func (store *memoryStore) run(command memoryCommand, args []driver.Value) (*memoryResult, error) {
+	if command.readOnly {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+
+		return command.run(store.state, store, args)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	st := store.state.clone()
+
+	res, err := command.run(st, store, args)
+	if err != nil {
+		return nil, err
+	}
+
+	store.state = st
+	store.version++
+
+	return res, nil
+}
+
+// snapshot returns a copy of committed state along with its version.
+func (store *memoryStore) snapshot() (*memoryState, int64) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.state.clone(), store.version
+}
+
+func (store *memoryStore) commit(effects []memoryEffect) error {
+	if len(effects) == 0 {
+		return nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	st := store.state.clone()
+	for _, effect := range effects {
+		if err := st.apply(effect); err != nil {
+			return err
+		}
+	}
+
+	store.state = st
+	store.version++
+
+	return nil
+}
+
+type memoryConnector struct {
+	store *memoryStore
+}
+
+func (c *memoryConnector) Connect(context.Context) (driver.Conn, error) {
+	return &memoryConn{store: c.store}, nil
+}
+
+func (c *memoryConnector) Driver() driver.Driver {
+	return memoryDriver{}
+}
+
+type memoryDriver struct{}
+
+func (memoryDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("in-memory database can only be opened with OpenMemoryDatabase()")
+}
+
+type memoryConn struct {
+	store *memoryStore
+	tx    *memoryTx
+}
+
+func (c *memoryConn) run(ctx context.Context, query string, args []driver.Value) (*memoryResult, error) {
+	if err := ctx.Err(); err != nil {
+		// a cancelled statement aborts the transaction it belongs to
+		if c.tx != nil && c.tx.err == nil {
+			c.tx.err = err
+		}
+
+		return nil, err
+	}
+
+	command, ok := memoryCommands[query]
+	if !ok {
+		return nil, fmt.Errorf("unknown in-memory command %q", query)
+	}
+
+	if c.tx != nil {
+		return c.tx.run(command, args)
+	}
+
+	return c.store.run(command, args)
+}
+
+func (c *memoryConn) Prepare(query string) (driver.Stmt, error) {
+	if _, ok := memoryCommands[query]; !ok {
+		return nil, fmt.Errorf("unknown in-memory command %q", query)
+	}
+
+	return &memoryStmt{conn: c, query: query}, nil
+}
+
+func (c *memoryConn) Close() error {
+	c.tx = nil
+	return nil
+}
+
+func (c *memoryConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *memoryConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("there is already a transaction in progress")
+	}
+
+	c.tx = &memoryTx{conn: c, version: -1}
+
+	return c.tx, nil
+}
+
+func (c *memoryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.run(ctx, query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (c *memoryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.run(ctx, query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+
+	return &memoryRows{columns: res.columns, values: res.values}, nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
+
+type memoryTx struct {
+	conn *memoryConn
+
+	working *memoryState
+	version int64
+	effects []memoryEffect
+	err     error
+}
+
+// refresh makes changes committed by other transactions since the last statement
+// visible to the transaction.
+func (tx *memoryTx) refresh() error {
+	st, version := tx.conn.store.snapshot()
+	if version == tx.version {
+		return nil
+	}
+
+	for _, effect := range tx.effects {
+		if err := st.apply(effect); err != nil {
+			return err
+		}
+	}
+
+	st.effects = &tx.effects
+	tx.working, tx.version = st, version
+
+	return nil
+}
+
+func (tx *memoryTx) run(command memoryCommand, args []driver.Value) (*memoryResult, error) {
+	if tx.err != nil {
+		return nil, ErrTransactionAborted
+	}
+
+	if err := tx.refresh(); err != nil {
+		tx.err = err
+		return nil, err
+	}
+
+	res, err := command.run(tx.working, tx.conn.store, args)
+	if err != nil {
+		tx.err = err
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (tx *memoryTx) Commit() error {
+	defer func() { tx.conn.tx = nil }()
+
+	if tx.err != nil {
+		return ErrTransactionFailed
+	}
+
+	return tx.conn.store.commit(tx.effects)
+}
+
+func (tx *memoryTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
+
+type memoryStmt struct {
+	conn  *memoryConn
+	query string
+}
+
+func (s *memoryStmt) Close() error {
+	return nil
+}
+
+func (s *memoryStmt) NumInput() int {
+	return -1
+}
+
+func (s *memoryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	res, err := s.conn.run(context.Background(), s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (s *memoryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	res, err := s.conn.run(context.Background(), s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memoryRows{columns: res.columns, values: res.values}, nil
+}
+
+type memoryRows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (rows *memoryRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *memoryRows) Close() error {
+	return nil
+}
+
+func (rows *memoryRows) Next(dest []driver.Value) error {
+	if rows.pos >= len(rows.values) {
+		return io.EOF
+	}
+
+	copy(dest, rows.values[rows.pos])
+	rows.pos++
+
+	return nil
+}
@@ -0,0 +1,35 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/collaborationtest"
+)
+
+func TestMemoryCollaborationService_Conformance(t *testing.T) {
+	collaborationtest.RunConformanceSuite(t, func(t *testing.T) (*sql.DB, blamewarrior.Collaboration, func()) {
+		db := blamewarrior.OpenMemoryDatabase()
+
+		return db, blamewarrior.NewMemoryCollaborationService(), func() { db.Close() }
+	})
+}
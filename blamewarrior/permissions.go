@@ -0,0 +1,258 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Permission is a GitHub repository permission level. Levels are ordered, so that each
+// of them includes all lower ones, e.g. PermissionPush grants PermissionPull as well.
+type Permission int
+
+const (
+	// PermissionNone means no access to the repository.
+	PermissionNone Permission = iota
+	// PermissionPull allows to read and clone the repository.
+	PermissionPull
+	// PermissionTriage allows to manage issues and pull requests without write access.
+	PermissionTriage
+	// PermissionPush allows to push to the repository.
+	PermissionPush
+	// PermissionMaintain allows to manage the repository without access to sensitive actions.
+	PermissionMaintain
+	// PermissionAdmin grants full access to the repository.
+	PermissionAdmin
+)
+
+var permissionNames = map[Permission]string{
+	PermissionNone:     "none",
+	PermissionPull:     "pull",
+	PermissionTriage:   "triage",
+	PermissionPush:     "push",
+	PermissionMaintain: "maintain",
+	PermissionAdmin:    "admin",
+}
+
+func (p Permission) String() string {
+	if name, ok := permissionNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Permission(%d)", int(p))
+}
+
+// ParsePermission returns the permission level with given name.
+func ParsePermission(s string) (Permission, error) {
+	for p, name := range permissionNames {
+		if p != PermissionNone && name == s {
+			return p, nil
+		}
+	}
+
+	return PermissionNone, &UnknownPermissionError{Name: s}
+}
+
+// UnknownPermissionError is returned when permissions contain a key that is not
+// one of GitHub repository permissions.
+type UnknownPermissionError struct {
+	Name string
+}
+
+func (e *UnknownPermissionError) Error() string {
+	return fmt.Sprintf("unknown permission %q", e.Name)
+}
+
+// AccountPermissions are repository permissions granted to a collaborator. GitHub reports
+// every level that is included into the granted one, so an admin usually has all of them set.
+type AccountPermissions struct {
+	Pull     bool
+	Triage   bool
+	Push     bool
+	Maintain bool
+	Admin    bool
+}
+
+// PermissionsFromNames returns permissions with all named levels granted.
+func PermissionsFromNames(names []string) (AccountPermissions, error) {
+	var perms AccountPermissions
+
+	for _, name := range names {
+		p, err := ParsePermission(name)
+		if err != nil {
+			return AccountPermissions{}, err
+		}
+
+		perms.Grant(p)
+	}
+
+	return perms, nil
+}
+
+// PermissionsFromMap converts permissions reported by GitHub API. Unknown keys result in
+// UnknownPermissionError, however the returned value still has known permissions set.
+func PermissionsFromMap(m map[string]bool) (AccountPermissions, error) {
+	var (
+		perms   AccountPermissions
+		unknown []string
+	)
+
+	for name, granted := range m {
+		p, err := ParsePermission(name)
+		if err != nil {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if granted {
+			perms.Grant(p)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return perms, &UnknownPermissionError{Name: unknown[0]}
+	}
+
+	return perms, nil
+}
+
+func (perms *AccountPermissions) field(p Permission) *bool {
+	switch p {
+	case PermissionPull:
+		return &perms.Pull
+	case PermissionTriage:
+		return &perms.Triage
+	case PermissionPush:
+		return &perms.Push
+	case PermissionMaintain:
+		return &perms.Maintain
+	case PermissionAdmin:
+		return &perms.Admin
+	}
+
+	return nil
+}
+
+// Has checks whether the permission level p has been explicitly granted.
+func (perms AccountPermissions) Has(p Permission) bool {
+	if f := perms.field(p); f != nil {
+		return *f
+	}
+
+	return false
+}
+
+// Grant sets the permission level p.
+func (perms *AccountPermissions) Grant(p Permission) {
+	if f := perms.field(p); f != nil {
+		*f = true
+	}
+}
+
+// Role returns the highest granted permission level.
+func (perms AccountPermissions) Role() Permission {
+	for p := PermissionAdmin; p > PermissionNone; p-- {
+		if perms.Has(p) {
+			return p
+		}
+	}
+
+	return PermissionNone
+}
+
+// AtLeast checks whether the highest granted level is p or above.
+func (perms AccountPermissions) AtLeast(p Permission) bool {
+	return perms.Role() >= p
+}
+
+// Names returns names of granted permission levels in alphabetical order.
+func (perms AccountPermissions) Names() []string {
+	names := []string{}
+	for p := PermissionPull; p <= PermissionAdmin; p++ {
+		if perms.Has(p) {
+			names = append(names, p.String())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// MarshalJSON encodes granted permissions as an object, e.g. {"pull":true,"push":true}.
+func (perms AccountPermissions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]bool)
+	for _, name := range perms.Names() {
+		m[name] = true
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes permissions object returning UnknownPermissionError for keys
+// that are not GitHub repository permissions.
+func (perms *AccountPermissions) UnmarshalJSON(b []byte) error {
+	var m map[string]bool
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	p, err := PermissionsFromMap(m)
+	if err != nil {
+		return err
+	}
+
+	*perms = p
+
+	return nil
+}
+
+func (perms AccountPermissions) Value() (driver.Value, error) {
+	b, err := json.Marshal(perms)
+	return b, err
+}
+
+// Scan reads permissions stored as a JSON object. Rows written before permissions were
+// validated may contain arbitrary keys, those are ignored.
+func (perms *AccountPermissions) Scan(src interface{}) error {
+	var source []byte
+
+	switch src := src.(type) {
+	case []byte:
+		source = src
+	case string:
+		source = []byte(src)
+	default:
+		return errors.New("Type assertion .([]byte) failed.")
+	}
+
+	var m map[string]bool
+	if err := json.Unmarshal(source, &m); err != nil {
+		return err
+	}
+
+	*perms, _ = PermissionsFromMap(m)
+
+	return nil
+}
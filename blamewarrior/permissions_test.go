@@ -0,0 +1,122 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+func TestParsePermission(t *testing.T) {
+	for _, p := range []blamewarrior.Permission{
+		blamewarrior.PermissionPull,
+		blamewarrior.PermissionTriage,
+		blamewarrior.PermissionPush,
+		blamewarrior.PermissionMaintain,
+		blamewarrior.PermissionAdmin,
+	} {
+		parsed, err := blamewarrior.ParsePermission(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	_, err := blamewarrior.ParsePermission("none")
+	assert.Equal(t, &blamewarrior.UnknownPermissionError{Name: "none"}, err)
+
+	_, err = blamewarrior.ParsePermission("pusj")
+	assert.Equal(t, &blamewarrior.UnknownPermissionError{Name: "pusj"}, err)
+}
+
+func TestAccountPermissions_Role(t *testing.T) {
+	results := []struct {
+		Permissions blamewarrior.AccountPermissions
+		Role        blamewarrior.Permission
+	}{
+		{blamewarrior.AccountPermissions{}, blamewarrior.PermissionNone},
+		{blamewarrior.AccountPermissions{Pull: true}, blamewarrior.PermissionPull},
+		{blamewarrior.AccountPermissions{Pull: true, Triage: true}, blamewarrior.PermissionTriage},
+		{blamewarrior.AccountPermissions{Pull: true, Push: true}, blamewarrior.PermissionPush},
+		{blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true, Maintain: true}, blamewarrior.PermissionMaintain},
+		{blamewarrior.AccountPermissions{Admin: true}, blamewarrior.PermissionAdmin},
+	}
+
+	for _, result := range results {
+		assert.Equal(t, result.Role, result.Permissions.Role(), "%+v", result.Permissions)
+	}
+
+	perms := blamewarrior.AccountPermissions{Pull: true, Push: true}
+	assert.True(t, perms.AtLeast(blamewarrior.PermissionPull))
+	assert.True(t, perms.AtLeast(blamewarrior.PermissionTriage))
+	assert.True(t, perms.AtLeast(blamewarrior.PermissionPush))
+	assert.False(t, perms.AtLeast(blamewarrior.PermissionMaintain))
+	assert.False(t, perms.AtLeast(blamewarrior.PermissionAdmin))
+}
+
+func TestAccountPermissions_JSON(t *testing.T) {
+	b, err := json.Marshal(blamewarrior.AccountPermissions{Pull: true, Push: true, Admin: true})
+	require.NoError(t, err)
+	assert.Equal(t, `{"admin":true,"pull":true,"push":true}`, string(b))
+
+	b, err = json.Marshal(blamewarrior.AccountPermissions{})
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(b))
+
+	var perms blamewarrior.AccountPermissions
+
+	require.NoError(t, json.Unmarshal([]byte(`{"pull": true, "push": true, "admin": false}`), &perms))
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true, Push: true}, perms)
+
+	err = json.Unmarshal([]byte(`{"pull": true, "pusj": true}`), &perms)
+	assert.Equal(t, &blamewarrior.UnknownPermissionError{Name: "pusj"}, err)
+
+	err = json.Unmarshal([]byte(`{"pull": "yes"}`), &perms)
+	assert.Error(t, err)
+}
+
+func TestAccountPermissions_Scan(t *testing.T) {
+	results := []struct {
+		Source      interface{}
+		Permissions blamewarrior.AccountPermissions
+	}{
+		{[]byte(`{"pull": true, "push": true, "admin": false}`), blamewarrior.AccountPermissions{Pull: true, Push: true}},
+		{`{"admin": true}`, blamewarrior.AccountPermissions{Admin: true}},
+		// rows stored before permissions were validated
+		{[]byte(`{"pull": true, "pusj": true}`), blamewarrior.AccountPermissions{Pull: true}},
+		{[]byte(`null`), blamewarrior.AccountPermissions{}},
+	}
+
+	for _, result := range results {
+		var perms blamewarrior.AccountPermissions
+
+		require.NoError(t, perms.Scan(result.Source), "%s", result.Source)
+		assert.Equal(t, result.Permissions, perms, "%s", result.Source)
+	}
+
+	var perms blamewarrior.AccountPermissions
+	assert.Error(t, perms.Scan(42))
+
+	value, err := blamewarrior.AccountPermissions{Pull: true, Maintain: true}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"maintain":true,"pull":true}`), value)
+}
@@ -0,0 +1,55 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"errors"
+)
+
+// Providers are code hosting services repositories are synchronized with.
+const (
+	// ProviderGitHub is GitHub or GitHub Enterprise Server.
+	ProviderGitHub = "github"
+	// ProviderGitLab is GitLab or a self-managed GitLab instance.
+	ProviderGitLab = "gitlab"
+)
+
+// ErrProviderMismatch is returned when a repository is synchronized with a provider other than
+// the one it has been synchronized with before.
+var ErrProviderMismatch = errors.New("repository belongs to another provider")
+
+// Provider lists members of repositories hosted by a code hosting service.
+type Provider interface {
+	// Name returns one of Provider* constants.
+	Name() string
+	// RepositoryMembers returns accounts that have access to a repository. Permissions of
+	// members are mapped to GitHub permission levels, profiles are filled in if the provider
+	// returns them along with members.
+	RepositoryMembers(ctx context.Context, fullName string) ([]Account, error)
+}
+
+// NameValidator is implemented by providers whose repository and user names follow rules
+// other than GitHub ones.
+type NameValidator interface {
+	// ValidateRepositoryName checks a repository name qualified with the provider host name.
+	ValidateRepositoryName(fullName string) error
+	// ValidateLogin checks a name of a repository member.
+	ValidateLogin(login string) error
+}
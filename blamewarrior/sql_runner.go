@@ -18,11 +18,20 @@
 */
 package blamewarrior
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
+// SQLRunner is implemented by both *sql.DB and *sql.Tx.
 type SQLRunner interface {
 	Query(string, ...interface{}) (*sql.Rows, error)
 	QueryRow(string, ...interface{}) *sql.Row
 	Prepare(string) (*sql.Stmt, error)
 	Exec(string, ...interface{}) (sql.Result, error)
+
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
 }
@@ -0,0 +1,486 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDialect is the dialect of SQLite database opened with OpenSQLiteDatabase().
+var SQLiteDialect = &Dialect{
+	Name: "sqlite",
+
+	CreateRepositoryQuery:      sqliteQuery(CreateRepositoryQuery),
+	SetRepositoryProviderQuery: sqliteQuery(SetRepositoryProviderQuery),
+	ResetTeamMembersQuery:      sqliteQuery(ResetTeamMembersQuery),
+	ResetTeamsQuery:            sqliteQuery(ResetTeamsQuery),
+	ResetCollaborationQuery:    sqliteQuery(ResetCollaborationQuery),
+	ResetInvitationsQuery:      sqliteQuery(ResetInvitationsQuery),
+	GetListRepositoriesQuery: `
+    SELECT full_name FROM repositories
+      WHERE ?1 = '' OR lower(substr(full_name, 1, length(?1) + 1)) = lower(?1 || '/')
+      ORDER BY full_name
+  `,
+
+	GetListAccountsQuery: `
+     SELECT accounts.id, accounts.uid, accounts.login, collaboration.permissions, collaboration.affiliation,
+         (
+           SELECT json_group_array(teams.slug ORDER BY teams.slug) FROM teams
+           INNER JOIN team_members ON teams.id = team_members.team_id
+           WHERE teams.repository_id = repositories.id AND team_members.account_id = accounts.id
+         ),
+         ifnull(accounts.name, ''), ifnull(accounts.avatar_url, ''), ifnull(accounts.type, ''), accounts.site_admin
+         FROM accounts
+         INNER JOIN collaboration ON accounts.id = collaboration.account_id
+         INNER JOIN repositories ON collaboration.repository_id = repositories.id
+         WHERE repositories.full_name = ?1
+         ORDER BY accounts.login
+   `,
+	FindAccountQuery:    sqliteQuery(FindAccountQuery),
+	AddAccountQuery:     sqliteQuery(AddAccountQuery),
+	RefreshAccountQuery: sqliteQuery(RefreshAccountQuery),
+	BuildCollaborationQuery: `
+    INSERT INTO collaboration (repository_id, account_id, affiliation, permissions)
+      SELECT id, ?2, ?3, ?4 FROM repositories WHERE full_name = ?1
+  `,
+	EditAccountQuery:       sqliteQuery(EditAccountQuery),
+	EditCollaborationQuery: sqliteQuery(EditCollaborationQuery),
+	DisconnectAccountQuery: `
+    DELETE FROM collaboration
+      WHERE account_id IN (SELECT id FROM accounts WHERE login = ?2)
+        AND repository_id = (SELECT id FROM repositories WHERE full_name = ?1 LIMIT 1)
+  `,
+
+	UpdateProfileQuery: sqliteQuery(UpdateProfileQuery),
+	// SQLite sorts NULL values first
+	GetListOutdatedProfilesQuery: `
+      SELECT accounts.login FROM accounts
+        INNER JOIN collaboration ON accounts.id = collaboration.account_id
+        INNER JOIN repositories ON collaboration.repository_id = repositories.id
+        WHERE repositories.full_name = ?1 AND (accounts.profile_updated_at IS NULL OR accounts.profile_updated_at < ?2)
+        ORDER BY accounts.profile_updated_at, accounts.login
+        LIMIT ?3
+  `,
+
+	AddTeamQuery: sqliteQuery(AddTeamQuery),
+	AddTeamMemberQuery: `
+    INSERT INTO team_members (team_id, account_id)
+      SELECT teams.id, ?3 FROM teams
+      INNER JOIN repositories ON teams.repository_id = repositories.id
+      WHERE repositories.full_name = ?1 AND teams.slug = ?2
+  `,
+	GetListTeamsQuery:       sqliteQuery(GetListTeamsQuery),
+	GetListTeamMembersQuery: sqliteQuery(GetListTeamMembersQuery),
+
+	AddInvitationQuery:      sqliteQuery(AddInvitationQuery),
+	GetListInvitationsQuery: sqliteQuery(GetListInvitationsQuery),
+	PromoteInvitationQuery:  sqliteQuery(PromoteInvitationQuery),
+
+	StringList: func(dest *[]string) sql.Scanner {
+		return (*jsonStringList)(dest)
+	},
+}
+
+// sqliteMigrations are applied in order to a database opened with OpenSQLiteDatabase().
+// The number of applied migrations is stored as the database user_version, so existing
+// migrations should never be changed, add a new one instead.
+var sqliteMigrations = []string{
+	`
+    CREATE TABLE repositories (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        full_name varchar(255),
+        UNIQUE (full_name)
+    );
+
+    CREATE TABLE accounts (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        uid integer,
+        login varchar(255),
+        permissions text
+    );
+
+    CREATE INDEX accounts_login ON accounts (login);
+
+    CREATE TABLE collaboration (
+        repository_id integer NOT NULL REFERENCES repositories(id),
+        account_id integer NOT NULL REFERENCES accounts(id),
+        affiliation varchar(16) NOT NULL DEFAULT 'direct',
+        UNIQUE (repository_id, account_id)
+    );
+
+    CREATE TABLE teams (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        repository_id integer NOT NULL REFERENCES repositories(id),
+        uid integer NOT NULL,
+        name varchar(255),
+        slug varchar(255) NOT NULL,
+        permission varchar(32),
+        UNIQUE (repository_id, uid),
+        UNIQUE (repository_id, slug)
+    );
+
+    CREATE TABLE team_members (
+        team_id integer NOT NULL REFERENCES teams(id),
+        account_id integer NOT NULL REFERENCES accounts(id),
+        UNIQUE (team_id, account_id)
+    );
+
+    CREATE TABLE invitations (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        repository_id integer NOT NULL REFERENCES repositories(id),
+        uid integer NOT NULL,
+        invitee_uid integer,
+        invitee_login varchar(255) NOT NULL,
+        inviter_login varchar(255),
+        permission varchar(32),
+        created_at timestamp NOT NULL,
+        expires_at timestamp NOT NULL,
+        UNIQUE (repository_id, uid)
+    );
+  `,
+	// Repository names and logins are compared case-insensitively, same as GitHub does.
+	// Repositories and accounts that only differ in case are merged into the oldest one,
+	// teams and invitations of merged repositories are restored by the next sync.
+	`
+    CREATE TABLE repositories_nocase (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        full_name varchar(255) COLLATE NOCASE,
+        UNIQUE (full_name)
+    );
+
+    INSERT INTO repositories_nocase (id, full_name)
+      SELECT min(id), full_name FROM repositories GROUP BY lower(full_name);
+
+    CREATE TEMPORARY TABLE repository_merges AS
+      SELECT repositories.id AS old_id, repositories_nocase.id AS new_id
+        FROM repositories
+        JOIN repositories_nocase ON repositories_nocase.full_name = repositories.full_name
+        WHERE repositories.id <> repositories_nocase.id;
+
+    INSERT OR IGNORE INTO collaboration (repository_id, account_id, affiliation)
+      SELECT repository_merges.new_id, collaboration.account_id, collaboration.affiliation
+        FROM collaboration
+        JOIN repository_merges ON repository_merges.old_id = collaboration.repository_id;
+
+    DELETE FROM collaboration WHERE repository_id IN (SELECT old_id FROM repository_merges);
+    DELETE FROM team_members WHERE team_id IN (
+      SELECT id FROM teams WHERE repository_id IN (SELECT old_id FROM repository_merges)
+    );
+    DELETE FROM teams WHERE repository_id IN (SELECT old_id FROM repository_merges);
+    DELETE FROM invitations WHERE repository_id IN (SELECT old_id FROM repository_merges);
+
+    DROP TABLE repository_merges;
+    DROP TABLE repositories;
+    ALTER TABLE repositories_nocase RENAME TO repositories;
+
+    CREATE TABLE accounts_nocase (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        uid integer,
+        login varchar(255) COLLATE NOCASE,
+        permissions text,
+        UNIQUE (login)
+    );
+
+    INSERT INTO accounts_nocase (id, uid, login, permissions)
+      SELECT min(id), uid, login, permissions FROM accounts GROUP BY lower(login);
+
+    CREATE TEMPORARY TABLE account_merges AS
+      SELECT accounts.id AS old_id, accounts_nocase.id AS new_id
+        FROM accounts
+        JOIN accounts_nocase ON accounts_nocase.login = accounts.login
+        WHERE accounts.id <> accounts_nocase.id;
+
+    INSERT OR IGNORE INTO collaboration (repository_id, account_id, affiliation)
+      SELECT collaboration.repository_id, account_merges.new_id, collaboration.affiliation
+        FROM collaboration
+        JOIN account_merges ON account_merges.old_id = collaboration.account_id;
+
+    INSERT OR IGNORE INTO team_members (team_id, account_id)
+      SELECT team_members.team_id, account_merges.new_id
+        FROM team_members
+        JOIN account_merges ON account_merges.old_id = team_members.account_id;
+
+    DELETE FROM collaboration WHERE account_id IN (SELECT old_id FROM account_merges);
+    DELETE FROM team_members WHERE account_id IN (SELECT old_id FROM account_merges);
+
+    DROP TABLE account_merges;
+    DROP TABLE accounts;
+    ALTER TABLE accounts_nocase RENAME TO accounts;
+
+    CREATE TABLE invitations_nocase (
+        id integer PRIMARY KEY AUTOINCREMENT,
+        repository_id integer NOT NULL REFERENCES repositories(id),
+        uid integer NOT NULL,
+        invitee_uid integer,
+        invitee_login varchar(255) NOT NULL COLLATE NOCASE,
+        inviter_login varchar(255) COLLATE NOCASE,
+        permission varchar(32),
+        created_at timestamp NOT NULL,
+        expires_at timestamp NOT NULL,
+        UNIQUE (repository_id, uid)
+    );
+
+    INSERT INTO invitations_nocase SELECT * FROM invitations;
+
+    DROP TABLE invitations;
+    ALTER TABLE invitations_nocase RENAME TO invitations;
+  `,
+	// GitHub profiles of accounts
+	`
+    ALTER TABLE accounts ADD COLUMN name varchar(255);
+    ALTER TABLE accounts ADD COLUMN avatar_url varchar(2048);
+    ALTER TABLE accounts ADD COLUMN type varchar(16);
+    ALTER TABLE accounts ADD COLUMN site_admin boolean NOT NULL DEFAULT 0;
+    ALTER TABLE accounts ADD COLUMN profile_updated_at timestamp;
+  `,
+	// Providers of repositories, all of the existing ones have been synchronized with GitHub
+	`
+    ALTER TABLE repositories ADD COLUMN provider varchar(16);
+
+    UPDATE repositories SET provider = 'github';
+  `,
+	// Permissions are granted to collaborators per repository
+	`
+    ALTER TABLE collaboration ADD COLUMN permissions text;
+
+    UPDATE collaboration SET permissions = (SELECT permissions FROM accounts WHERE accounts.id = collaboration.account_id);
+
+    ALTER TABLE accounts DROP COLUMN permissions;
+  `,
+}
+
+// sqliteConnectionParams make SQLite connections wait for each other instead of failing
+// with "database is locked" and let readers work alongside with a writer.
+const sqliteConnectionParams = "_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL&_txlock=immediate"
+
+// OpenSQLiteDatabase opens an SQLite database stored in a file at path, creating it if
+// necessary, and applies pending migrations. The database is meant to be used with a
+// CollaborationService that uses SQLiteDialect.
+//
+// Unlike SQLite itself, a transaction opened with the returned handle is aborted once
+// any of its statements fails, same as in PostgreSQL.
+func OpenSQLiteDatabase(path string) (*sql.DB, error) {
+	db := sql.OpenDB(&sqliteConnector{
+		dsn:    "file:" + path + "?" + sqliteConnectionParams,
+		driver: &sqlite3.SQLiteDriver{},
+	})
+
+	if err := migrateSQLiteDatabase(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite database %s: %s", path, err)
+	}
+
+	return db, nil
+}
+
+// NewSQLiteCollaborationService returns a CollaborationService for a database opened with OpenSQLiteDatabase().
+func NewSQLiteCollaborationService() *CollaborationService {
+	return NewDialectCollaborationService(SQLiteDialect)
+}
+
+func migrateSQLiteDatabase(db *sql.DB) error {
+	ctx := context.Background()
+
+	// migrations are applied using a single connection, since foreign keys enforcement
+	// has to be disabled outside of a transaction for tables to be rebuilt
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	if version >= len(sqliteMigrations) {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if err := applySQLiteMigration(ctx, conn, i+1, sqliteMigrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applySQLiteMigration(ctx context.Context, conn *sql.Conn, version int, migration string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return fmt.Errorf("migration %d failed: %s", version, err)
+	}
+
+	var (
+		table, parent string
+		rowId         sql.NullInt64
+		fkId          int
+	)
+
+	err = tx.QueryRowContext(ctx, "PRAGMA foreign_key_check").Scan(&table, &rowId, &parent, &fkId)
+	if err == nil {
+		return fmt.Errorf("migration %d failed: row %d of %s references missing %s", version, rowId.Int64, table, parent)
+	}
+
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// sqliteQuery converts $n placeholders of a PostgreSQL query into SQLite ?n ones, since SQLite
+// treats $n as a named parameter and assigns it a position in order of appearance.
+func sqliteQuery(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteConn{SQLiteConn: conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sqliteConn keeps track of failed statements within a transaction to reject the
+// rest of them, since SQLite only rolls back the failed statement itself.
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+	tx *sqliteTx
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.SQLiteConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.tx = &sqliteTx{Tx: tx, conn: c}
+
+	return c.tx, nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.tx != nil && c.tx.err != nil {
+		return nil, ErrTransactionAborted
+	}
+
+	res, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	if err != nil && c.tx != nil {
+		c.tx.err = err
+	}
+
+	return res, err
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.tx != nil && c.tx.err != nil {
+		return nil, ErrTransactionAborted
+	}
+
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		if c.tx != nil {
+			c.tx.err = err
+		}
+
+		return nil, err
+	}
+
+	return &sqliteRows{Rows: rows, tx: c.tx}, nil
+}
+
+// sqliteRows aborts the transaction if a statement fails while its results are read,
+// as it happens with INSERT ... RETURNING violating a constraint.
+type sqliteRows struct {
+	driver.Rows
+	tx *sqliteTx
+}
+
+func (rows *sqliteRows) Next(dest []driver.Value) error {
+	err := rows.Rows.Next(dest)
+	if err != nil && err != io.EOF && rows.tx != nil && rows.tx.err == nil {
+		rows.tx.err = err
+	}
+
+	return err
+}
+
+type sqliteTx struct {
+	driver.Tx
+	conn *sqliteConn
+	err  error
+}
+
+func (tx *sqliteTx) Commit() error {
+	tx.conn.tx = nil
+
+	if tx.err != nil {
+		tx.Tx.Rollback()
+		return ErrTransactionFailed
+	}
+
+	return tx.Tx.Commit()
+}
+
+func (tx *sqliteTx) Rollback() error {
+	tx.conn.tx = nil
+	return tx.Tx.Rollback()
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/collaborationtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteCollaborationService_Conformance(t *testing.T) {
+	collaborationtest.RunConformanceSuite(t, func(t *testing.T) (*sql.DB, blamewarrior.Collaboration, func()) {
+		dir, err := ioutil.TempDir("", "collaborators")
+		require.NoError(t, err)
+
+		db, err := blamewarrior.OpenSQLiteDatabase(filepath.Join(dir, "collaborators.db"))
+		require.NoError(t, err)
+
+		return db, blamewarrior.NewSQLiteCollaborationService(), func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestOpenSQLiteDatabase_Migrated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collaborators")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collaborators.db")
+
+	db, err := blamewarrior.OpenSQLiteDatabase(path)
+	require.NoError(t, err)
+	require.NoError(t, blamewarrior.NewSQLiteCollaborationService().CreateRepository(context.Background(), db, "blamewarrior/repos"))
+	require.NoError(t, db.Close())
+
+	// reopening the database keeps the data and does not apply migrations again
+	db, err = blamewarrior.OpenSQLiteDatabase(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	teams, err := blamewarrior.NewSQLiteCollaborationService().ListTeams(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Empty(t, teams)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM repositories").Scan(&count))
+	require.Equal(t, 1, count)
+}
+
+func TestOpenSQLiteDatabase_MergesNamesDifferingInCase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collaborators")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collaborators.db")
+
+	// a database created before names were compared case-insensitively
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=1")
+	require.NoError(t, err)
+
+	for _, query := range []string{
+		blamewarrior.SQLiteMigrations[0],
+		"PRAGMA user_version = 1",
+		"INSERT INTO repositories (id, full_name) VALUES (1, 'BlameWarrior/Repos'), (2, 'blamewarrior/repos'), (3, 'blamewarrior/hooks')",
+		`INSERT INTO accounts (id, uid, login, permissions) VALUES (1, 1, 'OctoCat', '{"pull":true}'), (2, 1, 'octocat', '{"push":true}'), (3, 2, 'hubot', '{}')`,
+		"INSERT INTO collaboration (repository_id, account_id) VALUES (1, 1), (2, 2), (2, 3), (3, 2)",
+		"INSERT INTO teams (id, repository_id, uid, name, slug) VALUES (1, 2, 1, 'Developers', 'developers')",
+		"INSERT INTO team_members (team_id, account_id) VALUES (1, 2)",
+	} {
+		_, err := db.Exec(query)
+		require.NoError(t, err, query)
+	}
+	require.NoError(t, db.Close())
+
+	db, err = blamewarrior.OpenSQLiteDatabase(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	collaboration := blamewarrior.NewSQLiteCollaborationService()
+
+	repositories, err := collaboration.ListRepositories(context.Background(), db, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blamewarrior/hooks", "BlameWarrior/Repos"}, repositories)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "hubot", accounts[0].Login)
+	assert.Equal(t, "OctoCat", accounts[1].Login)
+	// permissions of accounts are moved to their collaborations
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[1].Permissions)
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "OctoCat", accounts[0].Login)
+
+	teams, err := collaboration.ListTeams(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM team_members").Scan(&count))
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,162 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Team represents GitHub team that has been granted access to a repository.
+type Team struct {
+	Id         int    `json:"-"`
+	Uid        int    `json:"uid"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Permission string `json:"permission"`
+}
+
+func (service *CollaborationService) AddTeam(ctx context.Context, tx *sql.Tx, repositoryFullName string, team *Team) (*Team, error) {
+	err := tx.QueryRowContext(ctx, service.queries().AddTeamQuery,
+		repositoryFullName,
+		team.Uid,
+		team.Name,
+		team.Slug,
+		team.Permission,
+	).Scan(&team.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create team: %s", err)
+	}
+
+	return team, nil
+}
+
+func (service *CollaborationService) AddTeamMember(ctx context.Context, tx *sql.Tx, repositoryFullName, teamSlug string, account *Account) error {
+	if _, err := service.findOrCreateAccount(ctx, tx, account); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, service.queries().AddTeamMemberQuery, repositoryFullName, teamSlug, account.Id); err != nil {
+		return fmt.Errorf("failed to create team membership: %s", err)
+	}
+
+	return nil
+}
+
+func (service *CollaborationService) ListTeams(ctx context.Context, sqlRunner SQLRunner, repositoryFullName string) ([]Team, error) {
+	teams := make([]Team, 0)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListTeamsQuery, repositoryFullName)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		team := Team{}
+
+		if err := rows.Scan(
+			&team.Id,
+			&team.Uid,
+			&team.Name,
+			&team.Slug,
+			&team.Permission,
+		); err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, team)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// ListTeamMembers returns members of a repository team along with permissions granted to them in
+// the repository. Members that are not collaborators of the repository have no permissions.
+func (service *CollaborationService) ListTeamMembers(ctx context.Context, sqlRunner SQLRunner, repositoryFullName, teamSlug string) ([]Account, error) {
+	accounts := make([]Account, 0)
+	rows, err := sqlRunner.QueryContext(ctx, service.queries().GetListTeamMembersQuery, repositoryFullName, teamSlug)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		account := Account{}
+
+		if err := rows.Scan(
+			&account.Id,
+			&account.Uid,
+			&account.Login,
+			&account.Permissions,
+		); err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+const (
+	AddTeamQuery = `
+    INSERT INTO teams(repository_id, uid, name, slug, permission)
+      SELECT id, $2, $3, $4, $5 FROM repositories WHERE full_name = $1
+      RETURNING id
+  `
+
+	AddTeamMemberQuery = `
+    INSERT INTO team_members (
+      SELECT teams.id, $3::int FROM teams
+      INNER JOIN repositories ON teams.repository_id = repositories.id
+      WHERE repositories.full_name = $1 AND teams.slug = $2
+    )
+  `
+
+	GetListTeamsQuery = `
+     SELECT teams.id, teams.uid, teams.name, teams.slug, teams.permission
+         FROM teams
+         INNER JOIN repositories ON teams.repository_id = repositories.id
+         WHERE repositories.full_name = $1
+         ORDER BY teams.slug
+   `
+
+	GetListTeamMembersQuery = `
+     SELECT accounts.id, accounts.uid, accounts.login, COALESCE(collaboration.permissions, '{}')
+         FROM accounts
+         INNER JOIN team_members ON accounts.id = team_members.account_id
+         INNER JOIN teams ON team_members.team_id = teams.id
+         INNER JOIN repositories ON teams.repository_id = repositories.id
+         LEFT JOIN collaboration ON collaboration.repository_id = repositories.id AND collaboration.account_id = accounts.id
+         WHERE repositories.full_name = $1 AND teams.slug = $2
+         ORDER BY accounts.login
+   `
+)
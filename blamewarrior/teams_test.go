@@ -0,0 +1,141 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package blamewarrior_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryAddTeam(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	var repositoryId int
+	err = db.QueryRow(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos").Scan(&repositoryId)
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	team, err := repositoriesService.AddTeam(context.Background(), db, "blamewarrior/repos", &blamewarrior.Team{
+		Uid:        1,
+		Name:       "Developers",
+		Slug:       "developers",
+		Permission: "push",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, team.Id)
+
+	var obtainedRepositoryId int
+	err = db.QueryRow("SELECT repository_id FROM teams WHERE id = $1", team.Id).Scan(&obtainedRepositoryId)
+	require.NoError(t, err)
+	assert.Equal(t, repositoryId, obtainedRepositoryId)
+
+	_, err = repositoriesService.AddTeam(context.Background(), db, "blamewarrior/unknown", &blamewarrior.Team{Uid: 2, Slug: "owners"})
+	assert.Error(t, err)
+}
+
+func TestRepositoryListTeams(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
+	require.NoError(t, err)
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/hooks")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.AddTeamQuery, "blamewarrior/repos", 1, "Owners", "owners", "admin")
+	require.NoError(t, err)
+	_, err = db.Exec(blamewarrior.AddTeamQuery, "blamewarrior/repos", 2, "Developers", "developers", "push")
+	require.NoError(t, err)
+	_, err = db.Exec(blamewarrior.AddTeamQuery, "blamewarrior/hooks", 3, "Hooks", "hooks", "pull")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+	teams, err := repositoriesService.ListTeams(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	require.Len(t, teams, 2)
+	assert.Equal(t, "developers", teams[0].Slug)
+	assert.Equal(t, "push", teams[0].Permission)
+	assert.Equal(t, "owners", teams[1].Slug)
+	assert.Equal(t, "admin", teams[1].Permission)
+}
+
+func TestRepositoryListTeamMembers(t *testing.T) {
+	db, teardown := setup()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	_, err = db.Exec(blamewarrior.CreateRepositoryQuery, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	repositoriesService := blamewarrior.NewCollaborationService()
+
+	_, err = repositoriesService.AddAccount(context.Background(), db, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         123,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Push: true},
+	})
+	require.NoError(t, err)
+
+	_, err = repositoriesService.AddAccount(context.Background(), db, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         124,
+		Login:       "hubot",
+		Permissions: blamewarrior.AccountPermissions{Admin: true},
+	})
+	require.NoError(t, err)
+
+	_, err = repositoriesService.AddTeam(context.Background(), db, "blamewarrior/repos", &blamewarrior.Team{Uid: 1, Slug: "developers", Permission: "push"})
+	require.NoError(t, err)
+
+	err = repositoriesService.AddTeamMember(context.Background(), db, "blamewarrior/repos", "developers", &blamewarrior.Account{Uid: 123, Login: "octocat"})
+	require.NoError(t, err)
+
+	members, err := repositoriesService.ListTeamMembers(context.Background(), db, "blamewarrior/repos", "developers")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "octocat", members[0].Login)
+
+	accounts, err := repositoriesService.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	for _, account := range accounts {
+		switch account.Login {
+		case "octocat":
+			assert.Equal(t, []string{"developers"}, account.Teams)
+		case "hubot":
+			assert.Empty(t, account.Teams)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tokens
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blamewarrior/collaborators/backoff"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultGitHubAPIURL is the GitHub API endpoint AppClient uses by default.
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	// InstallationTokenExpiryMargin is the time before expiration of an installation
+	// token when AppClient stops using it and requests a new one.
+	InstallationTokenExpiryMargin = 5 * time.Minute
+
+	// appJWTLifetime is the lifetime of JWT used to authenticate as a GitHub App,
+	// GitHub does not accept ones that live longer than 10 minutes
+	appJWTLifetime = 9 * time.Minute
+	// appJWTClockSkew is subtracted from JWT issue time to allow for clock drift
+	appJWTClockSkew = time.Minute
+)
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// AppClient is a Client that authenticates as a GitHub App and returns access tokens of
+// the app installation for given user or organization. Tokens are cached until shortly
+// before they expire. Requests that fail because of network errors or 5xx responses are
+// retried the same way TokenClient does.
+type AppClient struct {
+	BaseURL    string
+	AppID      int64
+	HTTPClient *http.Client
+
+	// MaxRetries limits the number of times a request is repeated after a failure.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, see TokenClient.RetryDelay.
+	RetryDelay time.Duration
+
+	key   *rsa.PrivateKey
+	now   func() time.Time
+	group singleflight.Group
+
+	mu     sync.Mutex
+	tokens map[string]installationToken
+}
+
+// NewAppClient returns a client for GitHub App with given id and a PEM-encoded private key
+// in PKCS#1 or PKCS#8 format.
+func NewAppClient(appID int64, privateKey []byte) (*AppClient, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &AppClient{
+		BaseURL:    DefaultGitHubAPIURL,
+		AppID:      appID,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+		key:        key,
+		now:        time.Now,
+		tokens:     make(map[string]installationToken),
+	}
+
+	return client, nil
+}
+
+// GetToken returns an access token of the app installation for given user or organization.
+// ErrUserNotFound is returned if the app is not installed for it, and ErrServiceUnavailable
+// if GitHub keeps failing. Installations are looked up on github.com only, so owners qualified
+// with a GitHub Enterprise Server host get ErrUserNotFound without sending any requests.
+func (client *AppClient) GetToken(nickname string) (token string, err error) {
+	if strings.Contains(nickname, "/") {
+		return "", ErrUserNotFound
+	}
+
+	key := strings.ToLower(nickname)
+
+	if token, ok := client.cachedToken(key); ok {
+		return token, nil
+	}
+
+	v, err, _ := client.group.Do(key, func() (interface{}, error) {
+		if token, ok := client.cachedToken(key); ok {
+			return token, nil
+		}
+
+		installationID, err := client.findInstallation(nickname)
+		if err != nil {
+			return "", err
+		}
+
+		token, err := client.createInstallationToken(installationID)
+		if err == ErrUserNotFound || err == ErrServiceUnavailable {
+			return "", err
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("cannot get access token of installation %d for %s: %s", installationID, nickname, err)
+		}
+
+		client.mu.Lock()
+		client.tokens[key] = token
+		client.mu.Unlock()
+
+		return token.token, nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+// Invalidate drops cached installation token for given user or organization.
+func (client *AppClient) Invalidate(nickname string) {
+	client.mu.Lock()
+	delete(client.tokens, strings.ToLower(nickname))
+	client.mu.Unlock()
+}
+
+func (client *AppClient) cachedToken(key string) (string, bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	token, ok := client.tokens[key]
+	if !ok || !client.now().Add(InstallationTokenExpiryMargin).Before(token.expiresAt) {
+		return "", false
+	}
+
+	return token.token, true
+}
+
+func (client *AppClient) findInstallation(nickname string) (int64, error) {
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+
+	switch err := client.do("GET", "/users/"+nickname+"/installation", &installation); err {
+	case nil:
+		return installation.ID, nil
+	case ErrUserNotFound, ErrServiceUnavailable:
+		return 0, err
+	default:
+		return 0, fmt.Errorf("cannot find app installation for %s: %s", nickname, err)
+	}
+}
+
+func (client *AppClient) createInstallationToken(installationID int64) (installationToken, error) {
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	if err := client.do("POST", "/app/installations/"+strconv.FormatInt(installationID, 10)+"/access_tokens", &resp); err != nil {
+		return installationToken{}, err
+	}
+
+	if resp.Token == "" {
+		return installationToken{}, errors.New("token cannot be empty")
+	}
+
+	return installationToken{token: resp.Token, expiresAt: resp.ExpiresAt}, nil
+}
+
+// do sends a request authenticated as the app and decodes JSON response into v. ErrUserNotFound
+// is returned for 404 responses and ErrServiceUnavailable once retries are exhausted.
+func (client *AppClient) do(method, path string, v interface{}) error {
+	for attempt := 0; ; attempt++ {
+		retry, err := client.request(method, path, v)
+		if !retry {
+			return err
+		}
+
+		if attempt >= client.MaxRetries {
+			log.Printf("failed to %s %s after %d attempts: %s", method, path, attempt+1, err)
+			return ErrServiceUnavailable
+		}
+
+		time.Sleep(backoff.Delay(client.RetryDelay, attempt))
+	}
+}
+
+// request sends a single request to GitHub and reports whether it's worth to be retried.
+func (client *AppClient) request(method, path string, v interface{}) (retry bool, err error) {
+	jwt, err := client.signJWT()
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(client.BaseURL, "/")+path, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("cannot read response body: %s", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, ErrUserNotFound
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("got unsuccessful response, status %d: %s", resp.StatusCode, string(b))
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return false, fmt.Errorf("got unsuccessful response, status %d: %s", resp.StatusCode, string(b))
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, fmt.Errorf("cannot unmarshal responded json: %s", err)
+	}
+
+	return false, nil
+}
+
+// signJWT returns RS256-signed JSON Web Token GitHub requires to authenticate as an app.
+func (client *AppClient) signJWT() (string, error) {
+	now := client.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": client.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+
+	digest := sha256.Sum256(buf.Bytes())
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, client.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("cannot sign app JWT: %s", err)
+	}
+
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+
+	return buf.String(), nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("private key is not PEM-encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
@@ -0,0 +1,249 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tokens_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitHubApp serves GitHub App installation endpoints verifying JWT signed by the app.
+type fakeGitHubApp struct {
+	t   *testing.T
+	key *rsa.PublicKey
+
+	tokenRequests        int64
+	installationRequests int64
+	// failures is the number of following installation lookups to fail with 502 Bad Gateway
+	failures int64
+}
+
+func (app *fakeGitHubApp) authenticate(w http.ResponseWriter, req *http.Request) bool {
+	parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+	if !assert.Len(app.t, parts, 3) {
+		http.Error(w, `{"message":"A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+		return false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(app.t, err)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !assert.NoError(app.t, rsa.VerifyPKCS1v15(app.key, crypto.SHA256, digest[:], signature)) {
+		http.Error(w, `{"message":"A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+		return false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(app.t, err)
+
+	var claims struct {
+		Iss int64 `json:"iss"`
+		Iat int64 `json:"iat"`
+		Exp int64 `json:"exp"`
+	}
+	require.NoError(app.t, json.Unmarshal(b, &claims))
+
+	assert.Equal(app.t, int64(42), claims.Iss)
+	assert.True(app.t, claims.Exp-claims.Iat <= int64(10*time.Minute/time.Second))
+
+	return true
+}
+
+func setupFakeGitHubApp(t *testing.T, now func() time.Time) (client *tokens.AppClient, app *fakeGitHubApp, teardown func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	app = &fakeGitHubApp{t: t, key: &key.PublicKey}
+
+	baseURL, mux, teardown := setup()
+
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&app.installationRequests, 1)
+
+		if !app.authenticate(w, req) {
+			return
+		}
+
+		if req.URL.Path != "/users/blamewarrior/installation" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+
+		if atomic.AddInt64(&app.failures, -1) >= 0 {
+			http.Error(w, `{"message":"Bad Gateway"}`, http.StatusBadGateway)
+			return
+		}
+
+		w.Write([]byte(`{"id": 1, "account": {"login": "blamewarrior"}}`))
+	})
+
+	mux.HandleFunc("/app/installations/1/access_tokens", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+
+		if !app.authenticate(w, req) {
+			return
+		}
+
+		n := atomic.AddInt64(&app.tokenRequests, 1)
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "installation_token_%d", "expires_at": %q}`, n, now().Add(time.Hour).Format(time.RFC3339))
+	})
+
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	client, err = tokens.NewAppClient(42, privateKey)
+	require.NoError(t, err)
+
+	client.BaseURL = baseURL
+	client.RetryDelay = time.Millisecond
+	tokens.SetAppClock(client, now)
+
+	return client, app, teardown
+}
+
+func TestAppClient_GetToken(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	client, app, teardown := setupFakeGitHubApp(t, func() time.Time { return now })
+	defer teardown()
+
+	token, err := client.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_1", token)
+
+	// cached until shortly before expiration
+	now = now.Add(time.Hour - tokens.InstallationTokenExpiryMargin - time.Second)
+
+	token, err = client.GetToken("BlameWarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_1", token)
+
+	now = now.Add(time.Second)
+
+	token, err = client.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_2", token)
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&app.tokenRequests))
+}
+
+func TestAppClient_GetToken_NotInstalled(t *testing.T) {
+	client, app, teardown := setupFakeGitHubApp(t, time.Now)
+	defer teardown()
+
+	_, err := client.GetToken("octocat")
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&app.tokenRequests))
+}
+
+func TestAppClient_GetToken_HostQualifiedOwner(t *testing.T) {
+	client, app, teardown := setupFakeGitHubApp(t, time.Now)
+	defer teardown()
+
+	_, err := client.GetToken("ghe.example.com/blamewarrior")
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+
+	assert.Equal(t, int64(0), atomic.LoadInt64(&app.installationRequests))
+}
+
+func TestAppClient_GetToken_Retry(t *testing.T) {
+	client, app, teardown := setupFakeGitHubApp(t, time.Now)
+	defer teardown()
+
+	atomic.StoreInt64(&app.failures, int64(client.MaxRetries))
+
+	token, err := client.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_1", token)
+
+	atomic.StoreInt64(&app.failures, int64(client.MaxRetries+1))
+
+	_, err = client.GetToken("blamewarrior2")
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+
+	client.Invalidate("blamewarrior")
+
+	_, err = client.GetToken("blamewarrior")
+	assert.Equal(t, tokens.ErrServiceUnavailable, err)
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&app.tokenRequests))
+}
+
+func TestAppClient_GetToken_Timeout(t *testing.T) {
+	baseURL, mux, teardown := setup()
+	defer teardown()
+
+	var requests int64
+	mux.HandleFunc("/users/blamewarrior/installation", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	client, err := tokens.NewAppClient(42, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	require.NoError(t, err)
+
+	client.BaseURL = baseURL
+	client.HTTPClient.Timeout = 10 * time.Millisecond
+	client.RetryDelay = time.Millisecond
+
+	_, err = client.GetToken("blamewarrior")
+	assert.Equal(t, tokens.ErrServiceUnavailable, err)
+	assert.Equal(t, int64(client.MaxRetries+1), atomic.LoadInt64(&requests))
+}
+
+func TestAppClient_Invalidate(t *testing.T) {
+	client, app, teardown := setupFakeGitHubApp(t, time.Now)
+	defer teardown()
+
+	token, err := client.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_1", token)
+
+	client.Invalidate("blamewarrior")
+
+	token, err = client.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "installation_token_2", token)
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&app.tokenRequests))
+}
+
+func TestNewAppClient_IncorrectPrivateKey(t *testing.T) {
+	_, err := tokens.NewAppClient(42, []byte("not a key"))
+	assert.Error(t, err)
+}
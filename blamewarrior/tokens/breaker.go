@@ -0,0 +1,99 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tokens
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failures that opens the circuit.
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is the time an open circuit rejects requests for.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// CircuitBreaker rejects requests to a failing service. After Threshold consecutive
+// failures it opens the circuit for Cooldown, then lets a single trial request through
+// and closes the circuit if it succeeds.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request can be sent.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.Threshold {
+		return true
+	}
+
+	if b.trial || b.now().Sub(b.openedAt) < b.Cooldown {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// Success closes the circuit.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed request opening the circuit once there were too many of them.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.failures >= b.Threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// Open reports whether the circuit is open.
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures >= b.Threshold
+}
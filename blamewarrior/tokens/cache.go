@@ -0,0 +1,180 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tokens
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultCacheTTL is the time a token is kept by CachingClient by default.
+	DefaultCacheTTL = 10 * time.Minute
+	// DefaultNegativeCacheTTL is the time CachingClient remembers by default that
+	// users service does not know a user or the user has revoked their token.
+	DefaultNegativeCacheTTL = time.Minute
+)
+
+// CacheStats contains CachingClient counters.
+type CacheStats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Invalidations int64   `json:"invalidations"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+type cacheEntry struct {
+	token     string
+	err       error
+	expiresAt time.Time
+}
+
+// CachingClient is a Client decorator that keeps tokens for TTL and ErrUserNotFound
+// or ErrTokenRevoked responses for NegativeTTL. Concurrent requests for a token of the same user result
+// in a single request to the underlying client. Nicknames are case-insensitive.
+type CachingClient struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	client Client
+	group  singleflight.Group
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// generation is incremented on each invalidation to prevent tokens requested
+	// before it from being cached
+	generation uint64
+
+	hits, misses, invalidations int64
+}
+
+func NewCachingClient(client Client, ttl time.Duration) *CachingClient {
+	return &CachingClient{
+		TTL:         ttl,
+		NegativeTTL: DefaultNegativeCacheTTL,
+		client:      client,
+		now:         time.Now,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// GetToken returns cached token of a user requesting it from the underlying client
+// if there is none or it has expired.
+func (c *CachingClient) GetToken(nickname string) (token string, err error) {
+	key := strings.ToLower(nickname)
+
+	if entry, ok := c.lookup(key); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return entry.token, entry.err
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		// the token might have been stored by a request that finished
+		// after the lookup above
+		if entry, ok := c.lookup(key); ok {
+			return entry.token, entry.err
+		}
+
+		c.mu.Lock()
+		generation := c.generation
+		c.mu.Unlock()
+
+		token, err := c.client.GetToken(nickname)
+
+		switch err {
+		case nil:
+			c.store(key, cacheEntry{token: token}, c.TTL, generation)
+		case ErrUserNotFound, ErrTokenRevoked:
+			c.store(key, cacheEntry{err: err}, c.NegativeTTL, generation)
+		}
+
+		return token, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return v.(string), nil
+}
+
+// Invalidate drops cached token of a user, so that the next GetToken call requests
+// a new one. It is meant to be called once GitHub rejects the token.
+func (c *CachingClient) Invalidate(nickname string) {
+	key := strings.ToLower(nickname)
+
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.generation++
+	c.mu.Unlock()
+
+	c.group.Forget(key)
+	atomic.AddInt64(&c.invalidations, 1)
+}
+
+// Stats returns cache counters.
+func (c *CachingClient) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+	}
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+
+	return stats
+}
+
+func (c *CachingClient) lookup(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return entry, false
+	}
+
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return entry, false
+	}
+
+	return entry, true
+}
+
+func (c *CachingClient) store(key string, entry cacheEntry, ttl time.Duration, generation uint64) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	entry.expiresAt = c.now().Add(ttl)
+	c.entries[key] = entry
+}
@@ -0,0 +1,160 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tokens_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingClient struct {
+	calls   int64
+	tokens  map[string]string
+	err     error
+	release chan struct{}
+}
+
+func (c *countingClient) GetToken(nickname string) (string, error) {
+	atomic.AddInt64(&c.calls, 1)
+
+	if c.release != nil {
+		<-c.release
+	}
+
+	if c.err != nil {
+		return "", c.err
+	}
+
+	token, ok := c.tokens[nickname]
+	if !ok {
+		return "", tokens.ErrUserNotFound
+	}
+
+	return token, nil
+}
+
+func (c *countingClient) Calls() int {
+	return int(atomic.LoadInt64(&c.calls))
+}
+
+func TestCachingClient_GetToken(t *testing.T) {
+	client := &countingClient{tokens: map[string]string{"blamewarrior": "test_token"}}
+
+	now := time.Now()
+	cache := tokens.NewCachingClient(client, time.Minute)
+	tokens.SetClock(cache, func() time.Time { return now })
+
+	for _, nickname := range []string{"blamewarrior", "blamewarrior", "BlameWarrior"} {
+		token, err := cache.GetToken(nickname)
+		require.NoError(t, err)
+		assert.Equal(t, "test_token", token)
+	}
+	assert.Equal(t, 1, client.Calls())
+
+	now = now.Add(time.Minute)
+
+	token, err := cache.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "test_token", token)
+	assert.Equal(t, 2, client.Calls())
+
+	assert.Equal(t, tokens.CacheStats{Hits: 2, Misses: 2, HitRate: 0.5}, cache.Stats())
+}
+
+func TestCachingClient_GetToken_UserNotFound(t *testing.T) {
+	client := &countingClient{}
+
+	now := time.Now()
+	cache := tokens.NewCachingClient(client, time.Minute)
+	cache.NegativeTTL = 10 * time.Second
+	tokens.SetClock(cache, func() time.Time { return now })
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetToken("blamewarrior")
+		assert.Equal(t, tokens.ErrUserNotFound, err)
+	}
+	assert.Equal(t, 1, client.Calls())
+
+	now = now.Add(10 * time.Second)
+
+	_, err := cache.GetToken("blamewarrior")
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+	assert.Equal(t, 2, client.Calls())
+}
+
+func TestCachingClient_GetToken_Error(t *testing.T) {
+	client := &countingClient{err: errors.New("users service is unavailable")}
+	cache := tokens.NewCachingClient(client, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetToken("blamewarrior")
+		assert.EqualError(t, err, "users service is unavailable")
+	}
+	assert.Equal(t, 2, client.Calls())
+}
+
+func TestCachingClient_GetToken_Concurrent(t *testing.T) {
+	client := &countingClient{
+		tokens:  map[string]string{"blamewarrior": "test_token"},
+		release: make(chan struct{}),
+	}
+	cache := tokens.NewCachingClient(client, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			token, err := cache.GetToken("blamewarrior")
+			assert.NoError(t, err)
+			assert.Equal(t, "test_token", token)
+		}()
+	}
+
+	for client.Calls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(client.release)
+
+	wg.Wait()
+	assert.Equal(t, 1, client.Calls())
+}
+
+func TestCachingClient_Invalidate(t *testing.T) {
+	client := &countingClient{tokens: map[string]string{"blamewarrior": "test_token"}}
+	cache := tokens.NewCachingClient(client, time.Minute)
+
+	_, err := cache.GetToken("blamewarrior")
+	require.NoError(t, err)
+
+	client.tokens["blamewarrior"] = "new_token"
+	cache.Invalidate("BlameWarrior")
+
+	token, err := cache.GetToken("blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "new_token", token)
+	assert.Equal(t, 2, client.Calls())
+
+	assert.Equal(t, int64(1), cache.Stats().Invalidations)
+}
@@ -17,42 +17,122 @@ package tokens
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/blamewarrior/collaborators/backoff"
+)
+
+var (
+	// ErrUserNotFound is returned when users service does not know the user token was requested for.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrTokenRevoked is returned when the user has revoked access of BlameWarrior to GitHub.
+	ErrTokenRevoked = errors.New("GitHub token has been revoked")
+	// ErrServiceUnavailable is returned when users service or GitHub cannot be reached or keeps failing.
+	ErrServiceUnavailable = errors.New("users service is unavailable")
+)
+
+const (
+	// DefaultTimeout limits the time of a single request to users service or GitHub.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxRetries is the number of times a failed request to users service is repeated.
+	DefaultMaxRetries = 3
+	// DefaultRetryDelay is the delay before the first retry of a failed request.
+	DefaultRetryDelay = 100 * time.Millisecond
 )
 
 type Client interface {
 	GetToken(nickname string) (token string, err error)
 }
 
+// Invalidator is implemented by clients that keep tokens around and need to be told once
+// a token has been rejected by GitHub.
+type Invalidator interface {
+	Invalidate(nickname string)
+}
+
 type Response struct {
 	Token string `json:"token"`
 }
 
+// TokenClient requests tokens from users service. Requests that fail because of network
+// errors or 5xx responses are retried, and once users service keeps failing the client
+// stops sending requests to it for a while returning ErrServiceUnavailable.
 type TokenClient struct {
-	BaseURL string
-	c       *http.Client
-
-	nickname string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// MaxRetries limits the number of times a request is repeated after a failure.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry. It is doubled for each next
+	// one and randomized to spread retries of concurrent requests.
+	RetryDelay time.Duration
+	// Breaker stops requests to users service after consecutive failures, nil disables it.
+	Breaker *CircuitBreaker
 }
 
+// GetToken returns GitHub token of a user. ErrUserNotFound, ErrTokenRevoked and
+// ErrServiceUnavailable are returned as is to let callers act on them.
 func (client *TokenClient) GetToken(nickname string) (token string, err error) {
+	if client.Breaker != nil && !client.Breaker.Allow() {
+		return "", ErrServiceUnavailable
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, retry, err := client.requestToken(nickname)
+
+		if !retry {
+			if client.Breaker != nil {
+				client.Breaker.Success()
+			}
+
+			return token, err
+		}
+
+		if attempt >= client.MaxRetries {
+			log.Printf("failed to get token for %s after %d attempts: %s", nickname, attempt+1, err)
+
+			if client.Breaker != nil {
+				client.Breaker.Failure()
+			}
+
+			return "", ErrServiceUnavailable
+		}
+
+		time.Sleep(backoff.Delay(client.RetryDelay, attempt))
+	}
+}
+
+// requestToken sends a single token request to users service and reports whether
+// it's worth to be retried.
+func (client *TokenClient) requestToken(nickname string) (token string, retry bool, err error) {
 
-	resp, err := client.c.Get(client.BaseURL + "/users/" + nickname)
+	resp, err := client.HTTPClient.Get(client.BaseURL + "/users/" + nickname)
 
 	if err != nil {
-		return "", fmt.Errorf("impossible to get data for %s: %s", nickname, err)
+		return "", true, fmt.Errorf("impossible to get data for %s: %s", nickname, err)
 	}
+	defer resp.Body.Close()
 
 	b, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
-		return "", fmt.Errorf("cannot read response body when getting data for %s: %s", nickname, err)
+		return "", true, fmt.Errorf("cannot read response body when getting data for %s: %s", nickname, err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("got unsuccessful response for %s, status %d: %s", nickname, resp.StatusCode, string(b))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", false, ErrUserNotFound
+	case resp.StatusCode == http.StatusGone:
+		return "", false, ErrTokenRevoked
+	case resp.StatusCode >= 500:
+		return "", true, fmt.Errorf("got unsuccessful response for %s, status %d: %s", nickname, resp.StatusCode, string(b))
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("got unsuccessful response for %s, status %d: %s", nickname, resp.StatusCode, string(b))
 	}
 
 	tokenResp := new(Response)
@@ -60,22 +140,25 @@ func (client *TokenClient) GetToken(nickname string) (token string, err error) {
 	err = json.Unmarshal(b, &tokenResp)
 
 	if err != nil {
-		return "", fmt.Errorf("cannot unmarshal responded json from users service: %s", err)
+		return "", false, fmt.Errorf("cannot unmarshal responded json from users service: %s", err)
 	}
 
 	token = tokenResp.Token
 
 	if token == "" {
-		return "", fmt.Errorf("token for %s user cannot be empty", nickname)
+		return "", false, fmt.Errorf("token for %s user cannot be empty", nickname)
 	}
 
-	return token, nil
+	return token, false, nil
 }
 
 func NewTokenClient(baseURL string) *TokenClient {
 	client := &TokenClient{
-		BaseURL: baseURL,
-		c:       http.DefaultClient,
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+		Breaker:    NewCircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
 	}
 
 	return client
@@ -18,7 +18,9 @@ package tokens_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
 	"github.com/stretchr/testify/assert"
@@ -45,6 +47,147 @@ func TestGetToken(t *testing.T) {
 
 }
 
+func TestGetToken_UserNotFound(t *testing.T) {
+	testAPIEndpoint, _, teardown := setup()
+
+	defer teardown()
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+
+	_, err := client.GetToken("blamewarrior")
+
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+}
+
+func TestGetToken_TokenRevoked(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setup()
+
+	defer teardown()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "token revoked", http.StatusGone)
+	})
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+
+	_, err := client.GetToken("blamewarrior")
+
+	assert.Equal(t, tokens.ErrTokenRevoked, err)
+}
+
+func TestGetToken_Retries(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setup()
+
+	defer teardown()
+
+	var requests int64
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) < 3 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(userResponse))
+	})
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+	client.RetryDelay = time.Millisecond
+
+	token, err := client.GetToken("blamewarrior")
+
+	require.NoError(t, err)
+	assert.Equal(t, "test_token", token)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+}
+
+func TestGetToken_ServiceUnavailable(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setup()
+
+	defer teardown()
+
+	var requests int64
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+	client.HTTPClient.Timeout = 10 * time.Millisecond
+	client.RetryDelay = time.Millisecond
+	client.MaxRetries = 2
+
+	_, err := client.GetToken("blamewarrior")
+
+	assert.Equal(t, tokens.ErrServiceUnavailable, err)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+}
+
+func TestGetToken_ClientError(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setup()
+
+	defer teardown()
+
+	var requests int64
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+
+	_, err := client.GetToken("blamewarrior")
+
+	assert.EqualError(t, err, "got unsuccessful response for blamewarrior, status 400: bad request\n")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+}
+
+func TestGetToken_CircuitBreaker(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setup()
+
+	defer teardown()
+
+	var (
+		requests int64
+		healthy  int32
+	)
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+
+		if atomic.LoadInt32(&healthy) == 0 {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(userResponse))
+	})
+
+	now := time.Now()
+
+	client := tokens.NewTokenClient(testAPIEndpoint)
+	client.MaxRetries = 0
+	client.Breaker = tokens.NewCircuitBreaker(2, time.Minute)
+	tokens.SetBreakerClock(client.Breaker, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetToken("blamewarrior")
+		assert.Equal(t, tokens.ErrServiceUnavailable, err)
+	}
+
+	assert.True(t, client.Breaker.Open())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+
+	// a trial request is let through after cooldown
+	now = now.Add(time.Minute)
+	atomic.StoreInt32(&healthy, 1)
+
+	token, err := client.GetToken("blamewarrior")
+
+	require.NoError(t, err)
+	assert.Equal(t, "test_token", token)
+	assert.False(t, client.Breaker.Open())
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+}
+
 func setup() (baseURL string, mux *http.ServeMux, teardown func()) {
 	mux = http.NewServeMux()
 	server := httptest.NewServer(mux)
@@ -0,0 +1,431 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// Supported formats of collaborator records.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// recordsContentTypes maps supported formats to their MIME types.
+var recordsContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// csvHeader is the first row of a CSV file with collaborator records.
+var csvHeader = []string{"repository", "login", "uid", "permissions"}
+
+// CollaboratorRecord is a single row of exported or imported collaborator data.
+// In CSV permissions are listed by name separated with spaces, only granted ones
+// are included.
+type CollaboratorRecord struct {
+	Repository  string                          `json:"repository"`
+	Login       string                          `json:"login"`
+	Uid         int                             `json:"uid"`
+	Permissions blamewarrior.AccountPermissions `json:"permissions"`
+
+	row int
+}
+
+// RejectedRecord describes a row that has not been imported.
+type RejectedRecord struct {
+	Row    int                 `json:"row"`
+	Record *CollaboratorRecord `json:"record,omitempty"`
+	Error  string              `json:"error"`
+}
+
+// ImportReport summarizes the outcome of a collaborators import.
+type ImportReport struct {
+	Added    int              `json:"added"`
+	Updated  int              `json:"updated"`
+	Rejected []RejectedRecord `json:"rejected"`
+}
+
+func newImportReport() *ImportReport {
+	return &ImportReport{Rejected: make([]RejectedRecord, 0)}
+}
+
+func (report *ImportReport) reject(row int, rec *CollaboratorRecord, err error) {
+	report.Rejected = append(report.Rejected, RejectedRecord{Row: row, Record: rec, Error: err.Error()})
+}
+
+// IsValidRecordsFormat checks whether format is one of Format* constants.
+func IsValidRecordsFormat(format string) bool {
+	_, ok := recordsContentTypes[format]
+	return ok
+}
+
+// recordWriter encodes collaborator records in one of the supported formats.
+type recordWriter interface {
+	Write(rec CollaboratorRecord) error
+	// Flush writes buffered records to the underlying writer.
+	Flush() error
+	// Close completes the output. It does not close the underlying writer.
+	Close() error
+}
+
+func newRecordWriter(w io.Writer, format string) (recordWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+
+		return &csvRecordWriter{cw}, nil
+	case FormatJSON:
+		return &jsonRecordWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonRecordWriter{json.NewEncoder(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+func (rw *csvRecordWriter) Write(rec CollaboratorRecord) error {
+	return rw.w.Write([]string{rec.Repository, rec.Login, strconv.Itoa(rec.Uid), formatPermissions(rec.Permissions)})
+}
+
+func (rw *csvRecordWriter) Flush() error {
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+func (rw *csvRecordWriter) Close() error {
+	return rw.Flush()
+}
+
+// jsonRecordWriter streams records as elements of a JSON array.
+type jsonRecordWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (rw *jsonRecordWriter) Write(rec CollaboratorRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	sep := []byte(",\n")
+	if !rw.started {
+		sep, rw.started = []byte("[\n"), true
+	}
+
+	_, err = rw.w.Write(append(sep, b...))
+
+	return err
+}
+
+func (rw *jsonRecordWriter) Flush() error {
+	return nil
+}
+
+func (rw *jsonRecordWriter) Close() error {
+	closing := "\n]\n"
+	if !rw.started {
+		closing = "[]\n"
+	}
+
+	_, err := io.WriteString(rw.w, closing)
+
+	return err
+}
+
+type ndjsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (rw *ndjsonRecordWriter) Write(rec CollaboratorRecord) error {
+	return rw.enc.Encode(rec)
+}
+
+func (rw *ndjsonRecordWriter) Flush() error {
+	return nil
+}
+
+func (rw *ndjsonRecordWriter) Close() error {
+	return nil
+}
+
+// formatPermissions returns sorted names of granted permissions separated by spaces.
+func formatPermissions(perms blamewarrior.AccountPermissions) string {
+	return strings.Join(perms.Names(), " ")
+}
+
+func parsePermissions(s string) (blamewarrior.AccountPermissions, error) {
+	return blamewarrior.PermissionsFromNames(strings.Fields(s))
+}
+
+// readRecords decodes collaborator records. Rows that can not be decoded are added to the
+// report as rejected, while malformed input as a whole results in an error.
+func readRecords(r io.Reader, format string, report *ImportReport) ([]CollaboratorRecord, error) {
+	switch format {
+	case FormatCSV:
+		return readCSVRecords(r, report)
+	case FormatJSON:
+		return readJSONRecords(r, report)
+	case FormatNDJSON:
+		return readNDJSONRecords(r, report)
+	}
+
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func readCSVRecords(r io.Reader, report *ImportReport) ([]CollaboratorRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %s", err)
+	}
+
+	for i := range csvHeader {
+		if strings.TrimSpace(strings.ToLower(header[i])) != csvHeader[i] {
+			return nil, fmt.Errorf("unexpected csv header, expected %s", strings.Join(csvHeader, ","))
+		}
+	}
+
+	var records []CollaboratorRecord
+	for row := 1; ; row++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok && len(fields) > 0 {
+				report.reject(row, nil, err)
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to read csv row %d: %s", row, err)
+		}
+
+		uid, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if err != nil {
+			report.reject(row, nil, fmt.Errorf("incorrect uid %q", fields[2]))
+			continue
+		}
+
+		perms, err := parsePermissions(fields[3])
+		if err != nil {
+			report.reject(row, nil, err)
+			continue
+		}
+
+		records = append(records, CollaboratorRecord{
+			Repository:  strings.TrimSpace(fields[0]),
+			Login:       strings.TrimSpace(fields[1]),
+			Uid:         uid,
+			Permissions: perms,
+			row:         row,
+		})
+	}
+
+	return records, nil
+}
+
+func readJSONRecords(r io.Reader, report *ImportReport) ([]CollaboratorRecord, error) {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode json: %s", err)
+	}
+
+	var records []CollaboratorRecord
+	for i, raw := range rows {
+		rec := CollaboratorRecord{row: i + 1}
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			report.reject(i+1, nil, err)
+			continue
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func readNDJSONRecords(r io.Reader, report *ImportReport) ([]CollaboratorRecord, error) {
+	scanner := bufio.NewScanner(r)
+
+	var records []CollaboratorRecord
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			row--
+			continue
+		}
+
+		rec := CollaboratorRecord{row: row}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			report.reject(row, nil, err)
+			continue
+		}
+
+		records = append(records, rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ndjson: %s", err)
+	}
+
+	return records, nil
+}
+
+// validateRecord checks whether a record can be imported, names of repositories hosted by
+// providers are checked with their rules.
+func validateRecord(providers map[string]blamewarrior.Provider, rec CollaboratorRecord) error {
+	if validateRepositoryName(providers, rec.Repository) != nil {
+		return fmt.Errorf("incorrect repository name %q", rec.Repository)
+	}
+
+	if rec.Login == "" {
+		return errors.New("missing login")
+	}
+
+	if validateLogin(providers, rec.Repository, rec.Login) != nil {
+		return fmt.Errorf("incorrect login %q", rec.Login)
+	}
+
+	if rec.Uid < 0 {
+		return fmt.Errorf("incorrect uid %d", rec.Uid)
+	}
+
+	return nil
+}
+
+// canonicalRecords qualifies repository names of records with the GitHub Enterprise Server host
+// their owner is assigned to, see github.Hosts.Canonical.
+func canonicalRecords(hosts *github.Hosts, records []CollaboratorRecord) {
+	for i := range records {
+		records[i].Repository = hosts.Canonical(records[i].Repository)
+	}
+}
+
+// importCollaborators validates records and applies valid ones in a single transaction.
+// Unknown repositories get created, collaborators that are already connected to a
+// repository are updated with EditAccount and the rest are added with AddAccount.
+// The transaction is rolled back if any of the records fails to be applied.
+func importCollaborators(ctx context.Context, db *sql.DB, collaboration blamewarrior.Collaboration,
+	providers map[string]blamewarrior.Provider, records []CollaboratorRecord, report *ImportReport) error {
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer blamewarrior.Rollback(collaboration, tx)
+
+	var (
+		added, updated int
+		collaborators  = make(map[string]map[string]bool)
+		seen           = make(map[string]int)
+	)
+
+	for i := range records {
+		rec := &records[i]
+
+		if err := validateRecord(providers, *rec); err != nil {
+			report.reject(rec.row, rec, err)
+			continue
+		}
+
+		key := strings.ToLower(rec.Repository + "\t" + rec.Login)
+		if row, ok := seen[key]; ok {
+			report.reject(rec.row, rec, fmt.Errorf("duplicates row %d", row))
+			continue
+		}
+		seen[key] = rec.row
+
+		logins, ok := collaborators[strings.ToLower(rec.Repository)]
+		if !ok {
+			if err := collaboration.CreateRepository(ctx, tx, rec.Repository); err != nil {
+				return fmt.Errorf("failed to create repository %s: %s", rec.Repository, err)
+			}
+
+			accounts, err := collaboration.ListAccounts(ctx, tx, rec.Repository)
+			if err != nil {
+				return fmt.Errorf("failed to list collaborators of %s: %s", rec.Repository, err)
+			}
+
+			logins = make(map[string]bool, len(accounts))
+			for _, account := range accounts {
+				logins[strings.ToLower(account.Login)] = true
+			}
+			collaborators[strings.ToLower(rec.Repository)] = logins
+		}
+
+		account := &blamewarrior.Account{
+			Uid:         rec.Uid,
+			Login:       rec.Login,
+			Permissions: rec.Permissions,
+		}
+
+		if logins[strings.ToLower(rec.Login)] {
+			if err := collaboration.EditAccount(ctx, tx, rec.Repository, account); err != nil {
+				return fmt.Errorf("row %d: %s", rec.row, err)
+			}
+			updated++
+
+			continue
+		}
+
+		if _, err := collaboration.AddAccount(ctx, tx, rec.Repository, account); err != nil {
+			return fmt.Errorf("row %d: %s", rec.row, err)
+		}
+		logins[strings.ToLower(rec.Login)] = true
+		added++
+	}
+
+	if err := blamewarrior.Commit(collaboration, tx); err != nil {
+		return err
+	}
+
+	report.Added, report.Updated = added, updated
+	sort.SliceStable(report.Rejected, func(i, j int) bool {
+		return report.Rejected[i].Row < report.Rejected[j].Row
+	})
+
+	return nil
+}
@@ -0,0 +1,1139 @@
+// Copyright (C) 2018 The BlameWarrior Authors.
+//
+// This file is a part of BlameWarrior service.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: collaboratorspb/collaborators.proto
+
+package collaboratorspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CollaboratorEvent_Type int32
+
+const (
+	CollaboratorEvent_TYPE_UNSPECIFIED CollaboratorEvent_Type = 0
+	CollaboratorEvent_ADDED            CollaboratorEvent_Type = 1
+	CollaboratorEvent_UPDATED          CollaboratorEvent_Type = 2
+	CollaboratorEvent_REMOVED          CollaboratorEvent_Type = 3
+)
+
+// Enum value maps for CollaboratorEvent_Type.
+var (
+	CollaboratorEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "ADDED",
+		2: "UPDATED",
+		3: "REMOVED",
+	}
+	CollaboratorEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"ADDED":            1,
+		"UPDATED":          2,
+		"REMOVED":          3,
+	}
+)
+
+func (x CollaboratorEvent_Type) Enum() *CollaboratorEvent_Type {
+	p := new(CollaboratorEvent_Type)
+	*p = x
+	return p
+}
+
+func (x CollaboratorEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CollaboratorEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_collaboratorspb_collaborators_proto_enumTypes[0].Descriptor()
+}
+
+func (CollaboratorEvent_Type) Type() protoreflect.EnumType {
+	return &file_collaboratorspb_collaborators_proto_enumTypes[0]
+}
+
+func (x CollaboratorEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CollaboratorEvent_Type.Descriptor instead.
+func (CollaboratorEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{11, 0}
+}
+
+type Collaborator struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uid   int64  `protobuf:"varint,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Login string `protobuf:"bytes,2,opt,name=login,proto3" json:"login,omitempty"`
+	// Names of granted permissions, e.g. "pull" or "push".
+	Permissions []string `protobuf:"bytes,3,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	// One of "direct", "outside" or "member".
+	Affiliation string `protobuf:"bytes,4,opt,name=affiliation,proto3" json:"affiliation,omitempty"`
+	// Slugs of repository teams the collaborator is a member of.
+	Teams []string `protobuf:"bytes,5,rep,name=teams,proto3" json:"teams,omitempty"`
+}
+
+func (x *Collaborator) Reset() {
+	*x = Collaborator{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Collaborator) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Collaborator) ProtoMessage() {}
+
+func (x *Collaborator) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Collaborator.ProtoReflect.Descriptor instead.
+func (*Collaborator) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Collaborator) GetUid() int64 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *Collaborator) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+func (x *Collaborator) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *Collaborator) GetAffiliation() string {
+	if x != nil {
+		return x.Affiliation
+	}
+	return ""
+}
+
+func (x *Collaborator) GetTeams() []string {
+	if x != nil {
+		return x.Teams
+	}
+	return nil
+}
+
+type ListCollaboratorsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Full repository name in owner/repo format.
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+}
+
+func (x *ListCollaboratorsRequest) Reset() {
+	*x = ListCollaboratorsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListCollaboratorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollaboratorsRequest) ProtoMessage() {}
+
+func (x *ListCollaboratorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollaboratorsRequest.ProtoReflect.Descriptor instead.
+func (*ListCollaboratorsRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListCollaboratorsRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+type ListCollaboratorsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Collaborators []*Collaborator `protobuf:"bytes,1,rep,name=collaborators,proto3" json:"collaborators,omitempty"`
+}
+
+func (x *ListCollaboratorsResponse) Reset() {
+	*x = ListCollaboratorsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListCollaboratorsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollaboratorsResponse) ProtoMessage() {}
+
+func (x *ListCollaboratorsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollaboratorsResponse.ProtoReflect.Descriptor instead.
+func (*ListCollaboratorsResponse) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListCollaboratorsResponse) GetCollaborators() []*Collaborator {
+	if x != nil {
+		return x.Collaborators
+	}
+	return nil
+}
+
+type GetCollaboratorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Login      string `protobuf:"bytes,2,opt,name=login,proto3" json:"login,omitempty"`
+}
+
+func (x *GetCollaboratorRequest) Reset() {
+	*x = GetCollaboratorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetCollaboratorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCollaboratorRequest) ProtoMessage() {}
+
+func (x *GetCollaboratorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCollaboratorRequest.ProtoReflect.Descriptor instead.
+func (*GetCollaboratorRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetCollaboratorRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *GetCollaboratorRequest) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+type AddCollaboratorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository   string        `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Collaborator *Collaborator `protobuf:"bytes,2,opt,name=collaborator,proto3" json:"collaborator,omitempty"`
+}
+
+func (x *AddCollaboratorRequest) Reset() {
+	*x = AddCollaboratorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddCollaboratorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddCollaboratorRequest) ProtoMessage() {}
+
+func (x *AddCollaboratorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddCollaboratorRequest.ProtoReflect.Descriptor instead.
+func (*AddCollaboratorRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AddCollaboratorRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *AddCollaboratorRequest) GetCollaborator() *Collaborator {
+	if x != nil {
+		return x.Collaborator
+	}
+	return nil
+}
+
+type EditCollaboratorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository   string        `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Collaborator *Collaborator `protobuf:"bytes,2,opt,name=collaborator,proto3" json:"collaborator,omitempty"`
+}
+
+func (x *EditCollaboratorRequest) Reset() {
+	*x = EditCollaboratorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EditCollaboratorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditCollaboratorRequest) ProtoMessage() {}
+
+func (x *EditCollaboratorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditCollaboratorRequest.ProtoReflect.Descriptor instead.
+func (*EditCollaboratorRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EditCollaboratorRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *EditCollaboratorRequest) GetCollaborator() *Collaborator {
+	if x != nil {
+		return x.Collaborator
+	}
+	return nil
+}
+
+type DisconnectCollaboratorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Login      string `protobuf:"bytes,2,opt,name=login,proto3" json:"login,omitempty"`
+}
+
+func (x *DisconnectCollaboratorRequest) Reset() {
+	*x = DisconnectCollaboratorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DisconnectCollaboratorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisconnectCollaboratorRequest) ProtoMessage() {}
+
+func (x *DisconnectCollaboratorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisconnectCollaboratorRequest.ProtoReflect.Descriptor instead.
+func (*DisconnectCollaboratorRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DisconnectCollaboratorRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *DisconnectCollaboratorRequest) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+type DisconnectCollaboratorResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DisconnectCollaboratorResponse) Reset() {
+	*x = DisconnectCollaboratorResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DisconnectCollaboratorResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisconnectCollaboratorResponse) ProtoMessage() {}
+
+func (x *DisconnectCollaboratorResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisconnectCollaboratorResponse.ProtoReflect.Descriptor instead.
+func (*DisconnectCollaboratorResponse) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{7}
+}
+
+type SyncRepositoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	// Compare stored collaborators with GitHub without applying any changes.
+	DryRun bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+}
+
+func (x *SyncRepositoryRequest) Reset() {
+	*x = SyncRepositoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncRepositoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRepositoryRequest) ProtoMessage() {}
+
+func (x *SyncRepositoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRepositoryRequest.ProtoReflect.Descriptor instead.
+func (*SyncRepositoryRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SyncRepositoryRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *SyncRepositoryRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type SyncRepositoryResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Changes to collaborators, only filled in for dry runs.
+	Added   []*Collaborator `protobuf:"bytes,1,rep,name=added,proto3" json:"added,omitempty"`
+	Updated []*Collaborator `protobuf:"bytes,2,rep,name=updated,proto3" json:"updated,omitempty"`
+	Removed []*Collaborator `protobuf:"bytes,3,rep,name=removed,proto3" json:"removed,omitempty"`
+}
+
+func (x *SyncRepositoryResponse) Reset() {
+	*x = SyncRepositoryResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncRepositoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRepositoryResponse) ProtoMessage() {}
+
+func (x *SyncRepositoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRepositoryResponse.ProtoReflect.Descriptor instead.
+func (*SyncRepositoryResponse) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SyncRepositoryResponse) GetAdded() []*Collaborator {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *SyncRepositoryResponse) GetUpdated() []*Collaborator {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *SyncRepositoryResponse) GetRemoved() []*Collaborator {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type WatchCollaboratorsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+}
+
+func (x *WatchCollaboratorsRequest) Reset() {
+	*x = WatchCollaboratorsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchCollaboratorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchCollaboratorsRequest) ProtoMessage() {}
+
+func (x *WatchCollaboratorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchCollaboratorsRequest.ProtoReflect.Descriptor instead.
+func (*WatchCollaboratorsRequest) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchCollaboratorsRequest) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+type CollaboratorEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type         CollaboratorEvent_Type `protobuf:"varint,1,opt,name=type,proto3,enum=blamewarrior.collaborators.v1.CollaboratorEvent_Type" json:"type,omitempty"`
+	Collaborator *Collaborator          `protobuf:"bytes,2,opt,name=collaborator,proto3" json:"collaborator,omitempty"`
+}
+
+func (x *CollaboratorEvent) Reset() {
+	*x = CollaboratorEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_collaboratorspb_collaborators_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CollaboratorEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollaboratorEvent) ProtoMessage() {}
+
+func (x *CollaboratorEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_collaboratorspb_collaborators_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollaboratorEvent.ProtoReflect.Descriptor instead.
+func (*CollaboratorEvent) Descriptor() ([]byte, []int) {
+	return file_collaboratorspb_collaborators_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CollaboratorEvent) GetType() CollaboratorEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return CollaboratorEvent_TYPE_UNSPECIFIED
+}
+
+func (x *CollaboratorEvent) GetCollaborator() *Collaborator {
+	if x != nil {
+		return x.Collaborator
+	}
+	return nil
+}
+
+var File_collaboratorspb_collaborators_proto protoreflect.FileDescriptor
+
+var file_collaboratorspb_collaborators_proto_rawDesc = []byte{
+	0x0a, 0x23, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x70,
+	0x62, 0x2f, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1d, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72,
+	0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x73, 0x2e, 0x76, 0x31, 0x22, 0x90, 0x01, 0x0a, 0x0c, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f,
+	0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x03, 0x75, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x6f, 0x67, 0x69, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x12, 0x20, 0x0a,
+	0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x20, 0x0a, 0x0b, 0x61, 0x66, 0x66, 0x69, 0x6c, 0x69, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x61, 0x66, 0x66, 0x69, 0x6c, 0x69, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x65, 0x61, 0x6d, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x05, 0x74, 0x65, 0x61, 0x6d, 0x73, 0x22, 0x3a, 0x0a, 0x18, 0x4c, 0x69, 0x73, 0x74, 0x43,
+	0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74,
+	0x6f, 0x72, 0x79, 0x22, 0x6e, 0x0a, 0x19, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x51, 0x0a, 0x0d, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77,
+	0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72,
+	0x61, 0x74, 0x6f, 0x72, 0x52, 0x0d, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x73, 0x22, 0x4e, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a,
+	0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x6f,
+	0x67, 0x69, 0x6e, 0x22, 0x89, 0x01, 0x0a, 0x16, 0x41, 0x64, 0x64, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e,
+	0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x4f,
+	0x0a, 0x0c, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72,
+	0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x52, 0x0c, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x22,
+	0x8a, 0x01, 0x0a, 0x17, 0x45, 0x64, 0x69, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72,
+	0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x72,
+	0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x4f, 0x0a, 0x0c, 0x63,
+	0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72,
+	0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x0c,
+	0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x22, 0x55, 0x0a, 0x1d,
+	0x44, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a,
+	0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x6f,
+	0x67, 0x69, 0x6e, 0x22, 0x20, 0x0a, 0x1e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63,
+	0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x50, 0x0a, 0x15, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e,
+	0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x17,
+	0x0a, 0x07, 0x64, 0x72, 0x79, 0x5f, 0x72, 0x75, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x22, 0xe9, 0x01, 0x0a, 0x16, 0x53, 0x79, 0x6e, 0x63,
+	0x52, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x41, 0x0a, 0x05, 0x61, 0x64, 0x64, 0x65, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72,
+	0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x05,
+	0x61, 0x64, 0x64, 0x65, 0x64, 0x12, 0x45, 0x0a, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61,
+	0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x52, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x12, 0x45, 0x0a, 0x07,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2b, 0x2e,
+	0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c,
+	0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f,
+	0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x07, 0x72, 0x65, 0x6d, 0x6f,
+	0x76, 0x65, 0x64, 0x22, 0x3b, 0x0a, 0x19, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6c, 0x6c,
+	0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79,
+	0x22, 0xf2, 0x01, 0x0a, 0x11, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x49, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x35, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72,
+	0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x4f, 0x0a, 0x0c, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77,
+	0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72,
+	0x61, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x22, 0x41, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x54, 0x59,
+	0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00,
+	0x12, 0x09, 0x0a, 0x05, 0x41, 0x44, 0x44, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x55,
+	0x50, 0x44, 0x41, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x45, 0x4d, 0x4f,
+	0x56, 0x45, 0x44, 0x10, 0x03, 0x32, 0x9b, 0x07, 0x0a, 0x0d, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x86, 0x01, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74,
+	0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x37, 0x2e,
+	0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c,
+	0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69,
+	0x73, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x38, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61,
+	0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x75, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x12, 0x35, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69,
+	0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73,
+	0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x62, 0x6c, 0x61,
+	0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x75, 0x0a, 0x0f, 0x41, 0x64, 0x64, 0x43, 0x6f,
+	0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x35, 0x2e, 0x62, 0x6c, 0x61,
+	0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x64, 0x64, 0x43, 0x6f,
+	0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x2b, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72,
+	0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x77,
+	0x0a, 0x10, 0x45, 0x64, 0x69, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x12, 0x36, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f,
+	0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x45, 0x64, 0x69, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x62, 0x6c, 0x61,
+	0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62,
+	0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x95, 0x01, 0x0a, 0x16, 0x44, 0x69, 0x73, 0x63,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x12, 0x3c, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f,
+	0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x6c,
+	0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x3d, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e,
+	0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x7d, 0x0a, 0x0e, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72,
+	0x79, 0x12, 0x34, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72,
+	0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x35, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77,
+	0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x70, 0x6f,
+	0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x82,
+	0x01, 0x0a, 0x12, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72,
+	0x61, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x38, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72,
+	0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f,
+	0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6c, 0x6c, 0x61,
+	0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x30, 0x2e, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2e, 0x63,
+	0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x30, 0x01, 0x42, 0x37, 0x5a, 0x35, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x62, 0x6c, 0x61, 0x6d, 0x65, 0x77, 0x61, 0x72, 0x72, 0x69, 0x6f, 0x72, 0x2f, 0x63,
+	0x6f, 0x6c, 0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x2f, 0x63, 0x6f, 0x6c,
+	0x6c, 0x61, 0x62, 0x6f, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_collaboratorspb_collaborators_proto_rawDescOnce sync.Once
+	file_collaboratorspb_collaborators_proto_rawDescData = file_collaboratorspb_collaborators_proto_rawDesc
+)
+
+func file_collaboratorspb_collaborators_proto_rawDescGZIP() []byte {
+	file_collaboratorspb_collaborators_proto_rawDescOnce.Do(func() {
+		file_collaboratorspb_collaborators_proto_rawDescData = protoimpl.X.CompressGZIP(file_collaboratorspb_collaborators_proto_rawDescData)
+	})
+	return file_collaboratorspb_collaborators_proto_rawDescData
+}
+
+var file_collaboratorspb_collaborators_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_collaboratorspb_collaborators_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_collaboratorspb_collaborators_proto_goTypes = []any{
+	(CollaboratorEvent_Type)(0),            // 0: blamewarrior.collaborators.v1.CollaboratorEvent.Type
+	(*Collaborator)(nil),                   // 1: blamewarrior.collaborators.v1.Collaborator
+	(*ListCollaboratorsRequest)(nil),       // 2: blamewarrior.collaborators.v1.ListCollaboratorsRequest
+	(*ListCollaboratorsResponse)(nil),      // 3: blamewarrior.collaborators.v1.ListCollaboratorsResponse
+	(*GetCollaboratorRequest)(nil),         // 4: blamewarrior.collaborators.v1.GetCollaboratorRequest
+	(*AddCollaboratorRequest)(nil),         // 5: blamewarrior.collaborators.v1.AddCollaboratorRequest
+	(*EditCollaboratorRequest)(nil),        // 6: blamewarrior.collaborators.v1.EditCollaboratorRequest
+	(*DisconnectCollaboratorRequest)(nil),  // 7: blamewarrior.collaborators.v1.DisconnectCollaboratorRequest
+	(*DisconnectCollaboratorResponse)(nil), // 8: blamewarrior.collaborators.v1.DisconnectCollaboratorResponse
+	(*SyncRepositoryRequest)(nil),          // 9: blamewarrior.collaborators.v1.SyncRepositoryRequest
+	(*SyncRepositoryResponse)(nil),         // 10: blamewarrior.collaborators.v1.SyncRepositoryResponse
+	(*WatchCollaboratorsRequest)(nil),      // 11: blamewarrior.collaborators.v1.WatchCollaboratorsRequest
+	(*CollaboratorEvent)(nil),              // 12: blamewarrior.collaborators.v1.CollaboratorEvent
+}
+var file_collaboratorspb_collaborators_proto_depIdxs = []int32{
+	1,  // 0: blamewarrior.collaborators.v1.ListCollaboratorsResponse.collaborators:type_name -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 1: blamewarrior.collaborators.v1.AddCollaboratorRequest.collaborator:type_name -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 2: blamewarrior.collaborators.v1.EditCollaboratorRequest.collaborator:type_name -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 3: blamewarrior.collaborators.v1.SyncRepositoryResponse.added:type_name -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 4: blamewarrior.collaborators.v1.SyncRepositoryResponse.updated:type_name -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 5: blamewarrior.collaborators.v1.SyncRepositoryResponse.removed:type_name -> blamewarrior.collaborators.v1.Collaborator
+	0,  // 6: blamewarrior.collaborators.v1.CollaboratorEvent.type:type_name -> blamewarrior.collaborators.v1.CollaboratorEvent.Type
+	1,  // 7: blamewarrior.collaborators.v1.CollaboratorEvent.collaborator:type_name -> blamewarrior.collaborators.v1.Collaborator
+	2,  // 8: blamewarrior.collaborators.v1.Collaborators.ListCollaborators:input_type -> blamewarrior.collaborators.v1.ListCollaboratorsRequest
+	4,  // 9: blamewarrior.collaborators.v1.Collaborators.GetCollaborator:input_type -> blamewarrior.collaborators.v1.GetCollaboratorRequest
+	5,  // 10: blamewarrior.collaborators.v1.Collaborators.AddCollaborator:input_type -> blamewarrior.collaborators.v1.AddCollaboratorRequest
+	6,  // 11: blamewarrior.collaborators.v1.Collaborators.EditCollaborator:input_type -> blamewarrior.collaborators.v1.EditCollaboratorRequest
+	7,  // 12: blamewarrior.collaborators.v1.Collaborators.DisconnectCollaborator:input_type -> blamewarrior.collaborators.v1.DisconnectCollaboratorRequest
+	9,  // 13: blamewarrior.collaborators.v1.Collaborators.SyncRepository:input_type -> blamewarrior.collaborators.v1.SyncRepositoryRequest
+	11, // 14: blamewarrior.collaborators.v1.Collaborators.WatchCollaborators:input_type -> blamewarrior.collaborators.v1.WatchCollaboratorsRequest
+	3,  // 15: blamewarrior.collaborators.v1.Collaborators.ListCollaborators:output_type -> blamewarrior.collaborators.v1.ListCollaboratorsResponse
+	1,  // 16: blamewarrior.collaborators.v1.Collaborators.GetCollaborator:output_type -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 17: blamewarrior.collaborators.v1.Collaborators.AddCollaborator:output_type -> blamewarrior.collaborators.v1.Collaborator
+	1,  // 18: blamewarrior.collaborators.v1.Collaborators.EditCollaborator:output_type -> blamewarrior.collaborators.v1.Collaborator
+	8,  // 19: blamewarrior.collaborators.v1.Collaborators.DisconnectCollaborator:output_type -> blamewarrior.collaborators.v1.DisconnectCollaboratorResponse
+	10, // 20: blamewarrior.collaborators.v1.Collaborators.SyncRepository:output_type -> blamewarrior.collaborators.v1.SyncRepositoryResponse
+	12, // 21: blamewarrior.collaborators.v1.Collaborators.WatchCollaborators:output_type -> blamewarrior.collaborators.v1.CollaboratorEvent
+	15, // [15:22] is the sub-list for method output_type
+	8,  // [8:15] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_collaboratorspb_collaborators_proto_init() }
+func file_collaboratorspb_collaborators_proto_init() {
+	if File_collaboratorspb_collaborators_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_collaboratorspb_collaborators_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Collaborator); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ListCollaboratorsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ListCollaboratorsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*GetCollaboratorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*AddCollaboratorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*EditCollaboratorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*DisconnectCollaboratorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*DisconnectCollaboratorResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*SyncRepositoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*SyncRepositoryResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchCollaboratorsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_collaboratorspb_collaborators_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*CollaboratorEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_collaboratorspb_collaborators_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_collaboratorspb_collaborators_proto_goTypes,
+		DependencyIndexes: file_collaboratorspb_collaborators_proto_depIdxs,
+		EnumInfos:         file_collaboratorspb_collaborators_proto_enumTypes,
+		MessageInfos:      file_collaboratorspb_collaborators_proto_msgTypes,
+	}.Build()
+	File_collaboratorspb_collaborators_proto = out.File
+	file_collaboratorspb_collaborators_proto_rawDesc = nil
+	file_collaboratorspb_collaborators_proto_goTypes = nil
+	file_collaboratorspb_collaborators_proto_depIdxs = nil
+}
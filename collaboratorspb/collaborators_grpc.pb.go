@@ -0,0 +1,396 @@
+// Copyright (C) 2018 The BlameWarrior Authors.
+//
+// This file is a part of BlameWarrior service.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: collaboratorspb/collaborators.proto
+
+package collaboratorspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Collaborators_ListCollaborators_FullMethodName      = "/blamewarrior.collaborators.v1.Collaborators/ListCollaborators"
+	Collaborators_GetCollaborator_FullMethodName        = "/blamewarrior.collaborators.v1.Collaborators/GetCollaborator"
+	Collaborators_AddCollaborator_FullMethodName        = "/blamewarrior.collaborators.v1.Collaborators/AddCollaborator"
+	Collaborators_EditCollaborator_FullMethodName       = "/blamewarrior.collaborators.v1.Collaborators/EditCollaborator"
+	Collaborators_DisconnectCollaborator_FullMethodName = "/blamewarrior.collaborators.v1.Collaborators/DisconnectCollaborator"
+	Collaborators_SyncRepository_FullMethodName         = "/blamewarrior.collaborators.v1.Collaborators/SyncRepository"
+	Collaborators_WatchCollaborators_FullMethodName     = "/blamewarrior.collaborators.v1.Collaborators/WatchCollaborators"
+)
+
+// CollaboratorsClient is the client API for Collaborators service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Collaborators exposes repository collaborators stored by the service.
+type CollaboratorsClient interface {
+	// ListCollaborators returns all collaborators of a repository ordered by login.
+	ListCollaborators(ctx context.Context, in *ListCollaboratorsRequest, opts ...grpc.CallOption) (*ListCollaboratorsResponse, error)
+	// GetCollaborator returns a single collaborator, NOT_FOUND is returned if the account
+	// is not connected to the repository.
+	GetCollaborator(ctx context.Context, in *GetCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error)
+	// AddCollaborator connects an account to the repository registering the repository if
+	// it's not known yet. ALREADY_EXISTS is returned for existing collaborators.
+	AddCollaborator(ctx context.Context, in *AddCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error)
+	// EditCollaborator updates uid and permissions of an existing collaborator.
+	EditCollaborator(ctx context.Context, in *EditCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error)
+	// DisconnectCollaborator removes a collaborator from the repository.
+	DisconnectCollaborator(ctx context.Context, in *DisconnectCollaboratorRequest, opts ...grpc.CallOption) (*DisconnectCollaboratorResponse, error)
+	// SyncRepository replaces repository collaborators, teams and invitations with the ones
+	// currently set on GitHub.
+	SyncRepository(ctx context.Context, in *SyncRepositoryRequest, opts ...grpc.CallOption) (*SyncRepositoryResponse, error)
+	// WatchCollaborators sends an ADDED event for every current collaborator of the repository
+	// followed by events describing subsequent changes until the client cancels the call.
+	WatchCollaborators(ctx context.Context, in *WatchCollaboratorsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CollaboratorEvent], error)
+}
+
+type collaboratorsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCollaboratorsClient(cc grpc.ClientConnInterface) CollaboratorsClient {
+	return &collaboratorsClient{cc}
+}
+
+func (c *collaboratorsClient) ListCollaborators(ctx context.Context, in *ListCollaboratorsRequest, opts ...grpc.CallOption) (*ListCollaboratorsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCollaboratorsResponse)
+	err := c.cc.Invoke(ctx, Collaborators_ListCollaborators_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) GetCollaborator(ctx context.Context, in *GetCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Collaborator)
+	err := c.cc.Invoke(ctx, Collaborators_GetCollaborator_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) AddCollaborator(ctx context.Context, in *AddCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Collaborator)
+	err := c.cc.Invoke(ctx, Collaborators_AddCollaborator_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) EditCollaborator(ctx context.Context, in *EditCollaboratorRequest, opts ...grpc.CallOption) (*Collaborator, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Collaborator)
+	err := c.cc.Invoke(ctx, Collaborators_EditCollaborator_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) DisconnectCollaborator(ctx context.Context, in *DisconnectCollaboratorRequest, opts ...grpc.CallOption) (*DisconnectCollaboratorResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisconnectCollaboratorResponse)
+	err := c.cc.Invoke(ctx, Collaborators_DisconnectCollaborator_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) SyncRepository(ctx context.Context, in *SyncRepositoryRequest, opts ...grpc.CallOption) (*SyncRepositoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncRepositoryResponse)
+	err := c.cc.Invoke(ctx, Collaborators_SyncRepository_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collaboratorsClient) WatchCollaborators(ctx context.Context, in *WatchCollaboratorsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CollaboratorEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Collaborators_ServiceDesc.Streams[0], Collaborators_WatchCollaborators_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchCollaboratorsRequest, CollaboratorEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Collaborators_WatchCollaboratorsClient = grpc.ServerStreamingClient[CollaboratorEvent]
+
+// CollaboratorsServer is the server API for Collaborators service.
+// All implementations must embed UnimplementedCollaboratorsServer
+// for forward compatibility.
+//
+// Collaborators exposes repository collaborators stored by the service.
+type CollaboratorsServer interface {
+	// ListCollaborators returns all collaborators of a repository ordered by login.
+	ListCollaborators(context.Context, *ListCollaboratorsRequest) (*ListCollaboratorsResponse, error)
+	// GetCollaborator returns a single collaborator, NOT_FOUND is returned if the account
+	// is not connected to the repository.
+	GetCollaborator(context.Context, *GetCollaboratorRequest) (*Collaborator, error)
+	// AddCollaborator connects an account to the repository registering the repository if
+	// it's not known yet. ALREADY_EXISTS is returned for existing collaborators.
+	AddCollaborator(context.Context, *AddCollaboratorRequest) (*Collaborator, error)
+	// EditCollaborator updates uid and permissions of an existing collaborator.
+	EditCollaborator(context.Context, *EditCollaboratorRequest) (*Collaborator, error)
+	// DisconnectCollaborator removes a collaborator from the repository.
+	DisconnectCollaborator(context.Context, *DisconnectCollaboratorRequest) (*DisconnectCollaboratorResponse, error)
+	// SyncRepository replaces repository collaborators, teams and invitations with the ones
+	// currently set on GitHub.
+	SyncRepository(context.Context, *SyncRepositoryRequest) (*SyncRepositoryResponse, error)
+	// WatchCollaborators sends an ADDED event for every current collaborator of the repository
+	// followed by events describing subsequent changes until the client cancels the call.
+	WatchCollaborators(*WatchCollaboratorsRequest, grpc.ServerStreamingServer[CollaboratorEvent]) error
+	mustEmbedUnimplementedCollaboratorsServer()
+}
+
+// UnimplementedCollaboratorsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCollaboratorsServer struct{}
+
+func (UnimplementedCollaboratorsServer) ListCollaborators(context.Context, *ListCollaboratorsRequest) (*ListCollaboratorsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCollaborators not implemented")
+}
+func (UnimplementedCollaboratorsServer) GetCollaborator(context.Context, *GetCollaboratorRequest) (*Collaborator, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCollaborator not implemented")
+}
+func (UnimplementedCollaboratorsServer) AddCollaborator(context.Context, *AddCollaboratorRequest) (*Collaborator, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddCollaborator not implemented")
+}
+func (UnimplementedCollaboratorsServer) EditCollaborator(context.Context, *EditCollaboratorRequest) (*Collaborator, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EditCollaborator not implemented")
+}
+func (UnimplementedCollaboratorsServer) DisconnectCollaborator(context.Context, *DisconnectCollaboratorRequest) (*DisconnectCollaboratorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisconnectCollaborator not implemented")
+}
+func (UnimplementedCollaboratorsServer) SyncRepository(context.Context, *SyncRepositoryRequest) (*SyncRepositoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncRepository not implemented")
+}
+func (UnimplementedCollaboratorsServer) WatchCollaborators(*WatchCollaboratorsRequest, grpc.ServerStreamingServer[CollaboratorEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCollaborators not implemented")
+}
+func (UnimplementedCollaboratorsServer) mustEmbedUnimplementedCollaboratorsServer() {}
+func (UnimplementedCollaboratorsServer) testEmbeddedByValue()                       {}
+
+// UnsafeCollaboratorsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CollaboratorsServer will
+// result in compilation errors.
+type UnsafeCollaboratorsServer interface {
+	mustEmbedUnimplementedCollaboratorsServer()
+}
+
+func RegisterCollaboratorsServer(s grpc.ServiceRegistrar, srv CollaboratorsServer) {
+	// If the following call pancis, it indicates UnimplementedCollaboratorsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Collaborators_ServiceDesc, srv)
+}
+
+func _Collaborators_ListCollaborators_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCollaboratorsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).ListCollaborators(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_ListCollaborators_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).ListCollaborators(ctx, req.(*ListCollaboratorsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_GetCollaborator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCollaboratorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).GetCollaborator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_GetCollaborator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).GetCollaborator(ctx, req.(*GetCollaboratorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_AddCollaborator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCollaboratorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).AddCollaborator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_AddCollaborator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).AddCollaborator(ctx, req.(*AddCollaboratorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_EditCollaborator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EditCollaboratorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).EditCollaborator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_EditCollaborator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).EditCollaborator(ctx, req.(*EditCollaboratorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_DisconnectCollaborator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisconnectCollaboratorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).DisconnectCollaborator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_DisconnectCollaborator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).DisconnectCollaborator(ctx, req.(*DisconnectCollaboratorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_SyncRepository_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRepositoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollaboratorsServer).SyncRepository(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Collaborators_SyncRepository_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollaboratorsServer).SyncRepository(ctx, req.(*SyncRepositoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collaborators_WatchCollaborators_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCollaboratorsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CollaboratorsServer).WatchCollaborators(m, &grpc.GenericServerStream[WatchCollaboratorsRequest, CollaboratorEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Collaborators_WatchCollaboratorsServer = grpc.ServerStreamingServer[CollaboratorEvent]
+
+// Collaborators_ServiceDesc is the grpc.ServiceDesc for Collaborators service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Collaborators_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "blamewarrior.collaborators.v1.Collaborators",
+	HandlerType: (*CollaboratorsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCollaborators",
+			Handler:    _Collaborators_ListCollaborators_Handler,
+		},
+		{
+			MethodName: "GetCollaborator",
+			Handler:    _Collaborators_GetCollaborator_Handler,
+		},
+		{
+			MethodName: "AddCollaborator",
+			Handler:    _Collaborators_AddCollaborator_Handler,
+		},
+		{
+			MethodName: "EditCollaborator",
+			Handler:    _Collaborators_EditCollaborator_Handler,
+		},
+		{
+			MethodName: "DisconnectCollaborator",
+			Handler:    _Collaborators_DisconnectCollaborator_Handler,
+		},
+		{
+			MethodName: "SyncRepository",
+			Handler:    _Collaborators_SyncRepository_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCollaborators",
+			Handler:       _Collaborators_WatchCollaborators_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "collaboratorspb/collaborators.proto",
+}
@@ -0,0 +1,683 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/bmizerany/pat"
+)
+
+// FormatTable is a human readable output format of list and repos commands, which also
+// accept FormatJSON.
+const FormatTable = "table"
+
+// CommandEnv holds dependencies shared by operator commands.
+type CommandEnv struct {
+	DB            *sql.DB
+	Collaboration blamewarrior.Collaboration
+	GithubClient  *github.Client
+	GithubBaseURL *url.URL
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+	// SyncJobs is the queue of repository syncs requested via API.
+	SyncJobs SyncJobQueue
+	// OwnerSyncJobs stores owner syncs requested via API.
+	OwnerSyncJobs OwnerSyncJobs
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Command is a subcommand of collaborators binary. Run receives command arguments without
+// the command name and returns the process exit code.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(env *CommandEnv, args []string) int
+}
+
+// Commands lists available subcommands in the order they are printed in usage.
+var Commands []*Command
+
+func init() {
+	Commands = []*Command{
+		{"serve", "[-addr host:port] [-grpc-addr host:port] [-sync-workers n]", "Start HTTP API server", runServe},
+		{"sync", "owner/repo [-dry-run]", "Sync repository collaborators, teams and invitations with GitHub", runSync},
+		{"sync-owner", "owner", "Sync all repositories of a user or an organization", runSyncOwner},
+		{"list", "owner/repo [-format table|json]", "List repository collaborators", runList},
+		{"add", "owner/repo login -uid uid [-permissions \"pull push\"]", "Add a collaborator or update permissions of an existing one", runAdd},
+		{"import", "file [-format csv|json|ndjson]", "Import collaborators from a file", runImport},
+		{"remove", "owner/repo login", "Disconnect a collaborator from repository", runRemove},
+		{"repos", "[-owner owner] [-format table|json]", "List known repositories", runRepos},
+	}
+}
+
+// FindCommand returns the command with given name or nil if there is no such command.
+func FindCommand(name string) *Command {
+	for _, cmd := range Commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// RunCommand runs a subcommand returning its exit code. Unknown commands and incorrect
+// arguments result in exit code 2.
+func RunCommand(env *CommandEnv, name string, args []string) int {
+	cmd := FindCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(env.stderr(), "unknown command %q\n", name)
+		return 2
+	}
+
+	return cmd.Run(env, args)
+}
+
+func (env *CommandEnv) stdout() io.Writer {
+	if env.Stdout == nil {
+		return os.Stdout
+	}
+
+	return env.Stdout
+}
+
+func (env *CommandEnv) stderr() io.Writer {
+	if env.Stderr == nil {
+		return os.Stderr
+	}
+
+	return env.Stderr
+}
+
+// flagSet returns a flag set that reports errors to env.Stderr instead of exiting.
+func (env *CommandEnv) flagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(env.stderr())
+
+	if c := FindCommand(cmd); c != nil {
+		fs.Usage = func() {
+			fmt.Fprintf(env.stderr(), "Usage: %s %s %s\n", binaryName, c.Name, c.Usage)
+			fs.PrintDefaults()
+		}
+	}
+
+	return fs
+}
+
+// parseCommandArgs parses flags interspersed with positional arguments, so that both
+// `sync -dry-run owner/repo` and `sync owner/repo -dry-run` are accepted, and checks
+// the number of positional arguments.
+func parseCommandArgs(fs *flag.FlagSet, args []string, nargs int) ([]string, bool) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, false
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) != nargs {
+		fs.Usage()
+		return nil, false
+	}
+
+	return positional, true
+}
+
+// parseRepositoryName checks that s is a full repository name in owner/repo format
+// optionally qualified with GitHub host name, or a name of a repository hosted by one
+// of env.Providers.
+func parseRepositoryName(env *CommandEnv, s string) bool {
+	if validateRepositoryName(env.Providers, s) != nil {
+		fmt.Fprintf(env.stderr(), "incorrect repository name %q, expected owner/repo or host/owner/repo\n", s)
+		return false
+	}
+
+	return true
+}
+
+// parseLogin checks that s is a valid name of a collaborator of the repository.
+func parseLogin(env *CommandEnv, fullName, s string) bool {
+	if validateLogin(env.Providers, fullName, s) != nil {
+		fmt.Fprintf(env.stderr(), "incorrect login %q\n", s)
+		return false
+	}
+
+	return true
+}
+
+// parseOwner checks that s is a valid GitHub user or organization name optionally
+// qualified with GitHub host name, or a name of an owner hosted by one of env.Providers.
+func parseOwner(env *CommandEnv, s string) bool {
+	if validateOwner(env.Providers, s) != nil {
+		fmt.Fprintf(env.stderr(), "incorrect owner %q\n", s)
+		return false
+	}
+
+	return true
+}
+
+func isValidOutputFormat(env *CommandEnv, format string) bool {
+	if format != FormatTable && format != FormatJSON {
+		fmt.Fprintf(env.stderr(), "unsupported format %q, expected one of table or json\n", format)
+		return false
+	}
+
+	return true
+}
+
+// NewRouter returns HTTP API handler. Repositories of hosts mapped to providers are
+// synchronized with them instead of GitHub. Syncs requested with POST .../collaborators/sync
+// are added to syncJobs to be run by SyncJobRunner, while owner syncs are run right away
+// and recorded in ownerSyncJobs.
+func NewRouter(db *sql.DB, collaboration blamewarrior.Collaboration, githubClient *github.Client,
+	providers map[string]blamewarrior.Provider, syncJobs SyncJobQueue, ownerSyncJobs OwnerSyncJobs) http.Handler {
+
+	mux := pat.New()
+
+	mux.Get("/debug/vars", expvar.Handler())
+	mux.Get("/admin/rate-limits", NewRateLimitsHandler("blamewarrior.com", githubClient.RateLimits))
+
+	exportCollaborators := NewExportCollaboratorsHandler("blamewarrior.com", db, collaboration)
+	exportCollaborators.Providers = providers
+	exportCollaborators.Hosts = githubClient.Hosts
+	importCollaborators := NewImportCollaboratorsHandler("blamewarrior.com", db, collaboration)
+	importCollaborators.Providers = providers
+	importCollaborators.Hosts = githubClient.Hosts
+
+	mux.Get("/collaborators/export", exportCollaborators)
+	mux.Post("/collaborators/import", importCollaborators)
+	mux.Get("/jobs/:id", NewSyncJobHandler("blamewarrior.com", syncJobs))
+
+	fetchCollaborators := NewFetchCollaboratorsHandler("blamewarrior.com", db, collaboration, githubClient)
+	fetchCollaborators.Providers = providers
+	syncCollaborators := NewSyncCollaboratorsHandler("blamewarrior.com", syncJobs)
+	syncCollaborators.Providers = providers
+	addCollaborator := NewAddCollaboratorHandler("blamewarrior.com", db, collaboration)
+	addCollaborator.Providers = providers
+	listCollaborators := NewListCollaboratorHandler("blamewarrior.com", db, collaboration)
+	listCollaborators.Providers = providers
+	listInvitations := NewListInvitationsHandler("blamewarrior.com", db, collaboration)
+	listInvitations.Providers = providers
+	editCollaborator := NewEditCollaboratorHandler("blamewarrior.com", db, collaboration)
+	editCollaborator.Providers = providers
+	disconnectCollaborator := NewDisconnectCollaboratorHandler("blamewarrior.com", db, collaboration)
+	disconnectCollaborator.Providers = providers
+	listTeams := NewListTeamsHandler("blamewarrior.com", db, collaboration)
+	listTeams.Providers = providers
+	listTeamMembers := NewListTeamMembersHandler("blamewarrior.com", db, collaboration)
+	listTeamMembers.Providers = providers
+	ownerSync := NewOwnerSyncHandler("blamewarrior.com", db, collaboration, githubClient, ownerSyncJobs)
+	ownerSync.Providers = providers
+	ownerSyncJob := NewOwnerSyncJobHandler("blamewarrior.com", ownerSyncJobs)
+	ownerSyncJob.Providers = providers
+
+	// repositories and owners hosted on GitHub Enterprise Server or GitLab are addressed with
+	// routes prefixed with the host name, i.e. /ghe.example.com/owner/repo/collaborators, GitLab
+	// subgroups are separated with escaped slashes, i.e. /gitlab.com/group%2Fsubgroup/project
+	for _, prefix := range []string{"", "/:host"} {
+		mux.Get(prefix+"/:username/:repo/collaborators/fetch", canonicalNames(githubClient, fetchCollaborators))
+		mux.Post(prefix+"/:username/:repo/collaborators/sync", canonicalNames(githubClient, syncCollaborators))
+		mux.Post(prefix+"/:username/:repo/collaborators", canonicalNames(githubClient, addCollaborator))
+		mux.Get(prefix+"/:username/:repo/collaborators", canonicalNames(githubClient, listCollaborators))
+		mux.Get(prefix+"/:username/:repo/collaborators/invitations", canonicalNames(githubClient, listInvitations))
+		mux.Put(prefix+"/:username/:repo/collaborators", canonicalNames(githubClient, editCollaborator))
+		mux.Del(prefix+"/:username/:repo/collaborators/:collaborator", canonicalNames(githubClient, disconnectCollaborator))
+		mux.Get(prefix+"/:username/:repo/teams", canonicalNames(githubClient, listTeams))
+		mux.Get(prefix+"/:username/:repo/teams/:team/members", canonicalNames(githubClient, listTeamMembers))
+		mux.Post(prefix+"/:owner/sync", canonicalNames(githubClient, ownerSync))
+		mux.Get(prefix+"/:owner/sync/:job", canonicalNames(githubClient, ownerSyncJob))
+	}
+
+	return mux
+}
+
+func runServe(env *CommandEnv, args []string) int {
+	fs := env.flagSet("serve")
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	grpcAddr := fs.String("grpc-addr", ":9090", "Address to serve gRPC API on")
+	syncWorkers := fs.Int("sync-workers", DefaultSyncWorkers, "Number of repository sync jobs to run concurrently")
+
+	if _, ok := parseCommandArgs(fs, args, 0); !ok {
+		return 2
+	}
+
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Printf("failed to listen on %s: %s", *grpcAddr, err)
+		return 1
+	}
+
+	syncer := NewSyncer(env.DB, env.Collaboration, env.GithubClient)
+	syncer.GithubBaseURL = env.GithubBaseURL
+	syncer.Providers = env.Providers
+
+	grpcServer := NewGRPCService(NewGRPCServer(env.DB, env.Collaboration, syncer))
+
+	syncJobs := env.SyncJobs
+	if syncJobs == nil {
+		syncJobs = NewMemorySyncJobQueue()
+	}
+
+	ownerSyncJobs := env.OwnerSyncJobs
+	if ownerSyncJobs == nil {
+		ownerSyncJobs = NewMemoryOwnerSyncJobs()
+	}
+
+	// jobs are run in background, so they wait for concurrent syncs of the same
+	// repository to finish instead of failing
+	jobSyncer := NewSyncer(env.DB, env.Collaboration, env.GithubClient)
+	jobSyncer.GithubBaseURL = env.GithubBaseURL
+	jobSyncer.Providers = env.Providers
+	jobSyncer.WaitForLock = true
+
+	runner := NewSyncJobRunner(jobSyncer, syncJobs)
+	runner.Workers = *syncWorkers
+
+	go runner.Run(context.Background())
+
+	errs := make(chan error, 2)
+
+	go func() {
+		log.Printf("serving gRPC API on %s", *grpcAddr)
+		errs <- grpcServer.Serve(lis)
+	}()
+
+	go func() {
+		log.Printf("listening on %s", *addr)
+		errs <- http.ListenAndServe(*addr, NewRouter(env.DB, env.Collaboration, env.GithubClient, env.Providers, syncJobs, ownerSyncJobs))
+	}()
+
+	if err := <-errs; err != nil {
+		log.Printf("failed to start server: %s", err)
+		return 1
+	}
+
+	return 0
+}
+
+func runSync(env *CommandEnv, args []string) int {
+	fs := env.flagSet("sync")
+	dryRun := fs.Bool("dry-run", false, "Print changes to collaborators without applying them")
+
+	positional, ok := parseCommandArgs(fs, args, 1)
+	if !ok || !parseRepositoryName(env, positional[0]) {
+		return 2
+	}
+
+	fullName := env.GithubClient.CanonicalName(positional[0])
+
+	syncer := NewSyncer(env.DB, env.Collaboration, env.GithubClient)
+	syncer.GithubBaseURL = env.GithubBaseURL
+	syncer.Providers = env.Providers
+
+	if !*dryRun {
+		if err := syncer.SyncRepository(context.Background(), fullName); err != nil {
+			log.Printf("failed to sync %s: %s", fullName, err)
+			return 1
+		}
+
+		fmt.Fprintf(env.stdout(), "synced %s\n", fullName)
+
+		return 0
+	}
+
+	changes, err := syncer.DiffRepository(context.Background(), fullName)
+	if err != nil {
+		log.Printf("failed to compare %s with GitHub: %s", fullName, err)
+		return 1
+	}
+
+	if changes.Empty() {
+		fmt.Fprintf(env.stdout(), "%s is up to date\n", fullName)
+		return 0
+	}
+
+	w := tabwriter.NewWriter(env.stdout(), 0, 4, 2, ' ', 0)
+	for _, account := range changes.Added {
+		fmt.Fprintf(w, "+\t%s\t%d\t%s\n", account.Login, account.Uid, formatPermissions(account.Permissions))
+	}
+	for _, account := range changes.Updated {
+		fmt.Fprintf(w, "~\t%s\t%d\t%s\n", account.Login, account.Uid, formatPermissions(account.Permissions))
+	}
+	for _, account := range changes.Removed {
+		fmt.Fprintf(w, "-\t%s\t%d\t%s\n", account.Login, account.Uid, formatPermissions(account.Permissions))
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Printf("failed to write changes: %s", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runSyncOwner synchronizes all repositories of an owner printing per-repository results.
+// Rate limits are waited out instead of failing the remaining repositories.
+func runSyncOwner(env *CommandEnv, args []string) int {
+	fs := env.flagSet("sync-owner")
+
+	positional, ok := parseCommandArgs(fs, args, 1)
+	if !ok || !parseOwner(env, positional[0]) {
+		return 2
+	}
+
+	owner := env.GithubClient.CanonicalName(positional[0])
+
+	syncer := NewSyncer(env.DB, env.Collaboration, env.GithubClient)
+	syncer.GithubBaseURL = env.GithubBaseURL
+	syncer.Providers = env.Providers
+	syncer.RateLimitPolicy = github.WaitForReset
+
+	// results are printed once the sync is over, so there is no need to share them
+	jobs := NewMemoryOwnerSyncJobs()
+
+	job, err := jobs.Create(context.Background(), owner)
+	if err != nil {
+		log.Printf("failed to create sync job: %s", err)
+		return 1
+	}
+
+	if err := syncer.SyncOwner(context.Background(), jobs, job); err != nil {
+		log.Printf("failed to sync repositories of %s: %s", owner, err)
+		return 1
+	}
+
+	if job, err = jobs.Get(context.Background(), job.Id); err != nil {
+		log.Printf("failed to get sync results: %s", err)
+		return 1
+	}
+
+	b, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("failed to marshal sync results: %s", err)
+		return 1
+	}
+	fmt.Fprintln(env.stdout(), string(b))
+
+	if job.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func runList(env *CommandEnv, args []string) int {
+	fs := env.flagSet("list")
+	format := fs.String("format", FormatTable, "Output format, one of table or json")
+
+	positional, ok := parseCommandArgs(fs, args, 1)
+	if !ok || !parseRepositoryName(env, positional[0]) || !isValidOutputFormat(env, *format) {
+		return 2
+	}
+
+	fullName := env.GithubClient.CanonicalName(positional[0])
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	accounts, err := env.Collaboration.ListAccounts(ctx, env.DB, fullName)
+	if err != nil {
+		log.Printf("failed to list collaborators of %s: %s", fullName, err)
+		return 1
+	}
+
+	if *format == FormatJSON {
+		if err := json.NewEncoder(env.stdout()).Encode(accounts); err != nil {
+			log.Printf("failed to write collaborators: %s", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	w := tabwriter.NewWriter(env.stdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LOGIN\tUID\tPERMISSIONS\tAFFILIATION\tTEAMS")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", account.Login, account.Uid, formatPermissions(account.Permissions),
+			affiliationOf(account), strings.Join(account.Teams, " "))
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Printf("failed to write collaborators: %s", err)
+		return 1
+	}
+
+	return 0
+}
+
+func runAdd(env *CommandEnv, args []string) int {
+	fs := env.flagSet("add")
+	uid := fs.Int("uid", 0, "GitHub user id of the collaborator")
+	permissions := fs.String("permissions", "pull", "Space-separated list of granted permissions")
+
+	positional, ok := parseCommandArgs(fs, args, 2)
+	if !ok || !parseRepositoryName(env, positional[0]) {
+		return 2
+	}
+
+	perms, err := parsePermissions(*permissions)
+	if err != nil {
+		fmt.Fprintln(env.stderr(), err)
+		return 2
+	}
+
+	record := CollaboratorRecord{
+		Repository:  env.GithubClient.CanonicalName(positional[0]),
+		Login:       positional[1],
+		Uid:         *uid,
+		Permissions: perms,
+		row:         1,
+	}
+
+	if err := validateRecord(env.Providers, record); err != nil {
+		fmt.Fprintln(env.stderr(), err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	report := newImportReport()
+	if err := importCollaborators(ctx, env.DB, env.Collaboration, env.Providers, []CollaboratorRecord{record}, report); err != nil {
+		log.Printf("failed to add %s to %s: %s", record.Login, record.Repository, err)
+		return 1
+	}
+
+	if report.Updated > 0 {
+		fmt.Fprintf(env.stdout(), "updated %s in %s\n", record.Login, record.Repository)
+	} else {
+		fmt.Fprintf(env.stdout(), "added %s to %s\n", record.Login, record.Repository)
+	}
+
+	return 0
+}
+
+// runImport imports collaborators from a file printing the import report. The format is
+// guessed from file extension unless given explicitly.
+func runImport(env *CommandEnv, args []string) int {
+	fs := env.flagSet("import")
+	format := fs.String("format", "", "Format of the file, one of csv, json or ndjson")
+
+	positional, ok := parseCommandArgs(fs, args, 1)
+	if !ok {
+		return 2
+	}
+
+	path := positional[0]
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	if !IsValidRecordsFormat(*format) {
+		fmt.Fprintf(env.stderr(), "unsupported format %q, expected one of csv, json or ndjson\n", *format)
+		return 2
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("failed to open %s: %s", path, err)
+		return 1
+	}
+	defer f.Close()
+
+	report := newImportReport()
+
+	records, err := readRecords(f, *format, report)
+	if err != nil {
+		log.Printf("failed to read %s: %s", path, err)
+		return 1
+	}
+
+	for i := range records {
+		records[i].Repository = env.GithubClient.CanonicalName(records[i].Repository)
+	}
+
+	// records are imported in a single transaction, so an interrupted import is rolled back as a whole
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	if err := importCollaborators(ctx, env.DB, env.Collaboration, env.Providers, records, report); err != nil {
+		log.Printf("failed to import %s: %s", path, err)
+		return 1
+	}
+
+	if err := json.NewEncoder(env.stdout()).Encode(report); err != nil {
+		log.Printf("failed to write import report: %s", err)
+		return 1
+	}
+
+	if len(report.Rejected) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func runRemove(env *CommandEnv, args []string) int {
+	fs := env.flagSet("remove")
+
+	positional, ok := parseCommandArgs(fs, args, 2)
+	if !ok || !parseRepositoryName(env, positional[0]) || !parseLogin(env, positional[0], positional[1]) {
+		return 2
+	}
+
+	fullName, login := env.GithubClient.CanonicalName(positional[0]), positional[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	accounts, err := env.Collaboration.ListAccounts(ctx, env.DB, fullName)
+	if err != nil {
+		log.Printf("failed to list collaborators of %s: %s", fullName, err)
+		return 1
+	}
+
+	found := false
+	for _, account := range accounts {
+		if strings.EqualFold(account.Login, login) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		fmt.Fprintf(env.stderr(), "%s is not a collaborator of %s\n", login, fullName)
+		return 1
+	}
+
+	if err := env.Collaboration.DisconnectAccount(ctx, env.DB, fullName, login); err != nil {
+		log.Printf("failed to remove %s from %s: %s", login, fullName, err)
+		return 1
+	}
+
+	fmt.Fprintf(env.stdout(), "removed %s from %s\n", login, fullName)
+
+	return 0
+}
+
+func runRepos(env *CommandEnv, args []string) int {
+	fs := env.flagSet("repos")
+	owner := fs.String("owner", "", "List only repositories of given GitHub user or organization")
+	format := fs.String("format", FormatTable, "Output format, one of table or json")
+
+	if _, ok := parseCommandArgs(fs, args, 0); !ok || !isValidOutputFormat(env, *format) {
+		return 2
+	}
+
+	if *owner != "" && !parseOwner(env, *owner) {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	repositories, err := env.Collaboration.ListRepositories(ctx, env.DB, env.GithubClient.CanonicalName(*owner))
+	if err != nil {
+		log.Printf("failed to list repositories: %s", err)
+		return 1
+	}
+
+	if *format == FormatJSON {
+		if repositories == nil {
+			repositories = []string{}
+		}
+
+		if err := json.NewEncoder(env.stdout()).Encode(repositories); err != nil {
+			log.Printf("failed to write repositories: %s", err)
+			return 1
+		}
+
+		return 0
+	}
+
+	for _, fullName := range repositories {
+		fmt.Fprintln(env.stdout(), fullName)
+	}
+
+	return 0
+}
@@ -0,0 +1,340 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/gitlab"
+)
+
+func setupCommandEnv() (env *main.CommandEnv, stdout, stderr *bytes.Buffer) {
+	stdout, stderr = new(bytes.Buffer), new(bytes.Buffer)
+
+	return &main.CommandEnv{
+		DB:            blamewarrior.OpenMemoryDatabase(),
+		Collaboration: blamewarrior.NewMemoryCollaborationService(),
+		Stdout:        stdout,
+		Stderr:        stderr,
+	}, stdout, stderr
+}
+
+func TestRunCommand_AddListRemove(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"blamewarrior/repos", "octocat", "-uid", "1", "-permissions", "pull push"}), stderr.String())
+	assert.Equal(t, "added octocat to blamewarrior/repos\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"-uid=2", "blamewarrior/repos", "hubot"}), stderr.String())
+	assert.Equal(t, "added hubot to blamewarrior/repos\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"blamewarrior/repos", "octocat", "-uid", "1", "-permissions", "admin pull push"}), stderr.String())
+	assert.Equal(t, "updated octocat in blamewarrior/repos\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "list", []string{"blamewarrior/repos"}), stderr.String())
+	assert.Equal(t, "LOGIN    UID  PERMISSIONS      AFFILIATION  TEAMS\n"+
+		"hubot    2    pull             direct       \n"+
+		"octocat  1    admin pull push  direct       \n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "remove", []string{"blamewarrior/repos", "hubot"}), stderr.String())
+	assert.Equal(t, "removed hubot from blamewarrior/repos\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "list", []string{"blamewarrior/repos", "-format", "json"}), stderr.String())
+	assert.JSONEq(t, `[{"uid":1,"login":"octocat","permissions":{"admin":true,"pull":true,"push":true},"affiliation":"direct"}]`, stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 1, main.RunCommand(env, "remove", []string{"blamewarrior/repos", "hubot"}))
+	assert.Equal(t, "hubot is not a collaborator of blamewarrior/repos\n", stderr.String())
+}
+
+func TestRunCommand_Repos(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	for _, fullName := range []string{"octocat/hello-world", "blamewarrior/repos", "blamewarrior/hooks", "ghe.example.com/blamewarrior/repos"} {
+		require.NoError(t, env.Collaboration.CreateRepository(context.Background(), env.DB, fullName))
+	}
+
+	require.Equal(t, 0, main.RunCommand(env, "repos", nil), stderr.String())
+	assert.Equal(t, "blamewarrior/hooks\nblamewarrior/repos\nghe.example.com/blamewarrior/repos\noctocat/hello-world\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "repos", []string{"-owner", "blamewarrior", "-format", "json"}), stderr.String())
+	assert.Equal(t, "[\"blamewarrior/hooks\",\"blamewarrior/repos\"]\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "repos", []string{"-owner", "ghe.example.com/blamewarrior", "-format", "json"}), stderr.String())
+	assert.Equal(t, "[\"ghe.example.com/blamewarrior/repos\"]\n", stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "repos", []string{"-owner", "hubot", "-format", "json"}), stderr.String())
+	assert.Equal(t, "[]\n", stdout.String())
+}
+
+func TestNewRouter_HostQualifiedRoutes(t *testing.T) {
+	env, _, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"blamewarrior/repos", "octocat", "-uid", "1"}), stderr.String())
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"ghe.example.com/blamewarrior/repos", "hubot", "-uid", "2"}), stderr.String())
+
+	router := main.NewRouter(env.DB, env.Collaboration, github.NewClient(nil), nil, main.NewMemorySyncJobQueue(), main.NewMemoryOwnerSyncJobs())
+
+	results := []struct {
+		Path         string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{"/blamewarrior/repos/collaborators", http.StatusOK, `[{"uid":1,"login":"octocat","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"/github.com/blamewarrior/repos/collaborators", http.StatusOK, `[{"uid":1,"login":"octocat","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"/ghe.example.com/blamewarrior/repos/collaborators", http.StatusOK, `[{"uid":2,"login":"hubot","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"/GHE.example.com/blamewarrior/repos/collaborators", http.StatusOK, `[{"uid":2,"login":"hubot","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"/ghe.example.org/blamewarrior/repos/collaborators", http.StatusOK, `[]`},
+		{"/ghe_example/blamewarrior/repos/collaborators", http.StatusBadRequest, "Incorrect full name\n"},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("GET", result.Path, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.Path)
+		if w.Code == http.StatusOK {
+			assert.JSONEq(t, result.ResponseBody, w.Body.String(), result.Path)
+		} else {
+			assert.Equal(t, result.ResponseBody, w.Body.String(), result.Path)
+		}
+	}
+}
+
+func TestRunCommand_SyncDryRun(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"login":"octocat", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}},{"login":"monalisa", "id": 3, "permissions": {"pull": true}}]`))
+	})
+
+	env.GithubClient = github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String()))
+	env.GithubBaseURL = testAPIEndpoint
+
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"blamewarrior/repos", "octocat", "-uid", "1"}), stderr.String())
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"blamewarrior/repos", "hubot", "-uid", "2"}), stderr.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "sync", []string{"blamewarrior/repos", "-dry-run"}), stderr.String())
+	assert.Equal(t, "+  monalisa  3  pull\n"+
+		"~  octocat   1  pull push\n"+
+		"-  hubot     2  pull\n", stdout.String())
+
+	accounts, err := env.Collaboration.ListAccounts(context.Background(), env.DB, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+}
+
+func TestNewRouter_GitLabNames(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	// GitLab names are checked with GitHub rules unless the host is mapped to GitLab
+	assert.Equal(t, 2, main.RunCommand(env, "add", []string{"gitlab.com/blamewarrior/services/repos", "octocat", "-uid", "1"}))
+
+	env.Providers = map[string]blamewarrior.Provider{"gitlab.com": gitlab.NewClient("gitlab.com", nil)}
+
+	for _, args := range [][]string{
+		{"gitlab.com/blamewarrior/services/repos", "octocat", "-uid", "1"},
+		{"gitlab.com/john.doe/project", "john.doe", "-uid", "2"},
+		{"gitlab.com/my_group/project", "john_doe", "-uid", "3"},
+	} {
+		stdout.Reset()
+		require.Equal(t, 0, main.RunCommand(env, "add", args), stderr.String())
+		assert.Equal(t, "added "+args[1]+" to "+args[0]+"\n", stdout.String())
+	}
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "repos", []string{"-owner", "gitlab.com/john.doe"}), stderr.String())
+	assert.Equal(t, "gitlab.com/john.doe/project\n", stdout.String())
+
+	router := main.NewRouter(env.DB, env.Collaboration, github.NewClient(nil), env.Providers, main.NewMemorySyncJobQueue(), main.NewMemoryOwnerSyncJobs())
+
+	results := []struct {
+		Method       string
+		Path         string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{"GET", "/gitlab.com/blamewarrior%2Fservices/repos/collaborators", http.StatusOK, `[{"uid":1,"login":"octocat","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"GET", "/gitlab.com/john.doe/project/collaborators", http.StatusOK, `[{"uid":2,"login":"john.doe","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"GET", "/gitlab.com/my_group/project/collaborators", http.StatusOK, `[{"uid":3,"login":"john_doe","permissions":{"pull":true},"affiliation":"direct"}]`},
+		{"GET", "/gitlab.com/-group/project/collaborators", http.StatusBadRequest, "Incorrect full name\n"},
+		{"GET", "/gitlab.com/group/project.git/collaborators", http.StatusBadRequest, "Incorrect full name\n"},
+		{"GET", "/ghe.example.com/john.doe/project/collaborators", http.StatusBadRequest, "Incorrect full name\n"},
+		{"DELETE", "/gitlab.com/john.doe/project/collaborators/john.doe", http.StatusNoContent, ""},
+		{"DELETE", "/gitlab.com/my_group/project/collaborators/.john", http.StatusBadRequest, "Incorrect collaborator name\n"},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest(result.Method, result.Path, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.Path)
+		if w.Code == http.StatusOK {
+			assert.JSONEq(t, result.ResponseBody, w.Body.String(), result.Path)
+		} else {
+			assert.Equal(t, result.ResponseBody, w.Body.String(), result.Path)
+		}
+	}
+}
+
+func TestNewRouter_EnterpriseNames(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	hosts, err := github.ReadHosts(strings.NewReader(`[{"name": "ghe.example.com", "owners": ["acme"]}]`))
+	require.NoError(t, err)
+
+	env.GithubClient = github.NewClient(nil)
+	env.GithubClient.Hosts = hosts
+
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"acme/repo", "octocat", "-uid", "1"}), stderr.String())
+	require.Equal(t, 0, main.RunCommand(env, "add", []string{"ghe.example.com/acme/repo", "hubot", "-uid", "2"}), stderr.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "repos", nil), stderr.String())
+	assert.Equal(t, "ghe.example.com/acme/repo\n", stdout.String())
+
+	router := main.NewRouter(env.DB, env.Collaboration, env.GithubClient, nil, main.NewMemorySyncJobQueue(), main.NewMemoryOwnerSyncJobs())
+
+	collaborators := `[
+		{"uid":2,"login":"hubot","permissions":{"pull":true},"affiliation":"direct"},
+		{"uid":1,"login":"octocat","permissions":{"pull":true},"affiliation":"direct"}
+	]`
+
+	for _, path := range []string{"/acme/repo/collaborators", "/ghe.example.com/acme/repo/collaborators"} {
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.JSONEq(t, collaborators, w.Body.String(), path)
+	}
+
+	req, err := http.NewRequest("DELETE", "/acme/repo/collaborators/octocat", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "list", []string{"acme/repo", "-format", "json"}), stderr.String())
+	assert.JSONEq(t, `[{"uid":2,"login":"hubot","permissions":{"pull":true},"affiliation":"direct"}]`, stdout.String())
+}
+
+func TestRunCommand_Import(t *testing.T) {
+	env, stdout, stderr := setupCommandEnv()
+	defer env.DB.Close()
+
+	f, err := ioutil.TempFile("", "collaborators")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("repository,login,uid,permissions\n" +
+		"blamewarrior/repos,octocat,1,pull push\n" +
+		"blamewarrior/repos,-hubot,2,pull\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, 1, main.RunCommand(env, "import", []string{f.Name(), "-format", "csv"}), stderr.String())
+
+	var report main.ImportReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+
+	assert.Equal(t, 1, report.Added)
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, 2, report.Rejected[0].Row)
+
+	stdout.Reset()
+	require.Equal(t, 0, main.RunCommand(env, "list", []string{"blamewarrior/repos"}), stderr.String())
+	assert.Contains(t, stdout.String(), "octocat")
+}
+
+func TestRunCommand_IncorrectArguments(t *testing.T) {
+	results := []struct {
+		Command string
+		Args    []string
+	}{
+		{"unknown", nil},
+		{"list", nil},
+		{"list", []string{"blamewarrior"}},
+		{"list", []string{"blamewarrior/repos", "-format", "xml"}},
+		{"add", []string{"blamewarrior/repos"}},
+		{"add", []string{"blamewarrior/repos", "octocat", "-permissions", "owner"}},
+		{"remove", []string{"blamewarrior/repos", "octocat", "hubot"}},
+		{"sync", []string{"blamewarrior/repos", "-force"}},
+		{"repos", []string{"blamewarrior"}},
+		{"sync-owner", nil},
+		{"sync-owner", []string{"blamewarrior/repos"}},
+		{"import", nil},
+		{"import", []string{"collaborators.xml"}},
+		{"import", []string{"collaborators.csv", "-format", "xml"}},
+	}
+
+	for _, result := range results {
+		env, stdout, stderr := setupCommandEnv()
+
+		assert.Equal(t, 2, main.RunCommand(env, result.Command, result.Args), "%s %v", result.Command, result.Args)
+		assert.Empty(t, stdout.String(), "%s %v", result.Command, result.Args)
+		assert.NotEmpty(t, stderr.String(), "%s %v", result.Command, result.Args)
+
+		env.DB.Close()
+	}
+}
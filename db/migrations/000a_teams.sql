@@ -0,0 +1,18 @@
+-- Adds teams with access to repositories and their members, which are filled in by
+-- repository syncs.
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL primary key,
+    repository_id integer NOT NULL REFERENCES repositories(id),
+    uid integer NOT NULL,
+    name varchar(255),
+    slug varchar(255) NOT NULL,
+    permission varchar(32),
+    UNIQUE (repository_id, uid),
+    UNIQUE (repository_id, slug)
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id integer NOT NULL REFERENCES teams(id),
+    account_id integer NOT NULL REFERENCES accounts(id),
+    UNIQUE (team_id, account_id)
+);
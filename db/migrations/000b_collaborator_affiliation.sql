@@ -0,0 +1,3 @@
+-- Adds the way collaborators got access to repositories. Existing collaborators are
+-- considered direct ones until the next sync of their repositories.
+ALTER TABLE collaboration ADD COLUMN IF NOT EXISTS affiliation varchar(16) NOT NULL DEFAULT 'direct';
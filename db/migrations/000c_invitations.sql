@@ -0,0 +1,13 @@
+-- Adds pending repository invitations, which are filled in by repository syncs.
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL primary key,
+    repository_id integer NOT NULL REFERENCES repositories(id),
+    uid integer NOT NULL,
+    invitee_uid integer,
+    invitee_login varchar(255) NOT NULL,
+    inviter_login varchar(255),
+    permission varchar(32),
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    UNIQUE (repository_id, uid)
+);
@@ -0,0 +1,64 @@
+-- Makes repository names and logins case-insensitive, since GitHub treats `Owner/Repo`
+-- and `owner/repo` as the same repository. Repositories and accounts that only differ
+-- in case are merged into the oldest one.
+--
+-- Teams, team members and invitations of merged repositories are dropped, they are
+-- restored by the next sync of the repository.
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TEMPORARY TABLE repository_merges ON COMMIT DROP AS
+  SELECT repositories.id AS old_id, survivors.id AS new_id
+    FROM repositories
+    JOIN (
+      SELECT min(id) AS id, lower(full_name) AS full_name FROM repositories GROUP BY lower(full_name)
+    ) AS survivors ON survivors.full_name = lower(repositories.full_name)
+    WHERE repositories.id <> survivors.id;
+
+INSERT INTO collaboration (repository_id, account_id, affiliation)
+  SELECT repository_merges.new_id, collaboration.account_id, collaboration.affiliation
+    FROM collaboration
+    JOIN repository_merges ON repository_merges.old_id = collaboration.repository_id
+  ON CONFLICT DO NOTHING;
+
+DELETE FROM collaboration WHERE repository_id IN (SELECT old_id FROM repository_merges);
+DELETE FROM team_members WHERE team_id IN (
+  SELECT id FROM teams WHERE repository_id IN (SELECT old_id FROM repository_merges)
+);
+DELETE FROM teams WHERE repository_id IN (SELECT old_id FROM repository_merges);
+DELETE FROM invitations WHERE repository_id IN (SELECT old_id FROM repository_merges);
+DELETE FROM repositories WHERE id IN (SELECT old_id FROM repository_merges);
+
+CREATE TEMPORARY TABLE account_merges ON COMMIT DROP AS
+  SELECT accounts.id AS old_id, survivors.id AS new_id
+    FROM accounts
+    JOIN (
+      SELECT min(id) AS id, lower(login) AS login FROM accounts GROUP BY lower(login)
+    ) AS survivors ON survivors.login = lower(accounts.login)
+    WHERE accounts.id <> survivors.id;
+
+INSERT INTO collaboration (repository_id, account_id, affiliation)
+  SELECT collaboration.repository_id, account_merges.new_id, collaboration.affiliation
+    FROM collaboration
+    JOIN account_merges ON account_merges.old_id = collaboration.account_id
+  ON CONFLICT DO NOTHING;
+
+INSERT INTO team_members (team_id, account_id)
+  SELECT team_members.team_id, account_merges.new_id
+    FROM team_members
+    JOIN account_merges ON account_merges.old_id = team_members.account_id
+  ON CONFLICT DO NOTHING;
+
+DELETE FROM collaboration WHERE account_id IN (SELECT old_id FROM account_merges);
+DELETE FROM team_members WHERE account_id IN (SELECT old_id FROM account_merges);
+DELETE FROM accounts WHERE id IN (SELECT old_id FROM account_merges);
+
+ALTER TABLE repositories ALTER COLUMN full_name TYPE citext;
+ALTER TABLE accounts ALTER COLUMN login TYPE citext;
+ALTER TABLE accounts ADD UNIQUE (login);
+ALTER TABLE invitations
+  ALTER COLUMN invitee_login TYPE citext,
+  ALTER COLUMN inviter_login TYPE citext;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Stores GitHub API responses to be revalidated with conditional requests when the service
+-- runs with -github-cache=postgres. Keys are SHA-256 hashes of the request URL and token,
+-- rows that have not been updated for -github-cache-ttl are deleted.
+CREATE TABLE github_response_cache (
+    key char(64) primary key,
+    etag varchar(255) NOT NULL,
+    header jsonb NOT NULL,
+    body bytea NOT NULL,
+    updated_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX github_response_cache_updated_at_idx ON github_response_cache (updated_at);
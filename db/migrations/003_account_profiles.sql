@@ -0,0 +1,7 @@
+-- Adds GitHub profile data of accounts, which is filled in by repository syncs.
+ALTER TABLE accounts
+    ADD COLUMN name varchar(255),
+    ADD COLUMN avatar_url varchar(2048),
+    ADD COLUMN type varchar(16),
+    ADD COLUMN site_admin boolean NOT NULL DEFAULT false,
+    ADD COLUMN profile_updated_at timestamp with time zone;
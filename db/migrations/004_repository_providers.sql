@@ -0,0 +1,5 @@
+-- Adds the provider repositories are synchronized with. Repositories that have not been
+-- synchronized yet have no provider, all of the existing ones come from GitHub.
+ALTER TABLE repositories ADD COLUMN provider varchar(16);
+
+UPDATE repositories SET provider = 'github';
@@ -0,0 +1,17 @@
+-- Stores repository syncs requested with POST /owner/repo/collaborators/sync. Jobs are claimed
+-- by instances with SELECT ... FOR UPDATE SKIP LOCKED, the partial index keeps the lookup of
+-- pending jobs cheap as finished ones pile up.
+CREATE TABLE sync_jobs (
+    id BIGSERIAL primary key,
+    repository citext NOT NULL,
+    status varchar(16) NOT NULL,
+    added integer NOT NULL DEFAULT 0,
+    updated integer NOT NULL DEFAULT 0,
+    removed integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone
+);
+
+CREATE INDEX sync_jobs_pending_idx ON sync_jobs (id) WHERE status IN ('queued', 'running');
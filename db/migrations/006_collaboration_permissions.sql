@@ -0,0 +1,9 @@
+-- Moves permissions from accounts to collaboration, since an account may be granted
+-- different permissions in each repository. Existing collaborators keep the permissions
+-- of their accounts until the next sync of their repositories.
+ALTER TABLE collaboration ADD COLUMN permissions jsonb;
+
+UPDATE collaboration SET permissions = accounts.permissions
+    FROM accounts WHERE accounts.id = collaboration.account_id;
+
+ALTER TABLE accounts DROP COLUMN permissions;
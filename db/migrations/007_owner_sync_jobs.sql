@@ -0,0 +1,10 @@
+-- Stores owner syncs requested with POST /owner/sync in sync_jobs along with repository syncs, so
+-- that their progress is reported by any instance. Repositories synced within an owner sync are
+-- stored as finished jobs referring to it with parent_id.
+ALTER TABLE sync_jobs ALTER COLUMN repository DROP NOT NULL;
+ALTER TABLE sync_jobs ADD COLUMN owner citext;
+ALTER TABLE sync_jobs ADD COLUMN total integer NOT NULL DEFAULT 0;
+ALTER TABLE sync_jobs ADD COLUMN parent_id bigint REFERENCES sync_jobs (id) ON DELETE CASCADE;
+ALTER TABLE sync_jobs ADD CONSTRAINT sync_jobs_repository_or_owner CHECK ((repository IS NULL) <> (owner IS NULL));
+
+CREATE INDEX sync_jobs_parent_id_idx ON sync_jobs (parent_id) WHERE parent_id IS NOT NULL;
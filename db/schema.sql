@@ -1,20 +1,89 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
 CREATE TABLE repositories (
     id SERIAL primary key,
-    full_name varchar(255),
+    full_name citext,
+    provider varchar(16),
     UNIQUE (full_name)
 );
 
 CREATE TABLE accounts (
     id SERIAL primary key,
     uid varchar(255),
-    login varchar(255),
-    permissions jsonb
+    login citext,
+    name varchar(255),
+    avatar_url varchar(2048),
+    type varchar(16),
+    site_admin boolean NOT NULL DEFAULT false,
+    profile_updated_at timestamp with time zone,
+    UNIQUE (login)
 );
 
 
 CREATE TABLE collaboration (
     repository_id integer NOT NULL REFERENCES repositories(id),
     account_id integer NOT NULL REFERENCES accounts(id),
+    affiliation varchar(16) NOT NULL DEFAULT 'direct',
+    permissions jsonb,
     UNIQUE (repository_id, account_id)
 );
 
+CREATE TABLE teams (
+    id SERIAL primary key,
+    repository_id integer NOT NULL REFERENCES repositories(id),
+    uid integer NOT NULL,
+    name varchar(255),
+    slug varchar(255) NOT NULL,
+    permission varchar(32),
+    UNIQUE (repository_id, uid),
+    UNIQUE (repository_id, slug)
+);
+
+CREATE TABLE team_members (
+    team_id integer NOT NULL REFERENCES teams(id),
+    account_id integer NOT NULL REFERENCES accounts(id),
+    UNIQUE (team_id, account_id)
+);
+
+CREATE TABLE invitations (
+    id SERIAL primary key,
+    repository_id integer NOT NULL REFERENCES repositories(id),
+    uid integer NOT NULL,
+    invitee_uid integer,
+    invitee_login citext NOT NULL,
+    inviter_login citext,
+    permission varchar(32),
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    UNIQUE (repository_id, uid)
+);
+
+CREATE TABLE github_response_cache (
+    key char(64) primary key,
+    etag varchar(255) NOT NULL,
+    header jsonb NOT NULL,
+    body bytea NOT NULL,
+    updated_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX github_response_cache_updated_at_idx ON github_response_cache (updated_at);
+
+CREATE TABLE sync_jobs (
+    id BIGSERIAL primary key,
+    repository citext,
+    status varchar(16) NOT NULL,
+    added integer NOT NULL DEFAULT 0,
+    updated integer NOT NULL DEFAULT 0,
+    removed integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    owner citext,
+    total integer NOT NULL DEFAULT 0,
+    parent_id bigint REFERENCES sync_jobs (id) ON DELETE CASCADE,
+    CONSTRAINT sync_jobs_repository_or_owner CHECK ((repository IS NULL) <> (owner IS NULL))
+);
+
+CREATE INDEX sync_jobs_pending_idx ON sync_jobs (id) WHERE status IN ('queued', 'running');
+CREATE INDEX sync_jobs_parent_id_idx ON sync_jobs (parent_id) WHERE parent_id IS NOT NULL;
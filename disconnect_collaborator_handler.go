@@ -16,40 +16,49 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
 )
 
 type DisconnectCollaboratorHandler struct {
 	hostname      string
 	db            *sql.DB
 	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
 }
 
 func (h *DisconnectCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	host := req.URL.Query().Get(":host")
 	username := req.URL.Query().Get(":username")
 	repo := req.URL.Query().Get(":repo")
 
 	collaboratorName := req.URL.Query().Get(":collaborator")
 
-	fullName := fmt.Sprintf("%s/%s", username, repo)
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
 
-	if username == "" || repo == "" {
+	if validateRepositoryName(h.Providers, fullName) != nil {
 		http.Error(w, "Incorrect full name", http.StatusBadRequest)
 		return
 	}
 
-	if collaboratorName == "" {
+	if validateLogin(h.Providers, fullName, collaboratorName) != nil {
 		http.Error(w, "Incorrect collaborator name", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.collaboration.DisconnectAccount(h.db, fullName, collaboratorName); err != nil {
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	if err := h.collaboration.DisconnectAccount(ctx, h.db, fullName, collaboratorName); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		log.Printf("%s\t%s\t%v\t%s", "POST", req.RequestURI, http.StatusInternalServerError, err)
 		return
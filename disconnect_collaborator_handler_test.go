@@ -62,7 +62,7 @@ func TestDisconnectCollaboratorHandler(t *testing.T) {
 	for _, result := range results {
 		db, teardown := setupTestDBConn()
 
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name))
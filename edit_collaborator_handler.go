@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -23,22 +24,27 @@ import (
 	"net/http"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
 )
 
 type EditCollaboratorHandler struct {
 	hostname      string
 	db            *sql.DB
 	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
 }
 
 func (h *EditCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	host := req.URL.Query().Get(":host")
 	username := req.URL.Query().Get(":username")
 	repo := req.URL.Query().Get(":repo")
 
-	fullName := fmt.Sprintf("%s/%s", username, repo)
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
 
-	if username == "" || repo == "" {
+	if validateRepositoryName(h.Providers, fullName) != nil {
 		http.Error(w, "Incorrect full name", http.StatusBadRequest)
 		return
 	}
@@ -46,11 +52,24 @@ func (h *EditCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Req
 	var account blamewarrior.Account
 
 	if err := json.NewDecoder(req.Body).Decode(&account); err != nil {
+		if _, ok := err.(*blamewarrior.UnknownPermissionError); ok {
+			http.Error(w, "Unknown permission", http.StatusUnprocessableEntity)
+			return
+		}
+
 		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.collaboration.EditAccount(h.db, fullName, &account); err != nil {
+	if validateLogin(h.Providers, fullName, account.Login) != nil {
+		http.Error(w, "Incorrect collaborator name", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	if err := h.collaboration.EditAccount(ctx, h.db, fullName, &account); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		log.Printf("%s\t%s\t%v\t%s", "POST", req.RequestURI, http.StatusInternalServerError, err)
 		return
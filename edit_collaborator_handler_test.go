@@ -38,7 +38,7 @@ func TestEditCollaboratorHandler(t *testing.T) {
 
 	for _, result := range results {
 		db, teardown := setupTestDBConn()
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name))
@@ -60,6 +60,48 @@ func TestEditCollaboratorHandler(t *testing.T) {
 	}
 }
 
+func TestEditCollaboratorHandler_UnknownPermission(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	results := []struct {
+		RequestBody  string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": true, "pusj": true}}`,
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: "Unknown permission\n",
+		},
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": "yes"}}`,
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Unable to decode request body\n",
+		},
+		{
+			RequestBody:  `{"uid": 1345, "login": "blamewarrior", "permissions": {"pull": true, "push": true}}`,
+			ResponseCode: http.StatusOK,
+			ResponseBody: "",
+		},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("PUT", "/collaborators?:username=blamewarrior&:repo=test_permissions", bytes.NewBufferString(result.RequestBody))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewEditCollaboratorHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.RequestBody)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body), result.RequestBody)
+	}
+}
+
 const (
 	editCollaboratorRequestBody = `
     {
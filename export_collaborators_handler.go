@@ -0,0 +1,150 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// ExportCollaboratorsHandler streams collaborators of a single repository, all repositories
+// of an owner or every known repository in CSV, JSON or NDJSON format.
+type ExportCollaboratorsHandler struct {
+	hostname      string
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+
+	// Hosts qualifies names of owners assigned to GitHub Enterprise Server hosts, see
+	// github.Hosts.Canonical.
+	Hosts *github.Hosts
+}
+
+func (h *ExportCollaboratorsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+
+	if !IsValidRecordsFormat(format) {
+		http.Error(w, "Incorrect format", http.StatusBadRequest)
+		return
+	}
+
+	fullName := req.URL.Query().Get("repository")
+	owner := req.URL.Query().Get("owner")
+
+	if owner != "" && validateOwner(h.Providers, owner) != nil {
+		http.Error(w, "Incorrect owner name", http.StatusBadRequest)
+		return
+	}
+
+	if owner != "" {
+		owner = h.Hosts.Canonical(owner)
+	}
+
+	if fullName != "" {
+		fullName = h.Hosts.Canonical(fullName)
+	}
+
+	if fullName != "" {
+		if repoOwner, _ := github.SplitRepositoryName(fullName); validateRepositoryName(h.Providers, fullName) != nil ||
+			(owner != "" && !strings.EqualFold(owner, repoOwner)) {
+			http.Error(w, "Incorrect full name", http.StatusBadRequest)
+			return
+		}
+	}
+
+	repositories := []string{fullName}
+	if fullName == "" {
+		ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+		defer cancel()
+
+		var err error
+		if repositories, err = h.collaboration.ListRepositories(ctx, h.db, owner); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", recordsContentTypes[format])
+	if format == FormatCSV {
+		w.Header().Set("Content-Disposition", `attachment; filename="collaborators.csv"`)
+	}
+
+	rw, err := newRecordWriter(w, format)
+	if err != nil {
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the response status has already been sent, so failures can only be logged from now on
+	for _, repository := range repositories {
+		if err := h.exportRepository(req.Context(), rw, repository); err != nil {
+			log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+			return
+		}
+
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	if err := rw.Close(); err != nil {
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+	}
+}
+
+func (h *ExportCollaboratorsHandler) exportRepository(ctx context.Context, rw recordWriter, fullName string) error {
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	accounts, err := h.collaboration.ListAccounts(ctx, h.db, fullName)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if err := rw.Write(CollaboratorRecord{
+			Repository:  fullName,
+			Login:       account.Login,
+			Uid:         account.Uid,
+			Permissions: account.Permissions,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return rw.Flush()
+}
+
+func NewExportCollaboratorsHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration) *ExportCollaboratorsHandler {
+	return &ExportCollaboratorsHandler{
+		hostname:      hostname,
+		db:            db,
+		collaboration: collaboration,
+	}
+}
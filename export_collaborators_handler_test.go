@@ -0,0 +1,147 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+func TestExportCollaboratorsHandler(t *testing.T) {
+
+	results := []struct {
+		Query        string
+		ResponseCode int
+		ContentType  string
+		ResponseBody string
+	}{
+		{
+			Query:        "format=xml",
+			ResponseCode: http.StatusBadRequest,
+			ContentType:  "text/plain; charset=utf-8",
+			ResponseBody: "Incorrect format\n",
+		},
+		{
+			Query:        "format=csv&repository=blamewarrior",
+			ResponseCode: http.StatusBadRequest,
+			ContentType:  "text/plain; charset=utf-8",
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Query:        "format=csv&owner=blame_warrior",
+			ResponseCode: http.StatusBadRequest,
+			ContentType:  "text/plain; charset=utf-8",
+			ResponseBody: "Incorrect owner name\n",
+		},
+		{
+			Query:        "format=csv&owner=octocat&repository=blamewarrior/repos",
+			ResponseCode: http.StatusBadRequest,
+			ContentType:  "text/plain; charset=utf-8",
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Query:        "format=csv&repository=blamewarrior/repos",
+			ResponseCode: http.StatusOK,
+			ContentType:  "text/csv; charset=utf-8",
+			ResponseBody: "repository,login,uid,permissions\n" +
+				"blamewarrior/repos,hubot,2,pull\n" +
+				"blamewarrior/repos,octocat,1,pull\n",
+		},
+		{
+			Query:        "format=ndjson&owner=blamewarrior",
+			ResponseCode: http.StatusOK,
+			ContentType:  "application/x-ndjson",
+			ResponseBody: "{\"repository\":\"blamewarrior/hooks\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}}\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"hubot\",\"uid\":2,\"permissions\":{\"pull\":true}}\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}}\n",
+		},
+		{
+			Query:        "format=ndjson&owner=BlameWarrior",
+			ResponseCode: http.StatusOK,
+			ContentType:  "application/x-ndjson",
+			ResponseBody: "{\"repository\":\"blamewarrior/hooks\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}}\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"hubot\",\"uid\":2,\"permissions\":{\"pull\":true}}\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}}\n",
+		},
+		{
+			Query:        "",
+			ResponseCode: http.StatusOK,
+			ContentType:  "application/json",
+			ResponseBody: "[\n" +
+				"{\"repository\":\"blamewarrior/hooks\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}},\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"hubot\",\"uid\":2,\"permissions\":{\"pull\":true}},\n" +
+				"{\"repository\":\"blamewarrior/repos\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}},\n" +
+				"{\"repository\":\"octocat/hello-world\",\"login\":\"octocat\",\"uid\":1,\"permissions\":{\"pull\":true}}\n" +
+				"]\n",
+		},
+		{
+			Query:        "format=json&owner=hubot",
+			ResponseCode: http.StatusOK,
+			ContentType:  "application/json",
+			ResponseBody: "[]\n",
+		},
+	}
+
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	seed := []struct {
+		Repository string
+		Account    blamewarrior.Account
+	}{
+		{"blamewarrior/repos", blamewarrior.Account{Uid: 1, Login: "octocat", Permissions: blamewarrior.AccountPermissions{Pull: true}}},
+		{"blamewarrior/repos", blamewarrior.Account{Uid: 2, Login: "hubot", Permissions: blamewarrior.AccountPermissions{Pull: true}}},
+		{"blamewarrior/hooks", blamewarrior.Account{Uid: 1, Login: "octocat", Permissions: blamewarrior.AccountPermissions{Pull: true}}},
+		{"octocat/hello-world", blamewarrior.Account{Uid: 1, Login: "octocat", Permissions: blamewarrior.AccountPermissions{Pull: true}}},
+	}
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	for _, s := range seed {
+		require.NoError(t, collaboration.CreateRepository(context.Background(), tx, s.Repository))
+
+		account := s.Account
+		_, err = collaboration.AddAccount(context.Background(), tx, s.Repository, &account)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	for _, result := range results {
+		req, err := http.NewRequest("GET", "/collaborators/export?"+result.Query, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewExportCollaboratorsHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.Query)
+		assert.Equal(t, result.ContentType, w.Header().Get("Content-Type"), result.Query)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body), result.Query)
+	}
+}
@@ -20,11 +20,16 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
 	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/gitlab"
 )
 
 type FetchCollaboratorsHandler struct {
@@ -34,70 +39,61 @@ type FetchCollaboratorsHandler struct {
 	githubClient  *github.Client
 
 	GithubBaseURL *url.URL
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
 }
 
 func (h *FetchCollaboratorsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	host := req.URL.Query().Get(":host")
 	username := req.URL.Query().Get(":username")
 	repo := req.URL.Query().Get(":repo")
 
-	if username == "" || repo == "" {
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
+
+	if validateRepositoryName(h.Providers, fullName) != nil {
 		http.Error(w, "Incorrect full name", http.StatusBadRequest)
 		return
 	}
 
-	fullName := fmt.Sprintf("%s/%s", username, repo)
-
-	err := h.fetchCollaborators(fullName)
-
-	if err != nil {
+	owner, _ := github.SplitRepositoryName(fullName)
+
+	err := h.fetchCollaborators(req.Context(), fullName)
+
+	switch err {
+	case nil:
+	case github.ErrRateLimitReached:
+		retryAfter := h.githubClient.RateLimits.RetryAfter(owner)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "GitHub API rate limit reached", http.StatusTooManyRequests)
+	case tokens.ErrServiceUnavailable:
+		w.Header().Set("Retry-After", strconv.Itoa(int(tokens.DefaultBreakerCooldown/time.Second)))
+		http.Error(w, "Unable to get GitHub token, users service is unavailable", http.StatusServiceUnavailable)
+	case tokens.ErrUserNotFound, tokens.ErrTokenRevoked:
+		http.Error(w, "No valid GitHub token for "+owner, http.StatusForbidden)
+	case github.ErrUnknownHost:
+		http.Error(w, "Unknown GitHub host "+host, http.StatusNotFound)
+	case gitlab.ErrNoSuchProject:
+		http.Error(w, "No such GitLab project "+fullName, http.StatusNotFound)
+	case gitlab.ErrUnauthorized:
+		http.Error(w, "No valid GitLab token for "+owner, http.StatusForbidden)
+	case blamewarrior.ErrProviderMismatch:
+		http.Error(w, fullName+" has been synchronized with another provider", http.StatusConflict)
+	case blamewarrior.ErrRepositoryLocked:
+		http.Error(w, "Sync of "+fullName+" is already in progress", http.StatusConflict)
+	default:
 		w.WriteHeader(http.StatusInternalServerError)
 		log.Printf("%s\t%s\t%v\t%s", "POST", req.RequestURI, http.StatusInternalServerError, err)
 	}
 
 }
 
-func (h *FetchCollaboratorsHandler) fetchCollaborators(fullName string) error {
-	var ctx github.Context
-
-	ctx = github.Context{context.Background(), h.GithubBaseURL}
-
-	collaborators, err := h.githubClient.RepositoryCollaborators(ctx, fullName)
-
-	if err != nil {
-		return err
-	}
-
-	tx, err := h.db.Begin()
-
-	defer tx.Rollback()
-
-	if err != nil {
-		return err
-	}
-
-	if err := h.collaboration.CreateRepository(tx, fullName); err != nil {
-		return err
-	}
-
-	for _, collaborator := range collaborators {
-
-		account := &blamewarrior.Account{
-			Uid:         collaborator.Uid,
-			Login:       collaborator.Login,
-			Permissions: collaborator.Permissions,
-		}
-
-		_, err := h.collaboration.AddAccount(tx, fullName, account)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	tx.Commit()
+func (h *FetchCollaboratorsHandler) fetchCollaborators(ctx context.Context, fullName string) error {
+	syncer := NewSyncer(h.db, h.collaboration, h.githubClient)
+	syncer.GithubBaseURL = h.GithubBaseURL
+	syncer.Providers = h.Providers
 
-	return nil
+	return syncer.SyncRepository(ctx, fullName)
 }
 
 func NewFetchCollaboratorsHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration,
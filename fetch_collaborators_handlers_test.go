@@ -2,6 +2,7 @@ package main_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -25,6 +26,7 @@ func TestFetchCollaboratorHandler(t *testing.T) {
 		ResponseCode  int
 		ResponseBody  string
 		Collaborators []blamewarrior.Account
+		Invitations   []string
 	}{
 		{
 			Owner:         "",
@@ -42,16 +44,19 @@ func TestFetchCollaboratorHandler(t *testing.T) {
 				blamewarrior.Account{
 					Uid:         1,
 					Login:       "user1",
-					Permissions: blamewarrior.AccountPermissions{"pull": true, "push": true, "admin": false},
+					Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true},
+					Affiliation: blamewarrior.AffiliationDirect,
+					Teams:       []string{"developers"},
 				},
 			},
+			Invitations: []string{"user2"},
 		},
 	}
 
 	for _, result := range results {
 		db, teardownDB := setupTestDBConn()
 
-		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts")
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
 		require.NoError(t, err)
 
 		req, err := http.NewRequest("POST", "/repositories?:username="+result.Owner+"&:repo="+result.Name, bytes.NewBufferString(addCollaboratorRequestBody))
@@ -72,10 +77,31 @@ func TestFetchCollaboratorHandler(t *testing.T) {
 
 			assert.Equal(t, "Bearer test_token", req.Header.Get("Authorization"))
 
+			if req.FormValue("affiliation") == "outside" {
+				w.Write([]byte(`[]`))
+				return
+			}
+
 			w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}}]`))
 
 		})
 
+		mux.HandleFunc("/repos/blamewarrior/test_fetch_collaborator/teams", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`[{"id": 10, "name": "Developers", "slug": "developers", "permission": "push"}]`))
+		})
+
+		mux.HandleFunc("/teams/10/members", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`[{"login":"user1", "id": 1}]`))
+		})
+
+		mux.HandleFunc("/repos/blamewarrior/test_fetch_collaborator", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"id": 100, "full_name": "blamewarrior/test_fetch_collaborator"}`))
+		})
+
+		mux.HandleFunc("/repositories/100/invitations", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`[{"id": 1, "invitee": {"login": "user2", "id": 2}, "inviter": {"login": "user1", "id": 1}, "permissions": "write", "created_at": "2018-01-01T00:00:00Z"}]`))
+		})
+
 		collaboration := blamewarrior.NewCollaborationService()
 
 		handler := main.NewFetchCollaboratorsHandler("blamewarrior.com", db, collaboration, githubClient)
@@ -86,7 +112,7 @@ func TestFetchCollaboratorHandler(t *testing.T) {
 		assert.Equal(t, result.ResponseCode, w.Code)
 		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body))
 
-		accounts, err := collaboration.ListAccounts(db, fmt.Sprintf("%s/%s", result.Owner, result.Name))
+		accounts, err := collaboration.ListAccounts(context.Background(), db, fmt.Sprintf("%s/%s", result.Owner, result.Name))
 		require.NoError(t, err)
 
 		require.Equal(t, len(result.Collaborators), len(accounts))
@@ -96,9 +122,104 @@ func TestFetchCollaboratorHandler(t *testing.T) {
 
 			assert.Equal(t, result.Collaborators[i].Uid, accounts[i].Uid)
 			assert.Equal(t, result.Collaborators[i].Permissions, accounts[i].Permissions)
+			assert.Equal(t, result.Collaborators[i].Affiliation, accounts[i].Affiliation)
+			assert.Equal(t, result.Collaborators[i].Teams, accounts[i].Teams)
+		}
+
+		invitations, err := collaboration.ListInvitations(context.Background(), db, fmt.Sprintf("%s/%s", result.Owner, result.Name))
+		require.NoError(t, err)
+
+		require.Equal(t, len(result.Invitations), len(invitations))
+
+		for i := 0; i < len(result.Invitations); i++ {
+			assert.Equal(t, result.Invitations[i], invitations[i].InviteeLogin)
 		}
 
 		teardownDB()
 		teardownAPIServer()
 	}
 }
+
+func TestFetchCollaboratorHandler_TokenErrors(t *testing.T) {
+	results := []struct {
+		TokenStatus  int
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			TokenStatus:  http.StatusNotFound,
+			ResponseCode: http.StatusForbidden,
+			ResponseBody: "No valid GitHub token for blamewarrior\n",
+		},
+		{
+			TokenStatus:  http.StatusGone,
+			ResponseCode: http.StatusForbidden,
+			ResponseBody: "No valid GitHub token for blamewarrior\n",
+		},
+		{
+			TokenStatus:  http.StatusBadGateway,
+			ResponseCode: http.StatusServiceUnavailable,
+			ResponseBody: "Unable to get GitHub token, users service is unavailable\n",
+		},
+	}
+
+	for _, result := range results {
+		db := blamewarrior.OpenMemoryDatabase()
+
+		testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+
+		mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(result.TokenStatus)
+		})
+
+		tokenClient := tokens.NewTokenClient(testAPIEndpoint.String())
+		tokenClient.MaxRetries = 0
+
+		handler := main.NewFetchCollaboratorsHandler("blamewarrior.com", db, blamewarrior.NewMemoryCollaborationService(),
+			github.NewClient(tokenClient))
+		handler.GithubBaseURL = testAPIEndpoint
+
+		req, err := http.NewRequest("POST", "/repositories?:username=blamewarrior&:repo=test_fetch_collaborator", nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code, result.TokenStatus)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body), result.TokenStatus)
+
+		db.Close()
+		teardownAPIServer()
+	}
+}
+
+func TestFetchCollaboratorHandler_RateLimitReached(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_fetch_collaborator/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, `{"message":"You have exceeded a secondary rate limit."}`, http.StatusForbidden)
+	})
+
+	handler := main.NewFetchCollaboratorsHandler("blamewarrior.com", db, blamewarrior.NewMemoryCollaborationService(),
+		github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())))
+	handler.GithubBaseURL = testAPIEndpoint
+
+	req, err := http.NewRequest("POST", "/repositories?:username=blamewarrior&:repo=test_fetch_collaborator", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "GitHub API rate limit reached\n", w.Body.String())
+}
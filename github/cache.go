@@ -0,0 +1,350 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultResponseCacheSize is the number of responses kept by MemoryResponseCache by default.
+	DefaultResponseCacheSize = 10000
+	// DefaultResponseCacheTTL is the time PostgresResponseCache keeps responses for by default.
+	DefaultResponseCacheTTL = 7 * 24 * time.Hour
+
+	// responseCachePruneInterval is the minimum time between two prunes of PostgresResponseCache
+	responseCachePruneInterval = time.Hour
+)
+
+// CachedResponse is a GitHub API response stored to be revalidated with a conditional request.
+type CachedResponse struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// ResponseCache stores GitHub API responses by key. Keys are derived from request URL and
+// the token used to send it, tokens themselves are never passed to the cache.
+type ResponseCache interface {
+	// Get returns a cached response or nil if there is none.
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+}
+
+// ResponseCacheStats contains counters of conditional requests.
+type ResponseCacheStats struct {
+	// Revalidated is the number of requests answered with 304 Not Modified, those
+	// are not counted against GitHub API rate limit.
+	Revalidated int64 `json:"revalidated"`
+	Modified    int64 `json:"modified"`
+	Errors      int64 `json:"errors"`
+}
+
+func (stats *ResponseCacheStats) snapshot() ResponseCacheStats {
+	return ResponseCacheStats{
+		Revalidated: atomic.LoadInt64(&stats.Revalidated),
+		Modified:    atomic.LoadInt64(&stats.Modified),
+		Errors:      atomic.LoadInt64(&stats.Errors),
+	}
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// MemoryResponseCache is a ResponseCache that keeps up to Size least recently used responses in memory.
+type MemoryResponseCache struct {
+	Size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	recent  *list.List
+}
+
+func NewMemoryResponseCache(size int) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		Size:    size,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+func (c *MemoryResponseCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	c.recent.MoveToFront(el)
+
+	return el.Value.(*memoryCacheEntry).resp, nil
+}
+
+func (c *MemoryResponseCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*memoryCacheEntry).resp = resp
+		c.recent.MoveToFront(el)
+
+		return nil
+	}
+
+	c.entries[key] = c.recent.PushFront(&memoryCacheEntry{key: key, resp: resp})
+
+	for c.recent.Len() > c.Size {
+		el := c.recent.Back()
+		c.recent.Remove(el)
+		delete(c.entries, el.Value.(*memoryCacheEntry).key)
+	}
+
+	return nil
+}
+
+const (
+	getCachedResponseQuery = `
+		SELECT etag, header, body FROM github_response_cache WHERE key = $1
+	`
+
+	setCachedResponseQuery = `
+		INSERT INTO github_response_cache (key, etag, header, body, updated_at) VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (key) DO UPDATE
+			SET etag = EXCLUDED.etag, header = EXCLUDED.header, body = EXCLUDED.body, updated_at = EXCLUDED.updated_at
+	`
+
+	pruneCachedResponsesQuery = `
+		DELETE FROM github_response_cache WHERE updated_at < now() - $1::double precision * interval '1 second'
+	`
+)
+
+// PostgresResponseCache is a ResponseCache that persists responses in github_response_cache
+// table, so that they survive service restarts. Responses that have not been stored for TTL
+// are deleted, since keys of ones sent with a rotated token are never looked up again.
+type PostgresResponseCache struct {
+	TTL time.Duration
+
+	db *sql.DB
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+func NewPostgresResponseCache(db *sql.DB) *PostgresResponseCache {
+	return &PostgresResponseCache{
+		TTL: DefaultResponseCacheTTL,
+		db:  db,
+	}
+}
+
+func (c *PostgresResponseCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	var (
+		resp   CachedResponse
+		header []byte
+	)
+
+	err := c.db.QueryRowContext(ctx, getCachedResponseQuery, key).Scan(&resp.ETag, &header, &resp.Body)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(header, &resp.Header); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (c *PostgresResponseCache) Set(ctx context.Context, key string, resp *CachedResponse) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.db.ExecContext(ctx, setCachedResponseQuery, key, resp.ETag, header, resp.Body); err != nil {
+		return err
+	}
+
+	if !c.pruneDue() {
+		return nil
+	}
+
+	_, err = c.Prune(ctx)
+
+	return err
+}
+
+// Prune deletes responses that have not been stored for TTL and returns their number.
+func (c *PostgresResponseCache) Prune(ctx context.Context) (int64, error) {
+	res, err := c.db.ExecContext(ctx, pruneCachedResponsesQuery, c.TTL.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// pruneDue reports whether it's time for Set to prune the cache, which is done once in
+// responseCachePruneInterval.
+func (c *PostgresResponseCache) pruneDue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.prunedAt) < responseCachePruneInterval {
+		return false
+	}
+	c.prunedAt = time.Now()
+
+	return true
+}
+
+// responseCacheKey returns cache key of a request sent with given token.
+func responseCacheKey(token string, req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(token))
+	h.Write([]byte{0})
+	h.Write([]byte(req.URL.String()))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// etagTransport sends conditional GET requests for responses found in the cache and
+// stores responses that have an ETag. A 304 Not Modified response is replaced with the
+// cached one keeping up-to-date rate limit headers. Cache errors never fail requests.
+type etagTransport struct {
+	http.RoundTripper
+
+	ctx   context.Context
+	cache ResponseCache
+	stats *ResponseCacheStats
+	token string
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	key := responseCacheKey(t.token, req)
+
+	cached, err := t.cache.Get(t.ctx, key)
+	if err != nil {
+		atomic.AddInt64(&t.stats.Errors, 1)
+		cached = nil
+	}
+
+	if cached != nil {
+		req = cloneRequest(req)
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&t.stats.Revalidated, 1)
+
+		return cachedHTTPResponse(req, resp, cached), nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	if cached != nil {
+		atomic.AddInt64(&t.stats.Modified, 1)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := t.cache.Set(t.ctx, key, &CachedResponse{ETag: etag, Header: resp.Header, Body: body}); err != nil {
+		atomic.AddInt64(&t.stats.Errors, 1)
+	}
+
+	return resp, nil
+}
+
+// cachedHTTPResponse builds a response from a cached one and the 304 Not Modified
+// response it has been revalidated with.
+func cachedHTTPResponse(req *http.Request, notModified *http.Response, cached *CachedResponse) *http.Response {
+	notModified.Body.Close()
+
+	header := make(http.Header, len(cached.Header))
+	for k, v := range cached.Header {
+		header[k] = v
+	}
+
+	for _, k := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Date"} {
+		if v := notModified.Header.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// cloneRequest returns a shallow copy of req with a deep copy of its headers, since
+// a RoundTripper must not modify the request it was given.
+func cloneRequest(req *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *req
+
+	clone.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		clone.Header[k] = append([]string(nil), v...)
+	}
+
+	return clone
+}
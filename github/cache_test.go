@@ -0,0 +1,139 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ResponseCache(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	c.ResponseCache = github.NewMemoryResponseCache(github.DefaultResponseCacheSize)
+
+	var requests, notModified int64
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&requests, 1)
+
+		page := req.FormValue("page")
+		if page == "" {
+			page = "1"
+		}
+
+		etag := `"teams-page-` + page + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4990")
+		w.Header().Set("X-RateLimit-Reset", "1893456000")
+
+		if page == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/user1/repo1/teams?per_page=100&page=2>; rel="next"`, baseURL))
+		}
+
+		if req.Header.Get("If-None-Match") == etag {
+			atomic.AddInt64(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		fmt.Fprintf(w, `[{"id": %s, "name": "Team %s", "slug": "team-%s", "permission": "push"}]`, page, page, page)
+	})
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	expected := []blamewarrior.Team{
+		{Uid: 1, Name: "Team 1", Slug: "team-1", Permission: "push"},
+		{Uid: 2, Name: "Team 2", Slug: "team-2", Permission: "push"},
+	}
+
+	for i := 0; i < 2; i++ {
+		teams, err := c.RepositoryTeams(ctx, "user1/repo1")
+		require.NoError(t, err)
+		assert.Equal(t, expected, teams)
+	}
+
+	assert.Equal(t, int64(4), atomic.LoadInt64(&requests))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&notModified))
+	assert.Equal(t, github.ResponseCacheStats{Revalidated: 2}, c.CacheStats())
+	assert.Equal(t, 4990, c.RateLimits.All()[0].Remaining)
+}
+
+func TestClient_ResponseCache_TokenChanged(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil).Once()
+	ts.On("GetToken", "user1").Return("token2", nil).Once()
+
+	c := github.NewClient(ts)
+	c.ResponseCache = github.NewMemoryResponseCache(github.DefaultResponseCacheSize)
+
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		assert.Empty(t, req.Header.Get("If-None-Match"))
+
+		w.Header().Set("ETag", `"teams"`)
+		w.Write([]byte(`[]`))
+	})
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	for i := 0; i < 2; i++ {
+		_, err := c.RepositoryTeams(ctx, "user1/repo1")
+		require.NoError(t, err)
+	}
+
+	ts.AssertExpectations(t)
+}
+
+func TestMemoryResponseCache(t *testing.T) {
+	cache := github.NewMemoryResponseCache(2)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		require.NoError(t, cache.Set(ctx, key, &github.CachedResponse{ETag: key}))
+	}
+
+	// "a" becomes the most recently used one, so "b" is evicted
+	resp, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	require.NoError(t, cache.Set(ctx, "c", &github.CachedResponse{ETag: "c"}))
+
+	for key, cached := range map[string]bool{"a": true, "b": false, "c": true} {
+		resp, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, cached, resp != nil, key)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"context"
 
@@ -32,54 +33,285 @@ import (
 	"github.com/blamewarrior/collaborators/blamewarrior"
 	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
 	gh "github.com/google/go-github/github"
+	"github.com/google/go-querystring/query"
 )
 
 var (
 	ErrRateLimitReached = errors.New("GitHub API request rate limit reached")
 	ErrNoSuchRepository = errors.New("no such repository")
+	ErrNoSuchOwner      = errors.New("no such user or organization")
+
+	ErrInvalidLogin          = errors.New("invalid user or organization name")
+	ErrInvalidRepositoryName = errors.New("invalid repository name")
+)
+
+const (
+	// MaxLoginLength is the maximum length of GitHub user and organization names.
+	MaxLoginLength = 39
+	// MaxRepositoryNameLength is the maximum length of a repository name without the owner part.
+	MaxRepositoryNameLength = 100
+
+	botLoginSuffix = "[bot]"
 )
 
 type Context struct {
 	context.Context
-	// BaseURL overrides GitHub API endpoint and is intended for use in tests.
+	// BaseURL overrides GitHub API endpoint of all hosts and is intended for use in tests.
+	// GitHub Enterprise Server endpoints are configured with Client.Hosts.
 	BaseURL *url.URL
+	// RateLimitPolicy tells whether to wait for rate limit reset or to fail
+	// with ErrRateLimitReached once the owner token runs out of requests.
+	RateLimitPolicy RateLimitPolicy
+	// Reservation is the reservation requests are taken from, if any.
+	Reservation *Reservation
 }
 
 // Client wraps github.com/google/go-github/github.Client providing methods adapted
 // for BlameWarrior use cases.
 type Client struct {
+	// RateLimits keeps track of GitHub API rate limits of owner tokens.
+	RateLimits *RateLimits
+	// ResponseCache keeps responses to be revalidated with conditional requests
+	// that do not count against rate limit. Caching is disabled if it's nil.
+	ResponseCache ResponseCache
+	// Hosts maps GitHub Enterprise Server hosts and their owners to API endpoints.
+	// Only github.com is available if it's nil.
+	Hosts *Hosts
+	// MaxRetries is the number of times a request failed with 5xx response, timeout
+	// or connection reset is repeated. Set it to zero to disable retries.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, it doubles with each next attempt.
+	RetryDelay time.Duration
+	// RequestTimeout limits the time of a single attempt to send a request.
+	RequestTimeout time.Duration
+
 	tokenClient tokens.Client
+	cacheStats  ResponseCacheStats
+	retryStats  RetryStats
 }
 
 func NewClient(tokenClient tokens.Client) *Client {
-	return &Client{tokenClient}
+	return &Client{
+		RateLimits:     NewRateLimits(),
+		MaxRetries:     DefaultMaxRetries,
+		RetryDelay:     DefaultRetryDelay,
+		RequestTimeout: DefaultRequestTimeout,
+		tokenClient:    tokenClient,
+	}
+}
+
+// CacheStats returns counters of conditional requests sent by the client.
+func (c *Client) CacheStats() ResponseCacheStats {
+	return c.cacheStats.snapshot()
+}
+
+// RetryStats returns counters of requests repeated after transient failures.
+func (c *Client) RetryStats() RetryStats {
+	return c.retryStats.snapshot()
+}
+
+// CanonicalName returns the name an owner or a repository is stored under, see Hosts.Canonical.
+func (c *Client) CanonicalName(name string) string {
+	var hosts *Hosts
+	if c != nil {
+		hosts = c.Hosts
+	}
+
+	return hosts.Canonical(name)
 }
 
 // RepositoryCollaborators returns GitHub nicknames of collaborators of given
-// repository.
+// repository along with their affiliation. Collaborators of a repository owned by
+// an organization are listed up to three times, filtered by each affiliation,
+// while those of a user repository are listed once.
 func (c *Client) RepositoryCollaborators(ctx Context, repoFullName string) (collaborators []blamewarrior.Account, err error) {
 	owner, name := SplitRepositoryName(repoFullName)
 
-	api, err := initAPIClient(ctx, c.tokenClient, owner)
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	_, owner = SplitHost(owner)
+
+	all, err := listCollaborators(api, owner, name, "all")
 	if err != nil {
 		return nil, err
 	}
 
+	// GitHub does not report affiliation of a collaborator, so it's derived from
+	// the lists filtered by each of them: outside collaborators are a subset of
+	// direct ones, and the rest of all collaborators are organization members.
+	// Repositories of users list their owner, who cannot be a collaborator of an
+	// organization repository, and have direct collaborators only.
+	personal := false
+	for _, user := range all {
+		if strings.EqualFold(*user.Login, owner) {
+			personal = true
+			break
+		}
+	}
+
+	defaultAffiliation := blamewarrior.AffiliationMember
+	if personal {
+		defaultAffiliation = blamewarrior.AffiliationDirect
+	}
+
+	affiliations := make(map[string]string, len(all))
+	if !personal {
+		direct, err := listCollaborators(api, owner, name, "direct")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range direct {
+			affiliations[*user.Login] = blamewarrior.AffiliationDirect
+		}
+
+		// there are no outside collaborators without direct ones
+		if len(direct) > 0 {
+			outside, err := listCollaborators(api, owner, name, "outside")
+			if err != nil {
+				return nil, err
+			}
+
+			for _, user := range outside {
+				affiliations[*user.Login] = blamewarrior.AffiliationOutside
+			}
+		}
+	}
+
+	for _, user := range all {
+		affiliation, ok := affiliations[*user.Login]
+		if !ok {
+			affiliation = defaultAffiliation
+		}
+
+		collaborator := blamewarrior.Account{
+			Login:       *user.Login,
+			Uid:         *user.ID,
+			Affiliation: affiliation,
+		}
+
+		if user.Permissions != nil {
+			// permissions unknown to BlameWarrior are ignored
+			collaborator.Permissions, _ = blamewarrior.PermissionsFromMap(*user.Permissions)
+		}
+
+		collaborators = append(collaborators, collaborator)
+	}
+
+	return collaborators, nil
+}
+
+// collaboratorsListOptions specifies parameters of GitHub repository collaborators
+// list request. The vendored go-github does not support filtering by affiliation yet.
+type collaboratorsListOptions struct {
+	Affiliation string `url:"affiliation,omitempty"`
+
+	gh.ListOptions
+}
+
+func listCollaborators(api *gh.Client, owner, name, affiliation string) (users []*gh.User, err error) {
+	opt := &collaboratorsListOptions{
+		Affiliation: affiliation,
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+
+	for {
+		qs, err := query.Values(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := api.NewRequest("GET", fmt.Sprintf("repos/%v/%v/collaborators?%s", owner, name, qs.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []*gh.User
+		resp, err := api.Do(req, &page)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		for _, user := range page {
+			if user == nil || user.Login == nil {
+				continue
+			}
+
+			users = append(users, user)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return users, nil
+}
+
+// RepositoryTeams returns teams that have access to given repository along
+// with their permission level.
+func (c *Client) RepositoryTeams(ctx Context, repoFullName string) (teams []blamewarrior.Team, err error) {
+	owner, name := SplitRepositoryName(repoFullName)
+
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	_, owner = SplitHost(owner)
+
 	opt := &gh.ListOptions{PerPage: 100}
 	for {
-		users, resp, err := api.Repositories.ListCollaborators(owner, name, opt)
+		ghTeams, resp, err := api.Repositories.ListTeams(owner, name, opt)
 		if err != nil {
-			switch err.(type) {
-			case *gh.RateLimitError:
-				return nil, ErrRateLimitReached
-			case *gh.ErrorResponse:
-				apiErr := err.(*gh.ErrorResponse)
-				if apiErr.Response.StatusCode == http.StatusNotFound {
-					return nil, ErrNoSuchRepository
-				}
+			return nil, translateError(err)
+		}
+
+		for _, team := range ghTeams {
+			if team == nil || team.ID == nil || team.Slug == nil {
+				continue
+			}
+
+			t := blamewarrior.Team{
+				Uid:  *team.ID,
+				Slug: *team.Slug,
+			}
+
+			if team.Name != nil {
+				t.Name = *team.Name
 			}
 
-			return nil, fmt.Errorf("request failed: %s", err)
+			if team.Permission != nil {
+				t.Permission = *team.Permission
+			}
+
+			teams = append(teams, t)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return teams, nil
+}
+
+// TeamMembers returns GitHub accounts of members of a team identified by its
+// GitHub ID. The owner is used to obtain an API token.
+func (c *Client) TeamMembers(ctx Context, owner string, teamUid int) (members []blamewarrior.Account, err error) {
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &gh.OrganizationListTeamMembersOptions{ListOptions: gh.ListOptions{PerPage: 100}}
+	for {
+		users, resp, err := api.Organizations.ListTeamMembers(teamUid, opt)
+		if err != nil {
+			return nil, translateError(err)
 		}
 
 		for _, user := range users {
@@ -87,12 +319,10 @@ func (c *Client) RepositoryCollaborators(ctx Context, repoFullName string) (coll
 				continue
 			}
 
-			collaborators = append(collaborators, blamewarrior.Account{
-				Login:       *user.Login,
-				Uid:         *user.ID,
-				Permissions: *user.Permissions,
-			},
-			)
+			members = append(members, blamewarrior.Account{
+				Login: *user.Login,
+				Uid:   *user.ID,
+			})
 		}
 
 		if resp.NextPage == 0 {
@@ -101,31 +331,308 @@ func (c *Client) RepositoryCollaborators(ctx Context, repoFullName string) (coll
 		opt.Page = resp.NextPage
 	}
 
-	return collaborators, nil
+	return members, nil
+}
+
+// RepositoryInvitations returns pending invitations to collaborate on given repository.
+func (c *Client) RepositoryInvitations(ctx Context, repoFullName string) (invitations []blamewarrior.Invitation, err error) {
+	owner, name := SplitRepositoryName(repoFullName)
+
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	_, owner = SplitHost(owner)
+
+	// invitations are addressed by repository ID rather than by its name
+	repo, _, err := api.Repositories.Get(owner, name)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	opt := &gh.ListOptions{PerPage: 100}
+	for {
+		ghInvitations, resp, err := api.Repositories.ListInvitations(*repo.ID, opt)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		for _, inv := range ghInvitations {
+			if inv == nil || inv.ID == nil || inv.Invitee == nil || inv.Invitee.Login == nil {
+				continue
+			}
+
+			invitation := blamewarrior.Invitation{
+				Uid:          *inv.ID,
+				InviteeLogin: *inv.Invitee.Login,
+			}
+
+			if inv.Invitee.ID != nil {
+				invitation.InviteeUid = *inv.Invitee.ID
+			}
+
+			if inv.Inviter != nil && inv.Inviter.Login != nil {
+				invitation.InviterLogin = *inv.Inviter.Login
+			}
+
+			if inv.Permissions != nil {
+				invitation.Permission = *inv.Permissions
+			}
+
+			if inv.CreatedAt != nil {
+				invitation.CreatedAt = inv.CreatedAt.Time
+				invitation.ExpiresAt = inv.CreatedAt.Add(blamewarrior.InvitationTTL)
+			}
+
+			invitations = append(invitations, invitation)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return invitations, nil
+}
+
+// OwnerRepositories returns full names of all repositories that belong to given
+// GitHub user or organization. The owner's token is used, so private repositories
+// are included as well. Repositories of an owner hosted on GitHub Enterprise Server are
+// qualified with the host name, see Hosts.Canonical.
+func (c *Client) OwnerRepositories(ctx Context, owner string) (repositories []string, err error) {
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	host, owner := SplitHost(c.Hosts.Canonical(owner))
+
+	user, _, err := api.Users.Get(owner)
+	if err != nil {
+		if err = translateError(err); err == ErrNoSuchRepository {
+			return nil, ErrNoSuchOwner
+		}
+
+		return nil, err
+	}
+
+	isOrg := user.Type != nil && *user.Type == "Organization"
+
+	opt := gh.ListOptions{PerPage: 100}
+	for {
+		var (
+			repos []*gh.Repository
+			resp  *gh.Response
+		)
+
+		if isOrg {
+			repos, resp, err = api.Repositories.ListByOrg(owner, &gh.RepositoryListByOrgOptions{ListOptions: opt})
+		} else {
+			// an empty user name lists repositories of the token owner including private ones
+			repos, resp, err = api.Repositories.List("", &gh.RepositoryListOptions{Affiliation: "owner", ListOptions: opt})
+		}
+
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		for _, repo := range repos {
+			if repo == nil || repo.FullName == nil {
+				continue
+			}
+
+			repositories = append(repositories, QualifyName(host, *repo.FullName))
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return repositories, nil
+}
+
+// UserProfile returns public GitHub profile of a user. The owner is used to obtain an API
+// token and to pick the GitHub host.
+func (c *Client) UserProfile(ctx Context, owner, login string) (*blamewarrior.AccountProfile, error) {
+	api, err := c.initAPIClient(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	user, _, err := api.Users.Get(login)
+	if err != nil {
+		if err = translateError(err); err == ErrNoSuchRepository {
+			return nil, ErrNoSuchOwner
+		}
+
+		return nil, err
+	}
+
+	profile := &blamewarrior.AccountProfile{}
+
+	if user.Name != nil {
+		profile.Name = *user.Name
+	}
+
+	if user.AvatarURL != nil {
+		profile.AvatarURL = *user.AvatarURL
+	}
+
+	if user.Type != nil {
+		profile.Type = *user.Type
+	}
+
+	if user.SiteAdmin != nil {
+		profile.SiteAdmin = *user.SiteAdmin
+	}
+
+	return profile, nil
 }
 
 // SplitRepositoryName splits full GitHub repository name into owner and name parts.
+// The owner of a repository name qualified with GitHub host name is qualified as well.
 func SplitRepositoryName(fullName string) (owner, repo string) {
+	host, fullName := SplitHost(fullName)
+
 	sep := strings.IndexByte(fullName, '/')
 	if sep <= 0 || sep == len(fullName)-1 {
 		return "", ""
 	}
 
-	return fullName[0:sep], fullName[sep+1:]
+	return QualifyName(host, fullName[0:sep]), fullName[sep+1:]
+}
+
+// ValidateLogin checks that login follows GitHub naming rules for users and organizations:
+// up to 39 alphanumeric characters or single hyphens that cannot begin or end the name.
+// Names of GitHub App bot accounts are allowed to have "[bot]" suffix.
+func ValidateLogin(login string) error {
+	login = strings.TrimSuffix(login, botLoginSuffix)
+
+	if login == "" || len(login) > MaxLoginLength {
+		return ErrInvalidLogin
+	}
+
+	if login[0] == '-' || login[len(login)-1] == '-' || strings.Contains(login, "--") {
+		return ErrInvalidLogin
+	}
+
+	for _, c := range login {
+		if !isASCIIAlphanumeric(c) && c != '-' {
+			return ErrInvalidLogin
+		}
+	}
+
+	return nil
 }
 
-func initAPIClient(ctx Context, tokenClient tokens.Client, owner string) (*gh.Client, error) {
+// ValidateRepositoryName checks that fullName is a repository name in owner/repo format
+// with a valid owner login and a repository name of up to 100 alphanumeric characters,
+// hyphens, underscores or dots. The name can be qualified with GitHub host name, i.e.
+// host/owner/repo.
+func ValidateRepositoryName(fullName string) error {
+	owner, name := SplitRepositoryName(fullName)
+	if owner == "" {
+		return ErrInvalidRepositoryName
+	}
+
+	if err := ValidateOwner(owner); err != nil || strings.HasSuffix(owner, botLoginSuffix) {
+		return ErrInvalidRepositoryName
+	}
+
+	if len(name) > MaxRepositoryNameLength || name == "." || name == ".." {
+		return ErrInvalidRepositoryName
+	}
+
+	for _, c := range name {
+		if !isASCIIAlphanumeric(c) && c != '-' && c != '_' && c != '.' {
+			return ErrInvalidRepositoryName
+		}
+	}
 
-	token, err := tokenClient.GetToken(owner)
+	return nil
+}
+
+func isASCIIAlphanumeric(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
 
+// initAPIClient returns a client authorized with the token of an owner that sends requests
+// to the owner's GitHub host.
+func (c *Client) initAPIClient(ctx Context, owner string) (*gh.Client, error) {
+	host, err := c.Hosts.Lookup(owner)
 	if err != nil {
+		return nil, err
+	}
+
+	// tokens and rate limits of an owner are shared by all names it can be referred to by
+	owner = c.Hosts.Canonical(owner)
+
+	token, err := c.tokenClient.GetToken(owner)
+
+	switch err {
+	case nil:
+	case tokens.ErrUserNotFound, tokens.ErrTokenRevoked, tokens.ErrServiceUnavailable:
+		// returned as is to let callers tell them from other errors
+		return nil, err
+	default:
 		return nil, fmt.Errorf("unable to get token to init API client: %s", err)
 	}
 
 	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	oauthClient := oauth2.NewClient(ctx, tokenSource)
 
+	oauthClient.Transport = &timeoutTransport{
+		RoundTripper: oauthClient.Transport,
+		ctx:          ctx,
+		timeout:      c.RequestTimeout,
+	}
+
+	if invalidator, ok := c.tokenClient.(tokens.Invalidator); ok {
+		oauthClient.Transport = &invalidatingTransport{
+			RoundTripper: oauthClient.Transport,
+			invalidator:  invalidator,
+			owner:        owner,
+		}
+	}
+
+	if c.ResponseCache != nil {
+		oauthClient.Transport = &etagTransport{
+			RoundTripper: oauthClient.Transport,
+			ctx:          ctx,
+			cache:        c.ResponseCache,
+			stats:        &c.cacheStats,
+			token:        token,
+		}
+	}
+
+	if c.RateLimits != nil {
+		oauthClient.Transport = &rateLimitTransport{
+			RoundTripper: oauthClient.Transport,
+			ctx:          ctx,
+			limits:       c.RateLimits,
+			reservation:  ctx.Reservation,
+			owner:        owner,
+			policy:       ctx.RateLimitPolicy,
+		}
+	}
+
+	// retried attempts pass through the rate limit transport, so that limits reported
+	// in responses to each of them are recorded
+	oauthClient.Transport = &retryTransport{
+		RoundTripper: oauthClient.Transport,
+		ctx:          ctx,
+		maxRetries:   c.MaxRetries,
+		delay:        c.RetryDelay,
+		stats:        &c.retryStats,
+	}
+
 	api := gh.NewClient(oauthClient)
+	if host != nil {
+		api.BaseURL, api.UploadURL = host.BaseURL, host.UploadURL
+	}
+
 	if ctx.BaseURL != nil {
 		api.BaseURL = ctx.BaseURL
 	}
@@ -133,3 +640,45 @@ func initAPIClient(ctx Context, tokenClient tokens.Client, owner string) (*gh.Cl
 	return api, nil
 
 }
+
+// invalidatingTransport drops the token of an owner once GitHub responds that
+// it is not valid anymore.
+type invalidatingTransport struct {
+	http.RoundTripper
+
+	invalidator tokens.Invalidator
+	owner       string
+}
+
+func (t *invalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.invalidator.Invalidate(t.owner)
+	}
+
+	return resp, err
+}
+
+func translateError(err error) error {
+	switch err.(type) {
+	case *gh.RateLimitError, *gh.AbuseRateLimitError:
+		return ErrRateLimitReached
+	case *url.Error:
+		// requests held back by rateLimitTransport
+		if err.(*url.Error).Err == ErrRateLimitReached {
+			return ErrRateLimitReached
+		}
+
+		// requests that kept failing after all retries
+		if reqErr, ok := err.(*url.Error).Err.(*RequestError); ok {
+			return reqErr
+		}
+	case *gh.ErrorResponse:
+		apiErr := err.(*gh.ErrorResponse)
+		if apiErr.Response.StatusCode == http.StatusNotFound {
+			return ErrNoSuchRepository
+		}
+	}
+
+	return fmt.Errorf("request failed: %s", err)
+}
@@ -25,6 +25,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,32 +51,90 @@ func TestClient_RepositoryCollaborators(t *testing.T) {
 	defer teardown()
 
 	ts := new(tokenServiceMock)
-	ts.On("GetToken", "user1").Return("token1", nil)
+	ts.On("GetToken", "org1").Return("token1", nil)
 
 	c := github.NewClient(ts)
 
-	ctx := github.Context{context.Background(), baseURL}
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
 
-	mux.HandleFunc("/repos/user1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
+	mux.HandleFunc("/repos/org1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
 		url := baseURL.String() + "/" + req.URL.Path
 		w.Header().Set("Link", `<`+url+`?page=2>; rel="last"`)
 
 		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
 
-		if req.FormValue("page") != "2" {
-			w.Header().Set("Link", `<`+url+`?page=2>; rel="next", `+w.Header().Get("Link"))
-			w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}},{"login":"user2", "id": 2, "permissions": {"pull": true,"push": true,"admin": false}}]`))
-		} else {
+		switch req.FormValue("affiliation") {
+		case "outside":
 			w.Write([]byte(`[{"login":"user3", "id": 3, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+		case "direct":
+			w.Write([]byte(`[{"login":"user2", "id": 2, "permissions": {"pull": true,"push": true,"admin": false}},{"login":"user3", "id": 3, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+		case "all":
+			if req.FormValue("page") != "2" {
+				w.Header().Set("Link", `<`+url+`?page=2>; rel="next", `+w.Header().Get("Link"))
+				w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}},{"login":"user2", "id": 2, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+			} else {
+				w.Write([]byte(`[{"login":"user3", "id": 3, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+			}
+		default:
+			t.Errorf("unexpected affiliation %q", req.FormValue("affiliation"))
 		}
 	})
 
-	collaborators, err := c.RepositoryCollaborators(ctx, "user1/repo1")
+	collaborators, err := c.RepositoryCollaborators(ctx, "org1/repo1")
 	require.NoError(t, err)
 	assert.Len(t, collaborators, 3)
-	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user1", Uid: 1, Permissions: map[string]bool{"pull": true, "push": true, "admin": false}})
-	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user2", Uid: 2, Permissions: map[string]bool{"pull": true, "push": true, "admin": false}})
-	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user3", Uid: 3, Permissions: map[string]bool{"pull": true, "push": true, "admin": false}})
+	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user1", Uid: 1, Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true}, Affiliation: blamewarrior.AffiliationMember})
+	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user2", Uid: 2, Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true}, Affiliation: blamewarrior.AffiliationDirect})
+	assert.Contains(t, collaborators, blamewarrior.Account{Login: "user3", Uid: 3, Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true}, Affiliation: blamewarrior.AffiliationOutside})
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_RepositoryCollaborators_PersonalRepository(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	// collaborators of a repository that lists its owner are all direct ones
+	mux.HandleFunc("/repos/user1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		if affiliation := req.FormValue("affiliation"); affiliation != "all" {
+			t.Errorf("unexpected affiliation %q", affiliation)
+		}
+
+		w.Write([]byte(`[{"login":"User1", "id": 1, "permissions": {"admin": true}},{"login":"user2", "id": 2, "permissions": {"pull": true}}]`))
+	})
+
+	collaborators, err := c.RepositoryCollaborators(ctx, "user1/repo1")
+	require.NoError(t, err)
+	assert.Equal(t, []blamewarrior.Account{
+		{Login: "User1", Uid: 1, Permissions: blamewarrior.AccountPermissions{Admin: true}, Affiliation: blamewarrior.AffiliationDirect},
+		{Login: "user2", Uid: 2, Permissions: blamewarrior.AccountPermissions{Pull: true}, Affiliation: blamewarrior.AffiliationDirect},
+	}, collaborators)
+
+	// outside collaborators are not listed if there are no direct ones
+	mux.HandleFunc("/repos/org1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		switch req.FormValue("affiliation") {
+		case "all":
+			w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true}}]`))
+		case "direct":
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("unexpected affiliation %q", req.FormValue("affiliation"))
+		}
+	})
+
+	ts.On("GetToken", "org1").Return("token1", nil)
+
+	collaborators, err = c.RepositoryCollaborators(ctx, "org1/repo1")
+	require.NoError(t, err)
+	require.Len(t, collaborators, 1)
+	assert.Equal(t, blamewarrior.AffiliationMember, collaborators[0].Affiliation)
 
 	ts.AssertExpectations(t)
 }
@@ -89,7 +148,7 @@ func TestClient_RepositoryCollaborators_RepositoryDoesNotExist(t *testing.T) {
 
 	c := github.NewClient(ts)
 
-	ctx := github.Context{context.Background(), baseURL}
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
 
 	mux.HandleFunc("/repos/user1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
@@ -110,7 +169,7 @@ func TestClient_RepositoryCollaborators_RateLimitReached(t *testing.T) {
 
 	c := github.NewClient(ts)
 
-	ctx := github.Context{context.Background(), baseURL}
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
 
 	mux.HandleFunc("/repos/user1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("X-RateLimit-Limit", "1")
@@ -126,6 +185,236 @@ func TestClient_RepositoryCollaborators_RateLimitReached(t *testing.T) {
 	ts.AssertExpectations(t)
 }
 
+type invalidatingTokenServiceMock struct {
+	tokenServiceMock
+}
+
+func (tsMock *invalidatingTokenServiceMock) Invalidate(nickname string) {
+	tsMock.Called(nickname)
+}
+
+func TestClient_RepositoryCollaborators_Unauthorized(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(invalidatingTokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+	ts.On("Invalidate", "user1").Return().Once()
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/repos/user1/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	})
+
+	_, err := c.RepositoryCollaborators(ctx, "user1/repo1")
+	require.Error(t, err)
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_RepositoryTeams(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+
+		w.Write([]byte(`[{"id": 1, "name": "Developers", "slug": "developers", "permission": "push"},{"id": 2, "name": "Owners", "slug": "owners", "permission": "admin"}]`))
+	})
+
+	teams, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+	assert.Len(t, teams, 2)
+	assert.Contains(t, teams, blamewarrior.Team{Uid: 1, Name: "Developers", Slug: "developers", Permission: "push"})
+	assert.Contains(t, teams, blamewarrior.Team{Uid: 2, Name: "Owners", Slug: "owners", Permission: "admin"})
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_TeamMembers(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/teams/1/members", func(w http.ResponseWriter, req *http.Request) {
+		url := baseURL.String() + "/" + req.URL.Path
+		w.Header().Set("Link", `<`+url+`?page=2>; rel="last"`)
+
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+
+		if req.FormValue("page") != "2" {
+			w.Header().Set("Link", `<`+url+`?page=2>; rel="next", `+w.Header().Get("Link"))
+			w.Write([]byte(`[{"login":"user1", "id": 1}]`))
+		} else {
+			w.Write([]byte(`[{"login":"user2", "id": 2}]`))
+		}
+	})
+
+	members, err := c.TeamMembers(ctx, "user1", 1)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Contains(t, members, blamewarrior.Account{Login: "user1", Uid: 1})
+	assert.Contains(t, members, blamewarrior.Account{Login: "user2", Uid: 2})
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_RepositoryInvitations(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/repos/user1/repo1", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 42, "full_name": "user1/repo1"}`))
+	})
+
+	mux.HandleFunc("/repositories/42/invitations", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+
+		w.Write([]byte(`[{"id": 1, "invitee": {"login": "user2", "id": 2}, "inviter": {"login": "user1", "id": 1}, "permissions": "write", "created_at": "2018-01-01T00:00:00Z"}]`))
+	})
+
+	invitations, err := c.RepositoryInvitations(ctx, "user1/repo1")
+	require.NoError(t, err)
+
+	createdAt := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, 1, invitations[0].Uid)
+	assert.Equal(t, 2, invitations[0].InviteeUid)
+	assert.Equal(t, "user2", invitations[0].InviteeLogin)
+	assert.Equal(t, "user1", invitations[0].InviterLogin)
+	assert.Equal(t, "write", invitations[0].Permission)
+	assert.True(t, createdAt.Equal(invitations[0].CreatedAt))
+	assert.True(t, createdAt.Add(blamewarrior.InvitationTTL).Equal(invitations[0].ExpiresAt))
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_OwnerRepositories(t *testing.T) {
+	examples := map[string]struct {
+		UserType, ReposPath string
+	}{
+		"organization": {"Organization", "/orgs/user1/repos"},
+		"user":         {"User", "/user/repos"},
+	}
+
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			baseURL, mux, teardown := setupAPIServer()
+			defer teardown()
+
+			ts := new(tokenServiceMock)
+			ts.On("GetToken", "user1").Return("token1", nil)
+
+			c := github.NewClient(ts)
+
+			ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+			mux.HandleFunc("/users/user1", func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte(`{"login": "user1", "id": 1, "type": "` + example.UserType + `"}`))
+			})
+
+			mux.HandleFunc(example.ReposPath, func(w http.ResponseWriter, req *http.Request) {
+				url := baseURL.String() + "/" + req.URL.Path
+				w.Header().Set("Link", `<`+url+`?page=2>; rel="last"`)
+
+				assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+
+				if req.FormValue("page") != "2" {
+					w.Header().Set("Link", `<`+url+`?page=2>; rel="next", `+w.Header().Get("Link"))
+					w.Write([]byte(`[{"id": 1, "full_name": "user1/repo1"},{"id": 2, "full_name": "user1/repo2"}]`))
+				} else {
+					w.Write([]byte(`[{"id": 3, "full_name": "user1/repo3"}]`))
+				}
+			})
+
+			repositories, err := c.OwnerRepositories(ctx, "user1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"user1/repo1", "user1/repo2", "user1/repo3"}, repositories)
+
+			ts.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_OwnerRepositories_OwnerDoesNotExist(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/users/user1", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	})
+
+	_, err := c.OwnerRepositories(ctx, "user1")
+	assert.Equal(t, github.ErrNoSuchOwner, err)
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_UserProfile(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	mux.HandleFunc("/users/user2", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+		w.Write([]byte(`{"login": "user2", "id": 2, "name": "User 2", "avatar_url": "https://avatars.githubusercontent.com/u/2", "type": "User", "site_admin": true}`))
+	})
+
+	mux.HandleFunc("/users/user3", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	})
+
+	profile, err := c.UserProfile(ctx, "user1", "user2")
+	require.NoError(t, err)
+	assert.Equal(t, &blamewarrior.AccountProfile{
+		Name:      "User 2",
+		AvatarURL: "https://avatars.githubusercontent.com/u/2",
+		Type:      "User",
+		SiteAdmin: true,
+	}, profile)
+
+	_, err = c.UserProfile(ctx, "user1", "user3")
+	assert.Equal(t, github.ErrNoSuchOwner, err)
+
+	ts.AssertExpectations(t)
+}
+
 func TestSplitRepositoryName(t *testing.T) {
 	examples := map[string]struct {
 		Owner, Name string
@@ -137,6 +426,10 @@ func TestSplitRepositoryName(t *testing.T) {
 		"a/b":  {"a", "b"},
 		"a/b/": {"a", "b/"},
 		"a//b": {"a", "/b"},
+
+		"ghe.example.com/a/b":      {"ghe.example.com/a", "b"},
+		"GHE.example.com:8443/a/b": {"ghe.example.com:8443/a", "b"},
+		"ghe.example.com/a":        {"", ""},
 	}
 
 	for fullName, expected := range examples {
@@ -148,6 +441,68 @@ func TestSplitRepositoryName(t *testing.T) {
 	}
 }
 
+func TestValidateLogin(t *testing.T) {
+	examples := map[string]error{
+		"octocat":               nil,
+		"OctoCat":               nil,
+		"octo-cat":              nil,
+		"o":                     nil,
+		"1234":                  nil,
+		"dependabot[bot]":       nil,
+		strings.Repeat("a", 39): nil,
+		"":                      github.ErrInvalidLogin,
+		"[bot]":                 github.ErrInvalidLogin,
+		"-octocat":              github.ErrInvalidLogin,
+		"octocat-":              github.ErrInvalidLogin,
+		"octo--cat":             github.ErrInvalidLogin,
+		"octo_cat":              github.ErrInvalidLogin,
+		"octo.cat":              github.ErrInvalidLogin,
+		"octo/cat":              github.ErrInvalidLogin,
+		"octocat[bot][bot]":     github.ErrInvalidLogin,
+		"oktokät":               github.ErrInvalidLogin,
+		strings.Repeat("a", 40): github.ErrInvalidLogin,
+	}
+
+	for login, expected := range examples {
+		t.Run(fmt.Sprintf("login: %q", login), func(t *testing.T) {
+			assert.Equal(t, expected, github.ValidateLogin(login))
+		})
+	}
+}
+
+func TestValidateRepositoryName(t *testing.T) {
+	examples := map[string]error{
+		"blamewarrior/hooks":                  nil,
+		"BlameWarrior/Hooks":                  nil,
+		"octocat/.github":                     nil,
+		"octocat/hello_world-2.0":             nil,
+		"octocat/" + strings.Repeat("a", 100): nil,
+		"ghe.example.com/octocat/hooks":       nil,
+		"ghe.example.com:8443/octocat/hooks":  nil,
+		"ghe.example.com/octocat":             github.ErrInvalidRepositoryName,
+		"ghe_example.com/octocat/hooks":       github.ErrInvalidRepositoryName,
+		"ghe/octocat/hooks":                   github.ErrInvalidRepositoryName,
+		"":                                    github.ErrInvalidRepositoryName,
+		"octocat":                             github.ErrInvalidRepositoryName,
+		"octocat/":                            github.ErrInvalidRepositoryName,
+		"/hooks":                              github.ErrInvalidRepositoryName,
+		"octocat/hooks/":                      github.ErrInvalidRepositoryName,
+		"octocat//hooks":                      github.ErrInvalidRepositoryName,
+		"octocat/.":                           github.ErrInvalidRepositoryName,
+		"octocat/..":                          github.ErrInvalidRepositoryName,
+		"octocat/hello world":                 github.ErrInvalidRepositoryName,
+		"-octocat/hooks":                      github.ErrInvalidRepositoryName,
+		"dependabot[bot]/hooks":               github.ErrInvalidRepositoryName,
+		"octocat/" + strings.Repeat("a", 101): github.ErrInvalidRepositoryName,
+	}
+
+	for fullName, expected := range examples {
+		t.Run(fmt.Sprintf("fullName: %q", fullName), func(t *testing.T) {
+			assert.Equal(t, expected, github.ValidateRepositoryName(fullName))
+		})
+	}
+}
+
 func setupAPIServer() (baseURL *url.URL, mux *http.ServeMux, teardownFn func()) {
 	mux = http.NewServeMux()
 	srv := httptest.NewServer(mux)
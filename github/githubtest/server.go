@@ -0,0 +1,687 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+// Package githubtest provides a stateful fake GitHub API server for tests of code
+// that talks to GitHub with github.Client.
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+)
+
+const (
+	// DefaultPerPage is the page size of lists requested without per_page parameter.
+	DefaultPerPage = 30
+	// MaxPerPage is the maximum page size, larger per_page values are ignored.
+	MaxPerPage = 100
+	// DefaultRateLimit is the number of requests a token is allowed to send per hour.
+	DefaultRateLimit = 5000
+)
+
+// Server is a fake GitHub API that keeps users, repositories, their collaborators, teams
+// and pending invitations in memory. It paginates lists, reports rate limits of tokens,
+// revalidates responses with ETags and can be told to fail requests.
+//
+// Server implements tokens.Client, each known user or organization is given a token that
+// authorizes requests on its behalf.
+type Server struct {
+	// URL is the API endpoint to be used as github.Context.BaseURL or github.Host.BaseURL.
+	URL *url.URL
+	// RateLimit is the number of requests a token is allowed to send per hour.
+	RateLimit int
+
+	srv *httptest.Server
+
+	mu          sync.Mutex
+	lastID      int
+	users       map[string]*blamewarrior.Account
+	repos       map[string]*repository
+	teamMembers map[int][]string
+	revoked     map[string]bool
+	rateLimits  map[string]*rateLimit
+	failures    map[string]*failure
+	requests    map[string]int
+}
+
+type repository struct {
+	id            int
+	owner, name   string
+	collaborators []blamewarrior.Account
+	teams         []blamewarrior.Team
+	invitations   []blamewarrior.Invitation
+}
+
+func (repo *repository) fullName() string {
+	return repo.owner + "/" + repo.name
+}
+
+type rateLimit struct {
+	remaining int
+	reset     time.Time
+}
+
+type failure struct {
+	status int
+	// times is the number of requests left to fail, negative for all of them
+	times int
+}
+
+// NewServer starts a server, which should be closed once the test is finished.
+func NewServer() *Server {
+	s := &Server{
+		RateLimit:   DefaultRateLimit,
+		users:       make(map[string]*blamewarrior.Account),
+		repos:       make(map[string]*repository),
+		teamMembers: make(map[int][]string),
+		revoked:     make(map[string]bool),
+		rateLimits:  make(map[string]*rateLimit),
+		failures:    make(map[string]*failure),
+		requests:    make(map[string]int),
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL, _ = url.Parse(s.srv.URL + "/")
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddUser adds a user or replaces an existing one with the same login. Users without
+// type are of type "User", the ones without uid are given a new one. It returns the
+// stored account.
+func (s *Server) AddUser(user blamewarrior.Account) blamewarrior.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.addUser(user)
+}
+
+// AddOrganization adds an organization.
+func (s *Server) AddOrganization(login string) blamewarrior.Account {
+	return s.AddUser(blamewarrior.Account{
+		Login:          login,
+		AccountProfile: blamewarrior.AccountProfile{Type: "Organization"},
+	})
+}
+
+// CreateRepository adds a repository returning its id. The owner is added as a user
+// unless it's known already.
+func (s *Server) CreateRepository(fullName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if repo, ok := s.repos[strings.ToLower(fullName)]; ok {
+		return repo.id
+	}
+
+	owner, name := splitRepositoryName(fullName)
+	if _, ok := s.users[strings.ToLower(owner)]; !ok {
+		s.addUser(blamewarrior.Account{Login: owner})
+	}
+
+	repo := &repository{id: s.nextID(), owner: owner, name: name}
+	s.repos[strings.ToLower(fullName)] = repo
+
+	return repo.id
+}
+
+// AddCollaborator grants an account access to a repository replacing permissions and
+// affiliation of an existing collaborator. Collaborators without affiliation are direct.
+// Unknown accounts are added as users.
+func (s *Server) AddCollaborator(fullName string, account blamewarrior.Account) {
+	repo := s.repository(fullName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.findOrAddUser(account)
+	account.Uid, account.Login = user.Uid, user.Login
+
+	if account.Affiliation == "" {
+		account.Affiliation = blamewarrior.AffiliationDirect
+	}
+
+	for i := range repo.collaborators {
+		if strings.EqualFold(repo.collaborators[i].Login, account.Login) {
+			repo.collaborators[i] = account
+			return
+		}
+	}
+
+	repo.collaborators = append(repo.collaborators, account)
+}
+
+// RemoveCollaborator revokes access of an account to a repository.
+func (s *Server) RemoveCollaborator(fullName, login string) {
+	repo := s.repository(fullName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range repo.collaborators {
+		if strings.EqualFold(repo.collaborators[i].Login, login) {
+			repo.collaborators = append(repo.collaborators[:i], repo.collaborators[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddTeam grants a team access to a repository and replaces its members. Teams without
+// uid are given a new one, the same team can be added to several repositories. It returns
+// the team uid.
+func (s *Server) AddTeam(fullName string, team blamewarrior.Team, members ...blamewarrior.Account) int {
+	repo := s.repository(fullName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if team.Uid == 0 {
+		team.Uid = s.nextID()
+	}
+
+	logins := make([]string, 0, len(members))
+	for _, member := range members {
+		logins = append(logins, s.findOrAddUser(member).Login)
+	}
+	s.teamMembers[team.Uid] = logins
+
+	for i := range repo.teams {
+		if repo.teams[i].Uid == team.Uid {
+			repo.teams[i] = team
+			return team.Uid
+		}
+	}
+
+	repo.teams = append(repo.teams, team)
+
+	return team.Uid
+}
+
+// AddInvitation adds a pending invitation to collaborate on a repository. Invitations
+// without uid are given a new one, unknown invitees are added as users. It returns the
+// invitation uid.
+func (s *Server) AddInvitation(fullName string, invitation blamewarrior.Invitation) int {
+	repo := s.repository(fullName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if invitation.Uid == 0 {
+		invitation.Uid = s.nextID()
+	}
+
+	invitee := s.findOrAddUser(blamewarrior.Account{Uid: invitation.InviteeUid, Login: invitation.InviteeLogin})
+	invitation.InviteeUid, invitation.InviteeLogin = invitee.Uid, invitee.Login
+
+	if invitation.CreatedAt.IsZero() {
+		invitation.CreatedAt = time.Now()
+	}
+
+	repo.invitations = append(repo.invitations, invitation)
+
+	return invitation.Uid
+}
+
+// GetToken returns the token of a known user or organization. Owners qualified with a host
+// name are looked up by their login, so the server can stand in for GitHub Enterprise Server.
+func (s *Server) GetToken(nickname string) (string, error) {
+	login := nickname[strings.LastIndexByte(nickname, '/')+1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[strings.ToLower(login)]
+	if !ok {
+		return "", tokens.ErrUserNotFound
+	}
+
+	return tokenOf(user.Login), nil
+}
+
+// RevokeToken makes the server reject the token of an owner as GitHub does once the user
+// revokes it.
+func (s *Server) RevokeToken(owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[tokenOf(owner)] = true
+}
+
+// SetRateLimit sets the number of requests the token of an owner has left until reset.
+func (s *Server) SetRateLimit(owner string, remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimits[tokenOf(owner)] = &rateLimit{remaining: remaining, reset: reset}
+}
+
+// FailRequests makes the next given number of requests to a path, i.e. /repos/owner/repo/teams,
+// fail with the status code. All requests fail if times is negative, zero stops failing them.
+// Failed requests do not count against rate limit.
+func (s *Server) FailRequests(path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if times == 0 {
+		delete(s.failures, path)
+		return
+	}
+
+	s.failures[path] = &failure{status: status, times: times}
+}
+
+// Requests returns the number of requests received for a path including conditional
+// and failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *Server) repository(fullName string) *repository {
+	s.CreateRepository(fullName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repos[strings.ToLower(fullName)]
+}
+
+func (s *Server) nextID() int {
+	s.lastID++
+	return s.lastID
+}
+
+func (s *Server) addUser(user blamewarrior.Account) *blamewarrior.Account {
+	if user.Uid == 0 {
+		user.Uid = s.nextID()
+	}
+
+	if user.Type == "" {
+		user.Type = "User"
+	}
+
+	user.Permissions, user.Affiliation, user.Teams = blamewarrior.AccountPermissions{}, "", nil
+	s.users[strings.ToLower(user.Login)] = &user
+
+	return &user
+}
+
+func (s *Server) findOrAddUser(account blamewarrior.Account) *blamewarrior.Account {
+	if user, ok := s.users[strings.ToLower(account.Login)]; ok {
+		return user
+	}
+
+	return s.addUser(account)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[req.URL.Path]++
+
+	owner, ok := s.authorize(req)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+
+	if f, ok := s.failures[req.URL.Path]; ok {
+		if f.times > 0 {
+			if f.times--; f.times == 0 {
+				delete(s.failures, req.URL.Path)
+			}
+		}
+
+		writeError(w, f.status, http.StatusText(f.status))
+		return
+	}
+
+	limit := s.rateLimit(owner)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.RateLimit))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(limit.reset.Unix(), 10))
+
+	if limit.remaining <= 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		writeError(w, http.StatusForbidden, "API rate limit exceeded for "+owner.Login+".")
+		return
+	}
+
+	if req.Method != http.MethodGet {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.remaining))
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	v, status := s.route(w.Header(), req, owner)
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.remaining))
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// successful conditional requests do not count against rate limit
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(append([]byte(w.Header().Get("Link")), body...)))
+	if status == http.StatusOK && req.Header.Get("If-None-Match") == etag {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.remaining))
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	limit.remaining--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.remaining))
+
+	if status == http.StatusOK {
+		w.Header().Set("ETag", etag)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// authorize returns the owner of the request token.
+func (s *Server) authorize(req *http.Request) (*blamewarrior.Account, bool) {
+	auth := req.Header.Get("Authorization")
+
+	var token string
+	for _, scheme := range []string{"Bearer ", "token "} {
+		if strings.HasPrefix(auth, scheme) {
+			token = strings.TrimPrefix(auth, scheme)
+		}
+	}
+
+	if token == "" || s.revoked[token] || !strings.HasPrefix(token, tokenPrefix) {
+		return nil, false
+	}
+
+	user, ok := s.users[strings.TrimPrefix(token, tokenPrefix)]
+
+	return user, ok
+}
+
+func (s *Server) rateLimit(owner *blamewarrior.Account) *rateLimit {
+	token := tokenOf(owner.Login)
+
+	limit, ok := s.rateLimits[token]
+	if !ok || !time.Now().Before(limit.reset) {
+		limit = &rateLimit{remaining: s.RateLimit, reset: time.Now().Add(time.Hour)}
+		s.rateLimits[token] = limit
+	}
+
+	return limit
+}
+
+// route returns the response to a GET request along with its status code.
+func (s *Server) route(header http.Header, req *http.Request, owner *blamewarrior.Account) (interface{}, int) {
+	path := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case len(path) == 3 && path[0] == "repos":
+		if repo, ok := s.repos[strings.ToLower(path[1]+"/"+path[2])]; ok {
+			return s.repositoryJSON(repo), http.StatusOK
+		}
+	case len(path) == 4 && path[0] == "repos" && path[3] == "collaborators":
+		if repo, ok := s.repos[strings.ToLower(path[1]+"/"+path[2])]; ok {
+			return s.paginate(header, req, s.collaboratorsJSON(repo, req.FormValue("affiliation"))), http.StatusOK
+		}
+	case len(path) == 4 && path[0] == "repos" && path[3] == "teams":
+		if repo, ok := s.repos[strings.ToLower(path[1]+"/"+path[2])]; ok {
+			return s.paginate(header, req, teamsJSON(repo.teams)), http.StatusOK
+		}
+	case len(path) == 3 && path[0] == "repositories" && path[2] == "invitations":
+		for _, repo := range s.repos {
+			if strconv.Itoa(repo.id) == path[1] {
+				return s.paginate(header, req, s.invitationsJSON(repo)), http.StatusOK
+			}
+		}
+	case len(path) == 3 && path[0] == "teams" && path[2] == "members":
+		uid, _ := strconv.Atoi(path[1])
+		if logins, ok := s.teamMembers[uid]; ok {
+			var members []interface{}
+			for _, login := range logins {
+				members = append(members, userJSON(s.users[strings.ToLower(login)]))
+			}
+
+			return s.paginate(header, req, members), http.StatusOK
+		}
+	case len(path) == 2 && path[0] == "users":
+		if user, ok := s.users[strings.ToLower(path[1])]; ok {
+			return userJSON(user), http.StatusOK
+		}
+	case len(path) == 3 && path[0] == "orgs" && path[2] == "repos":
+		if user, ok := s.users[strings.ToLower(path[1])]; ok && user.Type == "Organization" {
+			return s.paginate(header, req, s.ownerRepositoriesJSON(user.Login)), http.StatusOK
+		}
+	case len(path) == 2 && path[0] == "user" && path[1] == "repos":
+		return s.paginate(header, req, s.ownerRepositoriesJSON(owner.Login)), http.StatusOK
+	}
+
+	return map[string]string{"message": "Not Found"}, http.StatusNotFound
+}
+
+// paginate returns the requested page of items and sets Link header to point to the others.
+func (s *Server) paginate(header http.Header, req *http.Request, items []interface{}) []interface{} {
+	perPage, _ := strconv.Atoi(req.FormValue("per_page"))
+	if perPage <= 0 || perPage > MaxPerPage {
+		perPage = DefaultPerPage
+	}
+
+	page, _ := strconv.Atoi(req.FormValue("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	lastPage := (len(items) + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	var links []string
+	link := func(page int, rel string) {
+		q := req.URL.Query()
+		q.Set("page", strconv.Itoa(page))
+
+		u := s.URL.ResolveReference(&url.URL{Path: strings.TrimPrefix(req.URL.Path, "/"), RawQuery: q.Encode()})
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u, rel))
+	}
+
+	if page < lastPage {
+		link(page+1, "next")
+		link(lastPage, "last")
+	}
+
+	if page > 1 {
+		link(1, "first")
+		link(page-1, "prev")
+	}
+
+	if len(links) > 0 {
+		header.Set("Link", strings.Join(links, ", "))
+	}
+
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(items) {
+		start = len(items)
+	}
+
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return append([]interface{}{}, items[start:end]...)
+}
+
+func (s *Server) repositoryJSON(repo *repository) interface{} {
+	return map[string]interface{}{
+		"id":        repo.id,
+		"name":      repo.name,
+		"full_name": repo.fullName(),
+		"owner":     userJSON(s.users[strings.ToLower(repo.owner)]),
+	}
+}
+
+// collaboratorsJSON lists collaborators filtered by affiliation the same way GitHub does:
+// outside collaborators are direct ones as well, and all collaborators are listed by default.
+func (s *Server) collaboratorsJSON(repo *repository, affiliation string) []interface{} {
+	var collaborators []interface{}
+
+	for i := range repo.collaborators {
+		collaborator := &repo.collaborators[i]
+
+		switch affiliation {
+		case "outside":
+			if collaborator.Affiliation != blamewarrior.AffiliationOutside {
+				continue
+			}
+		case "direct":
+			if collaborator.Affiliation == blamewarrior.AffiliationMember {
+				continue
+			}
+		}
+
+		user := userJSON(s.users[strings.ToLower(collaborator.Login)])
+		user["permissions"] = permissionsJSON(collaborator.Permissions)
+
+		collaborators = append(collaborators, user)
+	}
+
+	return collaborators
+}
+
+func (s *Server) invitationsJSON(repo *repository) []interface{} {
+	var invitations []interface{}
+
+	for _, invitation := range repo.invitations {
+		v := map[string]interface{}{
+			"id":          invitation.Uid,
+			"invitee":     userJSON(s.users[strings.ToLower(invitation.InviteeLogin)]),
+			"permissions": invitation.Permission,
+			"created_at":  invitation.CreatedAt.UTC().Format(time.RFC3339),
+		}
+
+		if inviter, ok := s.users[strings.ToLower(invitation.InviterLogin)]; ok {
+			v["inviter"] = userJSON(inviter)
+		}
+
+		invitations = append(invitations, v)
+	}
+
+	return invitations
+}
+
+func (s *Server) ownerRepositoriesJSON(owner string) []interface{} {
+	var repos []*repository
+	for _, repo := range s.repos {
+		if strings.EqualFold(repo.owner, owner) {
+			repos = append(repos, repo)
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool { return repos[i].id < repos[j].id })
+
+	var items []interface{}
+	for _, repo := range repos {
+		items = append(items, s.repositoryJSON(repo))
+	}
+
+	return items
+}
+
+func teamsJSON(teams []blamewarrior.Team) []interface{} {
+	var items []interface{}
+
+	for _, team := range teams {
+		items = append(items, map[string]interface{}{
+			"id":         team.Uid,
+			"name":       team.Name,
+			"slug":       team.Slug,
+			"permission": team.Permission,
+		})
+	}
+
+	return items
+}
+
+func userJSON(user *blamewarrior.Account) map[string]interface{} {
+	return map[string]interface{}{
+		"login":      user.Login,
+		"id":         user.Uid,
+		"name":       user.Name,
+		"avatar_url": user.AvatarURL,
+		"type":       user.Type,
+		"site_admin": user.SiteAdmin,
+	}
+}
+
+// permissionsJSON lists all permission levels the way GitHub does.
+func permissionsJSON(perms blamewarrior.AccountPermissions) map[string]bool {
+	m := make(map[string]bool)
+	for p := blamewarrior.PermissionPull; p <= blamewarrior.PermissionAdmin; p++ {
+		m[p.String()] = perms.Has(p)
+	}
+
+	return m
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":           message,
+		"documentation_url": "https://developer.github.com/v3",
+	})
+}
+
+const tokenPrefix = "githubtest-"
+
+func tokenOf(login string) string {
+	return tokenPrefix + strings.ToLower(login)
+}
+
+func splitRepositoryName(fullName string) (owner, name string) {
+	if i := strings.IndexByte(fullName, '/'); i >= 0 {
+		return fullName[:i], fullName[i+1:]
+	}
+
+	return fullName, ""
+}
+
+var _ tokens.Client = (*Server)(nil)
@@ -0,0 +1,274 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package githubtest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/github/githubtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RepositoryCollaborators(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+
+	var expected []blamewarrior.Account
+	for i := 1; i <= 150; i++ {
+		account := blamewarrior.Account{
+			Uid:         1000 + i,
+			Login:       fmt.Sprintf("user%d", i),
+			Permissions: blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true},
+			Affiliation: blamewarrior.AffiliationMember,
+		}
+
+		switch {
+		case i%3 == 0:
+			account.Affiliation = blamewarrior.AffiliationOutside
+		case i%2 == 0:
+			account.Affiliation = blamewarrior.AffiliationDirect
+			account.Permissions = blamewarrior.AccountPermissions{Pull: true}
+		}
+
+		srv.AddCollaborator("blamewarrior/repos", account)
+		expected = append(expected, account)
+	}
+
+	c := github.NewClient(srv)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	collaborators, err := c.RepositoryCollaborators(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Equal(t, expected, collaborators)
+
+	// outside and direct collaborators fit into a single page, and all of them take two
+	assert.Equal(t, 4, srv.Requests("/repos/blamewarrior/repos/collaborators"))
+
+	srv.RemoveCollaborator("blamewarrior/repos", "USER1")
+
+	collaborators, err = c.RepositoryCollaborators(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Equal(t, expected[1:], collaborators)
+}
+
+func TestServer_TeamsAndInvitations(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	user1 := srv.AddUser(blamewarrior.Account{Login: "user1"})
+	user2 := srv.AddUser(blamewarrior.Account{Login: "user2"})
+
+	teamUid := srv.AddTeam("blamewarrior/repos", blamewarrior.Team{Name: "Owners", Slug: "owners", Permission: "admin"}, user1, user2)
+
+	createdAt := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	invitationUid := srv.AddInvitation("blamewarrior/repos", blamewarrior.Invitation{
+		InviteeLogin: "user3",
+		InviterLogin: "user1",
+		Permission:   "write",
+		CreatedAt:    createdAt,
+	})
+
+	c := github.NewClient(srv)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	teams, err := c.RepositoryTeams(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Equal(t, []blamewarrior.Team{{Uid: teamUid, Name: "Owners", Slug: "owners", Permission: "admin"}}, teams)
+
+	members, err := c.TeamMembers(ctx, "blamewarrior", teamUid)
+	require.NoError(t, err)
+	assert.Equal(t, []blamewarrior.Account{{Uid: user1.Uid, Login: "user1"}, {Uid: user2.Uid, Login: "user2"}}, members)
+
+	invitations, err := c.RepositoryInvitations(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, invitationUid, invitations[0].Uid)
+	assert.Equal(t, "user3", invitations[0].InviteeLogin)
+	assert.NotZero(t, invitations[0].InviteeUid)
+	assert.Equal(t, "user1", invitations[0].InviterLogin)
+	assert.Equal(t, "write", invitations[0].Permission)
+	assert.True(t, createdAt.Equal(invitations[0].CreatedAt))
+}
+
+func TestServer_OwnerRepositories(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	srv.CreateRepository("blamewarrior/repos")
+	srv.CreateRepository("blamewarrior/collaborators")
+	srv.CreateRepository("octocat/hello-world")
+
+	c := github.NewClient(srv)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	repos, err := c.OwnerRepositories(ctx, "blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blamewarrior/repos", "blamewarrior/collaborators"}, repos)
+
+	repos, err = c.OwnerRepositories(ctx, "octocat")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"octocat/hello-world"}, repos)
+
+	_, err = c.OwnerRepositories(ctx, "unknown")
+	assert.Equal(t, tokens.ErrUserNotFound, err)
+}
+
+func TestServer_UserProfile(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	srv.AddUser(blamewarrior.Account{
+		Login:          "hubot",
+		AccountProfile: blamewarrior.AccountProfile{Name: "Hubot", AvatarURL: "https://avatars.githubusercontent.com/u/2", Type: "Bot"},
+	})
+
+	c := github.NewClient(srv)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	profile, err := c.UserProfile(ctx, "blamewarrior", "hubot")
+	require.NoError(t, err)
+	assert.Equal(t, &blamewarrior.AccountProfile{Name: "Hubot", AvatarURL: "https://avatars.githubusercontent.com/u/2", Type: "Bot"}, profile)
+
+	_, err = c.UserProfile(ctx, "blamewarrior", "unknown")
+	assert.Equal(t, github.ErrNoSuchOwner, err)
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.CreateRepository("blamewarrior/repos")
+	srv.SetRateLimit("blamewarrior", 3, time.Now().Add(time.Hour))
+
+	c := github.NewClient(srv)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	// all and direct collaborators are listed, there are no outside ones without direct
+	_, err := c.RepositoryCollaborators(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, c.RateLimits.All()[0].Remaining)
+
+	_, err = c.RepositoryTeams(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	_, err = c.RepositoryTeams(ctx, "blamewarrior/repos")
+	assert.Equal(t, github.ErrRateLimitReached, err)
+}
+
+func TestServer_ETags(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+	srv.SetRateLimit("blamewarrior", 10, time.Now().Add(time.Hour))
+
+	c := github.NewClient(srv)
+	c.ResponseCache = github.NewMemoryResponseCache(github.DefaultResponseCacheSize)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	_, err := c.RepositoryTeams(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	teams, err := c.RepositoryTeams(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, teams)
+
+	assert.Equal(t, int64(1), c.CacheStats().Revalidated)
+	assert.Equal(t, 9, c.RateLimits.All()[0].Remaining)
+
+	srv.AddTeam("blamewarrior/repos", blamewarrior.Team{Name: "Owners", Slug: "owners"})
+
+	teams, err = c.RepositoryTeams(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, teams, 1)
+
+	assert.Equal(t, int64(1), c.CacheStats().Revalidated)
+	assert.Equal(t, 8, c.RateLimits.All()[0].Remaining)
+}
+
+func TestServer_FailRequests(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.CreateRepository("blamewarrior/repos")
+	srv.FailRequests("/repos/blamewarrior/repos/teams", http.StatusBadGateway, 1)
+	srv.FailRequests("/repos/blamewarrior/repos", http.StatusNotFound, -1)
+
+	c := github.NewClient(srv)
+	c.MaxRetries = 0
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	_, err := c.RepositoryTeams(ctx, "blamewarrior/repos")
+	assert.Error(t, err)
+
+	_, err = c.RepositoryTeams(ctx, "blamewarrior/repos")
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.RepositoryInvitations(ctx, "blamewarrior/repos")
+		assert.Equal(t, github.ErrNoSuchRepository, err)
+	}
+
+	srv.FailRequests("/repos/blamewarrior/repos", 0, 0)
+
+	_, err = c.RepositoryInvitations(ctx, "blamewarrior/repos")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, srv.Requests("/repos/blamewarrior/repos"))
+}
+
+type invalidatingTokenClient struct {
+	tokens.Client
+	invalidated []string
+}
+
+func (c *invalidatingTokenClient) Invalidate(nickname string) {
+	c.invalidated = append(c.invalidated, nickname)
+}
+
+func TestServer_RevokeToken(t *testing.T) {
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.CreateRepository("blamewarrior/repos")
+	srv.RevokeToken("blamewarrior")
+
+	tokenClient := &invalidatingTokenClient{Client: srv}
+
+	c := github.NewClient(tokenClient)
+	ctx := github.Context{Context: context.Background(), BaseURL: srv.URL}
+
+	_, err := c.RepositoryTeams(ctx, "blamewarrior/repos")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"blamewarrior"}, tokenClient.invalidated)
+}
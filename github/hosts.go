@@ -0,0 +1,264 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// DefaultHost is the name of github.com. Names of repositories and owners hosted there
+// are not qualified with the host name.
+const DefaultHost = "github.com"
+
+var (
+	ErrInvalidHost = errors.New("invalid GitHub host name")
+	ErrUnknownHost = errors.New("unknown GitHub host")
+)
+
+// Host describes API endpoints of a GitHub Enterprise Server instance.
+type Host struct {
+	Name string
+	// BaseURL is the REST API endpoint, https://<name>/api/v3/ by default.
+	BaseURL *url.URL
+	// UploadURL is the uploads API endpoint, https://<name>/api/uploads/ by default.
+	UploadURL *url.URL
+}
+
+// Hosts maps GitHub Enterprise Server host names and owners hosted there to API endpoints.
+// Owners assigned to a host can be referred to without qualifying them with the host name.
+type Hosts struct {
+	hosts  map[string]*Host
+	owners map[string]*Host
+}
+
+func NewHosts() *Hosts {
+	return &Hosts{
+		hosts:  make(map[string]*Host),
+		owners: make(map[string]*Host),
+	}
+}
+
+// hostConfig is the JSON representation of a host in the hosts file.
+type hostConfig struct {
+	Name      string   `json:"name"`
+	APIURL    string   `json:"api_url"`
+	UploadURL string   `json:"upload_url"`
+	Owners    []string `json:"owners"`
+}
+
+// ReadHosts reads a JSON list of GitHub Enterprise Server hosts in the following format:
+//
+//	[{"name": "ghe.example.com", "api_url": "https://ghe.example.com/api/v3/", "owners": ["acme"]}]
+//
+// Endpoint URLs are optional and default to the ones used by GitHub Enterprise Server.
+func ReadHosts(r io.Reader) (*Hosts, error) {
+	var configs []hostConfig
+	if err := json.NewDecoder(r).Decode(&configs); err != nil {
+		return nil, fmt.Errorf("failed to decode hosts: %s", err)
+	}
+
+	hosts := NewHosts()
+	for _, config := range configs {
+		baseURL, err := parseEndpoint(config.APIURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API endpoint of %s: %s", config.Name, err)
+		}
+
+		uploadURL, err := parseEndpoint(config.UploadURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload endpoint of %s: %s", config.Name, err)
+		}
+
+		host := Host{Name: config.Name, BaseURL: baseURL, UploadURL: uploadURL}
+
+		if err := hosts.Add(host, config.Owners...); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %s", config.Name, err)
+		}
+	}
+
+	return hosts, nil
+}
+
+// parseEndpoint parses an absolute endpoint URL returning nil if it's empty.
+func parseEndpoint(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%q is not an absolute URL", s)
+	}
+
+	return u, nil
+}
+
+// Add registers a GitHub Enterprise Server host along with owners that should be looked up
+// there by default. Missing endpoints are filled in with GitHub Enterprise Server defaults.
+func (h *Hosts) Add(host Host, owners ...string) error {
+	if err := ValidateHost(host.Name); err != nil {
+		return err
+	}
+
+	host.Name = strings.ToLower(host.Name)
+	if host.Name == DefaultHost {
+		return fmt.Errorf("%s endpoints cannot be overridden", DefaultHost)
+	}
+
+	if host.BaseURL == nil {
+		host.BaseURL = &url.URL{Scheme: "https", Host: host.Name, Path: "/api/v3/"}
+	}
+
+	if host.UploadURL == nil {
+		host.UploadURL = &url.URL{Scheme: "https", Host: host.Name, Path: "/api/uploads/"}
+	}
+
+	// go-github resolves paths relative to endpoints, so they must end with a slash
+	for _, u := range []*url.URL{host.BaseURL, host.UploadURL} {
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+	}
+
+	for _, owner := range owners {
+		if err := ValidateLogin(owner); err != nil {
+			return err
+		}
+
+		if other, ok := h.owners[strings.ToLower(owner)]; ok && other.Name != host.Name {
+			return fmt.Errorf("%s is already assigned to %s", owner, other.Name)
+		}
+	}
+
+	h.hosts[host.Name] = &host
+	for _, owner := range owners {
+		h.owners[strings.ToLower(owner)] = &host
+	}
+
+	return nil
+}
+
+// Lookup returns the host of an owner, which is either qualified with the host name or
+// assigned to one of the hosts. Nil host is returned for github.com owners.
+func (h *Hosts) Lookup(owner string) (*Host, error) {
+	hostName, login := SplitHost(owner)
+
+	if hostName == DefaultHost {
+		if h == nil {
+			return nil, nil
+		}
+
+		return h.owners[strings.ToLower(login)], nil
+	}
+
+	if h != nil {
+		if host, ok := h.hosts[hostName]; ok {
+			return host, nil
+		}
+	}
+
+	return nil, ErrUnknownHost
+}
+
+// Canonical returns the name an owner or a repository is stored under. Names of owners assigned
+// to a host are qualified with its name, so that acme/repo and ghe.example.com/acme/repo refer
+// to the same repository. Names of github.com owners are never qualified.
+func (h *Hosts) Canonical(name string) string {
+	hostName, rest := SplitHost(name)
+
+	if hostName == DefaultHost && h != nil {
+		owner := rest
+		if sep := strings.IndexByte(rest, '/'); sep >= 0 {
+			owner = rest[:sep]
+		}
+
+		if host, ok := h.owners[strings.ToLower(owner)]; ok {
+			hostName = host.Name
+		}
+	}
+
+	return QualifyName(hostName, rest)
+}
+
+// SplitHost splits a name qualified with GitHub host name, such as ghe.example.com/owner or
+// ghe.example.com/owner/repo, into the host name and the rest. Names that are not qualified
+// belong to github.com. Host names are told from owners by a dot or a port number in them,
+// since neither is allowed in GitHub logins.
+func SplitHost(name string) (host, rest string) {
+	sep := strings.IndexByte(name, '/')
+	if sep <= 0 || !strings.ContainsAny(name[:sep], ".:") {
+		return DefaultHost, name
+	}
+
+	return strings.ToLower(name[:sep]), name[sep+1:]
+}
+
+// QualifyName prefixes an owner or a repository name with GitHub host name unless it
+// is github.com.
+func QualifyName(host, name string) string {
+	if host == "" || strings.EqualFold(host, DefaultHost) {
+		return name
+	}
+
+	return strings.ToLower(host) + "/" + name
+}
+
+// ValidateHost checks that host is a domain name or an IP address optionally followed
+// by a port number.
+func ValidateHost(host string) error {
+	if host == "" || len(host) > 255 {
+		return ErrInvalidHost
+	}
+
+	if !strings.ContainsAny(host, ".:") {
+		return ErrInvalidHost
+	}
+
+	u, err := url.Parse("https://" + host)
+	if err != nil || u.Host != host || u.Hostname() == "" || strings.HasSuffix(host, ":") {
+		return ErrInvalidHost
+	}
+
+	for _, c := range u.Hostname() {
+		if !isASCIIAlphanumeric(c) && c != '-' && c != '.' {
+			return ErrInvalidHost
+		}
+	}
+
+	return nil
+}
+
+// ValidateOwner checks that owner is a valid login optionally qualified with GitHub host name.
+func ValidateOwner(owner string) error {
+	host, login := SplitHost(owner)
+	if host != DefaultHost && ValidateHost(host) != nil {
+		return ErrInvalidHost
+	}
+
+	return ValidateLogin(login)
+}
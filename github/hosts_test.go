@@ -0,0 +1,206 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EnterpriseHost(t *testing.T) {
+	apiURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	hosts, err := github.ReadHosts(strings.NewReader(fmt.Sprintf(`[
+		{"name": "ghe.example.com", "api_url": "%s/api/v3", "owners": ["user2"]}
+	]`, apiURL)))
+	require.NoError(t, err)
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "ghe.example.com/user1").Return("token1", nil)
+	ts.On("GetToken", "ghe.example.com/user2").Return("token2", nil)
+
+	c := github.NewClient(ts)
+	c.Hosts = hosts
+
+	ctx := github.Context{Context: context.Background()}
+
+	mux.HandleFunc("/api/v3/users/user1", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"login": "user1", "id": 1, "type": "Organization"}`))
+	})
+
+	mux.HandleFunc("/api/v3/orgs/user1/repos", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+		w.Write([]byte(`[{"id": 1, "full_name": "user1/repo1"}]`))
+	})
+
+	mux.HandleFunc("/api/v3/repos/user2/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token2", req.Header.Get("Authorization"))
+		w.Write([]byte(`[{"id": 1, "slug": "team1"}]`))
+	})
+
+	repositories, err := c.OwnerRepositories(ctx, "ghe.example.com/user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ghe.example.com/user1/repo1"}, repositories)
+
+	teams, err := c.RepositoryTeams(ctx, "user2/repo1")
+	require.NoError(t, err)
+	assert.Len(t, teams, 1)
+
+	_, err = c.RepositoryTeams(ctx, "ghe.unknown.com/user1/repo1")
+	assert.Equal(t, github.ErrUnknownHost, err)
+
+	ts.AssertExpectations(t)
+}
+
+func TestReadHosts(t *testing.T) {
+	hosts, err := github.ReadHosts(strings.NewReader(`[
+		{"name": "GHE.example.com", "owners": ["user1"]},
+		{"name": "ghe.example.org:8443", "api_url": "https://api.ghe.example.org/", "upload_url": "https://uploads.ghe.example.org"}
+	]`))
+	require.NoError(t, err)
+
+	examples := map[string]*github.Host{
+		"user1": {
+			Name:      "ghe.example.com",
+			BaseURL:   &url.URL{Scheme: "https", Host: "ghe.example.com", Path: "/api/v3/"},
+			UploadURL: &url.URL{Scheme: "https", Host: "ghe.example.com", Path: "/api/uploads/"},
+		},
+		"ghe.example.com/user2": {
+			Name:      "ghe.example.com",
+			BaseURL:   &url.URL{Scheme: "https", Host: "ghe.example.com", Path: "/api/v3/"},
+			UploadURL: &url.URL{Scheme: "https", Host: "ghe.example.com", Path: "/api/uploads/"},
+		},
+		"ghe.example.org:8443/user1": {
+			Name:      "ghe.example.org:8443",
+			BaseURL:   &url.URL{Scheme: "https", Host: "api.ghe.example.org", Path: "/"},
+			UploadURL: &url.URL{Scheme: "https", Host: "uploads.ghe.example.org", Path: "/"},
+		},
+		"user2": nil,
+	}
+
+	for owner, expected := range examples {
+		t.Run(fmt.Sprintf("owner: %q", owner), func(t *testing.T) {
+			host, err := hosts.Lookup(owner)
+			require.NoError(t, err)
+			assert.Equal(t, expected, host)
+		})
+	}
+
+	_, err = hosts.Lookup("ghe.example.net/user1")
+	assert.Equal(t, github.ErrUnknownHost, err)
+}
+
+func TestReadHosts_Invalid(t *testing.T) {
+	examples := map[string]string{
+		"malformed":      `{"name": "ghe.example.com"}`,
+		"invalid host":   `[{"name": "ghe"}]`,
+		"github.com":     `[{"name": "github.com"}]`,
+		"relative URL":   `[{"name": "ghe.example.com", "api_url": "/api/v3/"}]`,
+		"invalid owner":  `[{"name": "ghe.example.com", "owners": ["-user1"]}]`,
+		"assigned owner": `[{"name": "ghe.example.com", "owners": ["user1"]}, {"name": "ghe.example.org", "owners": ["User1"]}]`,
+	}
+
+	for name, config := range examples {
+		t.Run(name, func(t *testing.T) {
+			_, err := github.ReadHosts(strings.NewReader(config))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	examples := map[string]struct {
+		Host, Rest string
+	}{
+		"":                         {github.DefaultHost, ""},
+		"octocat":                  {github.DefaultHost, "octocat"},
+		"octocat/hooks":            {github.DefaultHost, "octocat/hooks"},
+		"GHE.example.com/octocat":  {"ghe.example.com", "octocat"},
+		"ghe.example.com/a/b":      {"ghe.example.com", "a/b"},
+		"localhost:8080/octocat/b": {"localhost:8080", "octocat/b"},
+		"ghe.example.com":          {github.DefaultHost, "ghe.example.com"},
+	}
+
+	for name, expected := range examples {
+		t.Run(fmt.Sprintf("name: %q", name), func(t *testing.T) {
+			host, rest := github.SplitHost(name)
+			assert.Equal(t, expected.Host, host)
+			assert.Equal(t, expected.Rest, rest)
+		})
+	}
+}
+
+func TestQualifyName(t *testing.T) {
+	assert.Equal(t, "octocat/hooks", github.QualifyName("", "octocat/hooks"))
+	assert.Equal(t, "octocat/hooks", github.QualifyName("GitHub.com", "octocat/hooks"))
+	assert.Equal(t, "ghe.example.com/octocat/hooks", github.QualifyName("GHE.example.com", "octocat/hooks"))
+}
+
+func TestHosts_Canonical(t *testing.T) {
+	hosts, err := github.ReadHosts(strings.NewReader(`[
+		{"name": "ghe.example.com", "owners": ["user1"]}
+	]`))
+	require.NoError(t, err)
+
+	examples := map[string]string{
+		"":                            "",
+		"user1":                       "ghe.example.com/user1",
+		"User1/repo1":                 "ghe.example.com/User1/repo1",
+		"ghe.example.com/user1/repo1": "ghe.example.com/user1/repo1",
+		"GHE.example.com/user2/repo1": "ghe.example.com/user2/repo1",
+		"github.com/user2/repo1":      "user2/repo1",
+		"user2/repo1":                 "user2/repo1",
+	}
+
+	for name, expected := range examples {
+		t.Run(fmt.Sprintf("name: %q", name), func(t *testing.T) {
+			assert.Equal(t, expected, hosts.Canonical(name))
+		})
+	}
+
+	assert.Equal(t, "user1/repo1", (*github.Hosts)(nil).Canonical("user1/repo1"))
+}
+
+func TestValidateOwner(t *testing.T) {
+	examples := map[string]error{
+		"octocat":                         nil,
+		"ghe.example.com/octocat":         nil,
+		"10.0.0.1:8443/octocat":           nil,
+		"ghe.example.com/-octocat":        github.ErrInvalidLogin,
+		"ghe/octocat":                     github.ErrInvalidLogin,
+		"ghe_example.com/octocat":         github.ErrInvalidHost,
+		"ghe.example.com:x/octocat":       github.ErrInvalidHost,
+		"https://ghe.example.com/octocat": github.ErrInvalidHost,
+	}
+
+	for owner, expected := range examples {
+		t.Run(fmt.Sprintf("owner: %q", owner), func(t *testing.T) {
+			assert.Equal(t, expected, github.ValidateOwner(owner))
+		})
+	}
+}
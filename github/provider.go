@@ -0,0 +1,51 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+// Provider is the GitHub implementation of blamewarrior.Provider.
+type Provider struct {
+	Client *Client
+	// BaseURL overrides GitHub API endpoint, see Context.BaseURL.
+	BaseURL         *url.URL
+	RateLimitPolicy RateLimitPolicy
+	// Reservation is the reservation requests are taken from, see Context.Reservation.
+	Reservation *Reservation
+}
+
+// Name returns blamewarrior.ProviderGitHub.
+func (p *Provider) Name() string {
+	return blamewarrior.ProviderGitHub
+}
+
+// RepositoryMembers returns repository collaborators.
+func (p *Provider) RepositoryMembers(ctx context.Context, fullName string) ([]blamewarrior.Account, error) {
+	return p.Client.RepositoryCollaborators(p.Context(ctx), fullName)
+}
+
+// Context returns a request context with provider settings.
+func (p *Provider) Context(ctx context.Context) Context {
+	return Context{Context: ctx, BaseURL: p.BaseURL, RateLimitPolicy: p.RateLimitPolicy, Reservation: p.Reservation}
+}
@@ -0,0 +1,417 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy tells the client what to do when the token of an owner has run out of
+// GitHub API requests.
+type RateLimitPolicy int
+
+const (
+	// FailFast makes requests fail with ErrRateLimitReached until the limit is reset.
+	FailFast RateLimitPolicy = iota
+	// WaitForReset makes requests wait until the limit is reset or request context is done.
+	WaitForReset
+)
+
+const (
+	// SecondaryRateLimitDelay is the time requests are held back after GitHub reports that
+	// a secondary rate limit has been exceeded without telling when to retry.
+	SecondaryRateLimitDelay = time.Minute
+
+	// maxRateLimitRetries limits the number of times a request rejected because of rate
+	// limit is repeated after waiting for the reset
+	maxRateLimitRetries = 3
+)
+
+// RateLimit is the last known state of GitHub API rate limit of an owner token.
+type RateLimit struct {
+	Owner     string    `json:"owner"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	// RetryAfter is set when the token hit a secondary rate limit.
+	RetryAfter *time.Time `json:"retry_after,omitempty"`
+	// Reserved is the number of requests set aside by running syncs.
+	Reserved int `json:"reserved"`
+}
+
+type rateLimitState struct {
+	RateLimit
+
+	known bool
+}
+
+// available returns the number of requests that can be reserved, or -1 if it's unknown.
+func (st *rateLimitState) available(now time.Time) int {
+	if !st.known {
+		return -1
+	}
+
+	n := st.Remaining - st.Reserved
+	if !now.Before(st.Reset) {
+		n = st.Limit - st.Reserved
+	}
+
+	// requests made without a reservation may have used up reserved ones
+	if n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// blockedUntil returns the time requests are not allowed until, or zero time if
+// they are allowed.
+func (st *rateLimitState) blockedUntil(now time.Time) time.Time {
+	if st.RetryAfter != nil && now.Before(*st.RetryAfter) {
+		return *st.RetryAfter
+	}
+
+	if st.known && st.Remaining == 0 && now.Before(st.Reset) {
+		return st.Reset
+	}
+
+	return time.Time{}
+}
+
+// RateLimits tracks GitHub API rate limits of owner tokens using response headers.
+type RateLimits struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	limits map[string]*rateLimitState
+	// released is closed and replaced each time a reservation is released to wake up
+	// callers of Reserve waiting for requests to become available
+	released chan struct{}
+}
+
+func NewRateLimits() *RateLimits {
+	return &RateLimits{
+		now:      time.Now,
+		limits:   make(map[string]*rateLimitState),
+		released: make(chan struct{}),
+	}
+}
+
+// All returns rate limits of all tokens used so far ordered by owner.
+func (rl *RateLimits) All() []RateLimit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	limits := make([]RateLimit, 0, len(rl.limits))
+	for _, st := range rl.limits {
+		limits = append(limits, st.RateLimit)
+	}
+
+	sort.Slice(limits, func(i, j int) bool {
+		return strings.ToLower(limits[i].Owner) < strings.ToLower(limits[j].Owner)
+	})
+
+	return limits
+}
+
+// RetryAfter returns the time left until requests with the owner token are allowed again,
+// or zero if they are allowed.
+func (rl *RateLimits) RetryAfter(owner string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+
+	until := rl.state(owner).blockedUntil(now)
+	if until.IsZero() {
+		return 0
+	}
+
+	return until.Sub(now)
+}
+
+// Reserve sets aside n requests from the rate limit of the owner token for the caller,
+// so that concurrent syncs do not start work they cannot finish. If there are not enough
+// requests left, ErrRateLimitReached is returned or the call waits for the limit reset or
+// for other reservations to be released depending on policy. The reservation has to be
+// released once the work is done.
+func (rl *RateLimits) Reserve(ctx context.Context, owner string, n int, policy RateLimitPolicy) (*Reservation, error) {
+	if err := rl.acquire(ctx, owner, n, policy, true); err != nil {
+		return nil, err
+	}
+
+	return &Reservation{limits: rl, owner: owner, left: n}, nil
+}
+
+// acquire waits until n requests of the owner token are available and, if reserve is true,
+// sets them aside.
+func (rl *RateLimits) acquire(ctx context.Context, owner string, n int, policy RateLimitPolicy, reserve bool) error {
+	for {
+		rl.mu.Lock()
+
+		now := rl.now()
+		st := rl.state(owner)
+
+		until := st.blockedUntil(now)
+		if until.IsZero() {
+			// once the limit has been reset only releases can free up more requests, and
+			// there are none to wait for if nothing is reserved
+			available := st.available(now)
+			if available < 0 || available >= n || (st.Reserved == 0 && !now.Before(st.Reset)) {
+				if reserve {
+					st.Reserved += n
+				}
+				rl.mu.Unlock()
+
+				return nil
+			}
+
+			until = st.Reset
+		}
+
+		released := rl.released
+		rl.mu.Unlock()
+
+		if policy != WaitForReset {
+			return ErrRateLimitReached
+		}
+
+		if err := rl.sleepUntil(ctx, until, released); err != nil {
+			return err
+		}
+	}
+}
+
+// release returns n reserved requests of the owner token and wakes up waiting callers of Reserve.
+// The caller must hold rl.mu.
+func (rl *RateLimits) release(owner string, n int) {
+	rl.state(owner).Reserved -= n
+
+	close(rl.released)
+	rl.released = make(chan struct{})
+}
+
+// Reservation is a number of requests set aside by RateLimits.Reserve. Requests made with
+// a Context that carries the reservation are taken from it, and once it runs out each of
+// them waits for a request to become available the same way Reserve does, so that an
+// estimate falling short of the actual number of requests does not eat into reservations
+// of others.
+type Reservation struct {
+	limits *RateLimits
+	owner  string
+	// left is the number of reserved requests that have not been made yet, guarded by limits.mu
+	left int
+}
+
+// Release returns requests that have not been used to the rate limit. It's safe to call
+// Release more than once.
+func (r *Reservation) Release() {
+	r.limits.mu.Lock()
+	defer r.limits.mu.Unlock()
+
+	if r.left > 0 {
+		r.limits.release(r.owner, r.left)
+		r.left = 0
+	}
+}
+
+// take accounts for a request about to be made with the reservation.
+func (r *Reservation) take(ctx context.Context, policy RateLimitPolicy) error {
+	r.limits.mu.Lock()
+	if r.left > 0 {
+		// the request is counted by the remaining limit once its response arrives
+		r.left--
+		r.limits.state(r.owner).Reserved--
+		r.limits.mu.Unlock()
+
+		return nil
+	}
+	r.limits.mu.Unlock()
+
+	return r.limits.acquire(ctx, r.owner, 1, policy, false)
+}
+
+// wait returns once requests with the owner token are allowed, or ErrRateLimitReached
+// if they are not and policy is FailFast.
+func (rl *RateLimits) wait(ctx context.Context, owner string, policy RateLimitPolicy) error {
+	for {
+		rl.mu.Lock()
+		until := rl.state(owner).blockedUntil(rl.now())
+		rl.mu.Unlock()
+
+		if until.IsZero() {
+			return nil
+		}
+
+		if policy != WaitForReset {
+			return ErrRateLimitReached
+		}
+
+		if err := rl.sleepUntil(ctx, until, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// update records rate limit reported in response headers and reports whether the request
+// has been rejected because of a primary or secondary rate limit.
+func (rl *RateLimits) update(owner string, resp *http.Response) (limited bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	st := rl.state(owner)
+
+	if limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+
+		st.Limit, st.Remaining, st.Reset = limit, remaining, time.Unix(reset, 0)
+		st.known = true
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		st.blockUntil(now.Add(time.Duration(seconds) * time.Second))
+		return true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimitResponse(resp) {
+		st.blockUntil(now.Add(SecondaryRateLimitDelay))
+		return true
+	}
+
+	return false
+}
+
+func (st *rateLimitState) blockUntil(t time.Time) {
+	st.RetryAfter = &t
+}
+
+func (rl *RateLimits) state(owner string) *rateLimitState {
+	key := strings.ToLower(owner)
+
+	st, ok := rl.limits[key]
+	if !ok {
+		st = &rateLimitState{RateLimit: RateLimit{Owner: owner}}
+		rl.limits[key] = st
+	}
+
+	return st
+}
+
+// sleepUntil waits until t or, unless released is nil, until a reservation is released.
+func (rl *RateLimits) sleepUntil(ctx context.Context, t time.Time, released <-chan struct{}) error {
+	d := t.Sub(rl.now())
+	if d <= 0 && released == nil {
+		return nil
+	}
+
+	// a time in the past leaves only a release to wait for
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case <-timeout:
+		return nil
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isSecondaryRateLimitResponse checks whether GitHub rejected the request because of
+// a secondary rate limit or abuse detection. The response body is left intact.
+func isSecondaryRateLimitResponse(resp *http.Response) bool {
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(b))
+
+	return strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse detection")
+}
+
+// rateLimitTransport holds back requests with an owner token that has run out of GitHub API
+// requests and keeps track of its rate limit. Idempotent requests rejected because of a rate
+// limit are retried once it's reset if the policy is to wait, otherwise ErrRateLimitReached
+// is returned. Each attempt is taken from the reservation if there is one.
+type rateLimitTransport struct {
+	http.RoundTripper
+
+	ctx         context.Context
+	limits      *RateLimits
+	reservation *Reservation
+	owner       string
+	policy      RateLimitPolicy
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if t.reservation != nil {
+			if err := t.reservation.take(t.ctx, t.policy); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := t.limits.wait(t.ctx, t.owner, t.policy); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.RoundTripper.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		if !t.limits.update(t.owner, resp) {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+
+		if t.policy != WaitForReset || attempt >= maxRateLimitRetries || (req.Method != "GET" && req.Method != "HEAD") {
+			return nil, ErrRateLimitReached
+		}
+	}
+}
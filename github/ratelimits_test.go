@@ -0,0 +1,282 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RateLimit_FailFast(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	var requests int64
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&requests, 1)
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	_, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+
+	_, err = c.RepositoryTeams(ctx, "user1/repo1")
+	assert.Equal(t, github.ErrRateLimitReached, err)
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests))
+	assert.Equal(t, []github.RateLimit{
+		{Owner: "user1", Limit: 5000, Remaining: 0, Reset: reset},
+	}, c.RateLimits.All())
+	assert.True(t, c.RateLimits.RetryAfter("User1") > 59*time.Minute)
+}
+
+func TestClient_RateLimit_SecondaryLimit(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`, http.StatusForbidden)
+	})
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	_, err := c.RepositoryTeams(ctx, "user1/repo1")
+	assert.Equal(t, github.ErrRateLimitReached, err)
+
+	retryAfter := c.RateLimits.RetryAfter("user1")
+	assert.True(t, retryAfter > 59*time.Second && retryAfter <= github.SecondaryRateLimitDelay, retryAfter)
+}
+
+func TestClient_RateLimit_WaitForReset(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	var requests int64
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"message":"You have triggered an abuse detection mechanism."}`, http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`[{"id": 1, "name": "Developers", "slug": "developers", "permission": "push"}]`))
+	})
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL, RateLimitPolicy: github.WaitForReset}
+
+	start := time.Now()
+
+	teams, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+	assert.Len(t, teams, 1)
+
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+	assert.True(t, time.Since(start) >= time.Second)
+}
+
+func TestRateLimits_Reserve(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	// nothing is known about the limit before the first request
+	reservation, err := c.RateLimits.Reserve(context.Background(), "user1", 100, github.FailFast)
+	require.NoError(t, err)
+	reservation.Release()
+
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	_, err = c.RepositoryTeams(github.Context{Context: context.Background(), BaseURL: baseURL}, "user1/repo1")
+	require.NoError(t, err)
+
+	reservation, err = c.RateLimits.Reserve(context.Background(), "user1", 4, github.FailFast)
+	require.NoError(t, err)
+
+	_, err = c.RateLimits.Reserve(context.Background(), "user1", 2, github.FailFast)
+	assert.Equal(t, github.ErrRateLimitReached, err)
+
+	assert.Equal(t, 4, c.RateLimits.All()[0].Reserved)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = c.RateLimits.Reserve(ctx, "user1", 2, github.WaitForReset)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	reservation.Release()
+	reservation.Release()
+
+	reservation, err = c.RateLimits.Reserve(context.Background(), "user1", 2, github.FailFast)
+	require.NoError(t, err)
+	reservation.Release()
+
+	assert.Equal(t, 0, c.RateLimits.All()[0].Reserved)
+}
+
+func TestRateLimits_Reservation(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	var requests int64
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(4-atomic.AddInt64(&requests, 1), 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	_, err := c.RepositoryTeams(github.Context{Context: context.Background(), BaseURL: baseURL}, "user1/repo1")
+	require.NoError(t, err)
+
+	reservation, err := c.RateLimits.Reserve(context.Background(), "user1", 2, github.FailFast)
+	require.NoError(t, err)
+
+	other, err := c.RateLimits.Reserve(context.Background(), "user1", 1, github.FailFast)
+	require.NoError(t, err)
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL, Reservation: reservation}
+
+	// requests are taken from the reservation
+	for i := 0; i < 2; i++ {
+		_, err = c.RepositoryTeams(ctx, "user1/repo1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, c.RateLimits.All()[0].Reserved)
+
+	// and once it runs out they do not take requests reserved by others
+	_, err = c.RepositoryTeams(ctx, "user1/repo1")
+	assert.Equal(t, github.ErrRateLimitReached, err)
+
+	other.Release()
+
+	_, err = c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+
+	reservation.Release()
+	assert.Equal(t, 0, c.RateLimits.All()[0].Reserved)
+	assert.Equal(t, int64(4), atomic.LoadInt64(&requests))
+}
+
+func TestRateLimits_Reserve_AfterReset(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	_, err := c.RepositoryTeams(github.Context{Context: context.Background(), BaseURL: baseURL}, "user1/repo1")
+	require.NoError(t, err)
+
+	var clockCalls int64
+	github.SetRateLimitsClock(c.RateLimits, func() time.Time {
+		atomic.AddInt64(&clockCalls, 1)
+		return time.Now()
+	})
+
+	// requests more than the limit allows are granted if there is nothing to wait for
+	reservation, err := c.RateLimits.Reserve(context.Background(), "user1", 10, github.FailFast)
+	require.NoError(t, err)
+	reservation.Release()
+
+	reservation, err = c.RateLimits.Reserve(context.Background(), "user1", 4, github.FailFast)
+	require.NoError(t, err)
+
+	reserved := make(chan error)
+	go func() {
+		reservation, err := c.RateLimits.Reserve(context.Background(), "user1", 2, github.WaitForReset)
+		if err == nil {
+			reservation.Release()
+		}
+		reserved <- err
+	}()
+
+	select {
+	case err := <-reserved:
+		t.Fatalf("reservation exceeding the limit has been granted, err = %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the waiting call is woken up by a release rather than polling the limit
+	assert.True(t, atomic.LoadInt64(&clockCalls) < 10, "clock has been checked %d times", atomic.LoadInt64(&clockCalls))
+
+	reservation.Release()
+
+	select {
+	case err := <-reserved:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("reservation has not been granted after release")
+	}
+
+	assert.Equal(t, 0, c.RateLimits.All()[0].Reserved)
+}
@@ -0,0 +1,217 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/blamewarrior/collaborators/backoff"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a request that failed with a transient
+	// error is repeated.
+	DefaultMaxRetries = 3
+	// DefaultRetryDelay is the delay before the first retry of a failed request.
+	DefaultRetryDelay = 500 * time.Millisecond
+	// DefaultRequestTimeout limits the time a single attempt to send a request is allowed to take.
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// RequestError is returned when a request keeps failing with transient errors after all retries.
+type RequestError struct {
+	Method string
+	// URL is the request URI including the query.
+	URL string
+	// Page is the requested page of a list, zero for requests that are not paginated.
+	Page     int
+	Attempts int
+	// Err is either the error returned by the last attempt or the description of its
+	// response status.
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	if e.Page > 0 {
+		return fmt.Sprintf("%s %s (page %d) failed after %d attempts: %s", e.Method, e.URL, e.Page, e.Attempts, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s failed after %d attempts: %s", e.Method, e.URL, e.Attempts, e.Err)
+}
+
+// RetryStats contains counters of repeated requests.
+type RetryStats struct {
+	// Retries is the total number of times requests have been repeated.
+	Retries int64 `json:"retries"`
+	// Recovered is the number of requests that succeeded after being repeated.
+	Recovered int64 `json:"recovered"`
+	// Exhausted is the number of requests that kept failing after all retries.
+	Exhausted int64 `json:"exhausted"`
+}
+
+func (stats *RetryStats) snapshot() RetryStats {
+	return RetryStats{
+		Retries:   atomic.LoadInt64(&stats.Retries),
+		Recovered: atomic.LoadInt64(&stats.Recovered),
+		Exhausted: atomic.LoadInt64(&stats.Exhausted),
+	}
+}
+
+// retryTransport repeats GET requests that failed with 5xx responses, timeouts or connection
+// resets. Each page of a list is a request of its own, so pages that have already been received
+// are not requested again.
+type retryTransport struct {
+	http.RoundTripper
+
+	ctx        context.Context
+	maxRetries int
+	delay      time.Duration
+	stats      *RetryStats
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.RoundTripper.RoundTrip(req)
+
+		if !isTransientFailure(resp, err) || t.ctx.Err() != nil {
+			if err == nil && attempt > 0 {
+				atomic.AddInt64(&t.stats.Recovered, 1)
+			}
+
+			return resp, err
+		}
+
+		if attempt >= t.maxRetries {
+			atomic.AddInt64(&t.stats.Exhausted, 1)
+
+			if err == nil {
+				resp.Body.Close()
+				err = errors.New(resp.Status)
+			}
+
+			return nil, &RequestError{
+				Method:   req.Method,
+				URL:      req.URL.RequestURI(),
+				Page:     requestedPage(req),
+				Attempts: attempt + 1,
+				Err:      err,
+			}
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		atomic.AddInt64(&t.stats.Retries, 1)
+
+		select {
+		case <-time.After(backoff.Delay(t.delay, attempt)):
+		case <-t.ctx.Done():
+			return nil, t.ctx.Err()
+		}
+	}
+}
+
+// timeoutTransport limits the time it takes to send a single request and receive its response
+// body. It sits below the transports that wait for rate limit resets and repeat failed requests,
+// so that only the time spent talking to GitHub counts.
+type timeoutTransport struct {
+	http.RoundTripper
+
+	ctx     context.Context
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelingBody releases the request context once the response body is closed.
+type cancelingBody struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// isTransientFailure reports whether a request failed in a way that is worth to be retried.
+func isTransientFailure(resp *http.Response, err error) bool {
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+
+		return false
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// requestedPage returns the page of a list requested with per_page parameter.
+func requestedPage(req *http.Request) int {
+	q := req.URL.Query()
+	if q.Get("per_page") == "" {
+		return 0
+	}
+
+	if page, err := strconv.Atoi(q.Get("page")); err == nil && page > 0 {
+		return page
+	}
+
+	return 1
+}
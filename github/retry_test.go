@@ -0,0 +1,230 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package github_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedTeamsHandler serves three pages of repository teams failing the first
+// failures[page] requests of each page with given status.
+type pagedTeamsHandler struct {
+	status   int
+	failures map[int]int
+
+	mu       sync.Mutex
+	requests map[int]int
+}
+
+func (h *pagedTeamsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	page, _ := strconv.Atoi(req.FormValue("page"))
+	if page == 0 {
+		page = 1
+	}
+
+	h.mu.Lock()
+	if h.requests == nil {
+		h.requests = make(map[int]int)
+	}
+	h.requests[page]++
+	attempt := h.requests[page]
+	h.mu.Unlock()
+
+	if h.failures[page] < 0 || attempt <= h.failures[page] {
+		w.WriteHeader(h.status)
+		return
+	}
+
+	if page < 3 {
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, req.Host, req.URL.Path, page+1))
+	}
+	fmt.Fprintf(w, `[{"id":%d,"name":"Team %d","slug":"team-%d","permission":"push"}]`, page, page, page)
+}
+
+func (h *pagedTeamsHandler) Requests(page int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.requests[page]
+}
+
+func TestClient_RetryTransientFailures(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	handler := &pagedTeamsHandler{status: http.StatusBadGateway, failures: map[int]int{3: 2}}
+	mux.Handle("/repos/user1/repo1/teams", handler)
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	c.RetryDelay = time.Millisecond
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	teams, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+	assert.Len(t, teams, 3)
+
+	// only the failed page is requested again
+	assert.Equal(t, 1, handler.Requests(1))
+	assert.Equal(t, 1, handler.Requests(2))
+	assert.Equal(t, 3, handler.Requests(3))
+
+	assert.Equal(t, github.RetryStats{Retries: 2, Recovered: 1}, c.RetryStats())
+}
+
+func TestClient_RetriesExhausted(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	handler := &pagedTeamsHandler{status: http.StatusServiceUnavailable, failures: map[int]int{2: -1}}
+	mux.Handle("/repos/user1/repo1/teams", handler)
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	c.MaxRetries = 2
+	c.RetryDelay = time.Millisecond
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	_, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.Error(t, err)
+
+	reqErr, ok := err.(*github.RequestError)
+	require.True(t, ok, "unexpected error %#v", err)
+
+	assert.Equal(t, "GET", reqErr.Method)
+	assert.Equal(t, 2, reqErr.Page)
+	assert.Equal(t, 3, reqErr.Attempts)
+	assert.EqualError(t, err, "GET /repos/user1/repo1/teams?page=2&per_page=100 (page 2) failed after 3 attempts: 503 Service Unavailable")
+
+	assert.Equal(t, 3, handler.Requests(2))
+	assert.Equal(t, 0, handler.Requests(3))
+
+	assert.Equal(t, github.RetryStats{Retries: 2, Exhausted: 1}, c.RetryStats())
+}
+
+func TestClient_RetryNotFound(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	handler := &pagedTeamsHandler{status: http.StatusNotFound, failures: map[int]int{1: -1}}
+	mux.Handle("/repos/user1/repo1/teams", handler)
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	_, err := c.RepositoryTeams(ctx, "user1/repo1")
+	assert.Equal(t, github.ErrNoSuchRepository, err)
+
+	assert.Equal(t, 1, handler.Requests(1))
+	assert.Equal(t, github.RetryStats{}, c.RetryStats())
+}
+
+func TestClient_RetryTimeout(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		attempt := requests
+		mu.Unlock()
+
+		if attempt == 1 {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+
+		w.Write([]byte(`[{"id":1,"name":"Team 1","slug":"team-1","permission":"push"}]`))
+	})
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	c.RetryDelay = time.Millisecond
+	c.RequestTimeout = 50 * time.Millisecond
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	teams, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.NoError(t, err)
+	assert.Len(t, teams, 1)
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, github.RetryStats{Retries: 1, Recovered: 1}, c.RetryStats())
+}
+
+func TestClient_RetryRecordsRateLimits(t *testing.T) {
+	baseURL, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	var requests int64
+	mux.HandleFunc("/repos/user1/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(5000-n, 10))
+		w.Header().Set("X-RateLimit-Reset", "1893456000")
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "user1").Return("token1", nil)
+
+	c := github.NewClient(ts)
+	c.MaxRetries = 2
+	c.RetryDelay = time.Millisecond
+
+	ctx := github.Context{Context: context.Background(), BaseURL: baseURL}
+
+	_, err := c.RepositoryTeams(ctx, "user1/repo1")
+	require.Error(t, err)
+
+	// requests that have been retried still count against the rate limit
+	limits := c.RateLimits.All()
+	require.Len(t, limits, 1)
+	assert.Equal(t, 4997, limits[0].Remaining)
+}
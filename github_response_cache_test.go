@@ -0,0 +1,99 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresResponseCache(t *testing.T) {
+	db, teardown := setupTestDBConn()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE github_response_cache")
+	require.NoError(t, err)
+
+	cache := github.NewPostgresResponseCache(db)
+	ctx := context.Background()
+
+	resp, err := cache.Get(ctx, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+
+	expected := &github.CachedResponse{
+		ETag:   `"etag1"`,
+		Header: http.Header{"Link": []string{`<https://api.github.com/repositories/1/collaborators?page=2>; rel="next"`}},
+		Body:   []byte(`[{"login": "octocat"}]`),
+	}
+
+	for _, etag := range []string{`"etag0"`, `"etag1"`} {
+		cached := *expected
+		cached.ETag = etag
+
+		require.NoError(t, cache.Set(ctx, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", &cached))
+	}
+
+	resp, err = cache.Get(ctx, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, expected, resp)
+}
+
+func TestPostgresResponseCache_Prune(t *testing.T) {
+	db, teardown := setupTestDBConn()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE github_response_cache")
+	require.NoError(t, err)
+
+	cache := github.NewPostgresResponseCache(db)
+	cache.TTL = time.Hour
+	ctx := context.Background()
+
+	stale := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	fresh := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+	require.NoError(t, cache.Set(ctx, stale, &github.CachedResponse{ETag: `"etag0"`}))
+
+	_, err = db.Exec("UPDATE github_response_cache SET updated_at = now() - interval '2 hours'")
+	require.NoError(t, err)
+
+	// responses are pruned by the first Set
+	require.NoError(t, cache.Set(ctx, fresh, &github.CachedResponse{ETag: `"etag1"`}))
+
+	for key, cached := range map[string]bool{stale: false, fresh: true} {
+		resp, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, cached, resp != nil, key)
+	}
+
+	_, err = db.Exec("UPDATE github_response_cache SET updated_at = now() - interval '2 hours' WHERE key = $1", fresh)
+	require.NoError(t, err)
+
+	n, err := cache.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	resp, err := cache.Get(ctx, fresh)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,354 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+)
+
+var (
+	ErrNoSuchProject      = errors.New("no such project")
+	ErrUnauthorized       = errors.New("GitLab token is not valid")
+	ErrInvalidProjectPath = errors.New("invalid project path")
+	ErrInvalidUsername    = errors.New("invalid username")
+)
+
+// DefaultHost is the name of gitlab.com.
+const DefaultHost = "gitlab.com"
+
+// Access levels of GitLab project members.
+const (
+	GuestAccess      = 10
+	ReporterAccess   = 20
+	DeveloperAccess  = 30
+	MaintainerAccess = 40
+	OwnerAccess      = 50
+)
+
+// membersPerPage is the maximum page size allowed by GitLab API.
+const membersPerPage = 100
+
+const (
+	// MaxPathLength is the maximum length of a GitLab username, a group or a project path.
+	MaxPathLength = 255
+	// MaxSubgroupDepth is the maximum number of nested subgroups.
+	MaxSubgroupDepth = 20
+)
+
+// Client lists members of projects hosted on a GitLab instance. It implements blamewarrior.Provider
+// for repositories qualified with the instance host name, i.e. gitlab.com/group/project.
+type Client struct {
+	// Host is the name repositories hosted on the instance are qualified with.
+	Host string
+	// BaseURL is the REST API endpoint, https://<host>/api/v4/ by default.
+	BaseURL *url.URL
+	// HTTPClient is used to send requests, http.DefaultClient is used if it's nil.
+	HTTPClient *http.Client
+
+	tokenClient tokens.Client
+}
+
+// NewClient returns a client of GitLab instance on given host. Tokens are requested for
+// top-level groups and users qualified with the host name, i.e. gitlab.com/group.
+func NewClient(host string, tokenClient tokens.Client) *Client {
+	host = strings.ToLower(host)
+
+	return &Client{
+		Host:        host,
+		BaseURL:     &url.URL{Scheme: "https", Host: host, Path: "/api/v4/"},
+		tokenClient: tokenClient,
+	}
+}
+
+// Name returns blamewarrior.ProviderGitLab.
+func (c *Client) Name() string {
+	return blamewarrior.ProviderGitLab
+}
+
+// RepositoryMembers returns active members of a project including the ones that inherit
+// access from parent groups. Direct members of the project are affiliated with it directly,
+// the inherited ones are reported as organization members.
+func (c *Client) RepositoryMembers(ctx context.Context, fullName string) ([]blamewarrior.Account, error) {
+	project := c.projectPath(fullName)
+
+	token, err := c.token(project)
+	if err != nil {
+		return nil, err
+	}
+
+	direct, err := c.listMembers(ctx, token, project, "members")
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := c.listMembers(ctx, token, project, "members/all")
+	if err != nil {
+		return nil, err
+	}
+
+	directIds := make(map[int]bool, len(direct))
+	for _, m := range direct {
+		directIds[m.Id] = true
+	}
+
+	var accounts []blamewarrior.Account
+	for _, m := range all {
+		// blocked users have no access to the project
+		if m.State != "active" {
+			continue
+		}
+
+		account := blamewarrior.Account{
+			Uid:         m.Id,
+			Login:       m.Username,
+			Permissions: Permissions(m.AccessLevel),
+			Affiliation: blamewarrior.AffiliationMember,
+			AccountProfile: blamewarrior.AccountProfile{
+				Name:      m.Name,
+				AvatarURL: m.AvatarURL,
+				Type:      "User",
+			},
+		}
+
+		if directIds[m.Id] {
+			account.Affiliation = blamewarrior.AffiliationDirect
+		}
+
+		if m.Bot {
+			account.Type = "Bot"
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// ValidateRepositoryName checks that fullName is a path of a project hosted on the instance
+// qualified with its host name, i.e. gitlab.com/group/project or gitlab.com/group/subgroup/project.
+func (c *Client) ValidateRepositoryName(fullName string) error {
+	i := strings.IndexByte(fullName, '/')
+	if i < 0 || !strings.EqualFold(fullName[:i], c.Host) {
+		return ErrInvalidProjectPath
+	}
+
+	return ValidateProjectPath(fullName[i+1:])
+}
+
+// ValidateLogin checks that login is a valid GitLab username.
+func (c *Client) ValidateLogin(login string) error {
+	return ValidateUsername(login)
+}
+
+// ValidateProjectPath checks that path is a full project path that consists of a top-level
+// group or user namespace, up to MaxSubgroupDepth subgroups and the project name.
+func ValidateProjectPath(path string) error {
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > MaxSubgroupDepth+2 {
+		return ErrInvalidProjectPath
+	}
+
+	for _, part := range parts {
+		if !isValidPath(part) {
+			return ErrInvalidProjectPath
+		}
+	}
+
+	return nil
+}
+
+// ValidateUsername checks that username follows GitLab naming rules for users and groups:
+// up to 255 letters, digits, underscores, hyphens or dots that does not begin with a hyphen
+// or a dot and does not end with a dot, .git or .atom.
+func ValidateUsername(username string) error {
+	if !isValidPath(username) {
+		return ErrInvalidUsername
+	}
+
+	return nil
+}
+
+func isValidPath(s string) bool {
+	if s == "" || len(s) > MaxPathLength {
+		return false
+	}
+
+	if s[0] == '-' || s[0] == '.' || s[len(s)-1] == '.' {
+		return false
+	}
+
+	if lower := strings.ToLower(s); strings.HasSuffix(lower, ".git") || strings.HasSuffix(lower, ".atom") {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') &&
+			c != '_' && c != '-' && c != '.' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Permissions maps GitLab access level to GitHub permissions, so that guests are allowed
+// to pull, reporters to triage, developers to push and maintainers and owners are admins.
+// Each level includes all lower ones, same as in GitHub API responses.
+func Permissions(accessLevel int) blamewarrior.AccountPermissions {
+	var role blamewarrior.Permission
+
+	switch {
+	case accessLevel >= MaintainerAccess:
+		role = blamewarrior.PermissionAdmin
+	case accessLevel >= DeveloperAccess:
+		role = blamewarrior.PermissionPush
+	case accessLevel >= ReporterAccess:
+		role = blamewarrior.PermissionTriage
+	case accessLevel >= GuestAccess:
+		role = blamewarrior.PermissionPull
+	}
+
+	var perms blamewarrior.AccountPermissions
+	for p := blamewarrior.PermissionPull; p <= role; p++ {
+		perms.Grant(p)
+	}
+
+	return perms
+}
+
+type member struct {
+	Id          int    `json:"id"`
+	Username    string `json:"username"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	AvatarURL   string `json:"avatar_url"`
+	AccessLevel int    `json:"access_level"`
+	Bot         bool   `json:"bot"`
+}
+
+// listMembers requests all pages of a project members list.
+func (c *Client) listMembers(ctx context.Context, token, project, list string) ([]member, error) {
+	endpoint, err := c.BaseURL.Parse("projects/" + url.PathEscape(project) + "/" + list)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []member
+	for page := "1"; page != ""; {
+		endpoint.RawQuery = url.Values{
+			"per_page": {fmt.Sprint(membersPerPage)},
+			"page":     {page},
+		}.Encode()
+
+		var batch []member
+		if page, err = c.get(ctx, token, project, endpoint.String(), &batch); err != nil {
+			return nil, err
+		}
+
+		members = append(members, batch...)
+	}
+
+	return members, nil
+}
+
+// get sends a GET request and decodes response body into v. It returns the number of the
+// next page or an empty string if this one is the last.
+func (c *Client) get(ctx context.Context, token, project, endpoint string, v interface{}) (nextPage string, err error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrNoSuchProject
+	case http.StatusUnauthorized:
+		if invalidator, ok := c.tokenClient.(tokens.Invalidator); ok {
+			invalidator.Invalidate(c.owner(project))
+		}
+
+		return "", ErrUnauthorized
+	default:
+		return "", fmt.Errorf("request failed: GitLab API responded with %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("failed to decode GitLab API response: %s", err)
+	}
+
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+// token returns the token of the project owner.
+func (c *Client) token(project string) (string, error) {
+	token, err := c.tokenClient.GetToken(c.owner(project))
+
+	switch err {
+	case nil:
+		return token, nil
+	case tokens.ErrUserNotFound, tokens.ErrTokenRevoked, tokens.ErrServiceUnavailable:
+		// returned as is to let callers tell them from other errors
+		return "", err
+	default:
+		return "", fmt.Errorf("unable to get GitLab token: %s", err)
+	}
+}
+
+// owner returns the top-level group or user of a project qualified with the host name.
+func (c *Client) owner(project string) string {
+	if i := strings.IndexByte(project, '/'); i >= 0 {
+		project = project[:i]
+	}
+
+	return c.Host + "/" + project
+}
+
+// projectPath strips the host name off a repository name.
+func (c *Client) projectPath(fullName string) string {
+	if i := strings.IndexByte(fullName, '/'); i >= 0 && strings.EqualFold(fullName[:i], c.Host) {
+		return fullName[i+1:]
+	}
+
+	return fullName
+}
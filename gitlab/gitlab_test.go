@@ -0,0 +1,265 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+
+   This file is a part of BlameWarrior service.
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/gitlab"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenServiceMock struct {
+	mock.Mock
+}
+
+func (tsMock *tokenServiceMock) GetToken(nickname string) (string, error) {
+	args := tsMock.Called(nickname)
+	return args.String(0), args.Error(1)
+}
+
+type invalidatingTokenServiceMock struct {
+	tokenServiceMock
+}
+
+func (tsMock *invalidatingTokenServiceMock) Invalidate(nickname string) {
+	tsMock.Called(nickname)
+}
+
+func TestClient_RepositoryMembers(t *testing.T) {
+	baseURL, routes, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(tokenServiceMock)
+	ts.On("GetToken", "gitlab.com/blamewarrior").Return("token1", nil)
+
+	c := gitlab.NewClient("gitlab.com", ts)
+	c.BaseURL = baseURL
+
+	routes["/projects/blamewarrior%2Frepos/members"] = func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+
+		w.Write([]byte(`[
+			{"id": 1, "username": "user1", "name": "User 1", "state": "active", "avatar_url": "https://gitlab.com/uploads/1.png", "access_level": 40}
+		]`))
+	}
+
+	routes["/projects/blamewarrior%2Frepos/members/all"] = func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+		assert.Equal(t, "100", req.FormValue("per_page"))
+
+		switch req.FormValue("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			w.Write([]byte(`[
+				{"id": 1, "username": "user1", "name": "User 1", "state": "active", "avatar_url": "https://gitlab.com/uploads/1.png", "access_level": 40},
+				{"id": 2, "username": "user2", "name": "User 2", "state": "active", "avatar_url": "https://gitlab.com/uploads/2.png", "access_level": 30}
+			]`))
+		case "2":
+			w.Header().Set("X-Next-Page", "")
+			w.Write([]byte(`[
+				{"id": 3, "username": "user3", "name": "User 3", "state": "blocked", "access_level": 30},
+				{"id": 4, "username": "project_1_bot", "name": "Bot", "state": "active", "access_level": 10, "bot": true}
+			]`))
+		default:
+			t.Errorf("unexpected page %q", req.FormValue("page"))
+		}
+	}
+
+	members, err := c.RepositoryMembers(context.Background(), "gitlab.com/blamewarrior/repos")
+	require.NoError(t, err)
+
+	assert.Equal(t, []blamewarrior.Account{
+		{
+			Uid:         1,
+			Login:       "user1",
+			Permissions: blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true, Maintain: true, Admin: true},
+			Affiliation: blamewarrior.AffiliationDirect,
+			AccountProfile: blamewarrior.AccountProfile{
+				Name:      "User 1",
+				AvatarURL: "https://gitlab.com/uploads/1.png",
+				Type:      "User",
+			},
+		},
+		{
+			Uid:         2,
+			Login:       "user2",
+			Permissions: blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true},
+			Affiliation: blamewarrior.AffiliationMember,
+			AccountProfile: blamewarrior.AccountProfile{
+				Name:      "User 2",
+				AvatarURL: "https://gitlab.com/uploads/2.png",
+				Type:      "User",
+			},
+		},
+		{
+			Uid:         4,
+			Login:       "project_1_bot",
+			Permissions: blamewarrior.AccountPermissions{Pull: true},
+			Affiliation: blamewarrior.AffiliationMember,
+			AccountProfile: blamewarrior.AccountProfile{
+				Name: "Bot",
+				Type: "Bot",
+			},
+		},
+	}, members)
+
+	ts.AssertExpectations(t)
+}
+
+func TestClient_RepositoryMembers_Errors(t *testing.T) {
+	baseURL, routes, teardown := setupAPIServer()
+	defer teardown()
+
+	ts := new(invalidatingTokenServiceMock)
+	ts.On("GetToken", "gitlab.example.com/blamewarrior").Return("token1", nil)
+	ts.On("GetToken", "gitlab.example.com/revoked").Return("", tokens.ErrTokenRevoked)
+	ts.On("Invalidate", "gitlab.example.com/expired").Return()
+	ts.On("GetToken", "gitlab.example.com/expired").Return("token2", nil)
+
+	c := gitlab.NewClient("gitlab.example.com", ts)
+	c.BaseURL = baseURL
+
+	routes["/projects/expired%2Frepos/members"] = func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+	}
+
+	routes["/projects/blamewarrior%2Fbroken/members"] = func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"message":"500 Internal Server Error"}`, http.StatusInternalServerError)
+	}
+
+	_, err := c.RepositoryMembers(context.Background(), "gitlab.example.com/blamewarrior/missing")
+	assert.Equal(t, gitlab.ErrNoSuchProject, err)
+
+	_, err = c.RepositoryMembers(context.Background(), "gitlab.example.com/revoked/repos")
+	assert.Equal(t, tokens.ErrTokenRevoked, err)
+
+	_, err = c.RepositoryMembers(context.Background(), "gitlab.example.com/expired/repos")
+	assert.Equal(t, gitlab.ErrUnauthorized, err)
+
+	_, err = c.RepositoryMembers(context.Background(), "gitlab.example.com/blamewarrior/broken")
+	assert.EqualError(t, err, "request failed: GitLab API responded with 500 Internal Server Error")
+
+	ts.AssertExpectations(t)
+}
+
+func TestPermissions(t *testing.T) {
+	examples := map[int]blamewarrior.Permission{
+		0:                       blamewarrior.PermissionNone,
+		5:                       blamewarrior.PermissionNone,
+		gitlab.GuestAccess:      blamewarrior.PermissionPull,
+		gitlab.ReporterAccess:   blamewarrior.PermissionTriage,
+		gitlab.DeveloperAccess:  blamewarrior.PermissionPush,
+		gitlab.MaintainerAccess: blamewarrior.PermissionAdmin,
+		gitlab.OwnerAccess:      blamewarrior.PermissionAdmin,
+	}
+
+	for accessLevel, role := range examples {
+		perms := gitlab.Permissions(accessLevel)
+		assert.Equal(t, role, perms.Role(), "access level %d", accessLevel)
+
+		for p := blamewarrior.PermissionPull; p <= role; p++ {
+			assert.True(t, perms.Has(p), "access level %d is expected to grant %s", accessLevel, p)
+		}
+	}
+}
+
+func TestClient_ValidateRepositoryName(t *testing.T) {
+	client := gitlab.NewClient("gitlab.com", nil)
+
+	examples := map[string]error{
+		"gitlab.com/blamewarrior/repos":                nil,
+		"GitLab.com/blamewarrior/repos":                nil,
+		"gitlab.com/blamewarrior/services/repos":       nil,
+		"gitlab.com/blamewarrior/a/b/c/repos":          nil,
+		"gitlab.com/john.doe/project":                  nil,
+		"gitlab.com/my_group/my.project":               nil,
+		"gitlab.com/_group/project-1":                  nil,
+		"gitlab.com/blamewarrior":                      gitlab.ErrInvalidProjectPath,
+		"gitlab.example.com/blamewarrior/repos":        gitlab.ErrInvalidProjectPath,
+		"blamewarrior/repos":                           gitlab.ErrInvalidProjectPath,
+		"gitlab.com/blamewarrior/repos/":               gitlab.ErrInvalidProjectPath,
+		"gitlab.com/blamewarrior//repos":               gitlab.ErrInvalidProjectPath,
+		"gitlab.com/-group/project":                    gitlab.ErrInvalidProjectPath,
+		"gitlab.com/.group/project":                    gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group./project":                    gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group/project.git":                 gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group/project.atom":                gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group/my project":                  gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group/" + strings.Repeat("a", 256): gitlab.ErrInvalidProjectPath,
+		"gitlab.com/group" + strings.Repeat("/a", 22):  gitlab.ErrInvalidProjectPath,
+	}
+
+	for fullName, expected := range examples {
+		t.Run(fmt.Sprintf("fullName: %q", fullName), func(t *testing.T) {
+			assert.Equal(t, expected, client.ValidateRepositoryName(fullName))
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	examples := map[string]error{
+		"octocat":                nil,
+		"john.doe":               nil,
+		"john_doe":               nil,
+		"john-doe-":              nil,
+		strings.Repeat("a", 255): nil,
+		"":                       gitlab.ErrInvalidUsername,
+		"-john":                  gitlab.ErrInvalidUsername,
+		".john":                  gitlab.ErrInvalidUsername,
+		"john.":                  gitlab.ErrInvalidUsername,
+		"john.git":               gitlab.ErrInvalidUsername,
+		"john/doe":               gitlab.ErrInvalidUsername,
+		"jöhn":                   gitlab.ErrInvalidUsername,
+		strings.Repeat("a", 256): gitlab.ErrInvalidUsername,
+	}
+
+	for username, expected := range examples {
+		t.Run(fmt.Sprintf("username: %q", username), func(t *testing.T) {
+			assert.Equal(t, expected, gitlab.ValidateUsername(username))
+		})
+	}
+}
+
+// setupAPIServer starts a fake GitLab API server that routes requests by their escaped
+// path, since project paths are passed URL-encoded.
+func setupAPIServer() (baseURL *url.URL, routes map[string]http.HandlerFunc, teardownFn func()) {
+	routes = make(map[string]http.HandlerFunc)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if handler, ok := routes[req.URL.EscapedPath()]; ok {
+			handler(w, req)
+			return
+		}
+
+		http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+	}))
+	baseURL, _ = url.Parse(srv.URL + "/")
+
+	return baseURL, routes, srv.Close
+}
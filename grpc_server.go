@@ -0,0 +1,477 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	pb "github.com/blamewarrior/collaborators/collaboratorspb"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/gitlab"
+)
+
+// DefaultWatchInterval is how often WatchCollaborators checks stored collaborators for
+// changes made outside of the gRPC server, e.g. by HTTP handlers or owner syncs.
+const DefaultWatchInterval = 5 * time.Second
+
+// GRPCServer implements Collaborators gRPC service on top of Collaboration.
+type GRPCServer struct {
+	pb.UnimplementedCollaboratorsServer
+
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+	syncer        *Syncer
+	watchers      *repositoryWatchers
+
+	WatchInterval time.Duration
+}
+
+func NewGRPCServer(db *sql.DB, collaboration blamewarrior.Collaboration, syncer *Syncer) *GRPCServer {
+	return &GRPCServer{
+		db:            db,
+		collaboration: collaboration,
+		syncer:        syncer,
+		watchers:      newRepositoryWatchers(),
+		WatchInterval: DefaultWatchInterval,
+	}
+}
+
+// NewGRPCService returns a gRPC server with Collaborators service and server reflection
+// registered.
+func NewGRPCService(srv *GRPCServer, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+
+	pb.RegisterCollaboratorsServer(s, srv)
+	reflection.Register(s)
+
+	return s
+}
+
+func (s *GRPCServer) ListCollaborators(ctx context.Context, req *pb.ListCollaboratorsRequest) (*pb.ListCollaboratorsResponse, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	if validateRepositoryName(s.syncer.Providers, fullName) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect full name")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	accounts, err := s.collaboration.ListAccounts(ctx, s.db, fullName)
+	if err != nil {
+		return nil, grpcInternalError("ListCollaborators", err)
+	}
+
+	resp := &pb.ListCollaboratorsResponse{
+		Collaborators: make([]*pb.Collaborator, 0, len(accounts)),
+	}
+
+	for _, account := range accounts {
+		resp.Collaborators = append(resp.Collaborators, accountToProto(account))
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) GetCollaborator(ctx context.Context, req *pb.GetCollaboratorRequest) (*pb.Collaborator, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	if validateRepositoryName(s.syncer.Providers, fullName) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect full name")
+	}
+
+	if validateLogin(s.syncer.Providers, fullName, req.Login) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect collaborator name")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	account, err := s.findCollaborator(ctx, s.db, fullName, req.Login)
+	if err != nil {
+		return nil, grpcInternalError("GetCollaborator", err)
+	}
+
+	if account == nil {
+		return nil, status.Errorf(codes.NotFound, "%s is not a collaborator of %s", req.Login, fullName)
+	}
+
+	return accountToProto(*account), nil
+}
+
+func (s *GRPCServer) AddCollaborator(ctx context.Context, req *pb.AddCollaboratorRequest) (*pb.Collaborator, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	account, err := accountFromProto(s.syncer.Providers, fullName, req.Collaborator)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, grpcInternalError("AddCollaborator", err)
+	}
+
+	defer blamewarrior.Rollback(s.collaboration, tx)
+
+	existing, err := s.findCollaborator(ctx, tx, fullName, account.Login)
+	if err != nil {
+		return nil, grpcInternalError("AddCollaborator", err)
+	}
+
+	if existing != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "%s is already a collaborator of %s", account.Login, fullName)
+	}
+
+	if err := s.collaboration.CreateRepository(ctx, tx, fullName); err != nil {
+		return nil, grpcInternalError("AddCollaborator", err)
+	}
+
+	if _, err := s.collaboration.AddAccount(ctx, tx, fullName, account); err != nil {
+		return nil, grpcInternalError("AddCollaborator", err)
+	}
+
+	if err := blamewarrior.Commit(s.collaboration, tx); err != nil {
+		return nil, grpcInternalError("AddCollaborator", err)
+	}
+
+	s.watchers.notify(fullName)
+
+	return accountToProto(*account), nil
+}
+
+func (s *GRPCServer) EditCollaborator(ctx context.Context, req *pb.EditCollaboratorRequest) (*pb.Collaborator, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	account, err := accountFromProto(s.syncer.Providers, fullName, req.Collaborator)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	existing, err := s.findCollaborator(ctx, s.db, fullName, account.Login)
+	if err != nil {
+		return nil, grpcInternalError("EditCollaborator", err)
+	}
+
+	if existing == nil {
+		return nil, status.Errorf(codes.NotFound, "%s is not a collaborator of %s", account.Login, fullName)
+	}
+
+	if err := s.collaboration.EditAccount(ctx, s.db, fullName, account); err != nil {
+		return nil, grpcInternalError("EditCollaborator", err)
+	}
+
+	s.watchers.notify(fullName)
+
+	existing.Uid, existing.Permissions = account.Uid, account.Permissions
+
+	return accountToProto(*existing), nil
+}
+
+func (s *GRPCServer) DisconnectCollaborator(ctx context.Context, req *pb.DisconnectCollaboratorRequest) (*pb.DisconnectCollaboratorResponse, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	if validateRepositoryName(s.syncer.Providers, fullName) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect full name")
+	}
+
+	if validateLogin(s.syncer.Providers, fullName, req.Login) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect collaborator name")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	existing, err := s.findCollaborator(ctx, s.db, fullName, req.Login)
+	if err != nil {
+		return nil, grpcInternalError("DisconnectCollaborator", err)
+	}
+
+	if existing == nil {
+		return nil, status.Errorf(codes.NotFound, "%s is not a collaborator of %s", req.Login, fullName)
+	}
+
+	if err := s.collaboration.DisconnectAccount(ctx, s.db, fullName, req.Login); err != nil {
+		return nil, grpcInternalError("DisconnectCollaborator", err)
+	}
+
+	s.watchers.notify(fullName)
+
+	return &pb.DisconnectCollaboratorResponse{}, nil
+}
+
+func (s *GRPCServer) SyncRepository(ctx context.Context, req *pb.SyncRepositoryRequest) (*pb.SyncRepositoryResponse, error) {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	if validateRepositoryName(s.syncer.Providers, fullName) != nil {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect full name")
+	}
+
+	resp := &pb.SyncRepositoryResponse{}
+
+	if !req.DryRun {
+		if err := s.syncer.SyncRepository(ctx, fullName); err != nil {
+			return nil, grpcSyncError("SyncRepository", err)
+		}
+
+		s.watchers.notify(fullName)
+
+		return resp, nil
+	}
+
+	changes, err := s.syncer.DiffRepository(ctx, fullName)
+	if err != nil {
+		return nil, grpcSyncError("SyncRepository", err)
+	}
+
+	for _, account := range changes.Added {
+		resp.Added = append(resp.Added, accountToProto(account))
+	}
+
+	for _, account := range changes.Updated {
+		resp.Updated = append(resp.Updated, accountToProto(account))
+	}
+
+	for _, account := range changes.Removed {
+		resp.Removed = append(resp.Removed, accountToProto(account))
+	}
+
+	return resp, nil
+}
+
+// WatchCollaborators compares stored collaborators with the previously sent ones either
+// every WatchInterval or as soon as the repository gets modified via this server.
+func (s *GRPCServer) WatchCollaborators(req *pb.WatchCollaboratorsRequest, stream pb.Collaborators_WatchCollaboratorsServer) error {
+	fullName := s.syncer.canonicalName(req.Repository)
+
+	if validateRepositoryName(s.syncer.Providers, fullName) != nil {
+		return status.Error(codes.InvalidArgument, "Incorrect full name")
+	}
+
+	changed, unsubscribe := s.watchers.subscribe(fullName)
+	defer unsubscribe()
+
+	interval := s.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var sent []blamewarrior.Account
+
+	for {
+		ctx, cancel := context.WithTimeout(stream.Context(), DatabaseOperationTimeout)
+		accounts, err := s.collaboration.ListAccounts(ctx, s.db, fullName)
+		cancel()
+
+		if err != nil {
+			if stream.Context().Err() != nil {
+				return nil
+			}
+
+			return grpcInternalError("WatchCollaborators", err)
+		}
+
+		changes := diffCollaborators(sent, accounts)
+
+		events := []struct {
+			Type     pb.CollaboratorEvent_Type
+			Accounts []blamewarrior.Account
+		}{
+			{pb.CollaboratorEvent_ADDED, changes.Added},
+			{pb.CollaboratorEvent_UPDATED, changes.Updated},
+			{pb.CollaboratorEvent_REMOVED, changes.Removed},
+		}
+
+		for _, event := range events {
+			for _, account := range event.Accounts {
+				if err := stream.Send(&pb.CollaboratorEvent{Type: event.Type, Collaborator: accountToProto(account)}); err != nil {
+					return err
+				}
+			}
+		}
+
+		sent = accounts
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// findCollaborator returns a repository collaborator with given login compared
+// case-insensitively or nil if there is no such collaborator.
+func (s *GRPCServer) findCollaborator(ctx context.Context, sqlRunner blamewarrior.SQLRunner, fullName, login string) (*blamewarrior.Account, error) {
+	accounts, err := s.collaboration.ListAccounts(ctx, sqlRunner, fullName)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range accounts {
+		if strings.EqualFold(accounts[i].Login, login) {
+			return &accounts[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func accountToProto(account blamewarrior.Account) *pb.Collaborator {
+	collaborator := &pb.Collaborator{
+		Uid:         int64(account.Uid),
+		Login:       account.Login,
+		Affiliation: affiliationOf(account),
+		Teams:       account.Teams,
+	}
+
+	if names := account.Permissions.Names(); len(names) > 0 {
+		collaborator.Permissions = names
+	}
+
+	return collaborator
+}
+
+// accountFromProto converts a collaborator sent by client into an account returning
+// InvalidArgument error if any of its fields is incorrect.
+func accountFromProto(providers map[string]blamewarrior.Provider, fullName string, collaborator *pb.Collaborator) (*blamewarrior.Account, error) {
+	if collaborator == nil {
+		return nil, status.Error(codes.InvalidArgument, "Missing collaborator")
+	}
+
+	perms, err := blamewarrior.PermissionsFromNames(collaborator.Permissions)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	record := CollaboratorRecord{
+		Repository:  fullName,
+		Login:       collaborator.Login,
+		Uid:         int(collaborator.Uid),
+		Permissions: perms,
+	}
+
+	if err := validateRecord(providers, record); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if collaborator.Affiliation != "" && !blamewarrior.IsValidAffiliation(collaborator.Affiliation) {
+		return nil, status.Error(codes.InvalidArgument, "Incorrect affiliation")
+	}
+
+	return &blamewarrior.Account{
+		Uid:         record.Uid,
+		Login:       record.Login,
+		Permissions: record.Permissions,
+		Affiliation: collaborator.Affiliation,
+	}, nil
+}
+
+func grpcInternalError(method string, err error) error {
+	log.Printf("%s\t%v\t%s", method, codes.Internal, err)
+
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// grpcSyncError maps errors returned by Syncer to gRPC status codes.
+func grpcSyncError(method string, err error) error {
+	switch err {
+	case github.ErrRateLimitReached:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case github.ErrNoSuchRepository:
+		return status.Error(codes.NotFound, err.Error())
+	case tokens.ErrServiceUnavailable:
+		return status.Error(codes.Unavailable, err.Error())
+	case tokens.ErrUserNotFound, tokens.ErrTokenRevoked, gitlab.ErrUnauthorized, blamewarrior.ErrProviderMismatch:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case github.ErrUnknownHost, gitlab.ErrNoSuchProject:
+		return status.Error(codes.NotFound, err.Error())
+	case blamewarrior.ErrRepositoryLocked:
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	return grpcInternalError(method, err)
+}
+
+// repositoryWatchers wakes up WatchCollaborators calls when a repository gets modified.
+// Repository names are compared case-insensitively, the same way they are stored.
+type repositoryWatchers struct {
+	mu    sync.Mutex
+	chans map[string]map[chan struct{}]struct{}
+}
+
+func newRepositoryWatchers() *repositoryWatchers {
+	return &repositoryWatchers{
+		chans: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+func (w *repositoryWatchers) subscribe(fullName string) (<-chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	key := strings.ToLower(fullName)
+
+	if w.chans[key] == nil {
+		w.chans[key] = make(map[chan struct{}]struct{})
+	}
+	w.chans[key][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.chans[key], ch)
+		if len(w.chans[key]) == 0 {
+			delete(w.chans, key)
+		}
+	}
+}
+
+func (w *repositoryWatchers) notify(fullName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.chans[strings.ToLower(fullName)] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,335 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	pb "github.com/blamewarrior/collaborators/collaboratorspb"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/gitlab"
+)
+
+func setupGRPCServer(t *testing.T, srv *main.GRPCServer) (conn *grpc.ClientConn, teardownFn func()) {
+	lis := bufconn.Listen(1 << 20)
+
+	s := main.NewGRPCService(srv)
+	go s.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func setupMemoryGRPCServer(t *testing.T) (client pb.CollaboratorsClient, srv *main.GRPCServer, teardownFn func()) {
+	db := blamewarrior.OpenMemoryDatabase()
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	srv = main.NewGRPCServer(db, collaboration, main.NewSyncer(db, collaboration, nil))
+	conn, teardownServer := setupGRPCServer(t, srv)
+
+	return pb.NewCollaboratorsClient(conn), srv, func() {
+		teardownServer()
+		db.Close()
+	}
+}
+
+func TestGRPCServer_Collaborators(t *testing.T) {
+	client, _, teardown := setupMemoryGRPCServer(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	collaborator, err := client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat", Permissions: []string{"push", "pull"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pull", "push"}, collaborator.Permissions)
+	assert.Equal(t, blamewarrior.AffiliationDirect, collaborator.Affiliation)
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 2, Login: "hubot", Permissions: []string{"pull"}, Affiliation: blamewarrior.AffiliationOutside},
+	})
+	require.NoError(t, err)
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat"},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	collaborator, err = client.EditCollaborator(ctx, &pb.EditCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat", Permissions: []string{"admin", "pull", "push"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "pull", "push"}, collaborator.Permissions)
+
+	collaborator, err = client.GetCollaborator(ctx, &pb.GetCollaboratorRequest{Repository: "blamewarrior/repos", Login: "hubot"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), collaborator.Uid)
+	assert.Equal(t, blamewarrior.AffiliationOutside, collaborator.Affiliation)
+
+	_, err = client.DisconnectCollaborator(ctx, &pb.DisconnectCollaboratorRequest{Repository: "blamewarrior/repos", Login: "hubot"})
+	require.NoError(t, err)
+
+	resp, err := client.ListCollaborators(ctx, &pb.ListCollaboratorsRequest{Repository: "blamewarrior/repos"})
+	require.NoError(t, err)
+	require.Len(t, resp.Collaborators, 1)
+	assert.Equal(t, "octocat", resp.Collaborators[0].Login)
+	assert.Equal(t, []string{"admin", "pull", "push"}, resp.Collaborators[0].Permissions)
+
+	_, err = client.GetCollaborator(ctx, &pb.GetCollaboratorRequest{Repository: "blamewarrior/repos", Login: "hubot"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.EditCollaborator(ctx, &pb.EditCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 2, Login: "hubot"},
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DisconnectCollaborator(ctx, &pb.DisconnectCollaboratorRequest{Repository: "blamewarrior/repos", Login: "hubot"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCServer_InvalidArgument(t *testing.T) {
+	client, _, teardown := setupMemoryGRPCServer(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	_, err := client.ListCollaborators(ctx, &pb.ListCollaboratorsRequest{Repository: "blamewarrior"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{Repository: "blamewarrior/repos"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat", Permissions: []string{"owner"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat", Affiliation: "owner"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.WatchCollaborators(ctx, &pb.WatchCollaboratorsRequest{Repository: "blamewarrior/"})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCServer_GitLabNames(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	// names are checked with the rules of the provider the syncer uses
+	syncer := main.NewSyncer(db, collaboration, nil)
+	syncer.Providers = map[string]blamewarrior.Provider{"gitlab.com": gitlab.NewClient("gitlab.com", nil)}
+
+	conn, teardown := setupGRPCServer(t, main.NewGRPCServer(db, collaboration, syncer))
+	defer teardown()
+
+	client := pb.NewCollaboratorsClient(conn)
+	ctx := context.Background()
+
+	for _, fullName := range []string{"gitlab.com/blamewarrior/services/repos", "gitlab.com/john.doe/project", "gitlab.com/my_group/project"} {
+		_, err := client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+			Repository:   fullName,
+			Collaborator: &pb.Collaborator{Uid: 1, Login: "john.doe"},
+		})
+		require.NoError(t, err, fullName)
+
+		collaborator, err := client.GetCollaborator(ctx, &pb.GetCollaboratorRequest{Repository: fullName, Login: "john.doe"})
+		require.NoError(t, err, fullName)
+		assert.Equal(t, int64(1), collaborator.Uid)
+	}
+
+	_, err := client.ListCollaborators(ctx, &pb.ListCollaboratorsRequest{Repository: "gitlab.com/-group/project"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCServer_WatchCollaborators(t *testing.T) {
+	client, srv, teardown := setupMemoryGRPCServer(t)
+	defer teardown()
+
+	srv.WatchInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 1, Login: "octocat", Permissions: []string{"pull"}},
+	})
+	require.NoError(t, err)
+
+	// watchers are woken up by changes made to the repository under any case of its name
+	stream, err := client.WatchCollaborators(ctx, &pb.WatchCollaboratorsRequest{Repository: "BlameWarrior/Repos"})
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.CollaboratorEvent_ADDED, event.Type)
+	assert.Equal(t, "octocat", event.Collaborator.Login)
+
+	_, err = client.AddCollaborator(ctx, &pb.AddCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 2, Login: "hubot", Permissions: []string{"pull"}},
+	})
+	require.NoError(t, err)
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.CollaboratorEvent_ADDED, event.Type)
+	assert.Equal(t, "hubot", event.Collaborator.Login)
+
+	_, err = client.EditCollaborator(ctx, &pb.EditCollaboratorRequest{
+		Repository:   "blamewarrior/repos",
+		Collaborator: &pb.Collaborator{Uid: 2, Login: "hubot", Permissions: []string{"pull", "push"}},
+	})
+	require.NoError(t, err)
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.CollaboratorEvent_UPDATED, event.Type)
+	assert.Equal(t, []string{"pull", "push"}, event.Collaborator.Permissions)
+
+	_, err = client.DisconnectCollaborator(ctx, &pb.DisconnectCollaboratorRequest{Repository: "blamewarrior/repos", Login: "octocat"})
+	require.NoError(t, err)
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.CollaboratorEvent_REMOVED, event.Type)
+	assert.Equal(t, "octocat", event.Collaborator.Login)
+}
+
+func TestGRPCServer_SyncRepository(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"login":"octocat", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "blamewarrior/repos"}`))
+	})
+
+	mux.HandleFunc("/repositories/1/invitations", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())))
+	syncer.GithubBaseURL = testAPIEndpoint
+
+	conn, teardown := setupGRPCServer(t, main.NewGRPCServer(db, collaboration, syncer))
+	defer teardown()
+
+	client := pb.NewCollaboratorsClient(conn)
+
+	resp, err := client.SyncRepository(context.Background(), &pb.SyncRepositoryRequest{Repository: "blamewarrior/repos", DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Added, 1)
+	assert.Equal(t, "octocat", resp.Added[0].Login)
+	assert.Empty(t, resp.Updated)
+	assert.Empty(t, resp.Removed)
+
+	list, err := client.ListCollaborators(context.Background(), &pb.ListCollaboratorsRequest{Repository: "blamewarrior/repos"})
+	require.NoError(t, err)
+	assert.Empty(t, list.Collaborators)
+
+	_, err = client.SyncRepository(context.Background(), &pb.SyncRepositoryRequest{Repository: "blamewarrior/repos"})
+	require.NoError(t, err)
+
+	list, err = client.ListCollaborators(context.Background(), &pb.ListCollaboratorsRequest{Repository: "blamewarrior/repos"})
+	require.NoError(t, err)
+	require.Len(t, list.Collaborators, 1)
+	assert.Equal(t, []string{"pull", "push"}, list.Collaborators[0].Permissions)
+}
+
+func TestGRPCServer_Reflection(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	conn, teardown := setupGRPCServer(t, main.NewGRPCServer(db, collaboration, main.NewSyncer(db, collaboration, nil)))
+	defer teardown()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().Service {
+		services = append(services, service.Name)
+	}
+
+	assert.Contains(t, services, "blamewarrior.collaborators.v1.Collaborators")
+}
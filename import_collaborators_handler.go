@@ -0,0 +1,91 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// MaxImportSize limits the size of a file accepted by ImportCollaboratorsHandler.
+const MaxImportSize = 10 << 20
+
+// ImportCollaboratorsHandler validates uploaded collaborator records and applies the valid
+// ones, responding with a report that lists rejected rows.
+type ImportCollaboratorsHandler struct {
+	hostname      string
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+
+	// Hosts qualifies names of owners assigned to GitHub Enterprise Server hosts, see
+	// github.Hosts.Canonical.
+	Hosts *github.Hosts
+}
+
+func (h *ImportCollaboratorsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+
+	if !IsValidRecordsFormat(format) {
+		http.Error(w, "Incorrect format", http.StatusBadRequest)
+		return
+	}
+
+	report := newImportReport()
+
+	records, err := readRecords(http.MaxBytesReader(w, req.Body, MaxImportSize), format, report)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	canonicalRecords(h.Hosts, records)
+
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	if err := importCollaborators(ctx, h.db, h.collaboration, h.Providers, records, report); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func NewImportCollaboratorsHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration) *ImportCollaboratorsHandler {
+	return &ImportCollaboratorsHandler{
+		hostname:      hostname,
+		db:            db,
+		collaboration: collaboration,
+	}
+}
@@ -0,0 +1,125 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+func TestImportCollaboratorsHandler(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	require.NoError(t, collaboration.CreateRepository(context.Background(), tx, "blamewarrior/repos"))
+	_, err = collaboration.AddAccount(context.Background(), tx, "blamewarrior/repos", &blamewarrior.Account{
+		Uid:         1,
+		Login:       "octocat",
+		Permissions: blamewarrior.AccountPermissions{Pull: true},
+	})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	body := "repository,login,uid,permissions\n" +
+		"blamewarrior/repos,octocat,1,admin pull push\n" +
+		"blamewarrior/repos,hubot,2,pull\n" +
+		"blamewarrior,hubot,2,pull\n" +
+		"blamewarrior/hooks,hubot,2,owner\n" +
+		"blamewarrior/repos,hubot,2,push\n" +
+		"blamewarrior/hooks,,3,pull\n" +
+		"blamewarrior/hooks,monalisa,3,\n"
+
+	req, err := http.NewRequest("POST", "/collaborators/import?format=csv", strings.NewReader(body))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	handler := main.NewImportCollaboratorsHandler("blamewarrior.com", db, collaboration)
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report main.ImportReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+
+	assert.Equal(t, 2, report.Added)
+	assert.Equal(t, 1, report.Updated)
+
+	var rows []int
+	for _, rejected := range report.Rejected {
+		rows = append(rows, rejected.Row)
+		assert.NotEmpty(t, rejected.Error)
+	}
+	assert.Equal(t, []int{3, 4, 5, 6}, rows)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "hubot", accounts[0].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[0].Permissions)
+	assert.Equal(t, "octocat", accounts[1].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true, Push: true, Admin: true}, accounts[1].Permissions)
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "monalisa", accounts[0].Login)
+}
+
+func TestImportCollaboratorsHandler_IncorrectRequest(t *testing.T) {
+	results := []struct {
+		Query        string
+		Body         string
+		ResponseBody string
+	}{
+		{"format=xml", "", "Incorrect format\n"},
+		{"format=json", "{", "Unable to decode request body\n"},
+		{"format=csv", "login,repository\n", "Unable to decode request body\n"},
+	}
+
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	for _, result := range results {
+		req, err := http.NewRequest("POST", "/collaborators/import?"+result.Query, strings.NewReader(result.Body))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewImportCollaboratorsHandler("blamewarrior.com", db, blamewarrior.NewMemoryCollaborationService())
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, result.Query)
+		assert.Equal(t, result.ResponseBody, w.Body.String(), result.Query)
+	}
+}
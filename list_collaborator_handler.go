@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -23,29 +24,44 @@ import (
 	"net/http"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
 )
 
 type ListCollaboratorHandler struct {
 	hostname      string
 	db            *sql.DB
 	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
 }
 
 func (h *ListCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
+	host := req.URL.Query().Get(":host")
 	username := req.URL.Query().Get(":username")
 	repo := req.URL.Query().Get(":repo")
 
-	fullName := fmt.Sprintf("%s/%s", username, repo)
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
 
-	if username == "" || repo == "" {
+	if validateRepositoryName(h.Providers, fullName) != nil {
 		http.Error(w, "Incorrect full name", http.StatusBadRequest)
 		return
 	}
 
+	affiliation := req.URL.Query().Get("affiliation")
+
+	if affiliation != "" && !blamewarrior.IsValidAffiliation(affiliation) {
+		http.Error(w, "Incorrect affiliation", http.StatusBadRequest)
+		return
+	}
+
 	var accounts []blamewarrior.Account
 
-	accounts, err := h.collaboration.ListAccounts(h.db, fullName)
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	accounts, err := h.collaboration.ListAccounts(ctx, h.db, fullName)
 
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -53,6 +69,10 @@ func (h *ListCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Req
 		return
 	}
 
+	if affiliation != "" {
+		accounts = filterAccountsByAffiliation(accounts, affiliation)
+	}
+
 	if err := json.NewEncoder(w).Encode(accounts); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		log.Printf("%s\t%s\t%v\t%s", "POST", req.RequestURI, http.StatusInternalServerError, err)
@@ -60,6 +80,18 @@ func (h *ListCollaboratorHandler) ServeHTTP(w http.ResponseWriter, req *http.Req
 	}
 }
 
+func filterAccountsByAffiliation(accounts []blamewarrior.Account, affiliation string) []blamewarrior.Account {
+	filtered := make([]blamewarrior.Account, 0, len(accounts))
+
+	for _, account := range accounts {
+		if account.Affiliation == affiliation {
+			filtered = append(filtered, account)
+		}
+	}
+
+	return filtered
+}
+
 func NewListCollaboratorHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration) *ListCollaboratorHandler {
 	return &ListCollaboratorHandler{
 		hostname:      hostname,
@@ -1,6 +1,7 @@
 package main_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -19,6 +20,7 @@ func TestListCollaboratorHandler(t *testing.T) {
 		Owner        string
 		Name         string
 		AccountLogin string
+		Affiliation  string
 		ResponseCode int
 		ResponseBody string
 	}{
@@ -34,22 +36,49 @@ func TestListCollaboratorHandler(t *testing.T) {
 			Name:         "test_list_handler",
 			AccountLogin: "octocat",
 			ResponseCode: http.StatusOK,
-			ResponseBody: "[{\"uid\":123,\"login\":\"octocat\",\"permissions\":{\"admin\":true}}]\n",
+			ResponseBody: "[{\"uid\":123,\"login\":\"octocat\",\"permissions\":{\"admin\":true},\"affiliation\":\"outside\"}]\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_handler",
+			AccountLogin: "octocat",
+			Affiliation:  "outside",
+			ResponseCode: http.StatusOK,
+			ResponseBody: "[{\"uid\":123,\"login\":\"octocat\",\"permissions\":{\"admin\":true},\"affiliation\":\"outside\"}]\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_handler",
+			AccountLogin: "octocat",
+			Affiliation:  "direct",
+			ResponseCode: http.StatusOK,
+			ResponseBody: "[]\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_handler",
+			AccountLogin: "octocat",
+			Affiliation:  "unknown",
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect affiliation\n",
 		},
 	}
 
 	for _, result := range results {
 		db, teardown := setupTestDBConn()
 
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+		require.NoError(t, err)
+
 		var accountId int
-		_, err := db.Exec(blamewarrior.CreateRepositoryQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name))
+		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name))
 		require.NoError(t, err)
-		err = db.QueryRow(blamewarrior.AddAccountQuery, 123, result.AccountLogin, `{"admin": true}`).Scan(&accountId)
+		err = db.QueryRow(blamewarrior.AddAccountQuery, 123, result.AccountLogin).Scan(&accountId)
 		require.NoError(t, err)
-		_, err = db.Exec(blamewarrior.BuildCollaborationQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name), accountId)
+		_, err = db.Exec(blamewarrior.BuildCollaborationQuery, fmt.Sprintf("%s/%s", result.Owner, result.Name), accountId, blamewarrior.AffiliationOutside, `{"admin": true}`)
 		require.NoError(t, err)
 
-		req, err := http.NewRequest("GET", "/collaborators?:username="+result.Owner+"&:repo="+result.Name, nil)
+		req, err := http.NewRequest("GET", "/collaborators?:username="+result.Owner+"&:repo="+result.Name+"&affiliation="+result.Affiliation, nil)
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
@@ -65,3 +94,24 @@ func TestListCollaboratorHandler(t *testing.T) {
 		teardown()
 	}
 }
+
+func TestListCollaboratorHandler_CancelledRequest(t *testing.T) {
+	db := blamewarrior.OpenMemoryDatabase()
+	defer db.Close()
+
+	collaboration := blamewarrior.NewMemoryCollaborationService()
+	require.NoError(t, collaboration.CreateRepository(context.Background(), db, "blamewarrior/test_list_handler"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := http.NewRequest("GET", "/collaborators?:username=blamewarrior&:repo=test_list_handler", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	handler := main.NewListCollaboratorHandler("blamewarrior.com", db, collaboration)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
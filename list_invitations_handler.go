@@ -0,0 +1,76 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+type ListInvitationsHandler struct {
+	hostname      string
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+}
+
+func (h *ListInvitationsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	host := req.URL.Query().Get(":host")
+	username := req.URL.Query().Get(":username")
+	repo := req.URL.Query().Get(":repo")
+
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
+
+	if validateRepositoryName(h.Providers, fullName) != nil {
+		http.Error(w, "Incorrect full name", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), DatabaseOperationTimeout)
+	defer cancel()
+
+	invitations, err := h.collaboration.ListInvitations(ctx, h.db, fullName)
+
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(invitations); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func NewListInvitationsHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration) *ListInvitationsHandler {
+	return &ListInvitationsHandler{
+		hostname:      hostname,
+		db:            db,
+		collaboration: collaboration,
+	}
+}
@@ -0,0 +1,100 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+func TestListTeamMembersHandler(t *testing.T) {
+
+	results := []struct {
+		Owner        string
+		Name         string
+		Team         string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			Owner:        "",
+			Name:         "",
+			Team:         "developers",
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_team_members_handler",
+			Team:         "",
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect team name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_team_members_handler",
+			Team:         "developers",
+			ResponseCode: http.StatusOK,
+			ResponseBody: "[{\"uid\":123,\"login\":\"octocat\",\"permissions\":{\"push\":true}}]\n",
+		},
+	}
+
+	for _, result := range results {
+		db, teardown := setupTestDBConn()
+
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+		require.NoError(t, err)
+
+		fullName := fmt.Sprintf("%s/%s", result.Owner, result.Name)
+
+		var accountId, teamId int
+		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fullName)
+		require.NoError(t, err)
+		err = db.QueryRow(blamewarrior.AddAccountQuery, 123, "octocat").Scan(&accountId)
+		require.NoError(t, err)
+		_, err = db.Exec(blamewarrior.BuildCollaborationQuery, fullName, accountId, blamewarrior.AffiliationDirect, `{"push": true}`)
+		require.NoError(t, err)
+		err = db.QueryRow(blamewarrior.AddTeamQuery, fullName, 1, "Developers", "developers", "push").Scan(&teamId)
+		require.NoError(t, err)
+		_, err = db.Exec(blamewarrior.AddTeamMemberQuery, fullName, "developers", accountId)
+		require.NoError(t, err)
+
+		requestURL := fmt.Sprintf("/teams?:username=%s&:repo=%s&:team=%s", result.Owner, result.Name, result.Team)
+
+		req, err := http.NewRequest("GET", requestURL, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		collaboration := blamewarrior.NewCollaborationService()
+
+		handler := main.NewListTeamMembersHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body))
+
+		teardown()
+	}
+}
@@ -0,0 +1,81 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+	"github.com/blamewarrior/collaborators/blamewarrior"
+)
+
+func TestListTeamsHandler(t *testing.T) {
+
+	results := []struct {
+		Owner        string
+		Name         string
+		ResponseCode int
+		ResponseBody string
+	}{
+		{
+			Owner:        "",
+			Name:         "",
+			ResponseCode: http.StatusBadRequest,
+			ResponseBody: "Incorrect full name\n",
+		},
+		{
+			Owner:        "blamewarrior",
+			Name:         "test_list_teams_handler",
+			ResponseCode: http.StatusOK,
+			ResponseBody: "[{\"uid\":1,\"name\":\"Developers\",\"slug\":\"developers\",\"permission\":\"push\"}]\n",
+		},
+	}
+
+	for _, result := range results {
+		db, teardown := setupTestDBConn()
+
+		_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+		require.NoError(t, err)
+
+		fullName := fmt.Sprintf("%s/%s", result.Owner, result.Name)
+
+		_, err = db.Exec(blamewarrior.CreateRepositoryQuery, fullName)
+		require.NoError(t, err)
+		_, err = db.Exec(blamewarrior.AddTeamQuery, fullName, 1, "Developers", "developers", "push")
+		require.NoError(t, err)
+
+		req, err := http.NewRequest("GET", "/teams?:username="+result.Owner+"&:repo="+result.Name, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		collaboration := blamewarrior.NewCollaborationService()
+
+		handler := main.NewListTeamsHandler("blamewarrior.com", db, collaboration)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code)
+		assert.Equal(t, result.ResponseBody, fmt.Sprintf("%v", w.Body))
+
+		teardown()
+	}
+}
@@ -19,17 +19,28 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"expvar"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/blamewarrior/collaborators/blamewarrior"
 	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
 	"github.com/blamewarrior/collaborators/github"
-	"github.com/bmizerany/pat"
+	"github.com/blamewarrior/collaborators/gitlab"
 )
 
+// DatabaseOperationTimeout limits the time a single database operation, such as a query
+// issued by a handler or a repository sync transaction, is allowed to take.
+const DatabaseOperationTimeout = 10 * time.Second
+
 var (
 	binaryName     = os.Args[0]
 	version        = "n/a"
@@ -38,15 +49,55 @@ var (
 	buildGoVersion = "n/a"
 
 	args struct {
-		version bool
+		version               bool
+		storage               string
+		sqlitePath            string
+		tokenSource           string
+		tokenServiceTimeout   time.Duration
+		tokenCacheTTL         time.Duration
+		tokenNegativeCacheTTL time.Duration
+		githubAppID           int64
+		githubAppPrivateKey   string
+		githubCache           string
+		githubCacheTTL        time.Duration
+		githubHosts           string
+		githubMaxRetries      int
+		githubRetryDelay      time.Duration
+		githubRequestTimeout  time.Duration
+		gitlabHosts           string
+		listCacheTTL          time.Duration
 	}
 )
 
 func init() {
 	flag.BoolVar(&args.version, "version", false, "Print version and quit")
+	flag.StringVar(&args.storage, "storage", "postgres", "Storage backend to use, one of postgres, sqlite or memory")
+	flag.StringVar(&args.sqlitePath, "sqlite-path", "collaborators.db", "Path to SQLite database file used with -storage=sqlite")
+	flag.StringVar(&args.tokenSource, "token-source", "users", "Source of GitHub tokens, one of users (users service) or app (GitHub App installation tokens)")
+	flag.DurationVar(&args.tokenServiceTimeout, "token-service-timeout", tokens.DefaultTimeout, "Timeout of a single request to users service or for a GitHub App installation token")
+	flag.Int64Var(&args.githubAppID, "github-app-id", 0, "GitHub App id used with -token-source=app")
+	flag.StringVar(&args.githubAppPrivateKey, "github-app-private-key", "", "Path to PEM-encoded GitHub App private key used with -token-source=app")
+	flag.StringVar(&args.githubCache, "github-cache", "memory", "Where to keep GitHub API responses for conditional requests, one of memory, postgres or none")
+	flag.DurationVar(&args.githubCacheTTL, "github-cache-ttl", github.DefaultResponseCacheTTL, "Time to keep GitHub API responses stored with -github-cache=postgres")
+	flag.StringVar(&args.githubHosts, "github-hosts", "", "Path to JSON file with GitHub Enterprise Server hosts and owners hosted there")
+	flag.IntVar(&args.githubMaxRetries, "github-max-retries", github.DefaultMaxRetries, "Number of times GitHub API request is repeated after 5xx response, timeout or connection reset")
+	flag.DurationVar(&args.githubRetryDelay, "github-retry-delay", github.DefaultRetryDelay, "Delay before the first retry of GitHub API request, doubled with each next attempt")
+	flag.DurationVar(&args.githubRequestTimeout, "github-request-timeout", github.DefaultRequestTimeout, "Timeout of a single attempt to send GitHub API request")
+	flag.StringVar(&args.gitlabHosts, "gitlab-hosts", "", "Comma-separated GitLab host names, repositories qualified with them are synchronized with GitLab")
+	flag.DurationVar(&args.listCacheTTL, "list-cache-ttl", 0, "Time to cache repository collaborator lists for, zero disables caching")
+	flag.DurationVar(&args.tokenCacheTTL, "token-cache-ttl", tokens.DefaultCacheTTL, "Time to keep GitHub tokens received from users service")
+	flag.DurationVar(&args.tokenNegativeCacheTTL, "token-negative-cache-ttl", tokens.DefaultNegativeCacheTTL, "Time to remember that users service does not know a user")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]\nOptions:\n", binaryName)
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [COMMAND] [ARGS]\nOptions:\n", binaryName)
 		flag.PrintDefaults()
+
+		fmt.Fprintln(os.Stderr, "Commands:")
+		w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+		for _, cmd := range Commands {
+			fmt.Fprintf(w, "  %s %s\t%s\n", cmd.Name, cmd.Usage, cmd.Description)
+		}
+		w.Flush()
+		fmt.Fprintln(os.Stderr, "The server is started with serve command if none is given.")
 	}
 }
 
@@ -61,34 +112,243 @@ func main() {
 		os.Exit(0)
 	}
 
-	dbName := os.Getenv("DB_NAME")
+	cmd, cmdArgs := "serve", flag.Args()
+	if len(cmdArgs) > 0 {
+		cmd, cmdArgs = cmdArgs[0], cmdArgs[1:]
+	}
+
+	if FindCommand(cmd) == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	tokenClient := setupTokenClient(args.tokenSource)
+
+	githubClient := github.NewClient(tokenClient)
+	githubClient.MaxRetries = args.githubMaxRetries
+	githubClient.RetryDelay = args.githubRetryDelay
+	githubClient.RequestTimeout = args.githubRequestTimeout
+	if args.githubHosts != "" {
+		githubClient.Hosts = readGithubHosts(args.githubHosts)
+	}
+
+	var providers map[string]blamewarrior.Provider
+	if args.gitlabHosts != "" {
+		// GitHub App installation tokens are no good for GitLab
+		if args.tokenSource != "users" {
+			tokenClient = newUsersTokenClient()
+		}
+
+		providers = setupGitlabProviders(args.gitlabHosts, tokenClient)
+	}
+
+	db, collaboration := setupStorage(args.storage)
+	if args.listCacheTTL > 0 {
+		collaboration = setupListCache(args.storage, collaboration)
+	}
+
+	githubClient.ResponseCache = setupResponseCache(args.githubCache, db)
+	expvar.Publish("github_cache", expvar.Func(func() interface{} { return githubClient.CacheStats() }))
+	expvar.Publish("github_retries", expvar.Func(func() interface{} { return githubClient.RetryStats() }))
+
+	env := &CommandEnv{
+		DB:            db,
+		Collaboration: collaboration,
+		GithubClient:  githubClient,
+		Providers:     providers,
+		SyncJobs:      setupSyncJobQueue(args.storage, db),
+		OwnerSyncJobs: setupOwnerSyncJobs(args.storage, db),
+	}
+
+	os.Exit(RunCommand(env, cmd, cmdArgs))
+}
+
+// setupTokenClient returns a client to get GitHub tokens from given source. Tokens received
+// from users service are cached, while GitHub App client keeps installation tokens until they
+// are about to expire by itself.
+func setupTokenClient(source string) tokens.Client {
+	switch source {
+	case "users":
+		tokenClient := newUsersTokenClient()
+		expvar.Publish("token_cache", expvar.Func(func() interface{} { return tokenClient.Stats() }))
+
+		return tokenClient
+	case "app":
+		if args.githubAppID == 0 || args.githubAppPrivateKey == "" {
+			log.Fatal("-github-app-id and -github-app-private-key are required to use GitHub App tokens")
+		}
+
+		privateKey, err := ioutil.ReadFile(args.githubAppPrivateKey)
+		if err != nil {
+			log.Fatalf("failed to read GitHub App private key: %s", err)
+		}
+
+		tokenClient, err := tokens.NewAppClient(args.githubAppID, privateKey)
+		if err != nil {
+			log.Fatalf("failed to setup GitHub App client: %s", err)
+		}
+		tokenClient.HTTPClient.Timeout = args.tokenServiceTimeout
+
+		return tokenClient
+	default:
+		log.Fatalf("unknown token source %q, expected one of users or app", source)
+	}
+
+	return nil
+}
+
+// newUsersTokenClient returns a client that requests tokens from users service and caches them.
+func newUsersTokenClient() *tokens.CachingClient {
+	usersClient := tokens.NewTokenClient("https://blamewarrior.com")
+	usersClient.HTTPClient.Timeout = args.tokenServiceTimeout
+
+	tokenClient := tokens.NewCachingClient(usersClient, args.tokenCacheTTL)
+	tokenClient.NegativeTTL = args.tokenNegativeCacheTTL
+
+	return tokenClient
+}
+
+// setupGitlabProviders returns GitLab clients of comma-separated hosts mapped to their names.
+func setupGitlabProviders(hosts string, tokenClient tokens.Client) map[string]blamewarrior.Provider {
+	providers := make(map[string]blamewarrior.Provider)
+
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+
+		if err := github.ValidateHost(host); err != nil {
+			log.Fatalf("invalid GitLab host %q", host)
+		}
+
+		providers[host] = gitlab.NewClient(host, tokenClient)
+	}
+
+	return providers
+}
+
+// readGithubHosts reads API endpoints of GitHub Enterprise Server hosts from a file.
+func readGithubHosts(path string) *github.Hosts {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("failed to open GitHub hosts file: %s", err)
+	}
+	defer f.Close()
+
+	hosts, err := github.ReadHosts(f)
+	if err != nil {
+		log.Fatalf("failed to read GitHub hosts from %s: %s", path, err)
+	}
+
+	return hosts
+}
+
+// setupResponseCache returns a cache for GitHub API responses or nil if caching is disabled.
+func setupResponseCache(cache string, db *sql.DB) github.ResponseCache {
+	switch cache {
+	case "memory":
+		return github.NewMemoryResponseCache(github.DefaultResponseCacheSize)
+	case "postgres":
+		if args.storage != "postgres" {
+			log.Fatal("-github-cache=postgres requires -storage=postgres")
+		}
+
+		cache := github.NewPostgresResponseCache(db)
+		cache.TTL = args.githubCacheTTL
+
+		return cache
+	case "none":
+		return nil
+	default:
+		log.Fatalf("unknown GitHub cache %q, expected one of memory, postgres or none", cache)
+	}
+
+	return nil
+}
+
+// setupSyncJobQueue returns the queue of repository sync jobs. Only PostgreSQL storage
+// lets several instances share the queue, other storages keep jobs in memory.
+func setupSyncJobQueue(storage string, db *sql.DB) SyncJobQueue {
+	if storage == "postgres" {
+		return NewPostgresSyncJobQueue(db)
+	}
+
+	return NewMemorySyncJobQueue()
+}
+
+// setupOwnerSyncJobs returns the store of owner sync jobs. Only PostgreSQL storage lets
+// all instances report progress of jobs started by any of them.
+func setupOwnerSyncJobs(storage string, db *sql.DB) OwnerSyncJobs {
+	if storage == "postgres" {
+		return NewPostgresOwnerSyncJobs(db)
+	}
+
+	return NewMemoryOwnerSyncJobs()
+}
+
+// setupStorage returns a database connection along with the Collaboration implementation
+// to use with it. The in-memory storage is meant for development and loses its data on exit.
+func setupStorage(storage string) (*sql.DB, blamewarrior.Collaboration) {
+	switch storage {
+	case "postgres":
+	case "sqlite":
+		db, err := blamewarrior.OpenSQLiteDatabase(args.sqlitePath)
+		if err != nil {
+			log.Fatalf("failed to open sqlite database %s: %s", args.sqlitePath, err)
+		}
+
+		return db, blamewarrior.NewSQLiteCollaborationService()
+	case "memory":
+		log.Println("using in-memory storage, all data will be lost on exit")
+		return blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	default:
+		log.Fatalf("unknown storage %q, expected one of postgres, sqlite or memory", storage)
+	}
+
+	dbName, opts := postgresOptions()
+
+	db, err := blamewarrior.ConnectDatabase(dbName, opts)
+	if err != nil {
+		log.Fatalf("failed to establish connection with test db %s using connection string %s: %s", dbName, opts.ConnectionString(), err)
+	}
+
+	return db, blamewarrior.NewCollaborationService()
+}
+
+// postgresOptions returns the name of PostgreSQL database and connection options passed via ENV.
+func postgresOptions() (dbName string, opts *blamewarrior.DatabaseOptions) {
+	dbName = os.Getenv("DB_NAME")
 	if dbName == "" {
 		log.Fatal("missing test database name (expected to be passed via ENV['DB_NAME'])")
 	}
 
-	opts := &blamewarrior.DatabaseOptions{
+	return dbName, &blamewarrior.DatabaseOptions{
 		Host:     os.Getenv("DB_HOST"),
 		Port:     os.Getenv("DB_PORT"),
 		User:     os.Getenv("DB_USER"),
 		Password: os.Getenv("DB_PASSWORD"),
 	}
+}
 
-	db, err := blamewarrior.ConnectDatabase(dbName, opts)
-	if err != nil {
-		log.Fatalf("failed to establish connection with test db %s using connection string %s: %s", dbName, opts.ConnectionString(), err)
+// setupListCache puts a cache of repository collaborator lists in front of collaboration. Instances
+// using PostgreSQL storage exchange cache invalidations with LISTEN/NOTIFY.
+func setupListCache(storage string, collaboration blamewarrior.Collaboration) blamewarrior.Collaboration {
+	cache := blamewarrior.NewCachingCollaboration(collaboration, args.listCacheTTL)
+	expvar.Publish("list_cache", expvar.Func(func() interface{} { return cache.Stats() }))
+
+	if storage != "postgres" {
+		return cache
 	}
 
-	mux := pat.New()
+	cache.Channel = blamewarrior.ListCacheChannel
 
-	tokenClient := tokens.NewTokenClient("https://blamewarrior.com")
-	githubClient := github.NewClient(tokenClient)
+	listener := blamewarrior.NewDatabaseListener(postgresOptions())
 
-	collaboration := blamewarrior.NewCollaborationService()
+	go func() {
+		// serving lists that other instances have changed is worse than not serving them at all
+		if err := cache.Listen(context.Background(), listener); err != nil {
+			log.Fatalf("failed to receive list cache invalidations: %s", err)
+		}
+	}()
 
-	mux.Get("/:username/:repo/collaborators/fetch", NewFetchCollaboratorsHandler("blamewarrior.com", db, collaboration,
-		githubClient))
-	mux.Post("/:username/:repo/collaborators", NewAddCollaboratorHandler("blamewarrior.com", db, collaboration))
-	mux.Get("/:username/:repo/collaborators", NewListCollaboratorHandler("blamewarrior.com", db, collaboration))
-	mux.Put("/:username/:repo/collaborators", NewEditCollaboratorHandler("blamewarrior.com", db, collaboration))
-	mux.Del("/:username/:repo/collaborators/:collaborator", NewDisconnectCollaboratorHandler("blamewarrior.com", db, collaboration))
+	return cache
 }
@@ -0,0 +1,84 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// validateRepositoryName checks a repository name with the rules of the provider its host is
+// mapped to in providers. Names of repositories hosted on GitHub follow GitHub rules.
+func validateRepositoryName(providers map[string]blamewarrior.Provider, fullName string) error {
+	if validator, ok := nameValidator(providers, fullName); ok {
+		return validator.ValidateRepositoryName(fullName)
+	}
+
+	return github.ValidateRepositoryName(fullName)
+}
+
+// validateLogin checks a name of a collaborator of the repository, see validateRepositoryName.
+func validateLogin(providers map[string]blamewarrior.Provider, fullName, login string) error {
+	if validator, ok := nameValidator(providers, fullName); ok {
+		return validator.ValidateLogin(login)
+	}
+
+	return github.ValidateLogin(login)
+}
+
+// validateOwner checks an owner name optionally qualified with a host name. Owners of
+// repositories hosted by other providers are top-level groups or users named by their rules.
+func validateOwner(providers map[string]blamewarrior.Provider, owner string) error {
+	if validator, ok := nameValidator(providers, owner); ok {
+		_, login := github.SplitHost(owner)
+		return validator.ValidateLogin(login)
+	}
+
+	return github.ValidateOwner(owner)
+}
+
+// nameValidator returns the provider a name is qualified with the host of, unless it follows
+// GitHub naming rules.
+func nameValidator(providers map[string]blamewarrior.Provider, name string) (blamewarrior.NameValidator, bool) {
+	host, _ := github.SplitHost(name)
+	validator, ok := providers[host].(blamewarrior.NameValidator)
+
+	return validator, ok
+}
+
+// canonicalNames serves requests for repositories and owners assigned to a GitHub Enterprise
+// Server host as if their route was prefixed with the host name, see github.Hosts.Canonical.
+func canonicalNames(githubClient *github.Client, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+
+		owner := query.Get(":username")
+		if owner == "" {
+			owner = query.Get(":owner")
+		}
+
+		if query.Get(":host") == "" && owner != "" {
+			if host, _ := github.SplitHost(githubClient.CanonicalName(owner)); host != github.DefaultHost {
+				query.Set(":host", host)
+				req.URL.RawQuery = query.Encode()
+			}
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
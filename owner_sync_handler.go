@@ -0,0 +1,91 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+type OwnerSyncHandler struct {
+	hostname      string
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+	githubClient  *github.Client
+	jobs          OwnerSyncJobs
+
+	GithubBaseURL *url.URL
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+}
+
+func (h *OwnerSyncHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	owner := github.QualifyName(req.URL.Query().Get(":host"), req.URL.Query().Get(":owner"))
+
+	if validateOwner(h.Providers, owner) != nil {
+		http.Error(w, "Incorrect owner name", http.StatusBadRequest)
+		return
+	}
+
+	syncer := NewSyncer(h.db, h.collaboration, h.githubClient)
+	syncer.GithubBaseURL = h.GithubBaseURL
+	syncer.Providers = h.Providers
+	// owner syncs run in background, so they can wait for rate limit reset
+	// and for concurrent syncs of the same repositories to finish
+	syncer.RateLimitPolicy = github.WaitForReset
+	syncer.WaitForLock = true
+
+	job, err := h.jobs.Create(req.Context(), owner)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	go func() {
+		if err := syncer.SyncOwner(context.Background(), h.jobs, job); err != nil {
+			log.Printf("failed to sync repositories of %s: %s", owner, err)
+		}
+	}()
+
+	w.Header().Set("Location", fmt.Sprintf("/%s/sync/%d", owner, job.Id))
+	w.WriteHeader(http.StatusAccepted)
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+	}
+}
+
+func NewOwnerSyncHandler(hostname string, db *sql.DB, collaboration blamewarrior.Collaboration,
+	githubClient *github.Client, jobs OwnerSyncJobs) *OwnerSyncHandler {
+
+	return &OwnerSyncHandler{
+		hostname:      hostname,
+		db:            db,
+		collaboration: collaboration,
+		githubClient:  githubClient,
+		jobs:          jobs,
+	}
+}
@@ -0,0 +1,177 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/gitlab"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestOwnerSyncHandler(t *testing.T) {
+	db, teardownDB := setupTestDBConn()
+	defer teardownDB()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Write([]byte(`{"token": "test_token"}`))
+			return
+		}
+
+		w.Write([]byte(`{"login": "blamewarrior", "id": 1, "type": "Organization"}`))
+	})
+
+	mux.HandleFunc("/orgs/blamewarrior/repos", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"id": 1, "full_name": "blamewarrior/test_owner_sync"}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_owner_sync/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_owner_sync/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_owner_sync", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "blamewarrior/test_owner_sync"}`))
+	})
+
+	mux.HandleFunc("/repositories/1/invitations", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	collaboration := blamewarrior.NewCollaborationService()
+	jobs := main.NewMemoryOwnerSyncJobs()
+
+	handler := main.NewOwnerSyncHandler("blamewarrior.com", db, collaboration,
+		github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())), jobs)
+	handler.GithubBaseURL = testAPIEndpoint
+
+	req, err := http.NewRequest("POST", "/sync?:owner=", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Incorrect owner name\n", fmt.Sprintf("%v", w.Body))
+
+	req, err = http.NewRequest("POST", "/sync?:owner=blamewarrior", nil)
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var response struct {
+		Id     int64  `json:"id"`
+		Owner  string `json:"owner"`
+		Status string `json:"status"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+	assert.Equal(t, "blamewarrior", response.Owner)
+	assert.Equal(t, main.SyncJobRunning, response.Status)
+	assert.Equal(t, fmt.Sprintf("/blamewarrior/sync/%d", response.Id), w.Header().Get("Location"))
+
+	job, err := jobs.Get(context.Background(), response.Id)
+	require.NoError(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status == main.SyncJobRunning {
+		require.True(t, time.Now().Before(deadline), "sync job did not finish in time")
+		time.Sleep(10 * time.Millisecond)
+
+		job, err = jobs.Get(context.Background(), response.Id)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, main.SyncJobFinished, job.Status)
+	assert.Equal(t, 1, job.Total)
+	assert.Equal(t, 1, job.Done)
+	assert.Equal(t, 0, job.Failed)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/test_owner_sync")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 1)
+}
+
+func TestOwnerSyncHandler_Providers(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	providers := map[string]blamewarrior.Provider{"gitlab.com": gitlab.NewClient("gitlab.com", nil)}
+	jobs := main.NewMemoryOwnerSyncJobs()
+
+	handler := main.NewOwnerSyncHandler("blamewarrior.com", db, collaboration, github.NewClient(nil), jobs)
+	handler.Providers = providers
+
+	jobHandler := main.NewOwnerSyncJobHandler("blamewarrior.com", jobs)
+	jobHandler.Providers = providers
+
+	// GitLab owners are named by GitLab rules, so the job fails because of the provider
+	req, err := http.NewRequest("POST", "/sync?:host=gitlab.com&:owner=blame_warrior", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req, err := http.NewRequest("GET", w.Header().Get("Location")+"?:host=gitlab.com&:owner=blame_warrior&:job=1", nil)
+		require.NoError(t, err)
+
+		jobW := httptest.NewRecorder()
+		jobHandler.ServeHTTP(jobW, req)
+		require.Equal(t, http.StatusOK, jobW.Code)
+
+		var job main.OwnerSyncJob
+		require.NoError(t, json.NewDecoder(jobW.Body).Decode(&job))
+
+		if job.Status != main.SyncJobRunning {
+			assert.Equal(t, main.SyncJobFailed, job.Status)
+			assert.Equal(t, "owner syncs are not supported by gitlab", job.Error)
+			break
+		}
+
+		require.True(t, time.Now().Before(deadline), "sync job did not finish in time")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+type OwnerSyncJobHandler struct {
+	hostname string
+	jobs     OwnerSyncJobs
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+}
+
+func (h *OwnerSyncJobHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	owner := github.QualifyName(req.URL.Query().Get(":host"), req.URL.Query().Get(":owner"))
+
+	id, err := strconv.ParseInt(req.URL.Query().Get(":job"), 10, 64)
+
+	if validateOwner(h.Providers, owner) != nil || err != nil || id <= 0 {
+		http.Error(w, "Incorrect job id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Get(req.Context(), id)
+
+	switch err {
+	case nil:
+	case ErrNoSuchSyncJob:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !strings.EqualFold(job.Owner, owner) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func NewOwnerSyncJobHandler(hostname string, jobs OwnerSyncJobs) *OwnerSyncJobHandler {
+	return &OwnerSyncJobHandler{
+		hostname: hostname,
+		jobs:     jobs,
+	}
+}
@@ -0,0 +1,119 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestOwnerSyncJobHandler(t *testing.T) {
+	jobs := main.NewMemoryOwnerSyncJobs()
+	job, err := jobs.Create(context.Background(), "blamewarrior")
+	require.NoError(t, err)
+
+	results := []struct {
+		Owner        string
+		Job          string
+		ResponseCode int
+	}{
+		{
+			Owner:        "blamewarrior",
+			Job:          "abc",
+			ResponseCode: http.StatusBadRequest,
+		},
+		{
+			Owner:        "blamewarrior",
+			Job:          "100500",
+			ResponseCode: http.StatusNotFound,
+		},
+		{
+			Owner:        "octocat",
+			Job:          fmt.Sprint(job.Id),
+			ResponseCode: http.StatusNotFound,
+		},
+		{
+			Owner:        "blamewarrior",
+			Job:          fmt.Sprint(job.Id),
+			ResponseCode: http.StatusOK,
+		},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("GET", "/sync?:owner="+result.Owner+"&:job="+result.Job, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+
+		handler := main.NewOwnerSyncJobHandler("blamewarrior.com", jobs)
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, result.ResponseCode, w.Code)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/sync?:owner=blamewarrior&:job=%d", job.Id), nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	main.NewOwnerSyncJobHandler("blamewarrior.com", jobs).ServeHTTP(w, req)
+
+	assert.Contains(t, w.Body.String(), `"owner":"blamewarrior","status":"running","total":0,"done":0,"failed":0,"results":[]`)
+}
+
+func TestOwnerSyncJobs_Retention(t *testing.T) {
+	ctx, now := context.Background(), time.Now()
+
+	jobs := main.NewMemoryOwnerSyncJobs()
+	jobs.Retention = time.Hour
+	main.SetOwnerSyncJobsClock(jobs, func() time.Time { return now })
+
+	finished, err := jobs.Create(ctx, "blamewarrior")
+	require.NoError(t, err)
+	require.NoError(t, jobs.Finish(ctx, finished.Id, nil))
+
+	running, err := jobs.Create(ctx, "octocat")
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour - time.Second)
+
+	_, err = jobs.Get(ctx, finished.Id)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Second)
+
+	_, err = jobs.Get(ctx, finished.Id)
+	assert.Equal(t, main.ErrNoSuchSyncJob, err)
+
+	// running jobs are kept regardless of their age
+	now = now.Add(24 * time.Hour)
+
+	_, err = jobs.Get(ctx, running.Id)
+	assert.NoError(t, err)
+
+	// ids of removed jobs are never reused
+	job, err := jobs.Create(ctx, "hubot")
+	require.NoError(t, err)
+	assert.Equal(t, running.Id+1, job.Id)
+}
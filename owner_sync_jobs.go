@@ -0,0 +1,342 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Statuses of sync jobs. An owner sync job is finished once all of its repositories have
+// been tried, while a repository sync job either succeeds or fails.
+const (
+	SyncJobQueued    = "queued"
+	SyncJobRunning   = "running"
+	SyncJobFinished  = "finished"
+	SyncJobSucceeded = "succeeded"
+	SyncJobFailed    = "failed"
+)
+
+// DefaultOwnerSyncJobRetention is the time MemoryOwnerSyncJobs keeps finished jobs for by default.
+const DefaultOwnerSyncJobRetention = 24 * time.Hour
+
+// RepositorySyncResult is an outcome of a single repository sync within an owner sync job.
+type RepositorySyncResult struct {
+	Repository string `json:"repository"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OwnerSyncJob is a sync of all repositories that belong to an owner. Results are added
+// as repositories get synced, Done and Failed count them.
+type OwnerSyncJob struct {
+	Id         int64                  `json:"id"`
+	Owner      string                 `json:"owner"`
+	Status     string                 `json:"status"`
+	Total      int                    `json:"total"`
+	Done       int                    `json:"done"`
+	Failed     int                    `json:"failed"`
+	Results    []RepositorySyncResult `json:"results"`
+	Error      string                 `json:"error,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// OwnerSyncJobs stores owner sync jobs along with outcomes of their repository syncs.
+// Implementations are safe for concurrent use.
+type OwnerSyncJobs interface {
+	// Create records a running job for an owner.
+	Create(ctx context.Context, owner string) (*OwnerSyncJob, error)
+	// Start records the number of repositories the job is going to sync.
+	Start(ctx context.Context, id int64, total int) error
+	// Report records the outcome of a repository sync within the job, the sync has failed
+	// if err is not nil.
+	Report(ctx context.Context, id int64, repository string, changes *CollaboratorChanges, err error) error
+	// Finish marks a running job as finished once all of its repositories have been tried,
+	// the job fails if err is not nil.
+	Finish(ctx context.Context, id int64, err error) error
+	// Get returns a job by its id or ErrNoSuchSyncJob.
+	Get(ctx context.Context, id int64) (*OwnerSyncJob, error)
+}
+
+// MemoryOwnerSyncJobs is an OwnerSyncJobs that keeps jobs started by this instance in memory.
+// Jobs are removed once they have been finished for Retention.
+type MemoryOwnerSyncJobs struct {
+	Retention time.Duration
+
+	now func() time.Time
+
+	mu     sync.Mutex
+	lastId int64
+	jobs   map[int64]*OwnerSyncJob
+}
+
+func NewMemoryOwnerSyncJobs() *MemoryOwnerSyncJobs {
+	return &MemoryOwnerSyncJobs{
+		Retention: DefaultOwnerSyncJobRetention,
+		now:       time.Now,
+		jobs:      make(map[int64]*OwnerSyncJob),
+	}
+}
+
+func (r *MemoryOwnerSyncJobs) Create(ctx context.Context, owner string) (*OwnerSyncJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+	r.lastId++
+
+	job := &OwnerSyncJob{
+		Id:        r.lastId,
+		Owner:     owner,
+		Status:    SyncJobRunning,
+		Results:   make([]RepositorySyncResult, 0),
+		StartedAt: r.now(),
+	}
+	r.jobs[job.Id] = job
+
+	return job.copy(), nil
+}
+
+func (r *MemoryOwnerSyncJobs) Start(ctx context.Context, id int64, total int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ErrNoSuchSyncJob
+	}
+
+	job.Total = total
+
+	return nil
+}
+
+func (r *MemoryOwnerSyncJobs) Report(ctx context.Context, id int64, repository string, changes *CollaboratorChanges, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ErrNoSuchSyncJob
+	}
+
+	result := RepositorySyncResult{Repository: repository}
+	if err != nil {
+		result.Error = err.Error()
+		job.Failed++
+	}
+
+	job.Results = append(job.Results, result)
+	job.Done++
+
+	return nil
+}
+
+func (r *MemoryOwnerSyncJobs) Finish(ctx context.Context, id int64, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ErrNoSuchSyncJob
+	}
+
+	if job.Status != SyncJobRunning {
+		return nil
+	}
+
+	now := r.now()
+	job.Status, job.FinishedAt = SyncJobFinished, &now
+
+	if err != nil {
+		job.Status, job.Error = SyncJobFailed, err.Error()
+	}
+
+	return nil
+}
+
+func (r *MemoryOwnerSyncJobs) Get(ctx context.Context, id int64) (*OwnerSyncJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNoSuchSyncJob
+	}
+
+	return job.copy(), nil
+}
+
+// prune removes expired jobs, r.mu is expected to be held by the caller.
+func (r *MemoryOwnerSyncJobs) prune() {
+	now := r.now()
+
+	for id, job := range r.jobs {
+		if job.FinishedAt != nil && !now.Before(job.FinishedAt.Add(r.Retention)) {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+func (job *OwnerSyncJob) copy() *OwnerSyncJob {
+	c := *job
+	c.Results = append(make([]RepositorySyncResult, 0, len(job.Results)), job.Results...)
+
+	return &c
+}
+
+const (
+	createOwnerSyncJobQuery = `
+		INSERT INTO sync_jobs (owner, status, created_at, started_at) VALUES ($1, 'running', now(), now())
+		RETURNING id, owner, status, total, error, started_at, finished_at`
+
+	startOwnerSyncJobQuery = `UPDATE sync_jobs SET total = $2 WHERE id = $1 AND owner IS NOT NULL`
+
+	// repositories synced within an owner sync are recorded as finished jobs of their own
+	reportOwnerSyncJobQuery = `
+		INSERT INTO sync_jobs (repository, parent_id, status, added, updated, removed, error, created_at, started_at, finished_at)
+		SELECT $2, id, $3, $4, $5, $6, $7, now(), now(), now() FROM sync_jobs WHERE id = $1 AND owner IS NOT NULL`
+
+	finishOwnerSyncJobQuery = `
+		UPDATE sync_jobs SET status = $2, error = $3, finished_at = now()
+		WHERE id = $1 AND owner IS NOT NULL AND status = 'running'
+	`
+
+	getOwnerSyncJobQuery = `
+		SELECT id, owner, status, total, error, started_at, finished_at FROM sync_jobs
+		WHERE id = $1 AND owner IS NOT NULL`
+
+	listOwnerSyncJobResultsQuery = `SELECT repository, error FROM sync_jobs WHERE parent_id = $1 ORDER BY id`
+)
+
+// PostgresOwnerSyncJobs is an OwnerSyncJobs that keeps jobs in sync_jobs table along with
+// repository sync jobs, so that all instances using the same database report their progress.
+// Each repository synced within an owner sync is stored as a job that refers to its owner
+// sync with parent_id.
+type PostgresOwnerSyncJobs struct {
+	db *sql.DB
+}
+
+func NewPostgresOwnerSyncJobs(db *sql.DB) *PostgresOwnerSyncJobs {
+	return &PostgresOwnerSyncJobs{db}
+}
+
+func (r *PostgresOwnerSyncJobs) Create(ctx context.Context, owner string) (*OwnerSyncJob, error) {
+	return scanOwnerSyncJob(r.db.QueryRowContext(ctx, createOwnerSyncJobQuery, owner))
+}
+
+func (r *PostgresOwnerSyncJobs) Start(ctx context.Context, id int64, total int) error {
+	return execOwnerSyncJob(ctx, r.db, startOwnerSyncJobQuery, id, total)
+}
+
+func (r *PostgresOwnerSyncJobs) Report(ctx context.Context, id int64, repository string, changes *CollaboratorChanges, err error) error {
+	status, added, updated, removed, errMsg := syncJobOutcome(changes, err)
+
+	return execOwnerSyncJob(ctx, r.db, reportOwnerSyncJobQuery, id, repository, status, added, updated, removed, errMsg)
+}
+
+func (r *PostgresOwnerSyncJobs) Finish(ctx context.Context, id int64, err error) error {
+	status, errMsg := SyncJobFinished, ""
+	if err != nil {
+		status, errMsg = SyncJobFailed, err.Error()
+	}
+
+	_, err = r.db.ExecContext(ctx, finishOwnerSyncJobQuery, id, status, errMsg)
+
+	return err
+}
+
+func (r *PostgresOwnerSyncJobs) Get(ctx context.Context, id int64) (*OwnerSyncJob, error) {
+	job, err := scanOwnerSyncJob(r.db.QueryRowContext(ctx, getOwnerSyncJobQuery, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, listOwnerSyncJobResultsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result RepositorySyncResult
+		if err := rows.Scan(&result.Repository, &result.Error); err != nil {
+			return nil, err
+		}
+
+		if result.Error != "" {
+			job.Failed++
+		}
+
+		job.Results = append(job.Results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	job.Done = len(job.Results)
+
+	return job, nil
+}
+
+// execOwnerSyncJob runs a query that changes an owner sync job and returns ErrNoSuchSyncJob
+// if there is no job with given id.
+func execOwnerSyncJob(ctx context.Context, db *sql.DB, query string, id int64, args ...interface{}) error {
+	res, err := db.ExecContext(ctx, query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchSyncJob
+	}
+
+	return nil
+}
+
+func scanOwnerSyncJob(row *sql.Row) (*OwnerSyncJob, error) {
+	job := &OwnerSyncJob{Results: make([]RepositorySyncResult, 0)}
+
+	err := row.Scan(
+		&job.Id,
+		&job.Owner,
+		&job.Status,
+		&job.Total,
+		&job.Error,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSuchSyncJob
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
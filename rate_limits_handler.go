@@ -0,0 +1,46 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// RateLimitsHandler reports the last known GitHub API rate limits of owner tokens
+// along with the number of requests reserved by running syncs.
+type RateLimitsHandler struct {
+	hostname   string
+	rateLimits *github.RateLimits
+}
+
+func (h *RateLimitsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := json.NewEncoder(w).Encode(h.rateLimits.All()); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func NewRateLimitsHandler(hostname string, rateLimits *github.RateLimits) *RateLimitsHandler {
+	return &RateLimitsHandler{
+		hostname:   hostname,
+		rateLimits: rateLimits,
+	}
+}
@@ -0,0 +1,66 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestRateLimitsHandler(t *testing.T) {
+	testAPIEndpoint, mux, teardown := setupAPIServer()
+	defer teardown()
+
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	githubClient := github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String()))
+
+	_, err := githubClient.RepositoryTeams(github.Context{Context: context.Background(), BaseURL: testAPIEndpoint}, "blamewarrior/repos")
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("GET", "/admin/rate-limits", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	handler := main.NewRateLimitsHandler("blamewarrior.com", githubClient.RateLimits)
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"owner": "blamewarrior", "limit": 5000, "remaining": 4999, "reset": "`+reset.Format(time.RFC3339)+`", "reserved": 0}]`, w.Body.String())
+}
@@ -0,0 +1,71 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// SyncCollaboratorsHandler queues a repository sync to be run by SyncJobRunner and
+// responds with the job right away.
+type SyncCollaboratorsHandler struct {
+	hostname string
+	jobs     SyncJobQueue
+
+	// Providers maps host names to providers other than GitHub, see Syncer.Providers.
+	Providers map[string]blamewarrior.Provider
+}
+
+func (h *SyncCollaboratorsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	host := req.URL.Query().Get(":host")
+	username := req.URL.Query().Get(":username")
+	repo := req.URL.Query().Get(":repo")
+
+	fullName := github.QualifyName(host, fmt.Sprintf("%s/%s", username, repo))
+
+	if validateRepositoryName(h.Providers, fullName) != nil {
+		http.Error(w, "Incorrect full name", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Enqueue(req.Context(), fullName)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.Id))
+	w.WriteHeader(http.StatusAccepted)
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+	}
+}
+
+func NewSyncCollaboratorsHandler(hostname string, jobs SyncJobQueue) *SyncCollaboratorsHandler {
+	return &SyncCollaboratorsHandler{
+		hostname: hostname,
+		jobs:     jobs,
+	}
+}
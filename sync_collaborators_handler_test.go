@@ -0,0 +1,137 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/github/githubtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestSyncCollaboratorsHandler_SyncJobs(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user2", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+
+	githubClient := github.NewClient(srv)
+	jobs := main.NewMemorySyncJobQueue()
+
+	router := main.NewRouter(db, collaboration, githubClient, nil, jobs, main.NewMemoryOwnerSyncJobs())
+
+	syncer := main.NewSyncer(db, collaboration, githubClient)
+	syncer.GithubBaseURL = srv.URL
+	syncer.ProfileRefreshLimit = 0
+
+	runner := main.NewSyncJobRunner(syncer, jobs)
+
+	results := []struct {
+		Path         string
+		ResponseCode int
+		Location     string
+	}{
+		{"/blamewarrior/repos/collaborators/sync", http.StatusAccepted, "/jobs/1"},
+		{"/blamewarrior/missing/collaborators/sync", http.StatusAccepted, "/jobs/2"},
+		{"/blame_warrior/repos/collaborators/sync", http.StatusBadRequest, ""},
+	}
+
+	for _, result := range results {
+		req, err := http.NewRequest("POST", result.Path, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, result.ResponseCode, w.Code, result.Path)
+		assert.Equal(t, result.Location, w.Header().Get("Location"), result.Path)
+
+		if result.ResponseCode == http.StatusAccepted {
+			var job main.SyncJob
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+			assert.Equal(t, main.SyncJobQueued, job.Status)
+		}
+	}
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	for i := 0; i < 2; i++ {
+		ok, err := runner.RunNext(context.Background())
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	ok, err := runner.RunNext(context.Background())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	jobResults := []struct {
+		Path         string
+		ResponseCode int
+		Status       string
+		Added        int
+		Error        string
+	}{
+		{"/jobs/1", http.StatusOK, main.SyncJobSucceeded, 2, ""},
+		{"/jobs/2", http.StatusOK, main.SyncJobFailed, 0, github.ErrNoSuchRepository.Error()},
+		{"/jobs/3", http.StatusNotFound, "", 0, ""},
+		{"/jobs/abc", http.StatusBadRequest, "", 0, ""},
+	}
+
+	for _, result := range jobResults {
+		req, err := http.NewRequest("GET", result.Path, nil)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, result.ResponseCode, w.Code, result.Path)
+
+		if result.ResponseCode != http.StatusOK {
+			continue
+		}
+
+		var job main.SyncJob
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+
+		assert.Equal(t, result.Status, job.Status, result.Path)
+		assert.Equal(t, result.Added, job.Added, result.Path)
+		assert.Equal(t, result.Error, job.Error, result.Path)
+		assert.NotNil(t, job.StartedAt, result.Path)
+		assert.NotNil(t, job.FinishedAt, result.Path)
+	}
+}
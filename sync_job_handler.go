@@ -0,0 +1,64 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type SyncJobHandler struct {
+	hostname string
+	jobs     SyncJobQueue
+}
+
+func (h *SyncJobHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	id, err := strconv.ParseInt(req.URL.Query().Get(":id"), 10, 64)
+
+	if err != nil || id <= 0 {
+		http.Error(w, "Incorrect job id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Get(req.Context(), id)
+
+	switch err {
+	case nil:
+	case ErrNoSuchSyncJob:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("%s\t%s\t%v\t%s", req.Method, req.RequestURI, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func NewSyncJobHandler(hostname string, jobs SyncJobQueue) *SyncJobHandler {
+	return &SyncJobHandler{
+		hostname: hostname,
+		jobs:     jobs,
+	}
+}
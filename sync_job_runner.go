@@ -0,0 +1,111 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyncWorkers is the number of sync jobs an instance runs concurrently by default.
+	DefaultSyncWorkers = 2
+	// DefaultSyncJobPollInterval is the time an idle worker waits before checking the queue again.
+	DefaultSyncJobPollInterval = time.Second
+	// DefaultSyncJobLease is the time a job is allowed to run before it's considered
+	// abandoned and claimed by another worker.
+	DefaultSyncJobLease = 10 * time.Minute
+)
+
+// SyncJobRunner runs repository sync jobs claimed from a queue. Several runners, possibly
+// on different instances, can share the same queue.
+type SyncJobRunner struct {
+	Workers      int
+	PollInterval time.Duration
+	// Lease limits the time of a single sync, see SyncJobQueue.Claim.
+	Lease time.Duration
+
+	syncer *Syncer
+	jobs   SyncJobQueue
+}
+
+func NewSyncJobRunner(syncer *Syncer, jobs SyncJobQueue) *SyncJobRunner {
+	return &SyncJobRunner{
+		Workers:      DefaultSyncWorkers,
+		PollInterval: DefaultSyncJobPollInterval,
+		Lease:        DefaultSyncJobLease,
+		syncer:       syncer,
+		jobs:         jobs,
+	}
+}
+
+// Run starts workers and blocks until ctx is cancelled and running jobs are finished.
+func (r *SyncJobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < r.Workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (r *SyncJobRunner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ok, err := r.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("failed to run sync job: %s", err)
+		}
+
+		if ok && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RunNext claims a job and runs it. It returns false if there was no job to run.
+func (r *SyncJobRunner) RunNext(ctx context.Context) (bool, error) {
+	job, err := r.jobs.Claim(ctx, r.Lease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, r.Lease)
+	changes, syncErr := r.syncer.syncRepository(syncCtx, job.Repository, nil)
+	cancel()
+
+	if syncErr != nil {
+		log.Printf("failed to sync %s in job %d: %s", job.Repository, job.Id, syncErr)
+	}
+
+	// the outcome is recorded even if the runner is being stopped
+	dbCtx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	return true, r.jobs.Finish(dbCtx, job.Id, changes, syncErr)
+}
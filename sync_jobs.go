@@ -0,0 +1,242 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoSuchSyncJob is returned when a sync job with given id does not exist.
+var ErrNoSuchSyncJob = errors.New("no such sync job")
+
+// SyncJob is a repository sync requested via API and run in background by SyncJobRunner.
+// Added, Updated and Removed are the numbers of collaborators changed by a succeeded job.
+type SyncJob struct {
+	Id         int64      `json:"id"`
+	Repository string     `json:"repository"`
+	Status     string     `json:"status"`
+	Added      int        `json:"added"`
+	Updated    int        `json:"updated"`
+	Removed    int        `json:"removed"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// SyncJobQueue stores repository sync jobs. Implementations are safe for concurrent use
+// and hand each queued job to a single runner.
+type SyncJobQueue interface {
+	// Enqueue creates a queued job for a repository.
+	Enqueue(ctx context.Context, repository string) (*SyncJob, error)
+	// Claim marks the oldest queued job as running and returns it or nil if there is nothing
+	// to run. Jobs that have been running for longer than lease are considered abandoned by a
+	// stopped runner and are claimed again.
+	Claim(ctx context.Context, lease time.Duration) (*SyncJob, error)
+	// Finish records the outcome of a running job, the job fails if err is not nil.
+	Finish(ctx context.Context, id int64, changes *CollaboratorChanges, err error) error
+	// Get returns a job by its id or ErrNoSuchSyncJob.
+	Get(ctx context.Context, id int64) (*SyncJob, error)
+}
+
+// MemorySyncJobQueue is a SyncJobQueue that keeps jobs in memory, so they are only
+// run by this instance and are lost on exit.
+type MemorySyncJobQueue struct {
+	mu   sync.Mutex
+	jobs []*SyncJob
+	now  func() time.Time
+}
+
+func NewMemorySyncJobQueue() *MemorySyncJobQueue {
+	return &MemorySyncJobQueue{now: time.Now}
+}
+
+func (q *MemorySyncJobQueue) Enqueue(ctx context.Context, repository string) (*SyncJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := &SyncJob{
+		Id:         int64(len(q.jobs) + 1),
+		Repository: repository,
+		Status:     SyncJobQueued,
+		CreatedAt:  q.now(),
+	}
+	q.jobs = append(q.jobs, job)
+
+	return job.copy(), nil
+}
+
+func (q *MemorySyncJobQueue) Claim(ctx context.Context, lease time.Duration) (*SyncJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+
+	for _, job := range q.jobs {
+		if job.Status == SyncJobQueued || job.Status == SyncJobRunning && job.StartedAt.Before(now.Add(-lease)) {
+			job.Status, job.StartedAt = SyncJobRunning, &now
+
+			return job.copy(), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (q *MemorySyncJobQueue) Finish(ctx context.Context, id int64, changes *CollaboratorChanges, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if id < 1 || id > int64(len(q.jobs)) {
+		return ErrNoSuchSyncJob
+	}
+
+	job := q.jobs[id-1]
+	if job.Status != SyncJobRunning {
+		return nil
+	}
+
+	now := q.now()
+	job.Status, job.Added, job.Updated, job.Removed, job.Error = syncJobOutcome(changes, err)
+	job.FinishedAt = &now
+
+	return nil
+}
+
+func (q *MemorySyncJobQueue) Get(ctx context.Context, id int64) (*SyncJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if id < 1 || id > int64(len(q.jobs)) {
+		return nil, ErrNoSuchSyncJob
+	}
+
+	return q.jobs[id-1].copy(), nil
+}
+
+func (job *SyncJob) copy() *SyncJob {
+	c := *job
+
+	return &c
+}
+
+const (
+	syncJobColumns = `id, repository, status, added, updated, removed, error, created_at, started_at, finished_at`
+
+	enqueueSyncJobQuery = `
+		INSERT INTO sync_jobs (repository, status, created_at) VALUES ($1, 'queued', now())
+		RETURNING ` + syncJobColumns
+
+	// several instances claim jobs concurrently skipping the ones locked by each other,
+	// owner sync jobs share the table but are never claimed
+	claimSyncJobQuery = `
+		UPDATE sync_jobs SET status = 'running', started_at = now()
+		WHERE id = (
+			SELECT id FROM sync_jobs
+			WHERE (status = 'queued' OR (status = 'running' AND started_at < now() - make_interval(secs => $1)))
+				AND repository IS NOT NULL
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + syncJobColumns
+
+	finishSyncJobQuery = `
+		UPDATE sync_jobs SET status = $2, added = $3, updated = $4, removed = $5, error = $6, finished_at = now()
+		WHERE id = $1 AND status = 'running'
+	`
+
+	getSyncJobQuery = `SELECT ` + syncJobColumns + ` FROM sync_jobs WHERE id = $1 AND repository IS NOT NULL`
+)
+
+// PostgresSyncJobQueue is a SyncJobQueue that keeps jobs in sync_jobs table, so that
+// they are shared by all instances using the same database.
+type PostgresSyncJobQueue struct {
+	db *sql.DB
+}
+
+func NewPostgresSyncJobQueue(db *sql.DB) *PostgresSyncJobQueue {
+	return &PostgresSyncJobQueue{db}
+}
+
+func (q *PostgresSyncJobQueue) Enqueue(ctx context.Context, repository string) (*SyncJob, error) {
+	return scanSyncJob(q.db.QueryRowContext(ctx, enqueueSyncJobQuery, repository))
+}
+
+func (q *PostgresSyncJobQueue) Claim(ctx context.Context, lease time.Duration) (*SyncJob, error) {
+	job, err := scanSyncJob(q.db.QueryRowContext(ctx, claimSyncJobQuery, lease.Seconds()))
+	if err == ErrNoSuchSyncJob {
+		return nil, nil
+	}
+
+	return job, err
+}
+
+func (q *PostgresSyncJobQueue) Finish(ctx context.Context, id int64, changes *CollaboratorChanges, err error) error {
+	status, added, updated, removed, errMsg := syncJobOutcome(changes, err)
+
+	_, err = q.db.ExecContext(ctx, finishSyncJobQuery, id, status, added, updated, removed, errMsg)
+
+	return err
+}
+
+func (q *PostgresSyncJobQueue) Get(ctx context.Context, id int64) (*SyncJob, error) {
+	return scanSyncJob(q.db.QueryRowContext(ctx, getSyncJobQuery, id))
+}
+
+func scanSyncJob(row *sql.Row) (*SyncJob, error) {
+	job := &SyncJob{}
+
+	err := row.Scan(
+		&job.Id,
+		&job.Repository,
+		&job.Status,
+		&job.Added,
+		&job.Updated,
+		&job.Removed,
+		&job.Error,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSuchSyncJob
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// syncJobOutcome returns the status, diff counts and error message to record for a finished job.
+func syncJobOutcome(changes *CollaboratorChanges, err error) (status string, added, updated, removed int, errMsg string) {
+	if err != nil {
+		return SyncJobFailed, 0, 0, 0, err.Error()
+	}
+
+	if changes == nil {
+		return SyncJobSucceeded, 0, 0, 0, ""
+	}
+
+	return SyncJobSucceeded, len(changes.Added), len(changes.Updated), len(changes.Removed), ""
+}
@@ -0,0 +1,238 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestMemorySyncJobQueue(t *testing.T) {
+	testSyncJobQueue(t, main.NewMemorySyncJobQueue())
+}
+
+func TestPostgresSyncJobQueue(t *testing.T) {
+	db, teardown := setupTestDBConn()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE sync_jobs RESTART IDENTITY")
+	require.NoError(t, err)
+
+	testSyncJobQueue(t, main.NewPostgresSyncJobQueue(db))
+}
+
+func testSyncJobQueue(t *testing.T, q main.SyncJobQueue) {
+	ctx := context.Background()
+
+	job1, err := q.Enqueue(ctx, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Equal(t, "blamewarrior/repos", job1.Repository)
+	assert.Equal(t, main.SyncJobQueued, job1.Status)
+	assert.False(t, job1.CreatedAt.IsZero())
+	assert.Nil(t, job1.StartedAt)
+
+	job2, err := q.Enqueue(ctx, "blamewarrior/hooks")
+	require.NoError(t, err)
+
+	// jobs are claimed in order they have been queued
+	for _, expected := range []*main.SyncJob{job1, job2} {
+		job, err := q.Claim(ctx, time.Hour)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, expected.Id, job.Id)
+		assert.Equal(t, main.SyncJobRunning, job.Status)
+		assert.NotNil(t, job.StartedAt)
+	}
+
+	job, err := q.Claim(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	changes := &main.CollaboratorChanges{
+		Added:   make([]blamewarrior.Account, 2),
+		Removed: make([]blamewarrior.Account, 1),
+	}
+	require.NoError(t, q.Finish(ctx, job1.Id, changes, nil))
+
+	job, err = q.Get(ctx, job1.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobSucceeded, job.Status)
+	assert.Equal(t, 2, job.Added)
+	assert.Equal(t, 0, job.Updated)
+	assert.Equal(t, 1, job.Removed)
+	assert.Empty(t, job.Error)
+	assert.NotNil(t, job.FinishedAt)
+
+	// the second job is abandoned and claimed again once its lease is over
+	time.Sleep(10 * time.Millisecond)
+
+	job, err = q.Claim(ctx, time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, job2.Id, job.Id)
+
+	require.NoError(t, q.Finish(ctx, job2.Id, nil, errors.New("no such repository")))
+	require.NoError(t, q.Finish(ctx, job2.Id, changes, nil))
+
+	job, err = q.Get(ctx, job2.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobFailed, job.Status)
+	assert.Equal(t, 0, job.Added)
+	assert.Equal(t, "no such repository", job.Error)
+
+	_, err = q.Get(ctx, 100500)
+	assert.Equal(t, main.ErrNoSuchSyncJob, err)
+
+	// concurrent claims never return the same job
+	for i := 0; i < 10; i++ {
+		_, err := q.Enqueue(ctx, "blamewarrior/repos")
+		require.NoError(t, err)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		claimed = make(map[int64]int)
+	)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				job, err := q.Claim(ctx, time.Hour)
+				if !assert.NoError(t, err) || job == nil {
+					return
+				}
+
+				mu.Lock()
+				claimed[job.Id]++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, claimed, 10)
+	for id, n := range claimed {
+		assert.Equal(t, 1, n, "job %d has been claimed %d times", id, n)
+	}
+}
+
+func TestMemoryOwnerSyncJobs(t *testing.T) {
+	testOwnerSyncJobs(t, main.NewMemoryOwnerSyncJobs(), nil)
+}
+
+func TestPostgresOwnerSyncJobs(t *testing.T) {
+	db, teardown := setupTestDBConn()
+	defer teardown()
+
+	_, err := db.Exec("TRUNCATE sync_jobs RESTART IDENTITY")
+	require.NoError(t, err)
+
+	testOwnerSyncJobs(t, main.NewPostgresOwnerSyncJobs(db), main.NewPostgresSyncJobQueue(db))
+}
+
+// testOwnerSyncJobs runs common checks of owner sync jobs. If q is not nil, repositories synced
+// within an owner sync are expected to be available as its jobs.
+func testOwnerSyncJobs(t *testing.T, jobs main.OwnerSyncJobs, q main.SyncJobQueue) {
+	ctx := context.Background()
+
+	job, err := jobs.Create(ctx, "blamewarrior")
+	require.NoError(t, err)
+	assert.Equal(t, "blamewarrior", job.Owner)
+	assert.Equal(t, main.SyncJobRunning, job.Status)
+	assert.Empty(t, job.Results)
+	assert.False(t, job.StartedAt.IsZero())
+	assert.Nil(t, job.FinishedAt)
+
+	require.NoError(t, jobs.Start(ctx, job.Id, 2))
+
+	changes := &main.CollaboratorChanges{Added: make([]blamewarrior.Account, 2)}
+	require.NoError(t, jobs.Report(ctx, job.Id, "blamewarrior/repos", changes, nil))
+	require.NoError(t, jobs.Report(ctx, job.Id, "blamewarrior/hooks", nil, errors.New("no such repository")))
+
+	job, err = jobs.Get(ctx, job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobRunning, job.Status)
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 2, job.Done)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, []main.RepositorySyncResult{
+		{Repository: "blamewarrior/repos"},
+		{Repository: "blamewarrior/hooks", Error: "no such repository"},
+	}, job.Results)
+
+	require.NoError(t, jobs.Finish(ctx, job.Id, nil))
+	require.NoError(t, jobs.Finish(ctx, job.Id, errors.New("rate limit reached")))
+
+	job, err = jobs.Get(ctx, job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobFinished, job.Status)
+	assert.Empty(t, job.Error)
+	assert.NotNil(t, job.FinishedAt)
+
+	failed, err := jobs.Create(ctx, "octocat")
+	require.NoError(t, err)
+	require.NoError(t, jobs.Finish(ctx, failed.Id, errors.New("rate limit reached")))
+
+	failed, err = jobs.Get(ctx, failed.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobFailed, failed.Status)
+	assert.Equal(t, "rate limit reached", failed.Error)
+
+	_, err = jobs.Get(ctx, 100500)
+	assert.Equal(t, main.ErrNoSuchSyncJob, err)
+	assert.Equal(t, main.ErrNoSuchSyncJob, jobs.Report(ctx, 100500, "blamewarrior/repos", nil, nil))
+
+	if q == nil {
+		return
+	}
+
+	// owner sync jobs are neither repository jobs nor claimed as such
+	_, err = q.Get(ctx, job.Id)
+	assert.Equal(t, main.ErrNoSuchSyncJob, err)
+
+	repoJob, err := q.Get(ctx, job.Id+1)
+	require.NoError(t, err)
+	assert.Equal(t, "blamewarrior/repos", repoJob.Repository)
+	assert.Equal(t, main.SyncJobSucceeded, repoJob.Status)
+	assert.Equal(t, 2, repoJob.Added)
+
+	running, err := jobs.Create(ctx, "hubot")
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	claimed, err := q.Claim(ctx, time.Millisecond)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	require.NoError(t, jobs.Finish(ctx, running.Id, nil))
+}
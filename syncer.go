@@ -0,0 +1,533 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+)
+
+// DefaultSyncConcurrency is the number of repositories synchronized in parallel
+// during an owner sync.
+const DefaultSyncConcurrency = 4
+
+// RepositorySyncRequests is the number of GitHub API requests reserved from the owner rate limit
+// before a repository is synchronized by SyncOwner. It's only a lower bound, since the number of
+// pages of collaborators, teams and their members is not known in advance, so requests a sync
+// makes on top of it are reserved one at a time as they are made.
+const RepositorySyncRequests = 6
+
+const (
+	// DefaultProfileTTL is the time GitHub profiles of collaborators are considered up to date.
+	DefaultProfileTTL = 24 * time.Hour
+	// DefaultProfileRefreshLimit is the number of outdated collaborator profiles refreshed
+	// by a repository sync.
+	DefaultProfileRefreshLimit = 10
+)
+
+// Syncer synchronizes collaborators and teams of BlameWarrior repositories with GitHub
+// and other providers.
+type Syncer struct {
+	db            *sql.DB
+	collaboration blamewarrior.Collaboration
+	githubClient  *github.Client
+
+	// Providers maps lowercase host names to providers that repositories hosted there
+	// are synchronized with instead of GitHub.
+	Providers map[string]blamewarrior.Provider
+
+	// Concurrency limits the number of repositories synchronized at once by SyncOwner.
+	Concurrency   int
+	GithubBaseURL *url.URL
+	// RateLimitPolicy tells whether to wait for GitHub rate limit reset or to fail
+	// once the owner token runs out of requests.
+	RateLimitPolicy github.RateLimitPolicy
+	// ProfileTTL is the time after which collaborator profiles are requested from GitHub again.
+	ProfileTTL time.Duration
+	// ProfileRefreshLimit limits the number of profiles refreshed by a repository sync,
+	// zero disables refreshing.
+	ProfileRefreshLimit int
+	// WaitForLock makes a sync of a repository that is being synchronized elsewhere wait for
+	// the other sync to finish instead of failing with blamewarrior.ErrRepositoryLocked.
+	WaitForLock bool
+}
+
+func NewSyncer(db *sql.DB, collaboration blamewarrior.Collaboration, githubClient *github.Client) *Syncer {
+	return &Syncer{
+		db:            db,
+		collaboration: collaboration,
+		githubClient:  githubClient,
+		Concurrency:   DefaultSyncConcurrency,
+
+		ProfileTTL:          DefaultProfileTTL,
+		ProfileRefreshLimit: DefaultProfileRefreshLimit,
+	}
+}
+
+func (s *Syncer) githubContext(ctx context.Context) github.Context {
+	return github.Context{Context: ctx, BaseURL: s.GithubBaseURL, RateLimitPolicy: s.RateLimitPolicy}
+}
+
+// SyncRepository registers a repository if it's not known yet and replaces its
+// collaborators, teams and pending invitations with the ones currently set on GitHub
+// or another provider the repository host is mapped to. Outdated GitHub profiles of
+// collaborators are refreshed afterwards if there are GitHub API requests to spare.
+// The repository is locked for the time of the sync, so that concurrent syncs of the
+// same repository, possibly run by other instances, fail or wait depending on WaitForLock.
+func (s *Syncer) SyncRepository(ctx context.Context, fullName string) error {
+	_, err := s.syncRepository(ctx, fullName, nil)
+
+	return err
+}
+
+// syncRepository does the same as SyncRepository returning the changes it has made
+// to repository collaborators. GitHub API requests are taken from reservation unless it's nil.
+func (s *Syncer) syncRepository(ctx context.Context, fullName string, reservation *github.Reservation) (*CollaboratorChanges, error) {
+	fullName = s.canonicalName(fullName)
+
+	unlock, err := s.collaboration.LockRepository(ctx, s.db, fullName, s.WaitForLock)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// deferred, so that the lock is released if the sync fails or panics
+	defer unlock()
+
+	provider := s.provider(fullName, reservation)
+
+	collaborators, err := provider.RepositoryMembers(ctx, fullName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		teams       []blamewarrior.Team
+		invitations []blamewarrior.Invitation
+		teamMembers = make(map[string][]blamewarrior.Account)
+	)
+
+	// teams and invitations are only known to GitHub
+	if gh, ok := provider.(*github.Provider); ok {
+		ghCtx := gh.Context(ctx)
+
+		teams, err = s.githubClient.RepositoryTeams(ghCtx, fullName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		invitations, err = s.githubClient.RepositoryInvitations(ghCtx, fullName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		owner, _ := github.SplitRepositoryName(fullName)
+
+		for _, team := range teams {
+			members, err := s.githubClient.TeamMembers(ghCtx, owner, team.Uid)
+
+			if err != nil {
+				return nil, err
+			}
+
+			teamMembers[team.Slug] = members
+		}
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(dbCtx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer blamewarrior.Rollback(s.collaboration, tx)
+
+	if err := s.collaboration.CreateRepository(dbCtx, tx, fullName); err != nil {
+		return nil, err
+	}
+
+	if err := s.collaboration.SetRepositoryProvider(dbCtx, tx, fullName, provider.Name()); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.collaboration.ListAccounts(dbCtx, tx, fullName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.collaboration.ResetRepository(dbCtx, tx, fullName); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, collaborator := range collaborators {
+
+		account := &blamewarrior.Account{
+			Uid:         collaborator.Uid,
+			Login:       collaborator.Login,
+			Permissions: collaborator.Permissions,
+			Affiliation: collaborator.Affiliation,
+		}
+
+		_, err := s.collaboration.AddAccount(dbCtx, tx, fullName, account)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// providers that list members along with their profiles save refreshing them
+		if collaborator.AccountProfile != (blamewarrior.AccountProfile{}) {
+			if err := s.collaboration.UpdateProfile(dbCtx, tx, collaborator.Login, &collaborator.AccountProfile, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range teams {
+		if _, err := s.collaboration.AddTeam(dbCtx, tx, fullName, &teams[i]); err != nil {
+			return nil, err
+		}
+
+		for _, member := range teamMembers[teams[i].Slug] {
+			account := &blamewarrior.Account{
+				Uid:   member.Uid,
+				Login: member.Login,
+			}
+
+			if err := s.collaboration.AddTeamMember(dbCtx, tx, fullName, teams[i].Slug, account); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range invitations {
+		if _, err := s.collaboration.AddInvitation(dbCtx, tx, fullName, &invitations[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := blamewarrior.Commit(s.collaboration, tx); err != nil {
+		return nil, err
+	}
+
+	if gh, ok := provider.(*github.Provider); ok {
+		// profiles are not essential, so failing to refresh them does not fail the sync
+		if err := s.refreshProfiles(gh.Context(ctx), fullName); err != nil {
+			log.Printf("failed to refresh profiles of %s collaborators: %s", fullName, err)
+		}
+	}
+
+	return diffCollaborators(stored, collaborators), nil
+}
+
+// canonicalName returns the name an owner or a repository is stored under, names of owners
+// assigned to GitHub Enterprise Server hosts are qualified with the host name.
+func (s *Syncer) canonicalName(name string) string {
+	return s.githubClient.CanonicalName(name)
+}
+
+// provider returns the provider the repository host is mapped to in Providers,
+// GitHub is used for the rest of hosts with requests taken from reservation.
+func (s *Syncer) provider(fullName string, reservation *github.Reservation) blamewarrior.Provider {
+	host, _ := github.SplitHost(fullName)
+	if provider, ok := s.Providers[host]; ok {
+		return provider
+	}
+
+	return &github.Provider{
+		Client:          s.githubClient,
+		BaseURL:         s.GithubBaseURL,
+		RateLimitPolicy: s.RateLimitPolicy,
+		Reservation:     reservation,
+	}
+}
+
+// refreshProfiles requests outdated profiles of repository collaborators from GitHub. Requests
+// are reserved from the owner rate limit up front and profiles are left as is if there are not
+// enough of them, so that refreshing never waits for the limit reset or takes requests from syncs.
+func (s *Syncer) refreshProfiles(ctx github.Context, fullName string) error {
+	if s.ProfileRefreshLimit <= 0 {
+		return nil
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	logins, err := s.collaboration.ListOutdatedProfiles(dbCtx, s.db, fullName, time.Now().Add(-s.ProfileTTL), s.ProfileRefreshLimit)
+	cancel()
+
+	if err != nil || len(logins) == 0 {
+		return err
+	}
+
+	owner, _ := github.SplitRepositoryName(fullName)
+
+	reservation, err := s.githubClient.RateLimits.Reserve(ctx, owner, len(logins), github.FailFast)
+	if err == github.ErrRateLimitReached {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer reservation.Release()
+
+	ctx.RateLimitPolicy, ctx.Reservation = github.FailFast, reservation
+
+	for _, login := range logins {
+		profile, err := s.githubClient.UserProfile(ctx, owner, login)
+
+		switch err {
+		case nil:
+		case github.ErrNoSuchOwner:
+			// the account has been deleted and will be removed by the next sync
+			continue
+		case github.ErrRateLimitReached:
+			return nil
+		default:
+			return err
+		}
+
+		dbCtx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+		err = s.collaboration.UpdateProfile(dbCtx, s.db, login, profile, time.Now())
+		cancel()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CollaboratorChanges lists differences between collaborators of a repository stored
+// in the database and the ones currently set on GitHub or another provider.
+type CollaboratorChanges struct {
+	Added   []blamewarrior.Account `json:"added"`
+	Updated []blamewarrior.Account `json:"updated"`
+	Removed []blamewarrior.Account `json:"removed"`
+}
+
+// Empty returns true if stored collaborators are up to date.
+func (c *CollaboratorChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// DiffRepository fetches repository collaborators from the provider and compares them with the
+// stored ones without modifying the database. Accounts in Updated hold provider values.
+func (s *Syncer) DiffRepository(ctx context.Context, fullName string) (*CollaboratorChanges, error) {
+	fullName = s.canonicalName(fullName)
+
+	collaborators, err := s.provider(fullName, nil).RepositoryMembers(ctx, fullName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DatabaseOperationTimeout)
+	defer cancel()
+
+	stored, err := s.collaboration.ListAccounts(ctx, s.db, fullName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return diffCollaborators(stored, collaborators), nil
+}
+
+// diffCollaborators compares two lists of collaborators. Accounts are matched by login
+// regardless of its case and considered updated if their uid, permissions or affiliation differ.
+func diffCollaborators(stored, current []blamewarrior.Account) *CollaboratorChanges {
+	existing := make(map[string]blamewarrior.Account, len(stored))
+	for _, account := range stored {
+		existing[strings.ToLower(account.Login)] = account
+	}
+
+	changes := &CollaboratorChanges{
+		Added:   []blamewarrior.Account{},
+		Updated: []blamewarrior.Account{},
+		Removed: []blamewarrior.Account{},
+	}
+
+	for _, collaborator := range current {
+		key := strings.ToLower(collaborator.Login)
+
+		account, ok := existing[key]
+		delete(existing, key)
+
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, collaborator)
+		case account.Uid != collaborator.Uid,
+			account.Permissions != collaborator.Permissions,
+			affiliationOf(account) != affiliationOf(collaborator):
+			changes.Updated = append(changes.Updated, collaborator)
+		}
+	}
+
+	for _, account := range stored {
+		if _, ok := existing[strings.ToLower(account.Login)]; ok {
+			changes.Removed = append(changes.Removed, account)
+		}
+	}
+
+	return changes
+}
+
+func affiliationOf(account blamewarrior.Account) string {
+	if account.Affiliation == "" {
+		return blamewarrior.AffiliationDirect
+	}
+
+	return account.Affiliation
+}
+
+// SyncOwner discovers all GitHub repositories of an owner and synchronizes them
+// concurrently, recording progress of the job in jobs. All workers share the GitHub rate
+// limit and the token of the owner, so once the limit is exhausted or the token
+// cannot be obtained remaining repositories are marked as failed without issuing
+// any further requests.
+func (s *Syncer) SyncOwner(ctx context.Context, jobs OwnerSyncJobs, job *OwnerSyncJob) error {
+	if host, _ := github.SplitHost(job.Owner); s.Providers[host] != nil {
+		err := fmt.Errorf("owner syncs are not supported by %s", s.Providers[host].Name())
+		recordOwnerSync(job, func(ctx context.Context) error { return jobs.Finish(ctx, job.Id, err) })
+		return err
+	}
+
+	repositories, err := s.githubClient.OwnerRepositories(s.githubContext(ctx), job.Owner)
+	if err != nil {
+		recordOwnerSync(job, func(ctx context.Context) error { return jobs.Finish(ctx, job.Id, err) })
+		return err
+	}
+
+	recordOwnerSync(job, func(ctx context.Context) error { return jobs.Start(ctx, job.Id, len(repositories)) })
+
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+
+	var (
+		wg      sync.WaitGroup
+		queue   = make(chan string)
+		budget  = &rateLimitBudget{}
+		workers = concurrency
+	)
+
+	if len(repositories) < workers {
+		workers = len(repositories)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for fullName := range queue {
+				var changes *CollaboratorChanges
+
+				err := budget.exhausted()
+				if err == nil {
+					var reservation *github.Reservation
+
+					reservation, err = s.githubClient.RateLimits.Reserve(ctx, job.Owner, RepositorySyncRequests, s.RateLimitPolicy)
+					if err == nil {
+						changes, err = s.syncRepository(ctx, fullName, reservation)
+						reservation.Release()
+					}
+
+					if isOwnerWideError(err) {
+						budget.exhaust(err)
+					}
+				}
+
+				recordOwnerSync(job, func(ctx context.Context) error {
+					return jobs.Report(ctx, job.Id, fullName, changes, err)
+				})
+			}
+		}()
+	}
+
+	for _, fullName := range repositories {
+		queue <- fullName
+	}
+	close(queue)
+
+	wg.Wait()
+	recordOwnerSync(job, func(ctx context.Context) error { return jobs.Finish(ctx, job.Id, nil) })
+
+	return nil
+}
+
+// recordOwnerSync records progress of an owner sync job with a context of its own, so that
+// it's recorded even if the sync has been cancelled. Failures are only logged, since they
+// do not affect the sync itself.
+func recordOwnerSync(job *OwnerSyncJob, record func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseOperationTimeout)
+	defer cancel()
+
+	if err := record(ctx); err != nil {
+		log.Printf("failed to record progress of owner sync job %d: %s", job.Id, err)
+	}
+}
+
+// isOwnerWideError reports whether err affects all repositories of the owner,
+// so that there is no point to sync the rest of them.
+func isOwnerWideError(err error) bool {
+	switch err {
+	case github.ErrRateLimitReached, tokens.ErrUserNotFound, tokens.ErrTokenRevoked, tokens.ErrServiceUnavailable:
+		return true
+	}
+
+	return false
+}
+
+// rateLimitBudget is shared between owner sync workers to stop issuing requests
+// as soon as one of them hits GitHub rate limit or fails to get owner token.
+type rateLimitBudget struct {
+	mu  sync.Mutex
+	err error
+}
+
+// exhausted returns the error that exhausted the budget or nil if there was none.
+func (b *rateLimitBudget) exhausted() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err
+}
+
+func (b *rateLimitBudget) exhaust(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
@@ -0,0 +1,490 @@
+/*
+   Copyright (C) 2018 The BlameWarrior Authors.
+   This file is a part of BlameWarrior service.
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blamewarrior/collaborators/blamewarrior"
+	"github.com/blamewarrior/collaborators/blamewarrior/tokens"
+	"github.com/blamewarrior/collaborators/github"
+	"github.com/blamewarrior/collaborators/github/githubtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	main "github.com/blamewarrior/collaborators"
+)
+
+func TestSyncer_SyncRepository_Resync(t *testing.T) {
+	db, teardownDB := setupTestDBConn()
+	defer teardownDB()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	collaborators := `[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}},{"login":"user2", "id": 2, "permissions": {"pull": true,"push": false,"admin": false}}]`
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_resync/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(collaborators))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_resync/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/test_resync", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "blamewarrior/test_resync"}`))
+	})
+
+	mux.HandleFunc("/repositories/1/invitations", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	collaboration := blamewarrior.NewCollaborationService()
+
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())))
+	syncer.GithubBaseURL = testAPIEndpoint
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/test_resync"))
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/test_resync")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	collaborators = `[{"login":"user2", "id": 2, "permissions": {"pull": true,"push": true,"admin": false}}]`
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/test_resync"))
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/test_resync")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "user2", accounts[0].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true, Push: true}, accounts[0].Permissions)
+}
+
+func TestSyncer_SyncOwner(t *testing.T) {
+	db, teardownDB := setupTestDBConn()
+	defer teardownDB()
+
+	_, err := db.Exec("TRUNCATE repositories, collaboration, accounts, teams, team_members, invitations")
+	require.NoError(t, err)
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Write([]byte(`{"token": "test_token"}`))
+			return
+		}
+
+		w.Write([]byte(`{"login": "blamewarrior", "id": 1, "type": "Organization"}`))
+	})
+
+	mux.HandleFunc("/orgs/blamewarrior/repos", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"id": 1, "full_name": "blamewarrior/repo1"},{"id": 2, "full_name": "blamewarrior/repo2"},{"id": 3, "full_name": "blamewarrior/repo3"}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repo1/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true,"push": true,"admin": false}}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repo1/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repo1", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "blamewarrior/repo1"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repo2/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "1")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+		http.Error(w, `{"message":"API rate limit exceeded for 127.0.0.1"}`, http.StatusForbidden)
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repo3/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		t.Error("no requests are expected once the rate limit is reached")
+	})
+
+	mux.HandleFunc("/repositories/1/invitations", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	collaboration := blamewarrior.NewCollaborationService()
+
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())))
+	syncer.GithubBaseURL = testAPIEndpoint
+	syncer.Concurrency = 1
+
+	// repositories are synced within the owner sync job stored in the database
+	jobs := main.NewPostgresOwnerSyncJobs(db)
+
+	job, err := jobs.Create(context.Background(), "blamewarrior")
+	require.NoError(t, err)
+	require.NoError(t, syncer.SyncOwner(context.Background(), jobs, job))
+
+	job, err = jobs.Get(context.Background(), job.Id)
+	require.NoError(t, err)
+
+	assert.Equal(t, main.SyncJobFinished, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, 3, job.Done)
+	assert.Equal(t, 2, job.Failed)
+
+	assert.Equal(t, []main.RepositorySyncResult{
+		{Repository: "blamewarrior/repo1"},
+		{Repository: "blamewarrior/repo2", Error: github.ErrRateLimitReached.Error()},
+		{Repository: "blamewarrior/repo3", Error: github.ErrRateLimitReached.Error()},
+	}, job.Results)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repo1")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 1)
+}
+
+func TestSyncer_SyncRepository_Profiles(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	testAPIEndpoint, mux, teardownAPIServer := setupAPIServer()
+	defer teardownAPIServer()
+
+	// requests left once the repository is synced
+	remaining := 100
+	profileRequests := make(map[string]int)
+
+	mux.HandleFunc("/users/blamewarrior", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token": "test_token"}`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/collaborators", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"login":"user1", "id": 1, "permissions": {"pull": true}},{"login":"user2", "id": 2, "permissions": {"pull": true}}]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos/teams", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	mux.HandleFunc("/repos/blamewarrior/repos", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "blamewarrior/repos"}`))
+	})
+
+	mux.HandleFunc("/repositories/1/invitations", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write([]byte(`[]`))
+	})
+
+	for i, login := range []string{"user1", "user2"} {
+		uid, login := i+1, login
+
+		mux.HandleFunc("/users/"+login, func(w http.ResponseWriter, req *http.Request) {
+			profileRequests[login]++
+			fmt.Fprintf(w, `{"login": %q, "id": %d, "name": "User %d", "avatar_url": "https://avatars.githubusercontent.com/u/%d", "type": "User", "site_admin": false}`, login, uid, uid, uid)
+		})
+	}
+
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(tokens.NewTokenClient(testAPIEndpoint.String())))
+	syncer.GithubBaseURL = testAPIEndpoint
+	syncer.ProfileRefreshLimit = 1
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, map[string]int{"user1": 1}, profileRequests)
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, map[string]int{"user1": 1, "user2": 1}, profileRequests)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, blamewarrior.AccountProfile{Name: "User 1", AvatarURL: "https://avatars.githubusercontent.com/u/1", Type: "User"}, accounts[0].AccountProfile)
+	assert.Equal(t, blamewarrior.AccountProfile{Name: "User 2", AvatarURL: "https://avatars.githubusercontent.com/u/2", Type: "User"}, accounts[1].AccountProfile)
+
+	// profiles are up to date
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, map[string]int{"user1": 1, "user2": 1}, profileRequests)
+
+	// outdated profiles are not refreshed if there are not enough requests left
+	syncer.ProfileTTL, syncer.ProfileRefreshLimit, remaining = 0, 2, 1
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, map[string]int{"user1": 1, "user2": 1}, profileRequests)
+
+	remaining = 2
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, map[string]int{"user1": 2, "user2": 2}, profileRequests)
+}
+
+type staticProvider struct {
+	name    string
+	members []blamewarrior.Account
+}
+
+func (p *staticProvider) Name() string {
+	return p.name
+}
+
+func (p *staticProvider) RepositoryMembers(ctx context.Context, fullName string) ([]blamewarrior.Account, error) {
+	return p.members, nil
+}
+
+func TestSyncer_SyncRepository_Providers(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	gitlab := &staticProvider{
+		name: blamewarrior.ProviderGitLab,
+		members: []blamewarrior.Account{
+			{
+				Uid:            1,
+				Login:          "user1",
+				Permissions:    blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true},
+				Affiliation:    blamewarrior.AffiliationDirect,
+				AccountProfile: blamewarrior.AccountProfile{Name: "User 1", Type: "User"},
+			},
+			{
+				Uid:         2,
+				Login:       "user2",
+				Permissions: blamewarrior.AccountPermissions{Pull: true},
+				Affiliation: blamewarrior.AffiliationMember,
+			},
+		},
+	}
+
+	// GitHub API is not expected to be called
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(nil))
+	syncer.Providers = map[string]blamewarrior.Provider{"gitlab.com": gitlab}
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "GitLab.com/blamewarrior/repos"))
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "gitlab.com/blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	assert.Equal(t, "user1", accounts[0].Login)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true, Triage: true, Push: true}, accounts[0].Permissions)
+	assert.Equal(t, blamewarrior.AccountProfile{Name: "User 1", Type: "User"}, accounts[0].AccountProfile)
+	assert.Equal(t, "user2", accounts[1].Login)
+	assert.Equal(t, blamewarrior.AffiliationMember, accounts[1].Affiliation)
+
+	changes, err := syncer.DiffRepository(context.Background(), "gitlab.com/blamewarrior/repos")
+	require.NoError(t, err)
+	assert.True(t, changes.Empty())
+
+	// owner syncs are only supported by GitHub
+	jobs := main.NewMemoryOwnerSyncJobs()
+
+	job, err := jobs.Create(context.Background(), "gitlab.com/blamewarrior")
+	require.NoError(t, err)
+	assert.EqualError(t, syncer.SyncOwner(context.Background(), jobs, job), "owner syncs are not supported by gitlab")
+
+	job, err = jobs.Get(context.Background(), job.Id)
+	require.NoError(t, err)
+	assert.Equal(t, main.SyncJobFailed, job.Status)
+
+	// the repository is not taken over by another provider
+	syncer.Providers["gitlab.com"] = &staticProvider{name: blamewarrior.ProviderGitHub}
+
+	err = syncer.SyncRepository(context.Background(), "gitlab.com/blamewarrior/repos")
+	assert.Equal(t, blamewarrior.ErrProviderMismatch, err)
+}
+
+func TestSyncer_SyncRepository_FakeGitHub(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	user1 := srv.AddUser(blamewarrior.Account{Login: "user1"})
+
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Pull: true, Push: true}})
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{
+		Login:       "user2",
+		Permissions: blamewarrior.AccountPermissions{Pull: true},
+		Affiliation: blamewarrior.AffiliationOutside,
+	})
+	srv.AddTeam("blamewarrior/repos", blamewarrior.Team{Name: "Developers", Slug: "developers", Permission: "push"}, user1)
+	srv.AddInvitation("blamewarrior/repos", blamewarrior.Invitation{InviteeLogin: "user3", InviterLogin: "user1", Permission: "read"})
+
+	githubClient := github.NewClient(srv)
+	githubClient.RetryDelay = time.Millisecond
+
+	syncer := main.NewSyncer(db, collaboration, githubClient)
+	syncer.GithubBaseURL = srv.URL
+	syncer.ProfileRefreshLimit = 0
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "user1", accounts[0].Login)
+	assert.Equal(t, user1.Uid, accounts[0].Uid)
+	assert.Equal(t, []string{"developers"}, accounts[0].Teams)
+	assert.Equal(t, "user2", accounts[1].Login)
+	assert.Equal(t, blamewarrior.AffiliationOutside, accounts[1].Affiliation)
+
+	teams, err := collaboration.ListTeams(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, teams, 1)
+	assert.Equal(t, "developers", teams[0].Slug)
+
+	invitations, err := collaboration.ListInvitations(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+	assert.Equal(t, "user3", invitations[0].InviteeLogin)
+
+	// stored collaborators are kept if the sync fails half way
+	srv.RemoveCollaborator("blamewarrior/repos", "user2")
+	srv.FailRequests("/repos/blamewarrior/repos/teams", http.StatusBadGateway, -1)
+
+	err = syncer.SyncRepository(context.Background(), "blamewarrior/repos")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/repos/blamewarrior/repos/teams?per_page=100 (page 1) failed after 4 attempts: 502 Bad Gateway")
+	}
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	// a single failure is retried
+	srv.FailRequests("/repos/blamewarrior/repos/teams", http.StatusBadGateway, 1)
+
+	require.NoError(t, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+	assert.Equal(t, int64(1), githubClient.RetryStats().Recovered)
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "user1", accounts[0].Login)
+}
+
+func TestSyncer_SyncRepository_FakeGitHubPermissions(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+	srv.AddCollaborator("blamewarrior/hooks", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Admin: true}})
+
+	syncer := main.NewSyncer(db, collaboration, github.NewClient(srv))
+	syncer.GithubBaseURL = srv.URL
+	syncer.ProfileRefreshLimit = 0
+
+	for _, fullName := range []string{"blamewarrior/repos", "blamewarrior/hooks", "blamewarrior/repos"} {
+		require.NoError(t, syncer.SyncRepository(context.Background(), fullName))
+	}
+
+	// a sync of one repository does not change permissions granted in another one
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Pull: true}, accounts[0].Permissions)
+
+	accounts, err = collaboration.ListAccounts(context.Background(), db, "blamewarrior/hooks")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, blamewarrior.AccountPermissions{Admin: true}, accounts[0].Permissions)
+}
+
+func TestSyncer_SyncRepository_Locked(t *testing.T) {
+	db, collaboration := blamewarrior.OpenMemoryDatabase(), blamewarrior.NewMemoryCollaborationService()
+	defer db.Close()
+
+	srv := githubtest.NewServer()
+	defer srv.Close()
+
+	srv.AddOrganization("blamewarrior")
+	srv.AddCollaborator("blamewarrior/repos", blamewarrior.Account{Login: "user1", Permissions: blamewarrior.AccountPermissions{Pull: true}})
+
+	githubClient := github.NewClient(srv)
+
+	syncer := main.NewSyncer(db, collaboration, githubClient)
+	syncer.GithubBaseURL = srv.URL
+	syncer.ProfileRefreshLimit = 0
+
+	unlock, err := collaboration.LockRepository(context.Background(), db, "blamewarrior/repos", false)
+	require.NoError(t, err)
+
+	// a concurrent sync is rejected before sending any requests to GitHub
+	assert.Equal(t, blamewarrior.ErrRepositoryLocked, syncer.SyncRepository(context.Background(), "BlameWarrior/Repos"))
+	assert.Equal(t, 0, srv.Requests("/repos/blamewarrior/repos/collaborators"))
+
+	fetchCollaborators := main.NewFetchCollaboratorsHandler("blamewarrior.com", db, collaboration, githubClient)
+	fetchCollaborators.GithubBaseURL = srv.URL
+
+	req, err := http.NewRequest("GET", "/blamewarrior/repos/collaborators/fetch?:username=blamewarrior&:repo=repos", nil)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	fetchCollaborators.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "Sync of blamewarrior/repos is already in progress\n", w.Body.String())
+
+	// a waiting sync runs once the lock is released
+	syncer.WaitForLock = true
+
+	synced := make(chan error, 1)
+	go func() {
+		synced <- syncer.SyncRepository(context.Background(), "blamewarrior/repos")
+	}()
+
+	select {
+	case err := <-synced:
+		t.Fatalf("sync has not waited for the lock, err = %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	require.NoError(t, <-synced)
+
+	accounts, err := collaboration.ListAccounts(context.Background(), db, "blamewarrior/repos")
+	require.NoError(t, err)
+	assert.Len(t, accounts, 1)
+
+	// the lock is released when a sync fails
+	srv.FailRequests("/repos/blamewarrior/repos/teams", http.StatusNotFound, -1)
+
+	assert.Equal(t, github.ErrNoSuchRepository, syncer.SyncRepository(context.Background(), "blamewarrior/repos"))
+
+	unlock, err = collaboration.LockRepository(context.Background(), db, "blamewarrior/repos", false)
+	require.NoError(t, err)
+	unlock()
+}